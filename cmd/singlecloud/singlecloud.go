@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/x509"
 	"gopkg.in/yaml.v2"
@@ -16,13 +22,17 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/authorization"
 	"github.com/zdnscloud/singlecloud/pkg/clusteragent"
 	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/eventbus"
 	"github.com/zdnscloud/singlecloud/pkg/globaldns"
 	"github.com/zdnscloud/singlecloud/pkg/handler"
 	"github.com/zdnscloud/singlecloud/pkg/k8seventwatcher"
 	"github.com/zdnscloud/singlecloud/pkg/k8sshell"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/server"
 )
 
+var logger = logging.GetLogger("singlecloud").Std()
+
 const (
 	defaultTlsCertFile = "/tmp_tls_cert.crt"
 	defaultTlsKeyFile  = "/tmp_tls_key.key"
@@ -34,15 +44,31 @@ var (
 	showVersion bool
 	genConfFile bool
 	build       string
+
+	migrateDryRun bool
+	checkConfig   bool
+
+	snapshotFile       string
+	snapshotPassphrase string
+)
+
+const (
+	snapshotPassphraseEnv = "SINGLECLOUD_SNAPSHOT_PASSPHRASE"
 )
 
 func main() {
 	flag.StringVar(&configFile, "c", "singlecloud.conf", "configure file path")
 	flag.BoolVar(&genConfFile, "gen", false, "generate initial configure file to current directory")
 	flag.BoolVar(&showVersion, "version", false, "show version")
+	flag.BoolVar(&migrateDryRun, "migrate-dry-run", false, "show pending db migrations without applying them")
+	flag.BoolVar(&checkConfig, "check-config", false, "check configure file and report all the errors")
+	flag.StringVar(&snapshotFile, "f", "singlecloud.snapshot", "snapshot file for backup and restore command")
+	flag.StringVar(&snapshotPassphrase, "passphrase", "", "passphrase to encrypt or decrypt snapshot, default read from env "+snapshotPassphraseEnv)
 	flag.Parse()
 
-	log.InitLogger(log.Debug)
+	//cement log is only used by libraries, it's reinitialized with
+	//level in config once config is loaded
+	log.InitLogger(log.Info)
 
 	if showVersion {
 		fmt.Printf("singlecloud %s (build at %s)\n", version, build)
@@ -51,97 +77,266 @@ func main() {
 
 	if genConfFile {
 		if err := genInitConfig(); err != nil {
-			log.Fatalf("generate initial configure file failed:%s", err.Error())
+			logger.Fatalf("generate initial configure file failed:%s", err.Error())
+		}
+		return
+	}
+
+	if checkConfig {
+		if err := config.CheckConfig(configFile); err != nil {
+			for _, e := range err.(config.ConfigErrors) {
+				fmt.Fprintf(os.Stderr, "%s\n", e.Error())
+			}
+			os.Exit(1)
 		}
+		fmt.Printf("configure file %s is valid\n", configFile)
 		return
 	}
 
 	conf, err := config.LoadConfig(configFile)
 	if err != nil {
-		log.Fatalf("load configure file failed:%s", err.Error())
+		logger.Fatalf("load configure file failed:%s", err.Error())
 	}
+	level, _ := logging.ParseLevel(conf.Server.LogLevel)
+	log.InitLogger(log.LogLevel(level.String()))
 
-	if conf.DB.Role == config.Master {
+	if cmd := flag.Arg(0); cmd != "" {
+		if err := runCommand(conf, cmd); err != nil {
+			logger.Fatalf("%s failed:%s", cmd, err.Error())
+		}
+		return
+	}
+
+	//check before db server starts, so the db file isn't changed
+	if migrateDryRun {
+		if err := db.CheckMigrations(conf); err != nil {
+			logger.Fatalf("check db migration failed: %s", err.Error())
+		}
+		return
+	}
+
+	if conf.DB.Failover {
+		runWithFailover(conf)
+	} else if conf.DB.Role == config.Master {
 		runAsMaster(conf)
 	} else {
 		runAsSlave(conf)
 	}
 }
 
+// backup, restore and rotate-key work on db file, singlecloud should be stopped,
+// use admin api for online backup, witness runs db server on a third host
+func runCommand(conf *config.SinglecloudConf, cmd string) error {
+	if snapshotPassphrase == "" {
+		snapshotPassphrase = os.Getenv(snapshotPassphraseEnv)
+	}
+
+	switch cmd {
+	case "backup":
+		if err := db.BackupFile(conf, snapshotFile, snapshotPassphrase); err != nil {
+			return err
+		}
+		logger.Infof("backup db to %s", snapshotFile)
+	case "restore":
+		if err := db.RestoreFile(conf, snapshotFile, snapshotPassphrase); err != nil {
+			return err
+		}
+		logger.Infof("restore db from %s", snapshotFile)
+	case "rotate-key":
+		count, err := db.RotateEncryptionKeyFile(conf)
+		if err != nil {
+			return err
+		}
+		logger.Infof("rewrap %d records with primary encryption key", count)
+	case "witness":
+		//witness is a plain db server holding master lease for failover
+		db.RunAsSlave(conf)
+	default:
+		return fmt.Errorf("unknown command, only backup, restore, rotate-key and witness are supported")
+	}
+	return nil
+}
+
 func runAsMaster(conf *config.SinglecloudConf) {
 	stopCh := make(chan struct{})
 	err := db.RunAsMaster(conf, stopCh)
 	if err != nil {
-		log.Fatalf("create database failed: %s", err.Error())
+		logger.Fatalf("create database failed: %s", err.Error())
 	}
 	defer close(stopCh)
 
+	runServer(conf)
+}
+
+func runWithFailover(conf *config.SinglecloudConf) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	failover, err := db.NewFailoverManager(conf, stopCh)
+	if err != nil {
+		logger.Fatalf("create database failed: %s", err.Error())
+	}
+
+	//serve failover status before this instance becomes master
+	statusServer := runFailoverStatusServer(conf, failover)
+	if err := failover.Run(); err != nil {
+		logger.Fatalf("run as master failed: %s", err.Error())
+	}
+
+	if err := statusServer.Shutdown(context.TODO()); err != nil {
+		logger.Fatalf("stop failover status server failed: %s", err.Error())
+	}
+	runServer(conf, failover)
+}
+
+func runFailoverStatusServer(conf *config.SinglecloudConf, failover *db.FailoverManager) *http.Server {
+	certFile, keyFile, err := getTlsCertFiles(conf)
+	if err != nil {
+		logger.Fatalf("create selfsigned tls cert failed %s", err.Error())
+	}
+
+	router := gin.New()
+	failover.RegisterHandler(router)
+	handler.RegisterHealthzHandler(router)
+	srv := &http.Server{
+		Addr:    conf.Server.Addr,
+		Handler: router,
+	}
+	go func() {
+		if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("failover status server failed:%s", err.Error())
+		}
+	}()
+	return srv
+}
+
+func runServer(conf *config.SinglecloudConf, handlers ...server.WebHandler) {
+	var logLevel logLevelReloader
+	apply, err := logLevel.ReloadConfig(conf)
+	if err != nil {
+		logger.Fatalf("set log level failed: %s", err.Error())
+	}
+	apply()
+
+	if err := eventbus.Init(db.GetGlobalDB()); err != nil {
+		logger.Fatalf("init eventbus failed: %v", err.Error())
+	}
+
 	if err := globaldns.New(conf.Server.DNSAddr); err != nil {
-		log.Fatalf("create globaldns failed: %v", err.Error())
+		logger.Fatalf("create globaldns failed: %v", err.Error())
 	}
 
 	authenticator, err := authentication.New(conf.Server.CasAddr)
 	if err != nil {
-		log.Fatalf("create authenticator failed:%s", err.Error())
+		logger.Fatalf("create authenticator failed:%s", err.Error())
 	}
 
 	authorizer, err := authorization.New()
 	if err != nil {
-		log.Fatalf("create authorizer failed:%s", err.Error())
+		logger.Fatalf("create authorizer failed:%s", err.Error())
 	}
 
 	server, err := server.NewServer(authenticator.MiddlewareFunc())
 	if err != nil {
-		log.Fatalf("create server failed:%s", err.Error())
+		logger.Fatalf("create server failed:%s", err.Error())
 	}
 
 	watcher := k8seventwatcher.New()
 	if err := server.RegisterHandler(watcher); err != nil {
-		log.Fatalf("register k8s event watcher failed:%s", err.Error())
+		logger.Fatalf("register k8s event watcher failed:%s", err.Error())
 	}
 
 	if err := alarm.NewAlarmManager(); err != nil {
-		log.Fatalf("create alarm failed:%s", err.Error())
+		logger.Fatalf("create alarm failed:%s", err.Error())
 	}
 
 	if err := server.RegisterHandler(alarm.GetAlarmManager()); err != nil {
-		log.Fatalf("register alarm failed:%s", err.Error())
+		logger.Fatalf("register alarm failed:%s", err.Error())
 	}
 
 	shellExecutor := k8sshell.New()
 	if err := server.RegisterHandler(shellExecutor); err != nil {
-		log.Fatalf("register shell executor failed:%s", err.Error())
+		logger.Fatalf("register shell executor failed:%s", err.Error())
 	}
 
 	if err := server.RegisterHandler(clusteragent.GetAgent()); err != nil {
-		log.Fatalf("register agent failed:%s", err.Error())
+		logger.Fatalf("register agent failed:%s", err.Error())
 	}
 
-	app, err := handler.NewApp(authenticator, authorizer, conf)
+	reloader := config.NewReloader(conf)
+	reloader.Register(authenticator)
+	reloader.Register(logLevel)
+	app, err := handler.NewApp(authenticator, authorizer, conf, reloader)
 	if err != nil {
-		log.Fatalf("create app failed %s", err.Error())
+		logger.Fatalf("create app failed %s", err.Error())
 	}
 
 	if err := server.RegisterHandler(authenticator); err != nil {
-		log.Fatalf("register redirect handler failed:%s", err.Error())
+		logger.Fatalf("register redirect handler failed:%s", err.Error())
 	}
 
 	if err := server.RegisterHandler(app); err != nil {
-		log.Fatalf("register resource handler failed:%s", err.Error())
+		logger.Fatalf("register resource handler failed:%s", err.Error())
 	}
 
-	if conf.Server.TlsCertFile == "" && conf.Server.TlsKeyFile == "" {
-		if err := createSelfSignedTlsCert(); err != nil {
-			log.Fatalf("create selfsigned tls cert failed %s", err.Error())
-		}
-		if err := server.Run(conf.Server.Addr, defaultTlsCertFile, defaultTlsKeyFile); err != nil {
-			log.Fatalf("server run failed:%s", err.Error())
+	for _, h := range handlers {
+		if err := server.RegisterHandler(h); err != nil {
+			logger.Fatalf("register handler failed:%s", err.Error())
 		}
-	} else {
-		if err := server.Run(conf.Server.Addr, conf.Server.TlsCertFile, conf.Server.TlsKeyFile); err != nil {
-			log.Fatalf("server run failed:%s", err.Error())
+	}
+
+	certFile, keyFile, err := getTlsCertFiles(conf)
+	if err != nil {
+		logger.Fatalf("create selfsigned tls cert failed %s", err.Error())
+	}
+	reloader.ReloadOnSignal()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(conf.Server.Addr, certFile, keyFile)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			logger.Fatalf("server run failed:%s", err.Error())
 		}
+	case sig := <-sigCh:
+		logger.Infof("receive signal %s, shutting down", sig)
 	}
+	shutdown(conf, server, app)
+}
+
+// shutdown stops serving requests first, so no new cluster operation
+// or alarm is created, then waits for the running ones
+func shutdown(conf *config.SinglecloudConf, srv *server.Server, app *handler.App) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warnf("shutdown server failed: %s", err.Error())
+	}
+
+	if err := app.Shutdown(ctx); err != nil {
+		logger.Warnf("shutdown app failed: %s", err.Error())
+	}
+
+	eventbus.Shutdown()
+	if err := alarm.GetAlarmManager().Shutdown(ctx); err != nil {
+		logger.Warnf("shutdown alarm failed: %s", err.Error())
+	}
+	logger.Infof("singlecloud is stopped")
+}
+
+func getTlsCertFiles(conf *config.SinglecloudConf) (string, string, error) {
+	if conf.Server.TlsCertFile != "" || conf.Server.TlsKeyFile != "" {
+		return conf.Server.TlsCertFile, conf.Server.TlsKeyFile, nil
+	}
+
+	if err := createSelfSignedTlsCert(); err != nil {
+		return "", "", err
+	}
+	return defaultTlsCertFile, defaultTlsKeyFile, nil
 }
 
 func createSelfSignedTlsCert() error {
@@ -171,6 +366,20 @@ func genInitConfig() error {
 		return err
 	}
 	configFile := "./singlecloud.conf"
-	log.Debugf("Deploying cluster configuration file: %s", configFile)
+	logger.Debugf("Deploying cluster configuration file: %s", configFile)
 	return ioutil.WriteFile(configFile, yamlConfig, 0640)
 }
+
+// logLevelReloader applies default level of structured logs, levels set
+// for modules by admin api are kept
+type logLevelReloader struct{}
+
+func (r logLevelReloader) ReloadConfig(conf *config.SinglecloudConf) (func(), error) {
+	level, err := logging.ParseLevel(conf.Server.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		logging.SetDefaultLevel(level)
+	}, nil
+}
@@ -1,10 +1,9 @@
 package config
 
 import (
-	"errors"
+	"net/url"
 
-	"github.com/zdnscloud/cement/configure"
-	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 )
 
 type DBRole string
@@ -12,6 +11,8 @@ type DBRole string
 const (
 	Master DBRole = "master"
 	Slave  DBRole = "slave"
+
+	redactedValue = "******"
 )
 
 type SinglecloudConf struct {
@@ -20,6 +21,7 @@ type SinglecloudConf struct {
 	DB       DBConf         `yaml:"db"`
 	Chart    ChartConf      `yaml:"chart"`
 	Registry RegistryCAConf `yaml:"registry"`
+	Cluster  ClusterConf    `yaml:"cluster"`
 }
 
 type ServerConf struct {
@@ -29,13 +31,35 @@ type ServerConf struct {
 	DNSAddr     string `yaml:"dns_addr"`
 	CasAddr     string `yaml:"cas_addr"`
 	EnableDebug bool   `yaml:"enable_debug"`
+	//seconds to wait for requests and cluster operations on shutdown
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+	//default level of structured logs, level of each module could be
+	//changed at runtime by admin api
+	LogLevel string `yaml:"log_level"`
 }
 
 type DBConf struct {
-	Path        string `yaml:"path"`
-	Port        int    `yaml:"port"`
-	Role        DBRole `yaml:"role"`
-	SlaveDBAddr string `yaml:"slave_db_addr"`
+	Path          string `yaml:"path"`
+	Port          int    `yaml:"port"`
+	Role          DBRole `yaml:"role"`
+	SlaveDBAddr   string `yaml:"slave_db_addr"`
+	MasterDBAddr  string `yaml:"master_db_addr"`
+	Failover      bool   `yaml:"failover"`
+	LeaseDuration int    `yaml:"lease_duration"`
+	//witness is a third db server holding the master lease, so only the
+	//instance which could reach it becomes master when the two are
+	//partitioned, it could be started by singlecloud witness command
+	WitnessDBAddr string `yaml:"witness_db_addr"`
+	//encryption key file is yaml with primary key id and base64 encoded keys
+	EncryptionKeyFile string `yaml:"encryption_key_file"`
+}
+
+// PeerDBAddr is the db address of the other singlecloud instance
+func (c DBConf) PeerDBAddr() string {
+	if c.Role == Master {
+		return c.SlaveDBAddr
+	}
+	return c.MasterDBAddr
 }
 
 type ChartConf struct {
@@ -48,16 +72,62 @@ type RegistryCAConf struct {
 	CaKeyPath  string `yaml:"ca_key_path"`
 }
 
+type ClusterConf struct {
+	HealthCheckInterval      int `yaml:"health_check_interval"`
+	UnreachableThreshold     int `yaml:"unreachable_threshold"`
+	UnreachableAlarmDuration int `yaml:"unreachable_alarm_duration"`
+	HealthHistorySize        int `yaml:"health_history_size"`
+}
+
 func CreateDefaultConfig() SinglecloudConf {
 	return SinglecloudConf{
 		Server: ServerConf{
-			Addr: ":80",
+			Addr:            ":80",
+			ShutdownTimeout: 30,
+			LogLevel:        "info",
 		},
 		DB: DBConf{
-			Port: 6666,
-			Role: Master,
+			Port:          6666,
+			Role:          Master,
+			LeaseDuration: 15,
 		},
+		Cluster: ClusterConf{
+			HealthCheckInterval:      15,
+			UnreachableThreshold:     3,
+			UnreachableAlarmDuration: 300,
+			HealthHistorySize:        120,
+		},
+	}
+}
+
+// Redacted hides private key locations and credentials in urls, it's
+// used when config is exported
+func (c SinglecloudConf) Redacted() SinglecloudConf {
+	redacted := c
+	redacted.Server.TlsKeyFile = redactValue(c.Server.TlsKeyFile)
+	redacted.Server.CasAddr = redactURL(c.Server.CasAddr)
+	redacted.DB.EncryptionKeyFile = redactValue(c.DB.EncryptionKeyFile)
+	redacted.Chart.Repo = redactURL(c.Chart.Repo)
+	redacted.Registry.CaKeyPath = redactValue(c.Registry.CaKeyPath)
+	return redacted
+}
+
+func redactValue(v string) string {
+	if v == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+func redactURL(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return redactValue(addr)
 	}
+	if u.User != nil {
+		u.User = url.User(redactedValue)
+	}
+	return u.String()
 }
 
 func LoadConfig(path string) (*SinglecloudConf, error) {
@@ -72,32 +142,78 @@ func LoadConfig(path string) (*SinglecloudConf, error) {
 	return &conf, nil
 }
 
+// Reload only warns unknown keys, use CheckConfig to treat them as error
 func (c *SinglecloudConf) Reload() error {
-	newConf := CreateDefaultConfig()
-	if err := configure.Load(&newConf, c.Path); err != nil {
+	newConf, unknownKeys, err := parseConfig(c.Path)
+	if err != nil {
 		return err
 	}
-	newConf.Path = c.Path
-	*c = newConf
+	for _, err := range unknownKeys {
+		logger.Warnf("%s", err.Error())
+	}
+	*c = *newConf
 
 	return nil
 }
 
+// Verify reports all the invalid settings at once
 func (c *SinglecloudConf) Verify() error {
+	var errs ConfigErrors
 	if c.DB.Role != Master && c.DB.Role != Slave {
-		return errors.New("db role can only as master or slave")
+		errs.add("db role can only as master or slave")
 	}
 
 	if c.DB.Role == Slave && c.DB.SlaveDBAddr != "" {
-		return errors.New("slave node cann't have other slaves")
+		errs.add("slave node cann't have other slaves")
 	}
 
 	if c.DB.Role == Master && c.DB.SlaveDBAddr == "" {
-		log.Warnf("no slave node is specified, if master node is crashed, data will be lost\n")
+		logger.Warnf("no slave node is specified, if master node is crashed, data will be lost\n")
+	}
+
+	if c.DB.Failover {
+		if c.DB.PeerDBAddr() == "" {
+			errs.add("failover needs slave db address on master and master db address on slave")
+		}
+		if c.DB.LeaseDuration <= 0 {
+			errs.add("db lease duration must be positive")
+		}
+		errs.checkAddr("db.witness_db_addr", c.DB.WitnessDBAddr, true)
 	}
 
 	if c.Registry.CaCertPath == "" || c.Registry.CaKeyPath == "" {
-		return errors.New("registry ca must be specified")
+		errs.add("registry ca must be specified")
 	}
-	return nil
+
+	if c.Cluster.HealthCheckInterval <= 0 || c.Cluster.UnreachableThreshold <= 0 || c.Cluster.HealthHistorySize <= 0 {
+		errs.add("cluster health check interval, unreachable threshold and health history size must be positive")
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		errs.add("server.shutdown_timeout must be positive")
+	}
+
+	if _, err := logging.ParseLevel(c.Server.LogLevel); err != nil {
+		errs.add("server.log_level is invalid: %s", err.Error())
+	}
+
+	errs.checkAddr("server.addr", c.Server.Addr, true)
+	errs.checkAddr("server.dns_addr", c.Server.DNSAddr, false)
+	errs.checkAddr("db.slave_db_addr", c.DB.SlaveDBAddr, false)
+	errs.checkAddr("db.master_db_addr", c.DB.MasterDBAddr, false)
+	errs.checkURL("server.cas_addr", c.Server.CasAddr)
+	errs.checkURL("chart.repo", c.Chart.Repo)
+	if c.DB.Port <= 0 || c.DB.Port > 65535 {
+		errs.add("db.port %d is invalid", c.DB.Port)
+	}
+
+	if (c.Server.TlsCertFile == "") != (c.Server.TlsKeyFile == "") {
+		errs.add("server.tls_cert_file and server.tls_key_file should be specified together")
+	}
+	errs.checkFile("server.tls_cert_file", c.Server.TlsCertFile)
+	errs.checkFile("server.tls_key_file", c.Server.TlsKeyFile)
+	errs.checkFile("registry.ca_cert_path", c.Registry.CaCertPath)
+	errs.checkFile("registry.ca_key_path", c.Registry.CaKeyPath)
+	errs.checkFile("db.encryption_key_file", c.DB.EncryptionKeyFile)
+	return errs.toError()
 }
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+var logger = logging.GetLogger("config").Std()
+
+// settings which could be applied without restart, they are keyed by
+// yaml path
+var reloadableSettings = map[string]func(cur, new *SinglecloudConf){
+	"server.cas_addr": func(cur, new *SinglecloudConf) {
+		cur.Server.CasAddr = new.Server.CasAddr
+	},
+	"server.log_level": func(cur, new *SinglecloudConf) {
+		cur.Server.LogLevel = new.Server.LogLevel
+	},
+	"chart.repo": func(cur, new *SinglecloudConf) {
+		cur.Chart.Repo = new.Chart.Repo
+	},
+	"registry.ca_cert_path": func(cur, new *SinglecloudConf) {
+		cur.Registry.CaCertPath = new.Registry.CaCertPath
+	},
+	"registry.ca_key_path": func(cur, new *SinglecloudConf) {
+		cur.Registry.CaKeyPath = new.Registry.CaKeyPath
+	},
+}
+
+// ReloadHandler checks new config and returns the function to apply it,
+// nothing should be changed before the function is called, which only
+// happens when all the handlers accept new config
+type ReloadHandler interface {
+	ReloadConfig(conf *SinglecloudConf) (func(), error)
+}
+
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+type Reloader struct {
+	lock     sync.Mutex
+	conf     *SinglecloudConf
+	handlers []ReloadHandler
+}
+
+func NewReloader(conf *SinglecloudConf) *Reloader {
+	return &Reloader{
+		conf: conf,
+	}
+}
+
+func (r *Reloader) Register(h ReloadHandler) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// Reload reads configure file again, only reloadable settings are
+// applied, the others take effect after restart
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	newConf, err := LoadConfig(r.conf.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	merged := *r.conf
+	for _, key := range diffConfig(r.conf, newConf) {
+		if apply, ok := reloadableSettings[key]; ok {
+			apply(&merged, newConf)
+			result.Applied = append(result.Applied, key)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	if len(result.Applied) > 0 {
+		applies := make([]func(), 0, len(r.handlers))
+		for _, h := range r.handlers {
+			apply, err := h.ReloadConfig(&merged)
+			if err != nil {
+				return nil, fmt.Errorf("apply config failed %s", err.Error())
+			}
+			applies = append(applies, apply)
+		}
+
+		for _, apply := range applies {
+			apply()
+		}
+		*r.conf = merged
+	}
+
+	if len(result.RestartRequired) > 0 {
+		logger.Warnf("config %s changed, restart is required to apply them", strings.Join(result.RestartRequired, ","))
+	}
+	return result, nil
+}
+
+func (r *Reloader) ReloadOnSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			result, err := r.Reload()
+			if err != nil {
+				logger.Errorf("reload config failed %s", err.Error())
+				continue
+			}
+			logger.Infof("reload config, applied settings: %v", result.Applied)
+		}
+	}()
+}
+
+// diffConfig returns yaml path of changed settings
+func diffConfig(old, new *SinglecloudConf) []string {
+	return diffStruct("", reflect.ValueOf(*old), reflect.ValueOf(*new))
+}
+
+func diffStruct(prefix string, old, new reflect.Value) []string {
+	var keys []string
+	typ := old.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		if typ.Field(i).Type.Kind() == reflect.Struct {
+			keys = append(keys, diffStruct(key, old.Field(i), new.Field(i))...)
+		} else if !reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigErrors collects all the problems of configure file, so they
+// could be fixed in one round
+type ConfigErrors []error
+
+func (errs ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (errs *ConfigErrors) add(format string, args ...interface{}) {
+	*errs = append(*errs, fmt.Errorf(format, args...))
+}
+
+func (errs ConfigErrors) toError() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (errs *ConfigErrors) checkAddr(key, addr string, required bool) {
+	if addr == "" {
+		if required {
+			errs.add("%s must be specified", key)
+		}
+		return
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		errs.add("%s %s is invalid, it should be host:port", key, addr)
+		return
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		errs.add("%s %s has invalid port", key, addr)
+	}
+}
+
+func (errs *ConfigErrors) checkURL(key, addr string) {
+	if addr == "" {
+		return
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("%s %s is invalid, it should be http or https url", key, addr)
+	}
+}
+
+func (errs *ConfigErrors) checkFile(key, path string) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		errs.add("%s %s isn't readable: %s", key, path, err.Error())
+		return
+	}
+	f.Close()
+}
+
+// parseConfig returns unknown keys separately since they are only
+// warned on start and reload
+func parseConfig(path string) (*SinglecloudConf, ConfigErrors, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conf := CreateDefaultConfig()
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return nil, nil, err
+	}
+	conf.Path = path
+
+	var unknownKeys ConfigErrors
+	strictConf := CreateDefaultConfig()
+	if err := yaml.UnmarshalStrict(data, &strictConf); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, msg := range typeErr.Errors {
+				if strings.Contains(msg, "not found in type") {
+					unknownKeys.add("unknown key in configure file: %s", msg)
+				}
+			}
+		}
+	}
+	return &conf, unknownKeys, nil
+}
+
+// CheckConfig is strict, unknown keys are reported as errors
+func CheckConfig(path string) error {
+	conf, errs, err := parseConfig(path)
+	if err != nil {
+		return ConfigErrors{err}
+	}
+
+	if err := conf.Verify(); err != nil {
+		errs = append(errs, err.(ConfigErrors)...)
+	}
+	return errs.toError()
+}
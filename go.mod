@@ -22,6 +22,7 @@ require (
 	github.com/mitchellh/reflectwalk v1.0.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pkg/sftp v1.11.0 // indirect
+	github.com/prometheus/client_golang v1.4.0
 	github.com/tektoncd/pipeline v0.10.1
 	github.com/urfave/cli v1.22.2 // indirect
 	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
@@ -39,6 +40,7 @@ require (
 	github.com/zdnscloud/vanguard v0.0.0-20200214072003-226d0e690d9f
 	github.com/zdnscloud/zke v0.0.0-20200323081643-f45d03938e3f
 	github.com/zsais/go-gin-prometheus v0.1.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
@@ -50,7 +52,7 @@ require (
 	k8s.io/metrics v0.17.2
 	k8s.io/utils v0.0.0-20200124190032-861946025e34 // indirect
 	knative.dev/pkg v0.0.0-20191111150521-6d806b998379
-	sigs.k8s.io/yaml v1.2.0 // indirect
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
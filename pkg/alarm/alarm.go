@@ -1,10 +1,12 @@
 package alarm
 
 import (
+	"context"
 	"time"
 
 	"github.com/zdnscloud/gorest/resource"
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
@@ -51,6 +53,8 @@ func (a *AlarmEvent) Reason(reason string) *AlarmEvent {
 	return a
 }
 
-func (a *AlarmEvent) Publish() {
-	eb.PublishResourceCreateEvent(&a.Alarm)
+// Publish records id of request in ctx which causes the alarm
+func (a *AlarmEvent) Publish(ctx context.Context) {
+	a.Alarm.RequestID = logging.RequestIDFromContext(ctx)
+	eb.PublishResourceCreateEvent(ctx, &a.Alarm)
 }
@@ -2,6 +2,7 @@ package alarm
 
 import (
 	"container/list"
+	"context"
 	"fmt"
 	"sort"
 	"strconv"
@@ -9,7 +10,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/slice"
 	"github.com/zdnscloud/kvzoo"
 	"github.com/zdnscloud/singlecloud/pkg/types"
@@ -23,6 +23,7 @@ type AlarmCache struct {
 	lock           sync.RWMutex
 	cond           *sync.Cond
 	stopCh         chan struct{}
+	doneCh         chan struct{}
 	thresholdTable kvzoo.Table
 	alarmsTable    kvzoo.Table
 	alarmList      *list.List
@@ -48,6 +49,7 @@ func NewAlarmCache() (*AlarmCache, error) {
 	stop := make(chan struct{})
 	ac := &AlarmCache{
 		stopCh:         stop,
+		doneCh:         make(chan struct{}),
 		thresholdTable: thresholdTable,
 		alarmsTable:    alarmsTable,
 		alarmList:      list.New(),
@@ -56,6 +58,9 @@ func NewAlarmCache() (*AlarmCache, error) {
 		return nil, err
 	}
 	ac.cond = sync.NewCond(&ac.lock)
+	if err := registerCacheSizeMetric(ac); err != nil {
+		return nil, err
+	}
 	go subscribeAlarmEvent(ac, stop)
 	return ac, nil
 }
@@ -78,6 +83,17 @@ func (ac *AlarmCache) Stop() {
 	close(ac.stopCh)
 }
 
+// Flush waits for alarms published before eventbus shutdown to be
+// saved and mailed
+func (ac *AlarmCache) Flush(ctx context.Context) error {
+	select {
+	case <-ac.doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush alarm cache failed %s", ctx.Err().Error())
+	}
+}
+
 func (al *AlarmListener) AlarmChannel() <-chan interface{} {
 	return al.alarmCh
 }
@@ -157,14 +173,14 @@ func (ac *AlarmCache) Add(alarm *types.Alarm) {
 	alarm.UID = ac.eventID + 1
 	alarm.SetID(uintToStr(alarm.UID))
 	if err := addOrUpdateAlarmToDB(ac.alarmsTable, alarm, "add"); err != nil {
-		log.Warnf("add alarm id [%s] to table failed: %s", *alarm, err)
+		logger.Warnf("add alarm id [%s] to table failed: %s", alarm.GetID(), err.Error())
 		ac.lock.Unlock()
 		return
 	}
 	ac.alarmList.PushBack(alarm)
 	if ac.alarmList.Len() > MaxAlarmCount {
 		if err := ac.deleteoldest(); err != nil {
-			log.Warnf("delete oldest alarms failed: %s", err)
+			logger.Warnf("delete oldest alarms failed: %s", err)
 		}
 	}
 	ac.lock.Unlock()
@@ -172,7 +188,8 @@ func (ac *AlarmCache) Add(alarm *types.Alarm) {
 	atomic.AddUint64(&ac.eventID, 1)
 	ac.cond.Broadcast()
 	if err := SendMail(alarm, ac.thresholdTable); err != nil {
-		log.Warnf("send mail failed: %s", err)
+		mailSendFailures.Inc()
+		logger.Warnf("send mail failed: %s", err)
 	}
 }
 
@@ -248,7 +265,7 @@ func (ac *AlarmCache) deleteAlarmForCluster(cluster string) {
 		alarm := elem.Value.(*types.Alarm)
 		if alarm.Cluster == cluster {
 			if err := deleteAlarmFromDB(ac.alarmsTable, uintToStr(alarm.UID)); err != nil {
-				log.Warnf("delete alarm %d for cluster %s failed:%s", alarm.UID, cluster, err.Error())
+				logger.Warnf("delete alarm %d for cluster %s failed:%s", alarm.UID, cluster, err.Error())
 				continue
 			}
 			ac.alarmList.Remove(elem)
@@ -279,3 +296,9 @@ func (ac *AlarmCache) getUnAckNumber() int {
 	}
 	return num
 }
+
+func (ac *AlarmCache) size() int {
+	ac.lock.RLock()
+	defer ac.lock.RUnlock()
+	return ac.alarmList.Len()
+}
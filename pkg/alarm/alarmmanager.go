@@ -1,16 +1,19 @@
 package alarm
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
-	"github.com/zdnscloud/cement/log"
 	resterr "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
+var logger = logging.GetLogger("alarm").Std()
+
 var alarmManager *AlarmManager
 
 const (
@@ -41,9 +44,12 @@ func NewAlarmManager() error {
 }
 
 func (mgr *AlarmManager) eventLoop() {
-	clusterEventCh := eb.SubscribeResourceEvent(types.Cluster{})
+	clusterEventCh := eb.SubscribeDurableResourceEvent("alarm", types.Cluster{})
 	for {
-		event := <-clusterEventCh
+		event, ok := <-clusterEventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceCreateEvent:
 			cluster := e.Resource.(*types.Cluster)
@@ -57,7 +63,7 @@ func (mgr *AlarmManager) eventLoop() {
 				cache.Stop()
 				delete(mgr.clusterEventCache, clusterName)
 			} else {
-				log.Warnf("can not found event cache for cluster %s", clusterName)
+				logger.Warnf("can not found event cache for cluster %s", clusterName)
 			}
 			mgr.lock.Unlock()
 			mgr.cache.deleteAlarmForCluster(clusterName)
@@ -65,6 +71,10 @@ func (mgr *AlarmManager) eventLoop() {
 	}
 }
 
+func (m *AlarmManager) Shutdown(ctx context.Context) error {
+	return m.cache.Flush(ctx)
+}
+
 func (m *AlarmManager) List(ctx *resource.Context) (interface{}, *resterr.APIError) {
 	alarms := make([]*types.Alarm, 0)
 	m.cache.lock.RLock()
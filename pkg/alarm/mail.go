@@ -19,7 +19,7 @@ var ClusterKinds = []string{"Node", "node", "Cluster", "cluster"}
 func SendMail(alarm *types.Alarm, table kvzoo.Table) error {
 	threshold, err := getThresholdFromDB(table, types.ThresholdTable)
 	if err != nil {
-		return fmt.Errorf("get threshold %s failed: %s", err.Error())
+		return fmt.Errorf("get threshold failed: %s", err.Error())
 	}
 	if len(threshold.MailFrom.User) == 0 ||
 		len(threshold.MailFrom.Host) == 0 ||
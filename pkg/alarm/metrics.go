@@ -0,0 +1,34 @@
+package alarm
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var mailSendFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "singlecloud",
+	Subsystem: "alarm",
+	Name:      "mail_send_failures_total",
+	Help:      "Number of alarm mails failed to send.",
+})
+
+func init() {
+	prometheus.MustRegister(mailSendFailures)
+}
+
+func registerCacheSizeMetric(ac *AlarmCache) error {
+	cacheSize := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "singlecloud",
+		Subsystem: "alarm",
+		Name:      "cache_size",
+		Help:      "Number of alarms in alarm cache.",
+	}, func() float64 {
+		return float64(ac.size())
+	})
+
+	if err := prometheus.Register(cacheSize); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	return nil
+}
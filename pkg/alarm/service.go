@@ -5,10 +5,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gorest/resource"
 	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/server"
 )
 
 const (
@@ -26,9 +25,9 @@ func (mgr *AlarmManager) RegisterHandler(router gin.IRoutes) error {
 }
 
 func (mgr *AlarmManager) OpenAlarm(r *http.Request, w http.ResponseWriter) {
-	conn, err := websocket.Upgrade(w, r, nil, 0, 0)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 0, 0)
 	if err != nil {
-		log.Warnf("event websocket upgrade failed %s", err.Error())
+		logger.Warnf("event websocket upgrade failed %s", err.Error())
 		return
 	}
 	defer conn.Close()
@@ -53,7 +52,7 @@ func (mgr *AlarmManager) OpenAlarm(r *http.Request, w http.ResponseWriter) {
 		}
 		err = conn.WriteJSON(msg)
 		if err != nil {
-			log.Warnf("send alarm failed:%s", err.Error())
+			logger.Warnf("send alarm failed:%s", err.Error())
 			break
 		}
 	}
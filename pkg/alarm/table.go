@@ -22,7 +22,7 @@ func genTable(name string) (kvzoo.Table, error) {
 func addOrUpdateAlarmToDB(table kvzoo.Table, alarm *types.Alarm, action string) error {
 	value, err := json.Marshal(alarm)
 	if err != nil {
-		return fmt.Errorf("marshal list %s failed: %s", alarm.UID, err.Error())
+		return fmt.Errorf("marshal list %d failed: %s", alarm.UID, err.Error())
 	}
 
 	tx, err := table.Begin()
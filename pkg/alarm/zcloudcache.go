@@ -6,12 +6,17 @@ import (
 )
 
 func subscribeAlarmEvent(cache *AlarmCache, stop chan struct{}) {
-	alarmEventCh := eb.SubscribeResourceEvent(types.Alarm{})
+	alarmEventCh := eb.SubscribeDurableResourceEvent("alarmcache", types.Alarm{})
+	defer eb.UnsubscribeResourceEvent(alarmEventCh)
+	defer close(cache.doneCh)
 	for {
 		select {
 		case <-stop:
 			return
-		case event := <-alarmEventCh:
+		case event, ok := <-alarmEventCh:
+			if !ok {
+				return
+			}
 			switch e := event.(type) {
 			case eb.ResourceCreateEvent:
 				alarm := e.Resource.(*types.Alarm)
@@ -14,6 +14,7 @@ import (
 	"github.com/zdnscloud/kvzoo"
 	"github.com/zdnscloud/singlecloud/pkg/auditlog/storage"
 	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
@@ -24,8 +25,20 @@ const (
 	OperationTypeCreate = "create"
 	OperationTypeUpdate = "update"
 	OperationTypeDelete = "delete"
+
+	redactedValue = "******"
 )
 
+// values of these json fields are replaced in audit log detail
+var sensitiveFields = map[string]bool{
+	"sshKey":           true,
+	"password":         true,
+	"adminPassword":    true,
+	"registryPassword": true,
+	"oldPassword":      true,
+	"newPassword":      true,
+}
+
 type AuditLogger struct {
 	Storage storage.StorageDriver
 }
@@ -48,6 +61,10 @@ func New() (*AuditLogger, error) {
 	return a, nil
 }
 
+func (a *AuditLogger) Close() error {
+	return a.Storage.Close()
+}
+
 func (a *AuditLogger) List(user string) (types.AuditLogs, error) {
 	logs, err := a.Storage.List()
 	if err != nil {
@@ -75,6 +92,7 @@ func (a *AuditLogger) AuditHandler() gorest.HandlerFunc {
 			SourceAddress: ctx.Request.RemoteAddr,
 			ResourceKind:  resource.DefaultKindName(ctx.Resource),
 			ResourcePath:  ctx.Request.URL.Path,
+			RequestID:     logging.RequestIDFromContext(ctx.Request.Context()),
 		}
 
 		switch ctx.Request.Method {
@@ -102,7 +120,7 @@ func (a *AuditLogger) AuditHandler() gorest.HandlerFunc {
 		} else {
 			detailStr, err := getLogDetail(detail)
 			if err != nil {
-				return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("marshal %s audit log failed %s", log.Operation))
+				return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("marshal %s audit log failed %s", log.Operation, err.Error()))
 			}
 			log.Detail = detailStr
 		}
@@ -120,9 +138,37 @@ func getLogDetail(d interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
+
+	var detail interface{}
+	if err := json.Unmarshal(result, &detail); err != nil {
+		return "", err
+	}
+
+	result, err = json.Marshal(redact(detail))
+	if err != nil {
+		return "", err
+	}
 	return string(result), nil
 }
 
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			if s, ok := field.(string); ok && sensitiveFields[k] && s != "" {
+				value[k] = redactedValue
+			} else {
+				value[k] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redact(item)
+		}
+	}
+	return v
+}
+
 func getCurrentUser(ctx *resource.Context) string {
 	currentUser := ctx.Request.Context().Value(types.CurrentUserKey)
 	if currentUser == nil {
@@ -15,6 +15,7 @@ import (
 type StorageDriver interface {
 	Add(a *types.AuditLog) error
 	List() (types.AuditLogs, error)
+	Close() error
 }
 
 type DefaultDriver struct {
@@ -22,6 +23,7 @@ type DefaultDriver struct {
 	table          kvzoo.Table
 	firstID        uint64
 	currentID      uint64
+	closed         bool
 	lock           sync.Mutex
 }
 
@@ -82,6 +84,10 @@ func (d *DefaultDriver) Add(a *types.AuditLog) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	if d.closed {
+		return fmt.Errorf("audit log storage is closed")
+	}
+
 	if d.currentID-d.firstID > uint64(d.maxRecordCount-2) {
 		if err := deleteFromDB(d.table, uintToStr(d.firstID)); err != nil {
 			return err
@@ -133,6 +139,14 @@ func uintToStr(uid uint64) string {
 	return strconv.FormatUint(uid, 10)
 }
 
+// Close waits for the adding log to be written
+func (d *DefaultDriver) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.closed = true
+	return nil
+}
+
 func (d *DefaultDriver) List() (types.AuditLogs, error) {
 	return listFromDB(d.table)
 }
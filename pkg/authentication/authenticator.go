@@ -2,8 +2,10 @@ package authentication
 
 import (
 	"net/http"
+	"sync"
 
 	resterr "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/singlecloud/config"
 	"github.com/zdnscloud/singlecloud/pkg/authentication/cas"
 	"github.com/zdnscloud/singlecloud/pkg/authentication/jwt"
 	"github.com/zdnscloud/singlecloud/pkg/types"
@@ -12,6 +14,8 @@ import (
 type Authenticator struct {
 	JwtAuth *jwt.Authenticator
 	CasAuth *cas.Authenticator
+	casLock sync.RWMutex
+	casAddr string
 }
 
 func New(casServer string) (*Authenticator, error) {
@@ -22,6 +26,7 @@ func New(casServer string) (*Authenticator, error) {
 
 	auth := &Authenticator{
 		JwtAuth: jwtAuth,
+		casAddr: casServer,
 	}
 
 	if casServer != "" {
@@ -42,10 +47,11 @@ func (a *Authenticator) Authenticate(w http.ResponseWriter, req *http.Request) (
 		return user, nil
 	}
 
-	if a.CasAuth == nil {
+	casAuth := a.getCasAuth()
+	if casAuth == nil {
 		return "", nil
 	} else {
-		user, err := a.CasAuth.Authenticate(w, req)
+		user, err := casAuth.Authenticate(w, req)
 		if err == nil && user != "" {
 			if !a.JwtAuth.HasUser(user) {
 				newUser := &types.User{Name: user}
@@ -56,3 +62,36 @@ func (a *Authenticator) Authenticate(w http.ResponseWriter, req *http.Request) (
 		return user, err
 	}
 }
+
+func (a *Authenticator) getCasAuth() *cas.Authenticator {
+	a.casLock.RLock()
+	defer a.casLock.RUnlock()
+	return a.CasAuth
+}
+
+// ReloadConfig switches cas server, tickets of old cas server are
+// dropped, empty cas address disables cas
+func (a *Authenticator) ReloadConfig(conf *config.SinglecloudConf) (func(), error) {
+	casAddr := conf.Server.CasAddr
+	a.casLock.RLock()
+	unchanged := casAddr == a.casAddr
+	a.casLock.RUnlock()
+	if unchanged {
+		return func() {}, nil
+	}
+
+	var casAuth *cas.Authenticator
+	if casAddr != "" {
+		var err error
+		if casAuth, err = cas.NewAuthenticator(casAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		a.casLock.Lock()
+		defer a.casLock.Unlock()
+		a.CasAuth = casAuth
+		a.casAddr = casAddr
+	}, nil
+}
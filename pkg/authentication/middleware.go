@@ -9,10 +9,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
+var logger = logging.GetLogger("authentication").Std()
+
 const (
 	WebLogoutPath      = "/web/logout"
 	WebLoginPath       = "/web/login"
@@ -43,8 +45,8 @@ func indexPath(r *http.Request, index string) string {
 func (a *Authenticator) RegisterHandler(router gin.IRoutes) error {
 	router.GET(WebRolePath, func(c *gin.Context) {
 		var user, authBy string
-		if a.CasAuth != nil {
-			user, _ = a.CasAuth.Authenticate(c.Writer, c.Request)
+		if casAuth := a.getCasAuth(); casAuth != nil {
+			user, _ = casAuth.Authenticate(c.Writer, c.Request)
 			authBy = "CAS"
 		}
 
@@ -65,8 +67,8 @@ func (a *Authenticator) RegisterHandler(router gin.IRoutes) error {
 	})
 
 	router.GET(WebCASRedirectPath, func(c *gin.Context) {
-		if a.CasAuth != nil {
-			if err := a.CasAuth.SaveTicket(c.Writer, c.Request); err != nil {
+		if casAuth := a.getCasAuth(); casAuth != nil {
+			if err := casAuth.SaveTicket(c.Writer, c.Request); err != nil {
 				body, _ := json.Marshal(map[string]string{
 					"err": err.Error(),
 				})
@@ -87,8 +89,8 @@ func (a *Authenticator) RegisterHandler(router gin.IRoutes) error {
 		user, _ := a.JwtAuth.Authenticate(c.Writer, c.Request)
 		if user != "" {
 			a.JwtAuth.Logout(c.Writer, c.Request)
-		} else if a.CasAuth != nil {
-			a.CasAuth.Logout(c.Writer, c.Request)
+		} else if casAuth := a.getCasAuth(); casAuth != nil {
+			casAuth.Logout(c.Writer, c.Request)
 		}
 	})
 
@@ -98,6 +100,8 @@ func (a *Authenticator) RegisterHandler(router gin.IRoutes) error {
 func (a *Authenticator) MiddlewareFunc() gin.HandlerFunc {
 	var authExceptionPaths = []string{
 		"/assets",
+		"/healthz",
+		"/readyz",
 		"/apis/ws.zcloud.cn",
 		WebRolePath,
 		WebCASRedirectPath,
@@ -119,7 +123,7 @@ func (a *Authenticator) MiddlewareFunc() gin.HandlerFunc {
 
 		userName, err := a.Authenticate(c.Writer, c.Request)
 		if err != nil {
-			log.Errorf("auth failed:%v", err)
+			logger.Errorf("auth failed:%v", err)
 			return
 		}
 
@@ -139,11 +143,11 @@ func (a *Authenticator) MiddlewareFunc() gin.HandlerFunc {
 				return
 			}
 
-			if a.CasAuth != nil {
-				log.Debugf("redirect path %v to cas", path)
-				a.CasAuth.RedirectToLogin(c.Writer, c.Request, WebCASRedirectPath)
+			if casAuth := a.getCasAuth(); casAuth != nil {
+				logger.Debugf("redirect path %v to cas", path)
+				casAuth.RedirectToLogin(c.Writer, c.Request, WebCASRedirectPath)
 			} else {
-				log.Debugf("redirect path %v to /login", path)
+				logger.Debugf("redirect path %v to /login", path)
 				http.Redirect(c.Writer, c.Request, indexPath(c.Request, "/login"), http.StatusFound)
 			}
 		}
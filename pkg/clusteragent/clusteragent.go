@@ -7,12 +7,14 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zdnscloud/goproxy"
 )
 
 const (
 	AgentKey                = "_agent_key"
 	ClusterAgentServiceHost = "http://cluster-agent.zcloud.svc/apis/agent.zcloud.cn/v1"
+	agentServiceAddr        = "cluster-agent.zcloud.svc:80"
 	MethodGet               = "GET"
 )
 
@@ -22,11 +24,19 @@ type AgentManager struct {
 
 var clusterAgent *AgentManager
 
+var tunnelStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "singlecloud",
+	Subsystem: "clusteragent",
+	Name:      "tunnel_up",
+	Help:      "Whether cluster agent tunnel is connected, 1 for connected.",
+}, []string{"cluster"})
+
 func GetAgent() *AgentManager {
 	return clusterAgent
 }
 
 func init() {
+	prometheus.MustRegister(tunnelStatus)
 	clusterAgent = &AgentManager{
 		server: goproxy.New(authorizer),
 	}
@@ -54,6 +64,22 @@ func (m *AgentManager) proxyRequest(cluster string, req *http.Request) (*http.Re
 	return resp, err
 }
 
+func (m *AgentManager) CheckConnection(cluster string) error {
+	dialer := m.server.GetAgentDialer(cluster, 5*time.Second)
+	conn, err := dialer("tcp", agentServiceAddr)
+	if err != nil {
+		tunnelStatus.WithLabelValues(cluster).Set(0)
+		return err
+	}
+	tunnelStatus.WithLabelValues(cluster).Set(1)
+	return conn.Close()
+}
+
+// ForgetCluster removes the tunnel status of deleted cluster
+func (m *AgentManager) ForgetCluster(cluster string) {
+	tunnelStatus.DeleteLabelValues(cluster)
+}
+
 func (m *AgentManager) processRequest(method, cluster, url string, resource interface{}) error {
 	req, err := http.NewRequest(method, ClusterAgentServiceHost+url, nil)
 	if err != nil {
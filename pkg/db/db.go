@@ -1,65 +1,88 @@
 package db
 
 import (
-	"encoding/json"
 	"fmt"
 	"path"
+	"time"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/kvzoo"
 	"github.com/zdnscloud/kvzoo/client"
 	"github.com/zdnscloud/kvzoo/server"
 
 	"github.com/zdnscloud/singlecloud/config"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 )
 
+var logger = logging.GetLogger("db").Std()
+
 const (
 	DBFileName     = "singlecloud.db"
 	DBVersionTable = "version"
 	DBVersion      = "v1.0"
+
+	healthCheckTimeout = 5 * time.Second
 )
 
 type Version struct {
 	Version string `json:"version"`
 }
 
-var globalDB kvzoo.DB
+var (
+	globalDB   kvzoo.DB
+	healthCall boundedCall
+)
 
 func GetGlobalDB() kvzoo.DB {
     return globalDB
 }
 
-func RunAsMaster(conf *config.SinglecloudConf, stopCh chan struct{}) error {
-	dbServerAddr := fmt.Sprintf(":%d", conf.DB.Port)
-	db, err := server.NewWithBoltDB(dbServerAddr, path.Join(conf.DB.Path, DBFileName))
+// CheckHealth reads db version, it fails after timeout if db server
+// doesn't respond, and fails at once until the blocked read returns
+func CheckHealth() error {
+	if globalDB == nil {
+		return fmt.Errorf("db isn't initialized")
+	}
+
+	return healthCall.call(healthCheckTimeout, func() error {
+		table, err := getVersionTable(globalDB)
+		if err != nil {
+			return err
+		}
+		version, err := getDBVersion(table)
+		if err != nil {
+			return err
+		}
+		if current := CurrentDBVersion(); version != current {
+			return fmt.Errorf("db version %s doesn't match %s", version, current)
+		}
+		return nil
+	})
+}
+
+// CheckMigrations reports pending migrations of local db file without
+// applying them, db server isn't started so it works for both master and
+// slave, singlecloud should be stopped
+func CheckMigrations(conf *config.SinglecloudConf) error {
+	db, err := openDBFile(conf)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
+	return migrateDB(db, migrateOptions{dryRun: true})
+}
 
-	dbStarted := make(chan struct{})
-	go func() {
-		close(dbStarted)
-		db.Start()
-	}()
-	<-dbStarted
+// RunAsMaster starts db server and migrates db to current version
+func RunAsMaster(conf *config.SinglecloudConf, stopCh chan struct{}) error {
+	if err := startDBServer(conf, stopCh); err != nil {
+		return err
+	}
 
 	var slaves []string
 	if conf.DB.SlaveDBAddr != "" {
 		slaves = append(slaves, conf.DB.SlaveDBAddr)
 	}
 
-	globalDB, err = client.New(dbServerAddr, slaves)
-	if err != nil {
-		db.Stop()
-		return err
-	}
-
-	go func() {
-		<-stopCh
-		db.Stop()
-	}()
-
-	if err := checkDBVersion(globalDB); err != nil {
+	if err := initGlobalDB(conf, slaves); err != nil {
 		return err
 	}
 
@@ -72,53 +95,70 @@ func RunAsMaster(conf *config.SinglecloudConf, stopCh chan struct{}) error {
 	return nil
 }
 
-func checkDBVersion(db kvzoo.DB) error {
-	tn, _ := kvzoo.TableNameFromSegments(DBVersionTable)
-	table, err := db.CreateOrGetTable(tn)
+func startDBServer(conf *config.SinglecloudConf, stopCh chan struct{}) error {
+	db, err := server.NewWithBoltDB(localDBAddr(conf), path.Join(conf.DB.Path, DBFileName))
 	if err != nil {
-		return fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+		return err
 	}
 
-	tx, err := table.Begin()
-	if err != nil {
-		return fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	dbStarted := make(chan struct{})
+	go func() {
+		close(dbStarted)
+		db.Start()
+	}()
+	<-dbStarted
+
+	go func() {
+		<-stopCh
+		db.Stop()
+	}()
+	return nil
+}
+
+func initGlobalDB(conf *config.SinglecloudConf, slaves []string) error {
+	if err := initKeyring(conf); err != nil {
+		return err
 	}
 
-	defer tx.Rollback()
-	values, err := tx.List()
+	proxy, err := client.New(localDBAddr(conf), slaves)
 	if err != nil {
-		return fmt.Errorf("get db version failed: %s", err.Error())
+		return err
 	}
 
-	if len(values) == 0 {
-		v := &Version{Version: DBVersion}
-		value, err := json.Marshal(v)
-		if err != nil {
-			return fmt.Errorf("marshal db version failed: %s", err.Error())
-		}
-
-		if err := tx.Add(v.Version, value); err != nil {
-			return fmt.Errorf("add version to db failed: %s", err.Error())
-		}
+	if globalDB, err = newTrackedDB(proxy); err != nil {
+		return err
+	}
 
-		log.Debugf("init db version with %s", DBVersion)
-		return tx.Commit()
+	dbFile := path.Join(conf.DB.Path, DBFileName)
+	if err := migrateDB(globalDB, migrateOptions{
+		backup: func(version string) error {
+			return backupDBFile(dbFile, version)
+		},
+	}); err != nil {
+		return err
 	}
 
-	for v := range values {
-		if v != DBVersion {
-			return fmt.Errorf("invalid db version %s, current db version is %s", v, DBVersion)
+	//values wrapped by old key are rotated once new primary key is configured
+	if kr := getKeyring(); kr != nil {
+		count, err := reencryptDB(globalDB.(*trackedDB), kr)
+		if err != nil {
+			return fmt.Errorf("rotate encryption key failed %s", err.Error())
+		}
+		if count > 0 {
+			logger.Infof("rewrap %d records with encryption key %s", count, kr.primary)
 		}
 	}
-
 	return nil
 }
 
+func localDBAddr(conf *config.SinglecloudConf) string {
+	return fmt.Sprintf(":%d", conf.DB.Port)
+}
+
 func RunAsSlave(conf *config.SinglecloudConf) {
-	dbServerAddr := fmt.Sprintf(":%d", conf.DB.Port)
-	db, err := server.NewWithBoltDB(dbServerAddr, path.Join(conf.DB.Path, DBFileName))
+	db, err := server.NewWithBoltDB(localDBAddr(conf), path.Join(conf.DB.Path, DBFileName))
 	if err != nil {
-		log.Fatalf("start slave failed:%s", err.Error())
+		logger.Fatalf("start slave failed:%s", err.Error())
 		return
 	}
 
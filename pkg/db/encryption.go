@@ -0,0 +1,392 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/zdnscloud/cement/configure"
+	"github.com/zdnscloud/kvzoo"
+
+	"github.com/zdnscloud/singlecloud/config"
+)
+
+const (
+	encryptedPrefix = "enc:v1:"
+	dataKeyLen      = 32
+)
+
+var (
+	ErrEncryptionDisabled = errors.New("encryption key file isn't configured")
+
+	keyIDRegexp          = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	encryptedValueRegexp = regexp.MustCompile(`enc:v1:[A-Za-z0-9_.-]+:[A-Za-z0-9_-]+:[A-Za-z0-9_-]+`)
+)
+
+// KeyFile holds base64 encoded 32 bytes master keys, primary key wraps
+// data keys of new values, the other keys are only used to decrypt values
+// which haven't been rotated to primary key
+//
+//	primary: k2
+//	keys:
+//	  k1: <base64 key>
+//	  k2: <base64 key>
+type KeyFile struct {
+	Primary string            `yaml:"primary"`
+	Keys    map[string]string `yaml:"keys"`
+}
+
+// every value is encrypted with its own data key, the data key is
+// wrapped by master key, so key rotation only rewraps data keys
+type keyring struct {
+	file    string
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+var (
+	keyringLock   sync.RWMutex
+	globalKeyring *keyring
+)
+
+func loadKeyring(file string) (*keyring, error) {
+	var kf KeyFile
+	if err := configure.Load(&kf, file); err != nil {
+		return nil, fmt.Errorf("load encryption key file %s failed %s", file, err.Error())
+	}
+
+	if _, ok := kf.Keys[kf.Primary]; !ok {
+		return nil, fmt.Errorf("primary key %s doesn't exist in encryption key file", kf.Primary)
+	}
+
+	kr := &keyring{
+		file:    file,
+		primary: kf.Primary,
+		keys:    make(map[string]cipher.AEAD),
+	}
+	for id, encoded := range kf.Keys {
+		if !keyIDRegexp.MatchString(id) {
+			return nil, fmt.Errorf("invalid key id %s, only letters, digits, '_', '.' and '-' are allowed", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keyLen {
+			return nil, fmt.Errorf("key %s should be %d bytes encoded by base64", id, keyLen)
+		}
+
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[id] = gcm
+	}
+	return kr, nil
+}
+
+func initKeyring(conf *config.SinglecloudConf) error {
+	if conf.DB.EncryptionKeyFile == "" {
+		logger.Warnf("no encryption key file is specified, sensitive data is stored in plaintext")
+		setKeyring(nil)
+		return nil
+	}
+
+	kr, err := loadKeyring(conf.DB.EncryptionKeyFile)
+	if err != nil {
+		return err
+	}
+	setKeyring(kr)
+	return nil
+}
+
+func setKeyring(kr *keyring) {
+	keyringLock.Lock()
+	globalKeyring = kr
+	keyringLock.Unlock()
+}
+
+func getKeyring() *keyring {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	return globalKeyring
+}
+
+func EncryptionEnabled() bool {
+	return getKeyring() != nil
+}
+
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encryptedPrefix)
+}
+
+// EncryptString returns s as it is when encryption is disabled, the
+// result is a printable string which could be stored in json
+func EncryptString(s string) (string, error) {
+	kr := getKeyring()
+	if kr == nil || s == "" || IsEncrypted(s) {
+		return s, nil
+	}
+
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(kr.keys[kr.primary], dataKey, []byte(kr.primary))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := seal(gcm, []byte(s), nil)
+	if err != nil {
+		return "", err
+	}
+	return encodeEncryptedValue(kr.primary, wrapped, data), nil
+}
+
+// DecryptString returns plaintext value as it is, so data written
+// before encryption is enabled is still readable
+func DecryptString(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+
+	kr := getKeyring()
+	if kr == nil {
+		return "", ErrEncryptionDisabled
+	}
+
+	keyID, wrapped, data, err := decodeEncryptedValue(s)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := kr.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(gcm, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value failed %s", err.Error())
+	}
+	return string(plaintext), nil
+}
+
+func (kr *keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %s doesn't exist in key file", keyID)
+	}
+
+	dataKey, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key with key %s failed %s", keyID, err.Error())
+	}
+	return dataKey, nil
+}
+
+// rewrap wraps data key of encrypted value with primary key, the
+// encrypted data is left untouched
+func (kr *keyring) rewrap(s string) (string, bool, error) {
+	keyID, wrapped, data, err := decodeEncryptedValue(s)
+	if err != nil {
+		return "", false, err
+	}
+
+	if keyID == kr.primary {
+		return s, false, nil
+	}
+
+	dataKey, err := kr.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+
+	wrapped, err = seal(kr.keys[kr.primary], dataKey, []byte(kr.primary))
+	if err != nil {
+		return "", false, err
+	}
+	return encodeEncryptedValue(kr.primary, wrapped, data), true, nil
+}
+
+// RotateEncryptionKey reloads key file and rewraps all the encrypted
+// values with new primary key, it returns the count of updated records
+func RotateEncryptionKey() (int, error) {
+	db, ok := globalDB.(*trackedDB)
+	if !ok {
+		return 0, fmt.Errorf("db isn't initialized")
+	}
+
+	old := getKeyring()
+	if old == nil {
+		return 0, ErrEncryptionDisabled
+	}
+
+	kr, err := loadKeyring(old.file)
+	if err != nil {
+		return 0, err
+	}
+	setKeyring(kr)
+	return reencryptDB(db, kr)
+}
+
+// RotateEncryptionKeyFile opens db file directly, singlecloud should
+// be stopped
+func RotateEncryptionKeyFile(conf *config.SinglecloudConf) (int, error) {
+	if conf.DB.EncryptionKeyFile == "" {
+		return 0, ErrEncryptionDisabled
+	}
+
+	kr, err := loadKeyring(conf.DB.EncryptionKeyFile)
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := openDBFile(conf)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return reencryptDB(db, kr)
+}
+
+// reencryptDB scans all the tables for encrypted values, it doesn't
+// need to know the layout of records since encrypted value is self
+// described
+func reencryptDB(db *trackedDB, kr *keyring) (int, error) {
+	if err := db.beginMaintain(); err != nil {
+		return 0, err
+	}
+	defer db.endMaintain()
+
+	count := 0
+	for _, tn := range db.getTables() {
+		if isSnapshotExcluded(tn) {
+			continue
+		}
+
+		n, err := reencryptTable(db.DB, tn, kr)
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+func reencryptTable(db kvzoo.DB, tn kvzoo.TableName, kr *keyring) (int, error) {
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return 0, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+
+	values, err := tx.List()
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("list table %s failed: %s", tn, err.Error())
+	}
+
+	count := 0
+	for k, v := range values {
+		var rewrapErr error
+		changed := false
+		newValue := encryptedValueRegexp.ReplaceAllFunc(v, func(old []byte) []byte {
+			if rewrapErr != nil {
+				return old
+			}
+			s, ok, err := kr.rewrap(string(old))
+			if err != nil {
+				rewrapErr = err
+				return old
+			}
+			changed = changed || ok
+			return []byte(s)
+		})
+
+		if rewrapErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("rewrap %s in table %s failed: %s", k, tn, rewrapErr.Error())
+		}
+
+		if changed {
+			if err := tx.Update(k, newValue); err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("update %s in table %s failed: %s", k, tn, err.Error())
+			}
+			count += 1
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit table %s failed: %s", tn, err.Error())
+	}
+	return count, nil
+}
+
+func encodeEncryptedValue(keyID string, wrapped, data []byte) string {
+	return encryptedPrefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(wrapped) + ":" + base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEncryptedValue(s string) (string, []byte, []byte, error) {
+	segs := strings.Split(strings.TrimPrefix(s, encryptedPrefix), ":")
+	if len(segs) != 3 {
+		return "", nil, nil, fmt.Errorf("invalid encrypted value")
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(segs[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid wrapped data key")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(segs[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid encrypted data")
+	}
+	return segs[0], wrapped, data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prepends nonce to the sealed data
+func seal(gcm cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(gcm cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("data is too short")
+	}
+	nonce := data[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, data[gcm.NonceSize():], additional)
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+)
+
+const (
+	encryptionTestDbPath  = "encryption_tmp.db"
+	encryptionTestKeyPath = "encryption_tmp.key"
+)
+
+func writeTestKeyFile(t *testing.T, path, primary string, ids ...string) {
+	content := fmt.Sprintf("primary: %s\nkeys:\n", primary)
+	for _, id := range ids {
+		key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id, keyLen)[:keyLen]))
+		content += fmt.Sprintf("  %s: %s\n", id, key)
+	}
+	ut.Assert(t, ioutil.WriteFile(path, []byte(content), 0600) == nil, "write key file should succeed")
+}
+
+func TestEncryptString(t *testing.T) {
+	defer setKeyring(nil)
+
+	s, err := EncryptString("secret")
+	ut.Assert(t, err == nil && s == "secret", "value shouldn't be changed without key")
+
+	ut.WithTempFile(t, encryptionTestKeyPath, func(t *testing.T, f *os.File) {
+		writeTestKeyFile(t, f.Name(), "k1", "k1")
+		kr, err := loadKeyring(f.Name())
+		ut.Assert(t, err == nil, "load key file should succeed: %v", err)
+		setKeyring(kr)
+
+		s, err := EncryptString("secret")
+		ut.Assert(t, err == nil && IsEncrypted(s), "value should be encrypted")
+		s2, _ := EncryptString("secret")
+		ut.Assert(t, s != s2, "every value should have its own data key")
+
+		plaintext, err := DecryptString(s)
+		ut.Assert(t, err == nil, "decrypt should succeed: %v", err)
+		ut.Equal(t, plaintext, "secret")
+
+		plaintext, err = DecryptString("legacy")
+		ut.Assert(t, err == nil && plaintext == "legacy", "plaintext value should be returned as it is")
+
+		_, err = DecryptString(s[:len(s)-4] + "AAAA")
+		ut.Assert(t, err != nil, "decrypt tampered value should fail")
+
+		writeTestKeyFile(t, f.Name(), "k3", "k1")
+		_, err = loadKeyring(f.Name())
+		ut.Assert(t, err != nil, "primary key should exist")
+	})
+}
+
+func TestReencryptDB(t *testing.T) {
+	defer setKeyring(nil)
+
+	ut.WithTempFile(t, encryptionTestKeyPath, func(t *testing.T, kf *os.File) {
+		ut.WithTempFile(t, encryptionTestDbPath, func(t *testing.T, f *os.File) {
+			bdb, err := bolt.New(f.Name())
+			ut.Assert(t, err == nil, "create db should succeed: %v", err)
+			defer bdb.Close()
+
+			db, err := newTrackedDB(bdb)
+			ut.Assert(t, err == nil, "create tracked db should succeed: %v", err)
+
+			writeTestKeyFile(t, kf.Name(), "k1", "k1")
+			kr, _ := loadKeyring(kf.Name())
+			setKeyring(kr)
+			s, _ := EncryptString("secret")
+			setTestTable(t, db, "cluster", map[string]string{
+				"local": fmt.Sprintf(`{"sshKey":"%s","user":"root"}`, s),
+				"other": `{"user":"root"}`,
+			})
+
+			writeTestKeyFile(t, kf.Name(), "k2", "k1", "k2")
+			kr, err = loadKeyring(kf.Name())
+			ut.Assert(t, err == nil, "load key file should succeed: %v", err)
+			setKeyring(kr)
+			count, err := reencryptDB(db, kr)
+			ut.Assert(t, err == nil, "reencrypt should succeed: %v", err)
+			ut.Equal(t, count, 1)
+
+			value := getTestTable(t, db, "cluster")["local"]
+			ut.Assert(t, strings.Contains(value, encryptedPrefix+"k2:"), "value should be wrapped by new key")
+			rewrapped := strings.TrimSuffix(strings.TrimPrefix(value, `{"sshKey":"`), `","user":"root"}`)
+
+			writeTestKeyFile(t, kf.Name(), "k2", "k2")
+			kr, _ = loadKeyring(kf.Name())
+			setKeyring(kr)
+			plaintext, err := DecryptString(rewrapped)
+			ut.Assert(t, err == nil, "decrypt without old key should succeed: %v", err)
+			ut.Equal(t, plaintext, "secret")
+
+			count, _ = reencryptDB(db, kr)
+			ut.Equal(t, count, 0)
+		})
+	})
+}
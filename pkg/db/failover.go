@@ -0,0 +1,501 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zdnscloud/kvzoo"
+	"github.com/zdnscloud/kvzoo/client"
+
+	"github.com/zdnscloud/singlecloud/config"
+)
+
+const (
+	LeaseTable         = "lease"
+	leaseKey           = "master"
+	FailoverPrefix     = "/apis/ha.zcloud.cn/v1"
+	FailoverStatusPath = FailoverPrefix + "/status"
+	peerRequestTimeout = 5 * time.Second
+)
+
+// Lease is acquired and renewed on witness db, the instance which holds
+// it is master, master stops writes before the lease expires and slave
+// acquires it after it expires, so clock of the hosts should be synced.
+// lease is also replicated to slave to report replication lag
+type Lease struct {
+	Holder    string    `json:"holder"`
+	Epoch     int64     `json:"epoch"`
+	RenewTime time.Time `json:"renewTime"`
+}
+
+func (l *Lease) expired(now time.Time, duration time.Duration) bool {
+	return now.Sub(l.RenewTime) > duration
+}
+
+type FailoverStatus struct {
+	Role             config.DBRole `json:"role"`
+	Self             string        `json:"self"`
+	Peer             string        `json:"peer"`
+	Epoch            int64         `json:"epoch"`
+	LeaseHolder      string        `json:"leaseHolder,omitempty"`
+	LeaseRenewTime   time.Time     `json:"leaseRenewTime,omitempty"`
+	ReplicationLag   int64         `json:"replicationLag"`
+	ReplicationError string        `json:"replicationError,omitempty"`
+	PeerHealthy      bool          `json:"peerHealthy"`
+	PeerCheckTime    time.Time     `json:"peerCheckTime,omitempty"`
+	PeerError        string        `json:"peerError,omitempty"`
+}
+
+type FailoverManager struct {
+	conf          *config.SinglecloudConf
+	stopCh        chan struct{}
+	leaseDuration time.Duration
+	localDB       kvzoo.DB
+	peerDB        kvzoo.DB
+	witnessDB     kvzoo.DB
+	witnessCall   boundedCall
+	peerCall      boundedCall
+
+	lock           sync.RWMutex
+	status         FailoverStatus
+	outOfSyncSince time.Time
+	//writes are stopped after it, it's earlier than the lease expires
+	//on witness, so slave won't become master before writes are stopped
+	writeDeadline time.Time
+}
+
+var failoverMgr *FailoverManager
+
+func GetFailoverManager() *FailoverManager {
+	return failoverMgr
+}
+
+// NewFailoverManager starts local db server, the role of this instance
+// is decided in Run
+func NewFailoverManager(conf *config.SinglecloudConf, stopCh chan struct{}) (*FailoverManager, error) {
+	if err := startDBServer(conf, stopCh); err != nil {
+		return nil, err
+	}
+
+	localDB, err := client.New(localDBAddr(conf), nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect local db failed %s", err.Error())
+	}
+
+	peerDB, err := client.New(conf.DB.PeerDBAddr(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect peer db %s failed %s", conf.DB.PeerDBAddr(), err.Error())
+	}
+
+	witnessDB, err := client.New(conf.DB.WitnessDBAddr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect witness db %s failed %s", conf.DB.WitnessDBAddr, err.Error())
+	}
+
+	self, _ := os.Hostname()
+	failoverMgr = &FailoverManager{
+		conf:          conf,
+		stopCh:        stopCh,
+		leaseDuration: time.Duration(conf.DB.LeaseDuration) * time.Second,
+		localDB:       localDB,
+		peerDB:        peerDB,
+		witnessDB:     witnessDB,
+		status: FailoverStatus{
+			Role: config.Slave,
+			Self: fmt.Sprintf("%s%s", self, localDBAddr(conf)),
+			Peer: conf.DB.PeerDBAddr(),
+		},
+	}
+	return failoverMgr, nil
+}
+
+// Run blocks while this instance is slave, and returns after it
+// becomes master and global db is ready
+func (m *FailoverManager) Run() error {
+	if m.conf.DB.Role == config.Master {
+		lease, err := m.acquireLease(0)
+		if err == nil {
+			return m.becomeMaster(lease)
+		}
+		logger.Warnf("acquire master lease failed %s, run as slave", err.Error())
+	}
+
+	lease, err := m.waitForPromotion()
+	if err != nil {
+		return err
+	}
+	return m.becomeMaster(lease)
+}
+
+// waitForPromotion tries to acquire the lease on witness, it succeeds
+// only after the lease of master expires
+func (m *FailoverManager) waitForPromotion() (*Lease, error) {
+	for {
+		select {
+		case <-m.stopCh:
+			return nil, fmt.Errorf("failover manager is stopped")
+		case <-time.After(m.checkInterval()):
+		}
+
+		now := time.Now()
+		if lease, err := readLease(m.localDB); err != nil {
+			logger.Warnf("read local lease failed %s", err.Error())
+		} else if lease != nil {
+			m.setLease(lease)
+			m.setReplicationLag(now.Sub(lease.RenewTime), nil)
+		}
+
+		_, peerErr := m.readPeerLease()
+		m.setPeerHealth(now, peerErr)
+
+		lease, err := m.acquireLease(0)
+		if err == nil {
+			logger.Warnf("master lease expired, promote to master with epoch %d", lease.Epoch)
+			return lease, nil
+		} else if err != errLeaseHeld {
+			logger.Warnf("acquire master lease failed %s", err.Error())
+		}
+	}
+}
+
+func (m *FailoverManager) becomeMaster(lease *Lease) error {
+	m.lock.Lock()
+	m.status.Epoch = lease.Epoch
+	m.lock.Unlock()
+	m.setLease(lease)
+
+	//keep the lease while db is migrated
+	go m.renewLoop()
+	setWriteGuard(m.checkWritable)
+	if err := initGlobalDB(m.conf, []string{m.status.Peer}); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	m.status.Role = config.Master
+	m.lock.Unlock()
+
+	if err := writeLease(globalDB, lease); err != nil {
+		return err
+	}
+	logger.Infof("run as master with epoch %d", lease.Epoch)
+	return nil
+}
+
+func (m *FailoverManager) renewLoop() {
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-time.After(m.checkInterval()):
+		}
+
+		lease, err := m.acquireLease(m.getEpoch())
+		if err != nil {
+			logger.Warnf("renew master lease failed %s", err.Error())
+			if err == errLeaseLost || !m.isWritable(time.Now()) {
+				//fence self, writes have been stopped by write guard
+				logger.Fatalf("master lease is lost, stop running as master")
+			}
+		}
+
+		now := time.Now()
+		peerLease, peerErr := m.readPeerLease()
+		m.setPeerHealth(now, peerErr)
+		if m.GetStatus().Role != config.Master {
+			continue
+		}
+
+		if lease != nil {
+			if err := writeLease(globalDB, lease); err != nil {
+				logger.Warnf("replicate lease failed %s", err.Error())
+			}
+		}
+
+		if peerErr != nil {
+			m.setReplicationLag(0, peerErr)
+		} else if peerLease != nil && peerLease.Epoch > m.getEpoch() {
+			m.setReplicationLag(0, fmt.Errorf("peer has newer epoch %d", peerLease.Epoch))
+		} else if _, err := globalDB.Checksum(); err != nil {
+			m.setReplicationLag(0, err)
+		} else {
+			m.setReplicationLag(0, nil)
+		}
+	}
+}
+
+// acquireLease acquires a new lease when epoch is 0, otherwise renews
+// the lease of the epoch, write deadline is extended on success
+func (m *FailoverManager) acquireLease(epoch int64) (*Lease, error) {
+	start := time.Now()
+	var lease *Lease
+	err := m.witnessCall.call(peerRequestTimeout, func() error {
+		var err error
+		lease, err = acquireLease(m.witnessDB, m.status.Self, epoch, start, m.leaseDuration)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	m.writeDeadline = start.Add(m.leaseDuration - m.checkInterval())
+	m.lock.Unlock()
+	m.setLease(lease)
+	return lease, nil
+}
+
+func (m *FailoverManager) isWritable(now time.Time) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return now.Before(m.writeDeadline)
+}
+
+func (m *FailoverManager) checkWritable() error {
+	if !m.isWritable(time.Now()) {
+		return fmt.Errorf("master lease isn't renewed, writes are stopped")
+	}
+	return nil
+}
+
+func (m *FailoverManager) readPeerLease() (*Lease, error) {
+	var lease *Lease
+	err := m.peerCall.call(peerRequestTimeout, func() error {
+		var err error
+		lease, err = readLease(m.peerDB)
+		return err
+	})
+	return lease, err
+}
+
+func (m *FailoverManager) checkInterval() time.Duration {
+	return m.leaseDuration / 3
+}
+
+func (m *FailoverManager) getEpoch() int64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status.Epoch
+}
+
+func (m *FailoverManager) setLease(lease *Lease) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if lease != nil {
+		m.status.LeaseHolder = lease.Holder
+		m.status.LeaseRenewTime = lease.RenewTime
+		if m.status.Role == config.Slave {
+			m.status.Epoch = lease.Epoch
+		}
+	}
+}
+
+func (m *FailoverManager) setPeerHealth(now time.Time, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status.PeerCheckTime = now
+	m.status.PeerHealthy = err == nil
+	m.status.PeerError = ""
+	if err != nil {
+		m.status.PeerError = err.Error()
+	}
+}
+
+// for slave lag is the age of replicated lease, for master lag is the
+// time since replication to slave became broken
+func (m *FailoverManager) setReplicationLag(lag time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status.ReplicationError = ""
+	if m.status.Role == config.Master {
+		if err == nil {
+			m.outOfSyncSince = time.Time{}
+		} else {
+			if m.outOfSyncSince.IsZero() {
+				m.outOfSyncSince = time.Now()
+			}
+			lag = time.Since(m.outOfSyncSince)
+			m.status.ReplicationError = err.Error()
+		}
+	}
+	m.status.ReplicationLag = int64(lag / time.Millisecond)
+}
+
+func (m *FailoverManager) GetStatus() FailoverStatus {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status
+}
+
+func (m *FailoverManager) RegisterHandler(router gin.IRoutes) error {
+	router.GET(FailoverStatusPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, m.GetStatus())
+	})
+	return nil
+}
+
+var (
+	errLeaseHeld = errors.New("master lease is held by other instance")
+	errLeaseLost = errors.New("master lease is acquired by other instance")
+)
+
+// acquireLease reads and writes lease in one transaction, so the two
+// instances won't both get it from witness
+func acquireLease(db kvzoo.DB, holder string, epoch int64, now time.Time, duration time.Duration) (*Lease, error) {
+	tn, _ := kvzoo.TableNameFromSegments(LeaseTable)
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+	defer tx.Rollback()
+
+	var current *Lease
+	if value, err := tx.Get(leaseKey); err == nil {
+		current = &Lease{}
+		if err := json.Unmarshal(value, current); err != nil {
+			return nil, fmt.Errorf("unmarshal lease failed: %s", err.Error())
+		}
+	} else if err != kvzoo.ErrNotFound {
+		return nil, fmt.Errorf("get lease failed: %s", err.Error())
+	}
+
+	lease := &Lease{Holder: holder, Epoch: epoch, RenewTime: now}
+	if epoch != 0 {
+		if current == nil || current.Holder != holder || current.Epoch != epoch {
+			return nil, errLeaseLost
+		}
+	} else {
+		if current != nil && current.Holder != holder && !current.expired(now, duration) {
+			return nil, errLeaseHeld
+		}
+		lease.Epoch = 1
+		if current != nil {
+			lease.Epoch = current.Epoch + 1
+		}
+	}
+
+	value, err := json.Marshal(lease)
+	if err != nil {
+		return nil, fmt.Errorf("marshal lease failed: %s", err.Error())
+	}
+	if current == nil {
+		err = tx.Add(leaseKey, value)
+	} else {
+		err = tx.Update(leaseKey, value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write lease failed: %s", err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func readLease(db kvzoo.DB) (*Lease, error) {
+	tn, _ := kvzoo.TableNameFromSegments(LeaseTable)
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+
+	defer tx.Rollback()
+	value, err := tx.Get(leaseKey)
+	if err == kvzoo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get lease failed: %s", err.Error())
+	}
+
+	var lease Lease
+	if err := json.Unmarshal(value, &lease); err != nil {
+		return nil, fmt.Errorf("unmarshal lease failed: %s", err.Error())
+	}
+	return &lease, nil
+}
+
+func writeLease(db kvzoo.DB, lease *Lease) error {
+	tn, _ := kvzoo.TableNameFromSegments(LeaseTable)
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	value, err := json.Marshal(lease)
+	if err != nil {
+		return fmt.Errorf("marshal lease failed: %s", err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+
+	//delete then add, so slave which missed the lease still gets it
+	if _, err := tx.Get(leaseKey); err == nil {
+		if err := tx.Delete(leaseKey); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("delete lease failed: %s", err.Error())
+		}
+	} else if err != kvzoo.ErrNotFound {
+		tx.Rollback()
+		return fmt.Errorf("get lease failed: %s", err.Error())
+	}
+
+	if err := tx.Add(leaseKey, value); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("add lease failed: %s", err.Error())
+	}
+
+	return tx.Commit()
+}
+
+// boundedCall runs f in goroutine and waits for it until timeout, a new
+// call fails at once while the previous f hasn't returned, so f blocked
+// on unreachable db won't leak goroutines on every call
+type boundedCall struct {
+	lock    sync.Mutex
+	running bool
+}
+
+func (c *boundedCall) call(timeout time.Duration, f func() error) error {
+	c.lock.Lock()
+	if c.running {
+		c.lock.Unlock()
+		return fmt.Errorf("previous call hasn't returned after timeout")
+	}
+	c.running = true
+	c.lock.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := f()
+		c.lock.Lock()
+		c.running = false
+		c.lock.Unlock()
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+)
+
+const failoverTestDbPath = "failover_tmp.db"
+
+func TestLease(t *testing.T) {
+	ut.WithTempFile(t, failoverTestDbPath, func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer db.Close()
+
+		lease, err := readLease(db)
+		ut.Assert(t, err == nil && lease == nil, "empty db should has no lease")
+
+		now := time.Now()
+		ut.Assert(t, writeLease(db, &Lease{Holder: "a", Epoch: 1, RenewTime: now}) == nil, "write lease should succeed")
+		ut.Assert(t, writeLease(db, &Lease{Holder: "a", Epoch: 2, RenewTime: now}) == nil, "renew lease should succeed")
+		lease, err = readLease(db)
+		ut.Assert(t, err == nil, "read lease should succeed: %v", err)
+		ut.Equal(t, lease.Holder, "a")
+		ut.Equal(t, lease.Epoch, int64(2))
+
+		ut.Equal(t, lease.expired(now.Add(10*time.Second), 15*time.Second), false)
+		ut.Equal(t, lease.expired(now.Add(20*time.Second), 15*time.Second), true)
+	})
+}
+
+func TestAcquireLease(t *testing.T) {
+	ut.WithTempFile(t, failoverTestDbPath, func(t *testing.T, f *os.File) {
+		witness, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer witness.Close()
+
+		duration := 15 * time.Second
+		now := time.Now()
+		lease, err := acquireLease(witness, "a", 0, now, duration)
+		ut.Assert(t, err == nil, "acquire lease should succeed: %v", err)
+		ut.Equal(t, lease.Epoch, int64(1))
+
+		_, err = acquireLease(witness, "b", 0, now.Add(5*time.Second), duration)
+		ut.Equal(t, err, errLeaseHeld)
+		lease, err = acquireLease(witness, "a", 1, now.Add(10*time.Second), duration)
+		ut.Assert(t, err == nil, "renew lease should succeed: %v", err)
+
+		//a is partitioned from witness, b acquires the lease after it expires
+		_, err = acquireLease(witness, "b", 0, now.Add(20*time.Second), duration)
+		ut.Equal(t, err, errLeaseHeld)
+		lease, err = acquireLease(witness, "b", 0, now.Add(26*time.Second), duration)
+		ut.Assert(t, err == nil, "promote after lease expired should succeed: %v", err)
+		ut.Equal(t, lease.Epoch, int64(2))
+
+		//a reconnects and finds its lease is lost
+		_, err = acquireLease(witness, "a", 1, now.Add(27*time.Second), duration)
+		ut.Equal(t, err, errLeaseLost)
+	})
+}
+
+func TestWriteStoppedBeforeLeaseExpires(t *testing.T) {
+	ut.WithTempFile(t, failoverTestDbPath, func(t *testing.T, f *os.File) {
+		witness, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer witness.Close()
+
+		m := &FailoverManager{
+			leaseDuration: 3 * time.Second,
+			witnessDB:     witness,
+			status:        FailoverStatus{Self: "a"},
+		}
+		lease, err := m.acquireLease(0)
+		ut.Assert(t, err == nil, "acquire lease should succeed: %v", err)
+		ut.Assert(t, m.checkWritable() == nil, "master should be writable")
+
+		expireTime := lease.RenewTime.Add(m.leaseDuration)
+		ut.Assert(t, !m.isWritable(expireTime.Add(-time.Millisecond)), "writes should stop before lease expires")
+
+		tdb, err := newTrackedDB(witness)
+		ut.Assert(t, err == nil, "")
+		setWriteGuard(func() error { return m.checkWritable() })
+		defer setWriteGuard(nil)
+		m.writeDeadline = time.Now().Add(-time.Second)
+		ut.Assert(t, writeLease(tdb, lease) != nil, "write should fail after deadline")
+	})
+}
+
+func TestBoundedCall(t *testing.T) {
+	var c boundedCall
+	release := make(chan struct{})
+	calls := 0
+	hung := func() error {
+		calls += 1
+		<-release
+		return nil
+	}
+
+	ut.Assert(t, c.call(10*time.Millisecond, hung) != nil, "call should timeout")
+	ut.Assert(t, c.call(10*time.Millisecond, hung) != nil, "call should fail while previous one is running")
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	ut.Equal(t, calls, 1)
+	ut.Assert(t, c.call(10*time.Millisecond, func() error { return nil }) == nil, "call should succeed after previous one returns")
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	txResultCommit   = "commit"
+	txResultRollback = "rollback"
+	txResultFailed   = "failed"
+)
+
+var transactionLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "singlecloud",
+	Subsystem: "db",
+	Name:      "transaction_latency_seconds",
+	Help:      "Latency from transaction begin to commit or rollback, including replication to slaves.",
+	Buckets:   []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+}, []string{"table", "result"})
+
+func init() {
+	prometheus.MustRegister(transactionLatency)
+}
+
+func observeTransaction(table, result string, start time.Time, err error) {
+	if err != nil {
+		result = txResultFailed
+	}
+	transactionLatency.WithLabelValues(table, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdnscloud/kvzoo"
+)
+
+// Migration upgrades persisted data to Version, it may be run more
+// than once if singlecloud exits before the version is recorded, so
+// Migrate must be idempotent
+type Migration struct {
+	Version     string
+	Name        string
+	Description string
+	Migrate     func(db kvzoo.DB) error
+}
+
+type migrationRegistry struct {
+	lock       sync.Mutex
+	migrations []Migration
+}
+
+var migrations = &migrationRegistry{}
+
+// RegisterMigration is normally called in init of the package which
+// owns the tables to migrate
+func RegisterMigration(m Migration) {
+	if _, err := parseVersion(m.Version); err != nil {
+		panic(fmt.Sprintf("register migration %s failed %s", m.Name, err.Error()))
+	}
+	if m.Name == "" || m.Migrate == nil {
+		panic(fmt.Sprintf("register migration for version %s without name or migrate function", m.Version))
+	}
+
+	migrations.lock.Lock()
+	defer migrations.lock.Unlock()
+	for _, old := range migrations.migrations {
+		if old.Version == m.Version && old.Name == m.Name {
+			panic(fmt.Sprintf("migration %s for version %s is registered twice", m.Name, m.Version))
+		}
+	}
+	migrations.migrations = append(migrations.migrations, m)
+}
+
+// CurrentDBVersion is the db version after all registered migrations
+func CurrentDBVersion() string {
+	current := DBVersion
+	for _, m := range getMigrations() {
+		if compareVersion(m.Version, current) > 0 {
+			current = m.Version
+		}
+	}
+	return current
+}
+
+func getMigrations() []Migration {
+	migrations.lock.Lock()
+	ms := append([]Migration{}, migrations.migrations...)
+	migrations.lock.Unlock()
+
+	sort.SliceStable(ms, func(i, j int) bool {
+		if c := compareVersion(ms[i].Version, ms[j].Version); c != 0 {
+			return c < 0
+		}
+		return ms[i].Name < ms[j].Name
+	})
+	return ms
+}
+
+func getPendingMigrations(version string) []Migration {
+	var pending []Migration
+	for _, m := range getMigrations() {
+		if compareVersion(m.Version, version) > 0 {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+type migrateOptions struct {
+	dryRun bool
+	backup func(version string) error
+}
+
+// migrateDB inits version for empty db, otherwise applies the
+// pending migrations in version order and records the new version
+// after all migrations of one version succeed
+func migrateDB(db kvzoo.DB, opts migrateOptions) error {
+	table, err := getVersionTable(db)
+	if err != nil {
+		return err
+	}
+
+	version, err := getDBVersion(table)
+	if err != nil {
+		return err
+	}
+
+	current := CurrentDBVersion()
+	if version == "" {
+		if opts.dryRun {
+			logger.Infof("db is empty, version will be initialized to %s", current)
+			return nil
+		}
+		logger.Debugf("init db version with %s", current)
+		return setDBVersion(table, "", current)
+	}
+
+	if _, err := parseVersion(version); err != nil {
+		return fmt.Errorf("invalid db version %s", version)
+	}
+
+	if compareVersion(version, current) > 0 {
+		return fmt.Errorf("db version %s is newer than current db version %s", version, current)
+	}
+
+	pending := getPendingMigrations(version)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if opts.dryRun {
+		logger.Infof("db version is %s, %d migrations will be applied", version, len(pending))
+		for _, m := range pending {
+			logger.Infof("  %s %s: %s", m.Version, m.Name, m.Description)
+		}
+		return nil
+	}
+
+	if opts.backup != nil {
+		if err := opts.backup(version); err != nil {
+			return fmt.Errorf("backup db before migration failed %s", err.Error())
+		}
+	}
+
+	for i, m := range pending {
+		logger.Infof("run db migration %s %s", m.Version, m.Name)
+		if err := m.Migrate(db); err != nil {
+			return fmt.Errorf("db migration %s %s failed %s", m.Version, m.Name, err.Error())
+		}
+
+		if i == len(pending)-1 || pending[i+1].Version != m.Version {
+			if err := setDBVersion(table, version, m.Version); err != nil {
+				return err
+			}
+			version = m.Version
+		}
+	}
+
+	logger.Infof("db migrated to version %s", version)
+	return nil
+}
+
+func getVersionTable(db kvzoo.DB) (kvzoo.Table, error) {
+	tn, _ := kvzoo.TableNameFromSegments(DBVersionTable)
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+	return table, nil
+}
+
+func getDBVersion(table kvzoo.Table) (string, error) {
+	tx, err := table.Begin()
+	if err != nil {
+		return "", fmt.Errorf("begin table %s transaction failed: %s", DBVersionTable, err.Error())
+	}
+
+	defer tx.Rollback()
+	values, err := tx.List()
+	if err != nil {
+		return "", fmt.Errorf("get db version failed: %s", err.Error())
+	}
+
+	if len(values) > 1 {
+		return "", fmt.Errorf("db has %d versions", len(values))
+	}
+
+	for v := range values {
+		return v, nil
+	}
+	return "", nil
+}
+
+func setDBVersion(table kvzoo.Table, old, new string) error {
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", DBVersionTable, err.Error())
+	}
+
+	if old != "" {
+		if err := tx.Delete(old); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("delete db version %s failed: %s", old, err.Error())
+		}
+	}
+
+	value, err := json.Marshal(&Version{Version: new})
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("marshal db version failed: %s", err.Error())
+	}
+
+	if err := tx.Add(new, value); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("add version to db failed: %s", err.Error())
+	}
+
+	return tx.Commit()
+}
+
+// backupDBFile copies the db file, no write happens before migration
+// so the copy is consistent
+func backupDBFile(dbFile, version string) error {
+	src, err := os.Open(dbFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	backupFile := fmt.Sprintf("%s.%s.%s.bak", dbFile, version, time.Now().Format("20060102150405"))
+	dst, err := os.OpenFile(backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+
+	logger.Infof("backup db to %s", backupFile)
+	return dst.Close()
+}
+
+// version format is vMAJOR.MINOR
+func parseVersion(v string) ([]int, error) {
+	if !strings.HasPrefix(v, "v") {
+		return nil, fmt.Errorf("version %s should start with v", v)
+	}
+
+	segs := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(segs) != 2 {
+		return nil, fmt.Errorf("version %s should be vMAJOR.MINOR", v)
+	}
+
+	nums := make([]int, len(segs))
+	for i, s := range segs {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("version %s has invalid number %s", v, s)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func compareVersion(v1, v2 string) int {
+	n1, _ := parseVersion(v1)
+	n2, _ := parseVersion(v2)
+	for i := 0; i < len(n1) && i < len(n2); i++ {
+		if n1[i] != n2[i] {
+			if n1[i] < n2[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(n1) - len(n2)
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/zdnscloud/cement/log"
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/kvzoo"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+)
+
+const migrationTestDbPath = "migration_tmp.db"
+
+func TestMain(m *testing.M) {
+	log.InitLogger(log.Warn)
+	os.Exit(m.Run())
+}
+
+func resetMigrations(ms ...Migration) {
+	migrations = &migrationRegistry{}
+	for _, m := range ms {
+		RegisterMigration(m)
+	}
+}
+
+func getTestDBVersion(t *testing.T, db kvzoo.DB) string {
+	table, err := getVersionTable(db)
+	ut.Assert(t, err == nil, "get version table should succeed: %v", err)
+	version, err := getDBVersion(table)
+	ut.Assert(t, err == nil, "get db version should succeed: %v", err)
+	return version
+}
+
+func TestCompareVersion(t *testing.T) {
+	ut.Equal(t, compareVersion("v1.0", "v1.0"), 0)
+	ut.Equal(t, compareVersion("v1.2", "v1.10"), -1)
+	ut.Equal(t, compareVersion("v2.0", "v1.10"), 1)
+
+	_, err := parseVersion("1.0")
+	ut.Assert(t, err != nil, "version without v prefix should be invalid")
+	_, err = parseVersion("v1.x")
+	ut.Assert(t, err != nil, "version with non number should be invalid")
+}
+
+func TestMigrateDB(t *testing.T) {
+	defer resetMigrations()
+	ut.WithTempFile(t, migrationTestDbPath, func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer db.Close()
+
+		resetMigrations()
+		ut.Assert(t, migrateDB(db, migrateOptions{}) == nil, "init empty db should succeed")
+		ut.Equal(t, getTestDBVersion(t, db), DBVersion)
+
+		var applied []string
+		newMigration := func(version, name string) Migration {
+			return Migration{
+				Version: version,
+				Name:    name,
+				Migrate: func(kvzoo.DB) error {
+					applied = append(applied, version+"/"+name)
+					return nil
+				},
+			}
+		}
+		resetMigrations(newMigration("v1.2", "b"), newMigration("v1.1", "b"), newMigration("v1.1", "a"))
+		ut.Equal(t, CurrentDBVersion(), "v1.2")
+
+		ut.Assert(t, migrateDB(db, migrateOptions{dryRun: true}) == nil, "dry run should succeed")
+		ut.Equal(t, len(applied), 0)
+		ut.Equal(t, getTestDBVersion(t, db), DBVersion)
+
+		backups := 0
+		opts := migrateOptions{backup: func(string) error { backups += 1; return nil }}
+		ut.Assert(t, migrateDB(db, opts) == nil, "migrate should succeed")
+		ut.Equal(t, applied, []string{"v1.1/a", "v1.1/b", "v1.2/b"})
+		ut.Equal(t, backups, 1)
+		ut.Equal(t, getTestDBVersion(t, db), "v1.2")
+
+		ut.Assert(t, migrateDB(db, opts) == nil, "migrate again should succeed")
+		ut.Equal(t, len(applied), 3)
+		ut.Equal(t, backups, 1)
+
+		resetMigrations()
+		ut.Assert(t, migrateDB(db, opts) != nil, "migrate db with newer version should fail")
+	})
+}
+
+func TestMigrateDBFailed(t *testing.T) {
+	defer resetMigrations()
+	ut.WithTempFile(t, migrationTestDbPath, func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer db.Close()
+
+		resetMigrations()
+		ut.Assert(t, migrateDB(db, migrateOptions{}) == nil, "init empty db should succeed")
+
+		resetMigrations(
+			Migration{Version: "v1.1", Name: "ok", Migrate: func(kvzoo.DB) error { return nil }},
+			Migration{Version: "v1.2", Name: "fail", Migrate: func(kvzoo.DB) error { return errors.New("broken") }},
+		)
+		ut.Assert(t, migrateDB(db, migrateOptions{}) != nil, "failed migration should return error")
+		ut.Equal(t, getTestDBVersion(t, db), "v1.1")
+
+		opts := migrateOptions{backup: func(string) error { return errors.New("disk full") }}
+		ut.Assert(t, migrateDB(db, opts) != nil, "migration should stop when backup failed")
+		ut.Equal(t, getTestDBVersion(t, db), "v1.1")
+	})
+}
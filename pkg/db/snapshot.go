@@ -0,0 +1,495 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/zdnscloud/kvzoo"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/zdnscloud/singlecloud/config"
+)
+
+const (
+	SnapshotFormat      = "singlecloud-snapshot/v1"
+	RestoreStagingTable = "restorestaging"
+	RestoreJournalTable = "restorejournal"
+	saltLen             = 16
+	keyLen              = 32
+	snapshotReadRetry   = 5
+	restoreJournalKey   = "tables"
+)
+
+var (
+	ErrDBBusy             = errors.New("db is busy, snapshot couldn't be taken, try again later")
+	ErrPassphraseRequired = errors.New("snapshot is encrypted, passphrase is required")
+	ErrInvalidPassphrase  = errors.New("decrypt snapshot failed, passphrase may be wrong")
+)
+
+// Snapshot wraps gzip compressed table data, when encrypted the data is
+// sealed by AES-GCM with key derived from passphrase, checksum is
+// calculated on compressed data before encryption
+type Snapshot struct {
+	Format     string    `json:"format"`
+	DBVersion  string    `json:"dbVersion"`
+	CreateTime time.Time `json:"createTime"`
+	Encrypted  bool      `json:"encrypted"`
+	Salt       []byte    `json:"salt,omitempty"`
+	Nonce      []byte    `json:"nonce,omitempty"`
+	Checksum   string    `json:"checksum"`
+	Data       []byte    `json:"data"`
+}
+
+// table name -> key -> value
+type snapshotData map[string]map[string][]byte
+
+// Backup writes snapshot of running db
+func Backup(w io.Writer, passphrase string) error {
+	db, ok := globalDB.(*trackedDB)
+	if !ok {
+		return fmt.Errorf("db isn't initialized")
+	}
+	return writeSnapshot(db, w, passphrase)
+}
+
+// Restore replaces all the tables of running db with snapshot, data
+// cached by singlecloud is stale after restore, so it should be restarted
+func Restore(r io.Reader, passphrase string) error {
+	db, ok := globalDB.(*trackedDB)
+	if !ok {
+		return fmt.Errorf("db isn't initialized")
+	}
+	return restoreSnapshot(db, r, passphrase)
+}
+
+// BackupFile and RestoreFile open db file directly, they fail when
+// singlecloud is running since db file is locked
+func BackupFile(conf *config.SinglecloudConf, snapshotFile, passphrase string) error {
+	db, err := openDBFile(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(db, &buf, passphrase); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(snapshotFile, buf.Bytes(), 0600)
+}
+
+func RestoreFile(conf *config.SinglecloudConf, snapshotFile, passphrase string) error {
+	content, err := ioutil.ReadFile(snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDBFile(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return restoreSnapshot(db, bytes.NewReader(content), passphrase)
+}
+
+func openDBFile(conf *config.SinglecloudConf) (*trackedDB, error) {
+	db, err := bolt.New(path.Join(conf.DB.Path, DBFileName))
+	if err != nil {
+		return nil, fmt.Errorf("open db file failed %s, singlecloud should be stopped", err.Error())
+	}
+
+	tdb, err := newTrackedDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return tdb, nil
+}
+
+func writeSnapshot(db *trackedDB, w io.Writer, passphrase string) error {
+	version, data, err := readConsistentSnapshotData(db)
+	if err != nil {
+		return err
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if err := json.NewEncoder(zw).Encode(data); err != nil {
+		return fmt.Errorf("compress snapshot failed %s", err.Error())
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress snapshot failed %s", err.Error())
+	}
+
+	checksum := sha256.Sum256(compressed.Bytes())
+	snapshot := &Snapshot{
+		Format:     SnapshotFormat,
+		DBVersion:  version,
+		CreateTime: time.Now(),
+		Checksum:   hex.EncodeToString(checksum[:]),
+		Data:       compressed.Bytes(),
+	}
+
+	if passphrase != "" {
+		if err := encryptSnapshot(snapshot, passphrase); err != nil {
+			return err
+		}
+	}
+
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// readConsistentSnapshotData reads tables without blocking writers, the
+// read is retried if any commit happens during it
+func readConsistentSnapshotData(db *trackedDB) (string, snapshotData, error) {
+	for i := 0; i < snapshotReadRetry; i++ {
+		if db.isMaintaining() {
+			return "", nil, ErrDBMaintaining
+		}
+
+		count := db.commitCount()
+		version, data, err := readSnapshotData(db)
+		if err != nil {
+			return "", nil, err
+		}
+		if count == db.commitCount() && !db.isMaintaining() {
+			return version, data, nil
+		}
+	}
+	return "", nil, ErrDBBusy
+}
+
+func readSnapshotData(db *trackedDB) (string, snapshotData, error) {
+	table, err := getVersionTable(db.DB)
+	if err != nil {
+		return "", nil, err
+	}
+
+	version, err := getDBVersion(table)
+	if err != nil {
+		return "", nil, err
+	}
+	if version == "" {
+		return "", nil, fmt.Errorf("db has no version")
+	}
+
+	data := make(snapshotData)
+	for _, tn := range db.getTables() {
+		if isSnapshotExcluded(tn) {
+			continue
+		}
+
+		values, err := listTable(db.DB, tn)
+		if err != nil {
+			return "", nil, err
+		}
+		data[string(tn)] = values
+	}
+	return version, data, nil
+}
+
+func restoreSnapshot(db *trackedDB, r io.Reader, passphrase string) error {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("decode snapshot failed %s", err.Error())
+	}
+
+	data, err := openSnapshot(&snapshot, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := db.beginMaintain(); err != nil {
+		return err
+	}
+	defer db.endMaintain()
+
+	tables := make(map[string]bool)
+	for _, tn := range db.getTables() {
+		tables[string(tn)] = true
+	}
+	for name := range data {
+		tables[name] = true
+	}
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		tn, err := kvzoo.NewTableName(name)
+		if err != nil {
+			return fmt.Errorf("invalid table name %s in snapshot", name)
+		}
+		if !isSnapshotExcluded(tn) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if err := stageRestore(db.DB, names, data); err != nil {
+		deleteRestoreStaging(db.DB)
+		return err
+	}
+	return applyRestoreJournal(db)
+}
+
+// stageRestore writes snapshot into staging tables, then the journal
+// which lists tables to restore, once the journal is written, restore
+// is rolled forward even if singlecloud is stopped in the middle
+func stageRestore(db kvzoo.DB, names []string, data snapshotData) error {
+	if err := deleteRestoreStaging(db); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := replaceTable(db, restoreStagingTableName(kvzoo.TableName(name)), data[name]); err != nil {
+			return err
+		}
+	}
+
+	journal, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return replaceTable(db, restoreJournalTableName(), map[string][]byte{restoreJournalKey: journal})
+}
+
+// applyRestoreJournal copies staging tables to the live tables listed
+// in the journal, it's idempotent, the journal is deleted at last
+func applyRestoreJournal(db *trackedDB) error {
+	values, err := listTable(db.DB, restoreJournalTableName())
+	if err != nil {
+		return err
+	}
+
+	journal, ok := values[restoreJournalKey]
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(journal, &names); err != nil {
+		return fmt.Errorf("invalid restore journal %s", err.Error())
+	}
+
+	for _, name := range names {
+		tn, err := kvzoo.NewTableName(name)
+		if err != nil {
+			return fmt.Errorf("invalid table name %s in restore journal", name)
+		}
+
+		values, err := listTable(db.DB, restoreStagingTableName(tn))
+		if err != nil {
+			return err
+		}
+		if err := replaceTable(db.DB, tn, values); err != nil {
+			return err
+		}
+		if err := db.registerTable(tn); err != nil {
+			return err
+		}
+	}
+
+	if err := db.DB.DeleteTable(restoreJournalTableName()); err != nil {
+		return fmt.Errorf("delete restore journal failed %s", err.Error())
+	}
+	return deleteRestoreStaging(db.DB)
+}
+
+func deleteRestoreStaging(db kvzoo.DB) error {
+	tn, _ := kvzoo.TableNameFromSegments(RestoreStagingTable)
+	if _, err := db.CreateOrGetTable(tn); err != nil {
+		return fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+	if err := db.DeleteTable(tn); err != nil {
+		return fmt.Errorf("delete restore staging tables failed %s", err.Error())
+	}
+	return nil
+}
+
+func restoreStagingTableName(tn kvzoo.TableName) kvzoo.TableName {
+	staging, _ := kvzoo.TableNameFromSegments(append([]string{RestoreStagingTable}, tn.Segments()...)...)
+	return staging
+}
+
+func restoreJournalTableName() kvzoo.TableName {
+	tn, _ := kvzoo.TableNameFromSegments(RestoreJournalTable)
+	return tn
+}
+
+func openSnapshot(snapshot *Snapshot, passphrase string) (snapshotData, error) {
+	if snapshot.Format != SnapshotFormat {
+		return nil, fmt.Errorf("unknown snapshot format %s", snapshot.Format)
+	}
+
+	if err := checkSnapshotVersion(snapshot.DBVersion); err != nil {
+		return nil, err
+	}
+
+	compressed := snapshot.Data
+	if snapshot.Encrypted {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+
+		var err error
+		if compressed, err = decryptSnapshot(snapshot, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	checksum := sha256.Sum256(compressed)
+	if hex.EncodeToString(checksum[:]) != snapshot.Checksum {
+		return nil, fmt.Errorf("snapshot checksum mismatch, snapshot is corrupted")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("decompress snapshot failed %s", err.Error())
+	}
+	defer zr.Close()
+
+	var data snapshotData
+	if err := json.NewDecoder(zr).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode snapshot data failed %s", err.Error())
+	}
+	return data, nil
+}
+
+// snapshot with same major version and not newer than current db
+// version could be restored, it will be migrated on next start
+func checkSnapshotVersion(version string) error {
+	v, err := parseVersion(version)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot db version %s", version)
+	}
+
+	current := CurrentDBVersion()
+	cv, _ := parseVersion(current)
+	if v[0] != cv[0] || compareVersion(version, current) > 0 {
+		return fmt.Errorf("snapshot db version %s is incompatible with current db version %s", version, current)
+	}
+	return nil
+}
+
+func encryptSnapshot(snapshot *Snapshot, passphrase string) error {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	gcm, err := newSnapshotCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	snapshot.Encrypted = true
+	snapshot.Salt = salt
+	snapshot.Nonce = nonce
+	snapshot.Data = gcm.Seal(nil, nonce, snapshot.Data, []byte(snapshot.Checksum))
+	return nil
+}
+
+func decryptSnapshot(snapshot *Snapshot, passphrase string) ([]byte, error) {
+	gcm, err := newSnapshotCipher(passphrase, snapshot.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshot.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid snapshot nonce")
+	}
+
+	data, err := gcm.Open(nil, snapshot.Nonce, snapshot.Data, []byte(snapshot.Checksum))
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return data, nil
+}
+
+func newSnapshotCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// lease belongs to running instances, table registry is rebuilt
+// on restore
+func isSnapshotExcluded(tn kvzoo.TableName) bool {
+	return tn == leaseTableName() || tn == registryTableName()
+}
+
+func leaseTableName() kvzoo.TableName {
+	tn, _ := kvzoo.TableNameFromSegments(LeaseTable)
+	return tn
+}
+
+func listTable(db kvzoo.DB, tn kvzoo.TableName) (map[string][]byte, error) {
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+
+	defer tx.Rollback()
+	values, err := tx.List()
+	if err != nil {
+		return nil, fmt.Errorf("list table %s failed: %s", tn, err.Error())
+	}
+	return values, nil
+}
+
+func replaceTable(db kvzoo.DB, tn kvzoo.TableName, values map[string][]byte) error {
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", tn, err.Error())
+	}
+
+	old, err := tx.List()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("list table %s failed: %s", tn, err.Error())
+	}
+
+	for k := range old {
+		if err := tx.Delete(k); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("delete %s from table %s failed: %s", k, tn, err.Error())
+		}
+	}
+
+	for k, v := range values {
+		if err := tx.Add(k, v); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("add %s to table %s failed: %s", k, tn, err.Error())
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/kvzoo"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+)
+
+const snapshotTestDbPath = "snapshot_tmp.db"
+
+func setTestTable(t *testing.T, db kvzoo.DB, name string, values map[string]string) {
+	tn, _ := kvzoo.TableNameFromSegments(name)
+	data := make(map[string][]byte)
+	for k, v := range values {
+		data[k] = []byte(v)
+	}
+	ut.Assert(t, replaceTable(db, tn, data) == nil, "set table %s should succeed", name)
+}
+
+func getTestTable(t *testing.T, db kvzoo.DB, name string) map[string]string {
+	tn, _ := kvzoo.TableNameFromSegments(name)
+	data, err := listTable(db, tn)
+	ut.Assert(t, err == nil, "list table %s should succeed: %v", name, err)
+	values := make(map[string]string)
+	for k, v := range data {
+		values[k] = string(v)
+	}
+	return values
+}
+
+func TestSnapshot(t *testing.T) {
+	ut.WithTempFile(t, snapshotTestDbPath, func(t *testing.T, f *os.File) {
+		bdb, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer bdb.Close()
+
+		db, err := newTrackedDB(bdb)
+		ut.Assert(t, err == nil, "create tracked db should succeed: %v", err)
+		ut.Assert(t, migrateDB(db, migrateOptions{}) == nil, "init db version should succeed")
+		setTestTable(t, db, "user", map[string]string{"admin": "a", "bob": "b"})
+		setTestTable(t, db, "cluster", map[string]string{"local": "c"})
+
+		var plain, encrypted bytes.Buffer
+		ut.Assert(t, writeSnapshot(db, &plain, "") == nil, "backup should succeed")
+		ut.Assert(t, writeSnapshot(db, &encrypted, "secret") == nil, "encrypted backup should succeed")
+
+		setTestTable(t, db, "user", map[string]string{"alice": "x"})
+		setTestTable(t, db, "threshold", map[string]string{"cpu": "80"})
+
+		ut.Assert(t, restoreSnapshot(db, bytes.NewReader(encrypted.Bytes()), "") == ErrPassphraseRequired, "restore without passphrase should fail")
+		ut.Assert(t, restoreSnapshot(db, bytes.NewReader(encrypted.Bytes()), "wrong") == ErrInvalidPassphrase, "restore with wrong passphrase should fail")
+		ut.Equal(t, getTestTable(t, db, "user"), map[string]string{"alice": "x"})
+
+		ut.Assert(t, restoreSnapshot(db, bytes.NewReader(encrypted.Bytes()), "secret") == nil, "restore should succeed")
+		ut.Equal(t, getTestTable(t, db, "user"), map[string]string{"admin": "a", "bob": "b"})
+		ut.Equal(t, getTestTable(t, db, "cluster"), map[string]string{"local": "c"})
+		ut.Equal(t, getTestTable(t, db, "threshold"), map[string]string{})
+
+		var snapshot Snapshot
+		ut.Assert(t, json.Unmarshal(plain.Bytes(), &snapshot) == nil, "decode snapshot should succeed")
+		snapshot.Data[len(snapshot.Data)/2] ^= 0xff
+		_, err = openSnapshot(&snapshot, "")
+		ut.Assert(t, err != nil, "corrupted snapshot should be rejected")
+
+		ut.Assert(t, json.Unmarshal(plain.Bytes(), &snapshot) == nil, "decode snapshot should succeed")
+		snapshot.DBVersion = "v2.0"
+		_, err = openSnapshot(&snapshot, "")
+		ut.Assert(t, err != nil, "snapshot with incompatible version should be rejected")
+	})
+}
+
+func TestSnapshotWithOpenTransaction(t *testing.T) {
+	ut.WithTempFile(t, snapshotTestDbPath, func(t *testing.T, f *os.File) {
+		bdb, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer bdb.Close()
+
+		db, err := newTrackedDB(bdb)
+		ut.Assert(t, err == nil, "create tracked db should succeed: %v", err)
+		ut.Assert(t, migrateDB(db, migrateOptions{}) == nil, "init db version should succeed")
+		setTestTable(t, db, "user", map[string]string{"admin": "a"})
+
+		tn, _ := kvzoo.TableNameFromSegments("user")
+		table, err := db.CreateOrGetTable(tn)
+		ut.Assert(t, err == nil, "get table should succeed: %v", err)
+		tx, err := table.Begin()
+		ut.Assert(t, err == nil, "begin transaction should succeed: %v", err)
+		ut.Assert(t, tx.Add("bob", []byte("b")) == nil, "add should succeed")
+
+		done := make(chan error)
+		var buf bytes.Buffer
+		go func() {
+			done <- writeSnapshot(db, &buf, "")
+		}()
+		ut.Assert(t, tx.Commit() == nil, "commit shouldn't be blocked by snapshot")
+		ut.Assert(t, <-done == nil, "snapshot should succeed after commit")
+
+		ut.Assert(t, restoreSnapshot(db, bytes.NewReader(buf.Bytes()), "") == nil, "restore should succeed")
+		ut.Equal(t, getTestTable(t, db, "user"), map[string]string{"admin": "a", "bob": "b"})
+	})
+}
+
+func TestRestoreJournal(t *testing.T) {
+	ut.WithTempFile(t, snapshotTestDbPath, func(t *testing.T, f *os.File) {
+		bdb, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer bdb.Close()
+
+		db, err := newTrackedDB(bdb)
+		ut.Assert(t, err == nil, "create tracked db should succeed: %v", err)
+		setTestTable(t, db, "user", map[string]string{"alice": "x"})
+		setTestTable(t, db, "cluster", map[string]string{"local": "c"})
+
+		data := snapshotData{"/user": {"admin": []byte("a")}}
+		ut.Assert(t, stageRestore(bdb, []string{"/cluster", "/user"}, data) == nil, "stage restore should succeed")
+		ut.Equal(t, getTestTable(t, db, "user"), map[string]string{"alice": "x"})
+
+		db, err = newTrackedDB(bdb)
+		ut.Assert(t, err == nil, "reopen tracked db should succeed: %v", err)
+		ut.Equal(t, getTestTable(t, db, "user"), map[string]string{"admin": "a"})
+		ut.Equal(t, getTestTable(t, db, "cluster"), map[string]string{})
+
+		values, err := listTable(bdb, restoreJournalTableName())
+		ut.Assert(t, err == nil, "list restore journal should succeed: %v", err)
+		ut.Equal(t, len(values), 0)
+	})
+}
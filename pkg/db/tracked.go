@@ -0,0 +1,305 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zdnscloud/kvzoo"
+)
+
+const (
+	TableRegistryTable = "tableregistry"
+)
+
+var ErrDBMaintaining = errors.New("db is being restored or reencrypted")
+
+// trackedDB records every table into table registry so snapshot
+// could enumerate them, commits are counted so snapshot could detect
+// concurrent writes, and rejected while db is being restored
+type trackedDB struct {
+	kvzoo.DB
+	commitLock  sync.RWMutex
+	commits     uint64
+	maintaining int32
+	tablesLock  sync.Mutex
+	tables      map[string]kvzoo.TableName
+}
+
+func newTrackedDB(db kvzoo.DB) (*trackedDB, error) {
+	tdb := &trackedDB{
+		DB:     db,
+		tables: make(map[string]kvzoo.TableName),
+	}
+
+	names, err := tdb.loadTableRegistry()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		tn, err := kvzoo.NewTableName(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid table name %s in table registry", name)
+		}
+		tdb.tables[name] = tn
+	}
+
+	if err := applyRestoreJournal(tdb); err != nil {
+		return nil, err
+	}
+	return tdb, nil
+}
+
+// beginMaintain rejects commits of tracked transactions and waits
+// for the commits in progress
+func (db *trackedDB) beginMaintain() error {
+	if !atomic.CompareAndSwapInt32(&db.maintaining, 0, 1) {
+		return ErrDBMaintaining
+	}
+	db.commitLock.Lock()
+	db.commitLock.Unlock()
+	return nil
+}
+
+func (db *trackedDB) endMaintain() {
+	atomic.AddUint64(&db.commits, 1)
+	atomic.StoreInt32(&db.maintaining, 0)
+}
+
+func (db *trackedDB) isMaintaining() bool {
+	return atomic.LoadInt32(&db.maintaining) == 1
+}
+
+// commitCount waits for the commits in progress, so data read before
+// and after two equal counts is consistent
+func (db *trackedDB) commitCount() uint64 {
+	db.commitLock.Lock()
+	defer db.commitLock.Unlock()
+	return atomic.LoadUint64(&db.commits)
+}
+
+func (db *trackedDB) CreateOrGetTable(tn kvzoo.TableName) (kvzoo.Table, error) {
+	table, err := db.DB.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.registerTable(tn); err != nil {
+		return nil, err
+	}
+
+	return &trackedTable{
+		Table: table,
+		db:    db,
+		name:  string(tn),
+	}, nil
+}
+
+func (db *trackedDB) DeleteTable(tn kvzoo.TableName) error {
+	if db.isMaintaining() {
+		return ErrDBMaintaining
+	}
+
+	db.commitLock.RLock()
+	err := db.DB.DeleteTable(tn)
+	if err == nil {
+		atomic.AddUint64(&db.commits, 1)
+	}
+	db.commitLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	db.tablesLock.Lock()
+	defer db.tablesLock.Unlock()
+	if _, ok := db.tables[string(tn)]; !ok {
+		return nil
+	}
+	delete(db.tables, string(tn))
+	return db.updateTableRegistry(func(tx kvzoo.Transaction) error {
+		return tx.Delete(string(tn))
+	})
+}
+
+func (db *trackedDB) registerTable(tn kvzoo.TableName) error {
+	name := string(tn)
+	if name == string(registryTableName()) {
+		return nil
+	}
+
+	db.tablesLock.Lock()
+	defer db.tablesLock.Unlock()
+	if _, ok := db.tables[name]; ok {
+		return nil
+	}
+
+	if err := db.updateTableRegistry(func(tx kvzoo.Transaction) error {
+		if _, err := tx.Get(name); err == nil {
+			return nil
+		} else if err != kvzoo.ErrNotFound {
+			return err
+		}
+		return tx.Add(name, []byte(name))
+	}); err != nil {
+		return err
+	}
+	db.tables[name] = tn
+	return nil
+}
+
+func (db *trackedDB) getTables() []kvzoo.TableName {
+	db.tablesLock.Lock()
+	defer db.tablesLock.Unlock()
+
+	tables := make([]kvzoo.TableName, 0, len(db.tables))
+	for _, tn := range db.tables {
+		tables = append(tables, tn)
+	}
+	return tables
+}
+
+func (db *trackedDB) loadTableRegistry() ([]string, error) {
+	table, err := db.getRegistryTable()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", TableRegistryTable, err.Error())
+	}
+
+	defer tx.Rollback()
+	values, err := tx.List()
+	if err != nil {
+		return nil, fmt.Errorf("list table registry failed: %s", err.Error())
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (db *trackedDB) updateTableRegistry(f func(kvzoo.Transaction) error) error {
+	table, err := db.getRegistryTable()
+	if err != nil {
+		return err
+	}
+
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", TableRegistryTable, err.Error())
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update table registry failed: %s", err.Error())
+	}
+	return tx.Commit()
+}
+
+func (db *trackedDB) getRegistryTable() (kvzoo.Table, error) {
+	tn := registryTableName()
+	table, err := db.DB.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+	return table, nil
+}
+
+func registryTableName() kvzoo.TableName {
+	tn, _ := kvzoo.TableNameFromSegments(TableRegistryTable)
+	return tn
+}
+
+type trackedTable struct {
+	kvzoo.Table
+	db   *trackedDB
+	name string
+}
+
+func (t *trackedTable) Begin() (kvzoo.Transaction, error) {
+	tx, err := t.Table.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &trackedTransaction{
+		Transaction: tx,
+		db:          t.db,
+		table:       t.name,
+		start:       time.Now(),
+	}, nil
+}
+
+// writeGuard is set by failover manager, transaction isn't committed
+// when it returns error
+var writeGuard atomic.Value
+
+func setWriteGuard(f func() error) {
+	writeGuard.Store(f)
+}
+
+func checkWriteGuard() error {
+	if f, ok := writeGuard.Load().(func() error); ok && f != nil {
+		return f()
+	}
+	return nil
+}
+
+type trackedTransaction struct {
+	kvzoo.Transaction
+	db    *trackedDB
+	once  sync.Once
+	table string
+	start time.Time
+}
+
+func (tx *trackedTransaction) Commit() error {
+	if err := checkWriteGuard(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if tx.db.isMaintaining() {
+		tx.Rollback()
+		return ErrDBMaintaining
+	}
+
+	if !tx.close() {
+		return tx.Transaction.Commit()
+	}
+
+	tx.db.commitLock.RLock()
+	err := tx.Transaction.Commit()
+	if err == nil {
+		atomic.AddUint64(&tx.db.commits, 1)
+	}
+	tx.db.commitLock.RUnlock()
+	observeTransaction(tx.table, txResultCommit, tx.start, err)
+	return err
+}
+
+// Rollback is usually deferred after Commit, the closed transaction
+// shouldn't be observed again
+func (tx *trackedTransaction) Rollback() error {
+	if !tx.close() {
+		return nil
+	}
+	err := tx.Transaction.Rollback()
+	observeTransaction(tx.table, txResultRollback, tx.start, err)
+	return err
+}
+
+// close returns true only for the first call
+func (tx *trackedTransaction) close() bool {
+	first := false
+	tx.once.Do(func() {
+		first = true
+	})
+	return first
+}
@@ -0,0 +1,719 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/kvzoo"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+var logger = logging.GetLogger("eventbus").Std()
+
+const (
+	EventTable  = "eventbus_event"
+	CursorTable = "eventbus_cursor"
+
+	// events of current run are kept in memory with live resources,
+	// a subscriber lags more than this will lose events, which is
+	// counted in Dropped of its status and fails readiness if it's
+	// durable
+	maxRetainedEvents = 10 * EventBufLen
+	// persisted events are pruned when they are consumed by all the
+	// durable subscribers or there are too many of them
+	maxPersistedEvents = 10 * EventBufLen
+	// cursors are saved and events are pruned periodically, subscriber
+	// may get events again after restart, which is allowed since
+	// delete event is idempotent
+	flushInterval = time.Second
+
+	anonymousSubscriber = "anonymous"
+)
+
+type event struct {
+	seq     uint64
+	typ     string
+	kind    string
+	payload interface{}
+}
+
+type parentRef struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// only delete events are persisted, resources created in previous run
+// are published again by their owners when they are loaded
+type persistedEvent struct {
+	Seq       uint64          `json:"seq"`
+	Type      string          `json:"type"`
+	Kind      string          `json:"kind"`
+	ID        string          `json:"id"`
+	Parents   []parentRef     `json:"parents,omitempty"`
+	Resource  json.RawMessage `json:"resource,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Time      time.Time       `json:"time"`
+}
+
+type subscriber struct {
+	name    string
+	durable bool
+	topics  map[string]resource.ResourceKind
+	ch      chan interface{}
+	cursor  uint64
+	replay  []*persistedEvent
+	dropped uint64
+	stopped bool
+	stopCh  chan struct{}
+}
+
+type bus struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	seq      uint64
+	runStart uint64
+	events   []*event
+	history  []*persistedEvent
+	subs     map[chan interface{}]*subscriber
+	cursors  map[string]uint64
+	closed   bool
+	nextID   int
+
+	eventTable  kvzoo.Table
+	cursorTable kvzoo.Table
+
+	// db is accessed with ioLock instead of lock, pending events and
+	// dirty cursors are taken under lock and written in one transaction
+	ioLock       sync.Mutex
+	pending      []*persistedEvent
+	persisted    []uint64
+	dirtyCursors map[string]uint64
+}
+
+func newBus() *bus {
+	b := &bus{
+		subs:         make(map[chan interface{}]*subscriber),
+		cursors:      make(map[string]uint64),
+		dirtyCursors: make(map[string]uint64),
+	}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+// init loads sequence and cursors of previous run, it should be
+// called before any event is published
+func (b *bus) init(db kvzoo.DB) error {
+	eventTable, err := createOrGetTable(db, EventTable)
+	if err != nil {
+		return err
+	}
+	cursorTable, err := createOrGetTable(db, CursorTable)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadEvents(eventTable)
+	if err != nil {
+		return err
+	}
+	cursors, err := loadCursors(cursorTable)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.seq != 0 {
+		return fmt.Errorf("eventbus has published %d events before init", b.seq)
+	}
+
+	b.ioLock.Lock()
+	defer b.ioLock.Unlock()
+
+	for _, e := range history {
+		if e.Seq > b.seq {
+			b.seq = e.Seq
+		}
+	}
+	for _, cursor := range cursors {
+		if cursor > b.seq {
+			b.seq = cursor
+		}
+	}
+
+	b.runStart = b.seq
+	b.cursors = cursors
+	b.eventTable = eventTable
+	b.cursorTable = cursorTable
+	for _, e := range history {
+		//events persisted by previous version may not be delete event
+		b.persisted = append(b.persisted, e.Seq)
+		if e.Type == eventTypeDelete {
+			b.history = append(b.history, e)
+		}
+	}
+	go b.flushLoop()
+	return nil
+}
+
+// events published after shutdown are only persisted, so delete
+// events are still replayed in next run
+func (b *bus) publish(typ, kind, requestID string, payload interface{}, r resource.Resource) {
+	var pe *persistedEvent
+	if typ == eventTypeDelete {
+		pe = newPersistedEvent(kind, requestID, r)
+	}
+
+	b.lock.Lock()
+	if b.closed && b.eventTable == nil {
+		b.lock.Unlock()
+		return
+	}
+
+	b.seq += 1
+	e := &event{
+		seq:     b.seq,
+		typ:     typ,
+		kind:    kind,
+		payload: payload,
+	}
+	if !b.closed {
+		b.events = append(b.events, e)
+		if len(b.events) > maxRetainedEvents {
+			b.events = append([]*event{}, b.events[len(b.events)-maxRetainedEvents+maxRetainedEvents/10:]...)
+		}
+	}
+
+	persist := pe != nil && b.eventTable != nil
+	if persist {
+		pe.Seq = e.seq
+		b.pending = append(b.pending, pe)
+	}
+	b.cond.Broadcast()
+	b.lock.Unlock()
+
+	if persist {
+		b.flushEvents()
+	}
+}
+
+func (b *bus) subscribe(name string, kinds []resource.ResourceKind) chan interface{} {
+	b.lock.Lock()
+
+	s := &subscriber{
+		name:    name,
+		durable: name != "",
+		topics:  make(map[string]resource.ResourceKind),
+		ch:      make(chan interface{}),
+		stopCh:  make(chan struct{}),
+	}
+	for _, k := range kinds {
+		s.topics[resource.DefaultKindName(k)] = k
+	}
+
+	if s.durable {
+		for _, other := range b.subs {
+			if other.name == name {
+				b.lock.Unlock()
+				panic(fmt.Sprintf("duplicate eventbus subscriber %s", name))
+			}
+		}
+
+		// subscriber without cursor starts from the beginning of
+		// current run, so it won't miss events published before it
+		// is created
+		s.cursor = b.runStart
+		if cursor, ok := b.cursors[name]; ok {
+			s.cursor = cursor
+		} else if b.cursorTable != nil {
+			//save cursor of new subscriber, so it won't miss delete
+			//events even if it doesn't ack any event before restart
+			b.cursors[name] = s.cursor
+			b.dirtyCursors[name] = s.cursor
+		}
+		for _, e := range b.history {
+			if _, ok := s.topics[e.Kind]; ok && e.Seq > s.cursor {
+				s.replay = append(s.replay, e)
+			}
+		}
+	} else {
+		b.nextID += 1
+		s.name = anonymousSubscriber + "-" + strconv.Itoa(b.nextID)
+		s.cursor = b.seq
+	}
+
+	b.subs[s.ch] = s
+	go b.run(s)
+	b.lock.Unlock()
+
+	b.flushCursors()
+	return s.ch
+}
+
+func (b *bus) unsubscribe(ch chan interface{}) {
+	b.lock.Lock()
+	if s, ok := b.subs[ch]; ok {
+		s.stopped = true
+		close(s.stopCh)
+		delete(b.subs, ch)
+		b.cond.Broadcast()
+	}
+	b.lock.Unlock()
+
+	b.flushCursors()
+}
+
+// shutdown stops accepting new events, subscribers' channel is closed
+// after pending events are consumed
+func (b *bus) shutdown() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+func (b *bus) run(s *subscriber) {
+	defer b.flushCursors()
+	defer close(s.ch)
+	for {
+		b.lock.Lock()
+		seq, payload, ok := b.next(s)
+		for !ok && !s.stopped && !b.closed {
+			b.cond.Wait()
+			seq, payload, ok = b.next(s)
+		}
+		b.lock.Unlock()
+		if !ok {
+			return
+		}
+
+		select {
+		case s.ch <- payload:
+		case <-s.stopCh:
+			return
+		}
+		b.ack(s, seq)
+	}
+}
+
+// next returns the first pending event of the subscriber, history
+// events are replayed before events of current run
+func (b *bus) next(s *subscriber) (uint64, interface{}, bool) {
+	if s.stopped {
+		return 0, nil, false
+	}
+
+	for len(s.replay) > 0 {
+		e := s.replay[0]
+		s.replay = s.replay[1:]
+		payload, err := decodeDeleteEvent(e, s.topics[e.Kind])
+		if err != nil {
+			logger.Warnf("replay event %d to %s failed: %s", e.Seq, s.name, err.Error())
+			continue
+		}
+		return e.Seq, payload, true
+	}
+
+	if s.cursor < b.runStart {
+		s.cursor = b.runStart
+	}
+
+	if len(b.events) == 0 {
+		return 0, nil, false
+	}
+
+	first := b.events[0].seq
+	if s.cursor+1 < first {
+		logger.Warnf("eventbus subscriber %s lags too much, %d events are dropped", s.name, first-s.cursor-1)
+		s.dropped += first - s.cursor - 1
+		s.cursor = first - 1
+	}
+
+	for i := int(s.cursor + 1 - first); i < len(b.events); i++ {
+		e := b.events[i]
+		if _, ok := s.topics[e.kind]; ok {
+			return e.seq, e.payload, true
+		}
+		s.cursor = e.seq
+	}
+	return 0, nil, false
+}
+
+func (b *bus) ack(s *subscriber, seq uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if seq > s.cursor {
+		s.cursor = seq
+	}
+
+	if s.durable && b.cursorTable != nil {
+		b.cursors[s.name] = s.cursor
+		b.dirtyCursors[s.name] = s.cursor
+	}
+}
+
+type SubscriberStatus struct {
+	Name    string   `json:"name"`
+	Topics  []string `json:"topics"`
+	Durable bool     `json:"durable"`
+	Active  bool     `json:"active"`
+	Cursor  uint64   `json:"cursor"`
+	Lag     uint64   `json:"lag"`
+	Dropped uint64   `json:"dropped"`
+}
+
+type Status struct {
+	Sequence    uint64             `json:"sequence"`
+	Closed      bool               `json:"closed"`
+	Subscribers []SubscriberStatus `json:"subscribers"`
+}
+
+func (b *bus) status() Status {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	status := Status{Sequence: b.seq, Closed: b.closed}
+	active := make(map[string]bool)
+	for _, s := range b.subs {
+		active[s.name] = true
+		topics := make([]string, 0, len(s.topics))
+		for topic := range s.topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		status.Subscribers = append(status.Subscribers, SubscriberStatus{
+			Name:    s.name,
+			Topics:  topics,
+			Durable: s.durable,
+			Active:  true,
+			Cursor:  s.cursor,
+			Lag:     b.pendingCount(s),
+			Dropped: s.dropped,
+		})
+	}
+
+	// lag of inactive subscriber is counted on all the topics, since
+	// its topics are unknown
+	for name, cursor := range b.cursors {
+		if !active[name] {
+			status.Subscribers = append(status.Subscribers, SubscriberStatus{
+				Name:    name,
+				Durable: true,
+				Cursor:  cursor,
+				Lag:     b.seq - cursor,
+			})
+		}
+	}
+
+	sort.Slice(status.Subscribers, func(i, j int) bool {
+		return status.Subscribers[i].Name < status.Subscribers[j].Name
+	})
+	return status
+}
+
+func (b *bus) pendingCount(s *subscriber) uint64 {
+	count := uint64(len(s.replay))
+	for i := len(b.events) - 1; i >= 0 && b.events[i].seq > s.cursor; i-- {
+		if _, ok := s.topics[b.events[i].kind]; ok {
+			count += 1
+		}
+	}
+	return count
+}
+
+func newPersistedEvent(kind, requestID string, r resource.Resource) *persistedEvent {
+	pe := &persistedEvent{
+		Type:      eventTypeDelete,
+		Kind:      kind,
+		ID:        r.GetID(),
+		RequestID: requestID,
+		Time:      time.Now(),
+	}
+
+	for p := r.GetParent(); p != nil; p = p.GetParent() {
+		pe.Parents = append(pe.Parents, parentRef{
+			Kind: resource.DefaultKindName(p),
+			ID:   p.GetID(),
+		})
+	}
+	if data, err := json.Marshal(r); err == nil {
+		pe.Resource = data
+	} else {
+		logger.Warnf("marshal %s %s failed, only id is persisted: %s", kind, pe.ID, err.Error())
+	}
+	return pe
+}
+
+func (b *bus) flushEvents() {
+	b.ioLock.Lock()
+	defer b.ioLock.Unlock()
+
+	b.lock.Lock()
+	events := b.pending
+	b.pending = nil
+	b.lock.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	if err := saveEvents(b.eventTable, events); err != nil {
+		logger.Warnf("persist %d events failed: %s", len(events), err.Error())
+		return
+	}
+	for _, e := range events {
+		b.persisted = append(b.persisted, e.Seq)
+	}
+}
+
+func (b *bus) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.flushCursors()
+		b.lock.Lock()
+		closed := b.closed
+		b.lock.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// flushCursors saves changed cursors and prunes events consumed by
+// all known durable subscribers, at most maxPersistedEvents are kept
+func (b *bus) flushCursors() {
+	b.ioLock.Lock()
+	defer b.ioLock.Unlock()
+
+	b.lock.Lock()
+	if b.cursorTable == nil {
+		b.lock.Unlock()
+		return
+	}
+	cursors := b.dirtyCursors
+	b.dirtyCursors = make(map[string]uint64)
+	minCursor := b.minCursor()
+	var history []*persistedEvent
+	for _, e := range b.history {
+		if e.Seq > minCursor {
+			history = append(history, e)
+		}
+	}
+	b.history = history
+	b.lock.Unlock()
+
+	if len(cursors) > 0 {
+		if err := saveCursors(b.cursorTable, cursors); err != nil {
+			logger.Warnf("save eventbus cursors failed: %s", err.Error())
+			b.lock.Lock()
+			for name, cursor := range cursors {
+				if _, ok := b.dirtyCursors[name]; !ok {
+					b.dirtyCursors[name] = cursor
+				}
+			}
+			b.lock.Unlock()
+		}
+	}
+
+	i := 0
+	for i < len(b.persisted) && b.persisted[i] <= minCursor {
+		i += 1
+	}
+	if i == 0 {
+		return
+	}
+	if err := deleteEvents(b.eventTable, b.persisted[:i]); err != nil {
+		logger.Warnf("prune events failed: %s", err.Error())
+		return
+	}
+	b.persisted = append([]uint64{}, b.persisted[i:]...)
+}
+
+func (b *bus) minCursor() uint64 {
+	minCursor := b.seq
+	for _, cursor := range b.cursors {
+		if cursor < minCursor {
+			minCursor = cursor
+		}
+	}
+	if b.seq > maxPersistedEvents && minCursor < b.seq-maxPersistedEvents {
+		minCursor = b.seq - maxPersistedEvents
+	}
+	return minCursor
+}
+
+// decodeDeleteEvent rebuilds resource and its parents from persisted
+// event, the parent kinds are resolved by GetParents of the resource
+func decodeDeleteEvent(e *persistedEvent, kind resource.ResourceKind) (interface{}, error) {
+	r, err := newResource(kind)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Resource) > 0 {
+		if err := json.Unmarshal(e.Resource, r); err != nil {
+			return nil, fmt.Errorf("unmarshal %s %s failed: %s", e.Kind, e.ID, err.Error())
+		}
+	}
+	r.SetID(e.ID)
+
+	child := r
+	for _, ref := range e.Parents {
+		var parent resource.Resource
+		childKind, _ := child.(resource.ResourceKind)
+		if childKind == nil {
+			return nil, fmt.Errorf("%s has no parent kind", resource.DefaultKindName(child))
+		}
+		for _, k := range childKind.GetParents() {
+			if resource.DefaultKindName(k) == ref.Kind {
+				if parent, err = newResource(k); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if parent == nil {
+			return nil, fmt.Errorf("unknown parent kind %s of %s", ref.Kind, resource.DefaultKindName(child))
+		}
+		parent.SetID(ref.ID)
+		child.SetParent(parent)
+		child = parent
+	}
+	return ResourceDeleteEvent{Resource: r, RequestID: e.RequestID}, nil
+}
+
+func newResource(kind resource.ResourceKind) (resource.Resource, error) {
+	typ := reflect.TypeOf(kind)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	r, ok := reflect.New(typ).Interface().(resource.Resource)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a resource", typ.Name())
+	}
+	return r, nil
+}
+
+func eventKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+func createOrGetTable(db kvzoo.DB, name string) (kvzoo.Table, error) {
+	tn, _ := kvzoo.TableNameFromSegments(name)
+	table, err := db.CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+	return table, nil
+}
+
+func loadEvents(table kvzoo.Table) ([]*persistedEvent, error) {
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", EventTable, err.Error())
+	}
+	defer tx.Rollback()
+
+	values, err := tx.List()
+	if err != nil {
+		return nil, fmt.Errorf("list events failed: %s", err.Error())
+	}
+
+	events := make([]*persistedEvent, 0, len(values))
+	for key, value := range values {
+		var e persistedEvent
+		if err := json.Unmarshal(value, &e); err != nil {
+			return nil, fmt.Errorf("unmarshal event %s failed: %s", key, err.Error())
+		}
+		events = append(events, &e)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	return events, nil
+}
+
+func loadCursors(table kvzoo.Table) (map[string]uint64, error) {
+	tx, err := table.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin table %s transaction failed: %s", CursorTable, err.Error())
+	}
+	defer tx.Rollback()
+
+	values, err := tx.List()
+	if err != nil {
+		return nil, fmt.Errorf("list cursors failed: %s", err.Error())
+	}
+
+	cursors := make(map[string]uint64)
+	for name, value := range values {
+		cursor, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse cursor of %s failed: %s", name, err.Error())
+		}
+		cursors[name] = cursor
+	}
+	return cursors, nil
+}
+
+func saveEvents(table kvzoo.Table, events []*persistedEvent) error {
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", EventTable, err.Error())
+	}
+
+	for _, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("marshal event failed: %s", err.Error())
+		}
+		if err := tx.Add(eventKey(e.Seq), value); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("add event failed: %s", err.Error())
+		}
+	}
+	return tx.Commit()
+}
+
+func deleteEvents(table kvzoo.Table, seqs []uint64) error {
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", EventTable, err.Error())
+	}
+
+	for _, seq := range seqs {
+		if err := tx.Delete(eventKey(seq)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("delete event %d failed: %s", seq, err.Error())
+		}
+	}
+	return tx.Commit()
+}
+
+func saveCursors(table kvzoo.Table, cursors map[string]uint64) error {
+	tx, err := table.Begin()
+	if err != nil {
+		return fmt.Errorf("begin table %s transaction failed: %s", CursorTable, err.Error())
+	}
+
+	for name, cursor := range cursors {
+		value := []byte(strconv.FormatUint(cursor, 10))
+		if _, err = tx.Get(name); err == nil {
+			err = tx.Update(name, value)
+		} else if err == kvzoo.ErrNotFound {
+			err = tx.Add(name, value)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("save cursor of %s failed: %s", name, err.Error())
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,121 @@
+package eventbus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zdnscloud/cement/log"
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/kvzoo/backend/bolt"
+)
+
+const durableTestDbPath = "eventbus_tmp.db"
+
+type MyParent struct {
+	resource.ResourceBase
+}
+
+type MyChild struct {
+	resource.ResourceBase
+	Value string `json:"value"`
+}
+
+func (c MyChild) GetParents() []resource.ResourceKind {
+	return []resource.ResourceKind{MyParent{}}
+}
+
+func TestMain(m *testing.M) {
+	log.InitLogger(log.Warn)
+	os.Exit(m.Run())
+}
+
+func receive(t *testing.T, ch chan interface{}) interface{} {
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("wait event timeout")
+	}
+	return nil
+}
+
+func newChild(id, parentID string) *MyChild {
+	parent := &MyParent{}
+	parent.SetID(parentID)
+	child := &MyChild{Value: "v-" + id}
+	child.SetID(id)
+	child.SetParent(parent)
+	return child
+}
+
+func TestDurableSubscriber(t *testing.T) {
+	ut.WithTempFile(t, durableTestDbPath, func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer db.Close()
+
+		b := newBus()
+		ut.Assert(t, b.init(db) == nil, "init bus should succeed")
+		b.publish(eventTypeCreate, resource.DefaultKindName(MyChild{}), "", ResourceCreateEvent{Resource: newChild("c1", "p1")}, newChild("c1", "p1"))
+		b.publish(eventTypeDelete, resource.DefaultKindName(MyChild{}), "req-1", ResourceDeleteEvent{Resource: newChild("c1", "p1"), RequestID: "req-1"}, newChild("c1", "p1"))
+
+		//late subscriber gets events published before it subscribes
+		ch := b.subscribe("late", []resource.ResourceKind{MyChild{}})
+		_, ok := receive(t, ch).(ResourceCreateEvent)
+		ut.Assert(t, ok, "first event should be create event")
+		b.unsubscribe(ch)
+
+		status := b.status()
+		ut.Equal(t, status.Sequence, uint64(2))
+		ut.Equal(t, len(status.Subscribers), 1)
+		ut.Equal(t, status.Subscribers[0].Active, false)
+		ut.Equal(t, status.Subscribers[0].Lag, uint64(1))
+
+		//after restart, missed delete event is replayed
+		b = newBus()
+		ut.Assert(t, b.init(db) == nil, "init bus should succeed")
+		ch = b.subscribe("late", []resource.ResourceKind{MyChild{}})
+		e, ok := receive(t, ch).(ResourceDeleteEvent)
+		ut.Assert(t, ok, "replayed event should be delete event")
+		child := e.Resource.(*MyChild)
+		ut.Equal(t, child.GetID(), "c1")
+		ut.Equal(t, child.Value, "v-c1")
+		ut.Equal(t, child.GetParent().GetID(), "p1")
+		ut.Equal(t, e.RequestID, "req-1")
+
+		b.publish(eventTypeCreate, resource.DefaultKindName(MyChild{}), "", ResourceCreateEvent{Resource: newChild("c2", "p1")}, newChild("c2", "p1"))
+		e2 := receive(t, ch).(ResourceCreateEvent)
+		ut.Equal(t, e2.Resource.GetID(), "c2")
+		b.shutdown()
+	})
+}
+
+func TestPersistDeleteEventOnly(t *testing.T) {
+	ut.WithTempFile(t, durableTestDbPath, func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %v", err)
+		defer db.Close()
+
+		b := newBus()
+		ut.Assert(t, b.init(db) == nil, "init bus should succeed")
+		ch := b.subscribe("sub", []resource.ResourceKind{MyChild{}})
+		b.publish(eventTypeCreate, resource.DefaultKindName(MyChild{}), "", ResourceCreateEvent{Resource: newChild("c1", "p1")}, newChild("c1", "p1"))
+		b.publish(eventTypeDelete, resource.DefaultKindName(MyChild{}), "", ResourceDeleteEvent{Resource: newChild("c1", "p1")}, newChild("c1", "p1"))
+
+		events, err := loadEvents(b.eventTable)
+		ut.Assert(t, err == nil, "load events should succeed: %v", err)
+		ut.Equal(t, len(events), 1)
+		ut.Equal(t, events[0].Seq, uint64(2))
+
+		receive(t, ch)
+		receive(t, ch)
+		b.unsubscribe(ch)
+
+		cursors, err := loadCursors(b.cursorTable)
+		ut.Assert(t, err == nil, "load cursors should succeed: %v", err)
+		ut.Equal(t, cursors["sub"] >= uint64(1), true)
+		b.shutdown()
+	})
+}
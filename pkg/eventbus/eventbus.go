@@ -1,70 +1,109 @@
 package eventbus
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/zdnscloud/cement/pubsub"
 	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/kvzoo"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 )
 
 const EventBufLen = 1000
 
-var eventBus *pubsub.PubSub
+var eventBus *bus
 
 func init() {
-	eventBus = pubsub.New(EventBufLen)
+	eventBus = newBus()
+}
+
+// Init makes events and subscriber cursors durable, events published
+// before Init aren't persisted
+func Init(db kvzoo.DB) error {
+	return eventBus.init(db)
 }
 
+// RequestID is the id of request which causes the event, it's empty
+// if the event isn't triggered by request
 type ResourceCreateEvent struct {
-	Resource resource.Resource
+	Resource  resource.Resource
+	RequestID string
 }
 
 type ResourceDeleteEvent struct {
-	Resource resource.Resource
+	Resource  resource.Resource
+	RequestID string
 }
 
 type ResourceUpdateEvent struct {
 	ResourceOld resource.Resource
 	ResourceNew resource.Resource
+	RequestID   string
 }
 
-func PublishResourceCreateEvent(r resource.Resource) {
-	eventBus.Pub(ResourceCreateEvent{
-		Resource: r,
-	}, resource.DefaultKindName(r))
+func PublishResourceCreateEvent(ctx context.Context, r resource.Resource) {
+	kind := resource.DefaultKindName(r)
+	requestID := logging.RequestIDFromContext(ctx)
+	observePublish(kind, eventTypeCreate)
+	eventBus.publish(eventTypeCreate, kind, requestID, ResourceCreateEvent{
+		Resource:  r,
+		RequestID: requestID,
+	}, r)
 }
 
-func PublishResourceDeleteEvent(r resource.Resource) {
-	eventBus.Pub(ResourceDeleteEvent{
-		Resource: r,
-	}, resource.DefaultKindName(r))
+func PublishResourceDeleteEvent(ctx context.Context, r resource.Resource) {
+	kind := resource.DefaultKindName(r)
+	requestID := logging.RequestIDFromContext(ctx)
+	observePublish(kind, eventTypeDelete)
+	eventBus.publish(eventTypeDelete, kind, requestID, ResourceDeleteEvent{
+		Resource:  r,
+		RequestID: requestID,
+	}, r)
 }
 
-func PublishResourceUpdateEvent(resourceOld, resourceNew resource.Resource) {
+func PublishResourceUpdateEvent(ctx context.Context, resourceOld, resourceNew resource.Resource) {
 	oldKind := resource.DefaultKindName(resourceOld)
 	newKind := resource.DefaultKindName(resourceNew)
 	if oldKind != newKind {
 		panic(fmt.Sprintf("publish update event with different kind %s:%s", oldKind, newKind))
 	}
 
-	eventBus.Pub(ResourceUpdateEvent{
+	requestID := logging.RequestIDFromContext(ctx)
+	observePublish(oldKind, eventTypeUpdate)
+	eventBus.publish(eventTypeUpdate, oldKind, requestID, ResourceUpdateEvent{
 		ResourceOld: resourceOld,
 		ResourceNew: resourceNew,
-	}, oldKind)
+		RequestID:   requestID,
+	}, resourceNew)
 }
 
+// SubscribeResourceEvent only receives events published after it
+// subscribes
 func SubscribeResourceEvent(kinds ...resource.ResourceKind) chan interface{} {
-	topics := make([]string, 0, len(kinds))
-	for _, k := range kinds {
-		topics = append(topics, resource.DefaultKindName(k))
+	return eventBus.subscribe("", kinds)
+}
+
+// SubscribeDurableResourceEvent tracks the received events by name,
+// events published in current run before it subscribes and the ones
+// missed since its last run are replayed, only delete events are
+// replayed from last run, events of current run are lost if it lags
+// more than the retained ones, which is reported in Dropped of status
+func SubscribeDurableResourceEvent(name string, kinds ...resource.ResourceKind) chan interface{} {
+	if name == "" {
+		panic("durable subscriber should have name")
 	}
-	return eventBus.Sub(topics...)
+	return eventBus.subscribe(name, kinds)
 }
 
 func UnsubscribeResourceEvent(ch chan interface{}) {
-	eventBus.Unsub(ch)
+	eventBus.unsubscribe(ch)
+}
+
+func GetStatus() Status {
+	return eventBus.status()
 }
 
 func Shutdown() {
-	eventBus.Shutdown()
+	eventBus.shutdown()
 }
@@ -1,6 +1,7 @@
 package eventbus
 
 import (
+	"context"
 	"testing"
 
 	ut "github.com/zdnscloud/cement/unittest"
@@ -21,15 +22,15 @@ func TestPubSubResource(t *testing.T) {
 	sendDeleteEventCount := 20
 	sendUpdateEventCount := 30
 	for i := 0; i < sendCreateEventCount; i++ {
-		PublishResourceCreateEvent(&MyResource{})
+		PublishResourceCreateEvent(context.TODO(), &MyResource{})
 	}
 
 	for i := 0; i < sendDeleteEventCount; i++ {
-		PublishResourceDeleteEvent(&MyResource{})
+		PublishResourceDeleteEvent(context.TODO(), &MyResource{})
 	}
 
 	for i := 0; i < sendUpdateEventCount; i++ {
-		PublishResourceUpdateEvent(&MyResource{}, &MyResource{})
+		PublishResourceUpdateEvent(context.TODO(), &MyResource{}, &MyResource{})
 	}
 
 	Shutdown()
@@ -0,0 +1,87 @@
+package eventbus
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	eventTypeCreate = "create"
+	eventTypeDelete = "delete"
+	eventTypeUpdate = "update"
+)
+
+var (
+	publishedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "singlecloud",
+		Subsystem: "eventbus",
+		Name:      "published_events_total",
+		Help:      "Number of published resource events.",
+	}, []string{"kind", "type"})
+
+	subscribersDesc = prometheus.NewDesc(
+		"singlecloud_eventbus_subscribers",
+		"Number of subscribers by subscribed topics.",
+		[]string{"topics"}, nil)
+
+	queuedEventsDesc = prometheus.NewDesc(
+		"singlecloud_eventbus_queued_events",
+		"Number of events waiting in subscriber queues by subscribed topics.",
+		[]string{"topics"}, nil)
+
+	maxQueueDepthDesc = prometheus.NewDesc(
+		"singlecloud_eventbus_max_queue_depth",
+		"Max number of pending events of subscribers by subscribed topics.",
+		[]string{"topics"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(publishedEvents, subscriberCollector{})
+}
+
+func observePublish(kind, eventType string) {
+	publishedEvents.WithLabelValues(kind, eventType).Inc()
+}
+
+// subscriberCollector reports pending events of subscribers on
+// scrape, subscribers with same topics are aggregated
+type subscriberCollector struct{}
+
+func (c subscriberCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- subscribersDesc
+	ch <- queuedEventsDesc
+	ch <- maxQueueDepthDesc
+}
+
+func (c subscriberCollector) Collect(ch chan<- prometheus.Metric) {
+	type queueStat struct {
+		subscribers int
+		queued      uint64
+		maxDepth    uint64
+	}
+
+	stats := make(map[string]*queueStat)
+	for _, s := range eventBus.status().Subscribers {
+		if !s.Active {
+			continue
+		}
+		topics := strings.Join(s.Topics, ",")
+		stat, ok := stats[topics]
+		if !ok {
+			stat = &queueStat{}
+			stats[topics] = stat
+		}
+		stat.subscribers += 1
+		stat.queued += s.Lag
+		if s.Lag > stat.maxDepth {
+			stat.maxDepth = s.Lag
+		}
+	}
+
+	for topics, stat := range stats {
+		ch <- prometheus.MustNewConstMetric(subscribersDesc, prometheus.GaugeValue, float64(stat.subscribers), topics)
+		ch <- prometheus.MustNewConstMetric(queuedEventsDesc, prometheus.GaugeValue, float64(stat.queued), topics)
+		ch <- prometheus.MustNewConstMetric(maxQueueDepthDesc, prometheus.GaugeValue, float64(stat.maxDepth), topics)
+	}
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/zdnscloud/gok8s/handler"
 	"github.com/zdnscloud/gok8s/predicate"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/g53"
 )
 
@@ -58,9 +57,9 @@ func newClusterDNSSyncer(zoneName *g53.Name, c cache.Cache, proxy *DnsProxy) (*C
 
 func (c *ClusterDNSSyncer) Stop() {
 	if err := c.proxy.DeleteAuthZone(c.zoneName); err != nil {
-		log.Warnf("delete zone %v from globaldns failed: %v", c.zoneName.String(false), err.Error())
+		logger.Warnf("delete zone %v from globaldns failed: %v", c.zoneName.String(false), err.Error())
 	} else {
-		log.Debugf("delete zone %v from globaldns", c.zoneName.String(false))
+		logger.Debugf("delete zone %v from globaldns", c.zoneName.String(false))
 	}
 	close(c.stopCh)
 }
@@ -79,7 +78,7 @@ func (c *ClusterDNSSyncer) initClusterDNSSyncer(cache cache.Cache) error {
 	if err := c.proxy.AddAuthZone(c.zoneName); err != nil {
 		return fmt.Errorf("add zone %s to globaldns failed: %s", c.zoneName.String(false), err.Error())
 	} else {
-		log.Debugf("add zone %v to globaldns", c.zoneName.String(false))
+		logger.Debugf("add zone %v to globaldns", c.zoneName.String(false))
 	}
 
 	for _, node := range nodes.Items {
@@ -148,7 +147,7 @@ func (c *ClusterDNSSyncer) OnNewNode(k8snode *corev1.Node) {
 func (c *ClusterDNSSyncer) addNode(k8snode *corev1.Node) {
 	nodeIP := getK8sNodeIP(k8snode)
 	if nodeIP == "" {
-		log.Warnf("new edge node %s address should not be empty", k8snode.Name)
+		logger.Warnf("new edge node %s address should not be empty", k8snode.Name)
 		return
 	}
 
@@ -158,10 +157,10 @@ func (c *ClusterDNSSyncer) addNode(k8snode *corev1.Node) {
 		}
 	}
 
-	log.Debugf("add new edge node %v with ip %v", k8snode.Name, nodeIP)
+	logger.Debugf("add new edge node %v with ip %v", k8snode.Name, nodeIP)
 	c.edgeNodeIPs = append(c.edgeNodeIPs, nodeIP)
 	if err := c.proxy.AddAuthRRs(c.zoneName, c.ingressDomains, nodeIP); err != nil {
-		log.Errorf("add ingress rrsets when add new edge node %s failed: %v", k8snode.Name, err.Error())
+		logger.Errorf("add ingress rrsets when add new edge node %s failed: %v", k8snode.Name, err.Error())
 	}
 }
 
@@ -208,7 +207,7 @@ func (c *ClusterDNSSyncer) OnDeleteNode(k8snode *corev1.Node) {
 func (c *ClusterDNSSyncer) deleteNode(k8snode *corev1.Node) {
 	nodeIP := getK8sNodeIP(k8snode)
 	if nodeIP == "" {
-		log.Warnf("delete edge node %s address should not be empty", k8snode.Name)
+		logger.Warnf("delete edge node %s address should not be empty", k8snode.Name)
 		return
 	}
 
@@ -222,13 +221,13 @@ func (c *ClusterDNSSyncer) deleteNode(k8snode *corev1.Node) {
 	}
 
 	if isExist == false {
-		log.Warnf("delete edge node %s with ip %v is unknown", k8snode.Name, nodeIP)
+		logger.Warnf("delete edge node %s with ip %v is unknown", k8snode.Name, nodeIP)
 		return
 	}
 
-	log.Debugf("delete edge node %v with ip %v", k8snode.Name, nodeIP)
+	logger.Debugf("delete edge node %v with ip %v", k8snode.Name, nodeIP)
 	if err := c.proxy.DeleteAuthRRs(c.zoneName, c.ingressDomains, nodeIP); err != nil {
-		log.Errorf("delete all ingress rrsets with edge node %s failed: %v", k8snode.Name, err.Error())
+		logger.Errorf("delete all ingress rrsets with edge node %s failed: %v", k8snode.Name, err.Error())
 	}
 }
 
@@ -238,23 +237,23 @@ func (c *ClusterDNSSyncer) OnNewIngress(k8sing *extv1beta1.Ingress) {
 		hostDomain, exist, err := c.k8sIngressHostToSCDomain(rule.Host)
 		if err == nil {
 			if exist {
-				log.Warnf("duplicate ingress host %v with zone %v", rule.Host, c.zoneName.String(false))
+				logger.Warnf("duplicate ingress host %v with zone %v", rule.Host, c.zoneName.String(false))
 				continue
 			}
 			newDomains = append(newDomains, hostDomain)
-			log.Debugf("add new ingress host domain %v to zone %v", rule.Host, c.zoneName.String(false))
+			logger.Debugf("add new ingress host domain %v to zone %v", rule.Host, c.zoneName.String(false))
 		} else if err == ErrNotInZone {
-			log.Warnf("add new ingress rrset failed: host domain %v not belong to zone %v", rule.Host, c.zoneName.String(false))
+			logger.Warnf("add new ingress rrset failed: host domain %v not belong to zone %v", rule.Host, c.zoneName.String(false))
 			continue
 		} else {
-			log.Errorf("add new ingress with host %s failed: %v", rule.Host, err.Error())
+			logger.Errorf("add new ingress with host %s failed: %v", rule.Host, err.Error())
 			return
 		}
 	}
 
 	c.ingressDomains = append(c.ingressDomains, newDomains...)
 	if err := c.proxy.AddAuthRRs(c.zoneName, newDomains, c.edgeNodeIPs...); err != nil {
-		log.Errorf("add new ingress rrsets failed: %v", err.Error())
+		logger.Errorf("add new ingress rrsets failed: %v", err.Error())
 	}
 }
 
@@ -298,7 +297,7 @@ func (c *ClusterDNSSyncer) OnUpdateIngress(old, new *extv1beta1.Ingress) {
 	c.deleteIngressDomains(oldDomains)
 	c.ingressDomains = append(c.ingressDomains, newDomains...)
 	if err := c.proxy.UpdateAuthRRs(c.zoneName, oldDomains, newDomains, c.edgeNodeIPs...); err != nil {
-		log.Errorf("update ingress %s rrsets failed: %v", old.Name, err.Error())
+		logger.Errorf("update ingress %s rrsets failed: %v", old.Name, err.Error())
 	}
 }
 
@@ -319,23 +318,23 @@ func (c *ClusterDNSSyncer) OnDeleteIngress(k8sing *extv1beta1.Ingress) {
 		hostDomain, exist, err := c.k8sIngressHostToSCDomain(rule.Host)
 		if err == nil {
 			if exist == false {
-				log.Warnf("no found ingress host %v with zone %v", rule.Host, c.zoneName.String(false))
+				logger.Warnf("no found ingress host %v with zone %v", rule.Host, c.zoneName.String(false))
 				continue
 			}
-			log.Debugf("delete ingress host domain %v from zone %v", rule.Host, c.zoneName.String(false))
+			logger.Debugf("delete ingress host domain %v from zone %v", rule.Host, c.zoneName.String(false))
 			oldDomains = append(oldDomains, hostDomain)
 		} else if err == ErrNotInZone {
-			log.Warnf("delete ingress rrset failed: host domain %v not belong to zone %v", rule.Host, c.zoneName.String(false))
+			logger.Warnf("delete ingress rrset failed: host domain %v not belong to zone %v", rule.Host, c.zoneName.String(false))
 			continue
 		} else {
-			log.Errorf("delete ingress with host %s failed: %v", rule.Host, err.Error())
+			logger.Errorf("delete ingress with host %s failed: %v", rule.Host, err.Error())
 			return
 		}
 	}
 
 	c.deleteIngressDomains(oldDomains)
 	if err := c.proxy.DeleteAuthRRs(c.zoneName, oldDomains, c.edgeNodeIPs...); err != nil {
-		log.Errorf("delete ingress %s rrsets failed: %v", k8sing.Name, err.Error())
+		logger.Errorf("delete ingress %s rrsets failed: %v", k8sing.Name, err.Error())
 	}
 }
 
@@ -353,7 +352,7 @@ func (c *ClusterDNSSyncer) diffK8sIngressRules(rules1, rules2 []extv1beta1.Ingre
 		if found == false {
 			hostDomain, err := g53.NameFromString(r1.Host)
 			if err != nil {
-				log.Errorf("parse ingress host %s failed: %v", r1.Host, err.Error())
+				logger.Errorf("parse ingress host %s failed: %v", r1.Host, err.Error())
 				return nil, err
 			}
 
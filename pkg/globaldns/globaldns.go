@@ -9,14 +9,16 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/g53"
 	"github.com/zdnscloud/gok8s/cache"
 
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
+var logger = logging.GetLogger("globaldns").Std()
+
 const (
 	KubeSystemNamespace = "kube-system"
 	ClusterConfig       = "cluster-config"
@@ -44,7 +46,7 @@ func New(httpCmdAddr string) error {
 	}
 
 	gdns := &GlobalDNS{
-		clusterEventCh:    eb.SubscribeResourceEvent(types.Cluster{}),
+		clusterEventCh:    eb.SubscribeDurableResourceEvent("globaldns", types.Cluster{}),
 		clusterDNSSyncers: make(map[string]*ClusterDNSSyncer),
 		proxy:             proxy,
 	}
@@ -55,14 +57,17 @@ func New(httpCmdAddr string) error {
 
 func (g *GlobalDNS) eventLoop() {
 	for {
-		event := <-g.clusterEventCh
+		event, ok := <-g.clusterEventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceCreateEvent:
 			cluster := e.Resource.(*types.Cluster)
 			g.lock.Lock()
 			err := g.newClusterDNSSyncer(cluster.Name, cluster.KubeProvider.GetKubeCache())
 			if err != nil {
-				log.Warnf("create globaldns syncer for cluster %s failed: %s", cluster.Name, err.Error())
+				logger.Warnf("create globaldns syncer for cluster %s failed: %s", cluster.Name, err.Error())
 			}
 			g.lock.Unlock()
 		case eb.ResourceDeleteEvent:
@@ -73,7 +78,7 @@ func (g *GlobalDNS) eventLoop() {
 				syncer.Stop()
 				delete(g.clusterDNSSyncers, clusterName)
 			} else {
-				log.Warnf("globaldns syncer is unknown cluster %s", clusterName)
+				logger.Warnf("globaldns syncer is unknown cluster %s", clusterName)
 			}
 			g.lock.Unlock()
 		}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"reflect"
+	"sync"
+
+	restresource "github.com/zdnscloud/gorest/resource"
+)
+
+// gorest decodes action input into the struct declared in resource
+// actions, which is shared by all requests of the action, so fields omitted
+// in request keep the value of previous request and maps are merged.
+// decoding happens before handler is called and isn't protected by the
+// lock, so concurrent requests of the same action could still see each
+// other's input, the lock only keeps copy and reset atomic
+var actionInputLock sync.Mutex
+
+// takeActionInput copies action input into param which should be a pointer
+// to the same type of the input, and resets the shared input so values of
+// a finished request aren't reused by later requests, it doesn't isolate
+// requests running at the same time
+func takeActionInput(ctx *restresource.Context, param interface{}) bool {
+	input := ctx.Resource.GetAction().Input
+	if input == nil {
+		return false
+	}
+
+	src := reflect.ValueOf(input)
+	dst := reflect.ValueOf(param)
+	if src.Kind() != reflect.Ptr || src.Type() != dst.Type() || src.IsNil() || dst.IsNil() {
+		return false
+	}
+
+	actionInputLock.Lock()
+	defer actionInputLock.Unlock()
+	dst.Elem().Set(src.Elem())
+	src.Elem().Set(reflect.Zero(src.Elem().Type()))
+	return true
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -14,10 +15,13 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/auditlog"
 	"github.com/zdnscloud/singlecloud/pkg/authentication"
 	"github.com/zdnscloud/singlecloud/pkg/authorization"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 	"github.com/zdnscloud/singlecloud/pkg/zke/zkelog"
 )
 
+var logger = logging.GetLogger("handler").Std()
+
 var (
 	Version = restresource.APIVersion{
 		Version: "v1",
@@ -26,32 +30,88 @@ var (
 )
 
 type App struct {
-	clusterManager *ClusterManager
-	conf           *config.SinglecloudConf
+	clusterManager    *ClusterManager
+	chartManager      *ChartManager
+	deploymentManager *DeploymentManager
+	registryManager   *RegistryManager
+	auditLogger       *auditlog.AuditLogger
+	conf              *config.SinglecloudConf
+	reloader          *config.Reloader
 }
 
-func NewApp(authenticator *authentication.Authenticator, authorizer *authorization.Authorizer, conf *config.SinglecloudConf) (*App, error) {
-	clusterMgr, err := newClusterManager(authenticator, authorizer)
+func NewApp(authenticator *authentication.Authenticator, authorizer *authorization.Authorizer, conf *config.SinglecloudConf, reloader *config.Reloader) (*App, error) {
+	clusterMgr, err := newClusterManager(authenticator, authorizer, conf.Cluster, conf.Server.EnableDebug)
 	if err != nil {
 		return nil, err
 	}
-	return &App{
+	app := &App{
 		clusterManager: clusterMgr,
 		conf:           conf,
+		reloader:       reloader,
+	}
+	reloader.Register(app)
+	return app, nil
+}
+
+// managers are created in RegisterHandler, reload before it is ignored
+func (a *App) ReloadConfig(conf *config.SinglecloudConf) (func(), error) {
+	var applies []func()
+	if a.registryManager != nil {
+		apply, err := a.registryManager.ReloadConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, apply)
+	}
+	if a.chartManager != nil {
+		apply, err := a.chartManager.ReloadConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, apply)
+	}
+
+	return func() {
+		for _, apply := range applies {
+			apply()
+		}
 	}, nil
 }
 
+// Shutdown should be called after server stops serving requests
+func (a *App) Shutdown(ctx context.Context) error {
+	if a.deploymentManager != nil {
+		if err := a.deploymentManager.shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if err := a.clusterManager.zkeManager.Shutdown(ctx); err != nil {
+		return err
+	}
+	if a.auditLogger != nil {
+		return a.auditLogger.Close()
+	}
+	return nil
+}
+
 func (a *App) RegisterHandler(router gin.IRoutes) error {
 	if err := a.registerRestHandler(router); err != nil {
 		return err
 	}
 	a.registerWSHandler(router)
+	a.registerSnapshotHandler(router)
+	a.registerConfigHandler(router)
+	a.registerEventBusHandler(router)
+	a.registerLogLevelHandler(router)
+	a.registerHealthHandler(router)
+	a.registerDebugHandler(router)
 	return nil
 }
 
 func (a *App) registerRestHandler(router gin.IRoutes) error {
 	schemas := schema.NewSchemaManager()
 	schemas.MustImport(&Version, types.Cluster{}, a.clusterManager)
+	schemas.MustImport(&Version, types.ClusterTemplate{}, a.clusterManager.templateManager)
 	schemas.MustImport(&Version, types.Alarm{}, alarm.GetAlarmManager())
 	schemas.MustImport(&Version, types.Node{}, newNodeManager(a.clusterManager))
 	schemas.MustImport(&Version, types.PodNetwork{}, newPodNetworkManager(a.clusterManager))
@@ -63,11 +123,13 @@ func (a *App) registerRestHandler(router gin.IRoutes) error {
 		return err
 	}
 	schemas.MustImport(&Version, types.Namespace{}, namespaceManager)
-	schemas.MustImport(&Version, types.Chart{}, newChartManager(a.conf.Chart.Path, a.conf.Chart.Repo))
+	a.chartManager = newChartManager(a.conf.Chart.Path, a.conf.Chart.Repo)
+	schemas.MustImport(&Version, types.Chart{}, a.chartManager)
 	schemas.MustImport(&Version, types.ConfigMap{}, newConfigMapManager(a.clusterManager))
 	schemas.MustImport(&Version, types.CronJob{}, newCronJobManager(a.clusterManager))
 	schemas.MustImport(&Version, types.DaemonSet{}, newDaemonSetManager(a.clusterManager))
-	schemas.MustImport(&Version, types.Deployment{}, newDeploymentManager(a.clusterManager))
+	a.deploymentManager = newDeploymentManager(a.clusterManager)
+	schemas.MustImport(&Version, types.Deployment{}, a.deploymentManager)
 	schemas.MustImport(&Version, types.Ingress{}, newIngressManager(a.clusterManager))
 	schemas.MustImport(&Version, types.Job{}, newJobManager(a.clusterManager))
 	schemas.MustImport(&Version, types.LimitRange{}, newLimitRangeManager(a.clusterManager))
@@ -78,6 +140,7 @@ func (a *App) registerRestHandler(router gin.IRoutes) error {
 	schemas.MustImport(&Version, types.Service{}, newServiceManager(a.clusterManager))
 	schemas.MustImport(&Version, types.StatefulSet{}, newStatefulSetManager(a.clusterManager))
 	schemas.MustImport(&Version, types.Pod{}, newPodManager(a.clusterManager))
+	schemas.MustImport(&Version, types.WorkloadRollout{}, newWorkloadRolloutManager(a.clusterManager))
 	schemas.MustImport(&Version, types.UDPIngress{}, newUDPIngressManager(a.clusterManager))
 	schemas.MustImport(&Version, types.StorageClass{}, newStorageClassManager(a.clusterManager))
 	schemas.MustImport(&Version, types.InnerService{}, newInnerServiceManager(a.clusterManager))
@@ -94,6 +157,7 @@ func (a *App) registerRestHandler(router gin.IRoutes) error {
 	if err != nil {
 		return err
 	}
+	a.auditLogger = auditLogger
 	schemas.MustImport(&Version, types.AuditLog{}, newAuditLogManager(auditLogger))
 
 	userQuotaManager, err := newUserQuotaManager(a.clusterManager)
@@ -111,6 +175,7 @@ func (a *App) registerRestHandler(router gin.IRoutes) error {
 		return err
 	}
 	schemas.MustImport(&Version, types.Registry{}, registryManager)
+	a.registryManager = registryManager
 	thresholdManager, err := newThresholdManager(a.clusterManager)
 	if err != nil {
 		return err
@@ -135,6 +200,7 @@ const (
 	WSPodLogPathTemp          = WSPrefix + "/clusters/%s/namespaces/%s/pods/%s/containers/%s/log"
 	WSTapPathTemp             = WSPrefix + "/clusters/%s/namespaces/%s/tap"
 	WSWorkFlowTaskLogPathTemp = WSPrefix + "/clusters/%s/namespaces/%s/workflows/%s/workflowtasks/%s/log"
+	WSWorkloadRolloutPathTemp = WSPrefix + "/clusters/%s/namespaces/%s/%s/%s/rollout"
 )
 
 func (a *App) registerWSHandler(router gin.IRoutes) {
@@ -157,4 +223,12 @@ func (a *App) registerWSHandler(router gin.IRoutes) {
 	router.GET(workFlowTaskLogPath, func(c *gin.Context) {
 		a.clusterManager.OpenWorkFlowTaskLog(c.Param("cluster"), c.Param("namespace"), c.Param("workflow"), c.Param("workflowtask"), c.Request, c.Writer)
 	})
+
+	for _, kind := range (types.WorkloadRollout{}).GetParents() {
+		ownerType := restresource.DefaultKindName(kind)
+		rolloutPath := fmt.Sprintf(WSWorkloadRolloutPathTemp, ":cluster", ":namespace", restresource.DefaultResourceName(kind), ":workload")
+		router.GET(rolloutPath, func(c *gin.Context) {
+			a.clusterManager.OpenWorkloadRollout(c.Param("cluster"), c.Param("namespace"), ownerType, c.Param("workload"), c.Query("timeout"), c.Request, c.Writer)
+		})
+	}
 }
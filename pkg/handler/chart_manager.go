@@ -14,12 +14,13 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/slice"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/config"
 	"github.com/zdnscloud/singlecloud/pkg/charts"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
@@ -62,11 +63,43 @@ type ChartInfo struct {
 
 type ChartManager struct {
 	chartDir string
+	repoLock sync.RWMutex
+	repoUrl  string
+
+	syncLock sync.RWMutex
+	syncStat chartSyncStatus
+}
+
+type chartSyncStatus struct {
+	Repo            string    `json:"repo"`
+	LastSyncTime    time.Time `json:"lastSyncTime,omitempty"`
+	LastSuccessTime time.Time `json:"lastSuccessTime,omitempty"`
+	LastError       string    `json:"lastError,omitempty"`
 }
 
 func newChartManager(chartDir, repoUrl string) *ChartManager {
-	go syncCloudChartsToLocal(repoUrl, path.Join(chartDir, ZcloudChartDir))
-	return &ChartManager{chartDir: chartDir}
+	m := &ChartManager{
+		chartDir: chartDir,
+		repoUrl:  repoUrl,
+	}
+	go m.syncCloudChartsToLocal(path.Join(chartDir, ZcloudChartDir))
+	return m
+}
+
+func (m *ChartManager) getRepoUrl() string {
+	m.repoLock.RLock()
+	defer m.repoLock.RUnlock()
+	return m.repoUrl
+}
+
+// new repo is used in next sync round
+func (m *ChartManager) ReloadConfig(conf *config.SinglecloudConf) (func(), error) {
+	repoUrl := conf.Chart.Repo
+	return func() {
+		m.repoLock.Lock()
+		defer m.repoLock.Unlock()
+		m.repoUrl = repoUrl
+	}, nil
 }
 
 func (m *ChartManager) List(ctx *resource.Context) (interface{}, *resterror.APIError) {
@@ -76,7 +109,7 @@ func (m *ChartManager) List(ctx *resource.Context) (interface{}, *resterror.APIE
 	}
 
 	if len(charts) == 0 {
-		log.Warnf("no found valid chart in dir %s", m.chartDir)
+		logger.Warnf("no found valid chart in dir %s", m.chartDir)
 		return nil, nil
 	}
 
@@ -141,7 +174,7 @@ func getLocalCharts(chartDir string, needSystemChart bool) (types.Charts, error)
 			if err == nil {
 				charts = append(charts, chart)
 			} else if err != ErrNoFoundVersion {
-				log.Debugf("get chart %s failed:%s", cht.Name(), err.Error())
+				logger.Debugf("get chart %s failed:%s", cht.Name(), err.Error())
 			}
 		}
 	}
@@ -217,7 +250,7 @@ func listVersions(chartDir, chartName string, needSystemChart bool) ([]types.Cha
 			versionFullDir := path.Join(chartPath, versionDir.Name())
 			if description == "" {
 				if info, err := getChartInfo(versionFullDir); err != nil {
-					log.Warnf("load chart with version %s info failed: %s", versionDir.Name(), err.Error())
+					logger.Warnf("load chart with version %s info failed: %s", versionDir.Name(), err.Error())
 					continue
 				} else if needSystemChart == false && (slice.SliceIndex(info.Keywords, KeywordZcloudSystem) != -1) {
 					break
@@ -228,7 +261,7 @@ func listVersions(chartDir, chartName string, needSystemChart bool) ([]types.Cha
 
 			config, err := charts.LoadChartConfigs(versionFullDir)
 			if err != nil {
-				log.Warnf("load chart with version %s config failed: %s", versionDir.Name(), err.Error())
+				logger.Warnf("load chart with version %s config failed: %s", versionDir.Name(), err.Error())
 				continue
 			}
 
@@ -248,11 +281,7 @@ func listVersions(chartDir, chartName string, needSystemChart bool) ([]types.Cha
 	return versions, description, nil
 }
 
-func syncCloudChartsToLocal(repoUrl, zcloudChartDir string) {
-	if repoUrl == "" {
-		return
-	}
-
+func (m *ChartManager) syncCloudChartsToLocal(zcloudChartDir string) {
 	http.DefaultClient.Transport = &http.Transport{
 		ResponseHeaderTimeout: responseHeaderTimeout,
 		TLSClientConfig: &tls.Config{
@@ -261,13 +290,38 @@ func syncCloudChartsToLocal(repoUrl, zcloudChartDir string) {
 	}
 
 	for {
-		if err := loadCloudCharts(repoUrl, zcloudChartDir); err != nil {
-			log.Warnf("load cloud charts failed: %s", err.Error())
+		if repoUrl := m.getRepoUrl(); repoUrl != "" {
+			start := time.Now()
+			err := loadCloudCharts(repoUrl, zcloudChartDir)
+			observeChartSync(start, err)
+			m.setSyncStatus(repoUrl, start, err)
+			if err != nil {
+				logger.Warnf("load cloud charts failed: %s", err.Error())
+			}
 		}
 		time.Sleep(syncChartsInterval)
 	}
 }
 
+func (m *ChartManager) setSyncStatus(repoUrl string, syncTime time.Time, err error) {
+	m.syncLock.Lock()
+	defer m.syncLock.Unlock()
+	m.syncStat.Repo = repoUrl
+	m.syncStat.LastSyncTime = syncTime
+	if err != nil {
+		m.syncStat.LastError = err.Error()
+	} else {
+		m.syncStat.LastSuccessTime = syncTime
+		m.syncStat.LastError = ""
+	}
+}
+
+func (m *ChartManager) getSyncStatus() chartSyncStatus {
+	m.syncLock.RLock()
+	defer m.syncLock.RUnlock()
+	return m.syncStat
+}
+
 func loadCloudCharts(repoUrl, zcloudChartDir string) error {
 	chartsIndex, err := getCloudChartsIndex(repoUrl)
 	if err != nil {
@@ -286,7 +340,7 @@ func loadCloudCharts(repoUrl, zcloudChartDir string) error {
 		}
 
 		if !chartFound {
-			log.Infof("found new chart %s in registry, will load it", chartName)
+			logger.Infof("found new chart %s in registry, will load it", chartName)
 			if err := os.MkdirAll(path.Join(zcloudChartDir, chartName), 0755); err != nil {
 				return err
 			}
@@ -336,7 +390,7 @@ func checkChartExistAndLoadVersionsIfNeed(repoUrl, chartDir, chartName string, l
 					}
 				}
 				if !versionFound {
-					log.Infof("found chart %s new version %s in registry, will load it", chartName, chartEntry.Version)
+					logger.Infof("found chart %s new version %s in registry, will load it", chartName, chartEntry.Version)
 					err := loadCloudChartByVersion(chartEntry.Urls, repoUrl, chartDir, chartName, chartEntry.Version)
 					if err != nil {
 						return chartFound, err
@@ -371,7 +425,7 @@ func loadCloudChartByVersion(versionUrls []string, repoUrl, chartDir, chartName,
 		return fmt.Errorf("rename chart %s from %s to %s failed: %s", chartName, unzipFileDir, chartVersion, err.Error())
 	}
 
-	log.Infof("load chart %s with version %s succeed", chartName, chartVersion)
+	logger.Infof("load chart %s with version %s succeed", chartName, chartVersion)
 	return nil
 }
 
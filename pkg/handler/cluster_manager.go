@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,12 +9,12 @@ import (
 	"github.com/zdnscloud/gorest"
 	resterr "github.com/zdnscloud/gorest/error"
 	restresource "github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/config"
 	"github.com/zdnscloud/singlecloud/pkg/authentication"
 	"github.com/zdnscloud/singlecloud/pkg/authorization"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 	"github.com/zdnscloud/singlecloud/pkg/zke"
-
-	"github.com/zdnscloud/cement/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 const (
@@ -24,28 +25,37 @@ const (
 )
 
 type ClusterManager struct {
-	authorizer    *authorization.Authorizer
-	authenticator *authentication.Authenticator
-	zkeManager    *zke.ZKEManager
+	authorizer      *authorization.Authorizer
+	authenticator   *authentication.Authenticator
+	zkeManager      *zke.ZKEManager
+	templateManager *ClusterTemplateManager
+	enableDebug     bool
 }
 
-func newClusterManager(authenticator *authentication.Authenticator, authorizer *authorization.Authorizer) (*ClusterManager, error) {
+func newClusterManager(authenticator *authentication.Authenticator, authorizer *authorization.Authorizer, conf config.ClusterConf, enableDebug bool) (*ClusterManager, error) {
 	clusterMgr := &ClusterManager{
 		authorizer:    authorizer,
 		authenticator: authenticator,
+		enableDebug:   enableDebug,
 	}
 
 	storageNodeListener := &StorageNodeListener{
 		clusters: clusterMgr,
 	}
 
-	zkeMgr, err := zke.New(storageNodeListener)
+	zkeMgr, err := zke.New(storageNodeListener, conf)
 	if err != nil {
-		log.Errorf("create zke-manager failed %s", err.Error())
+		logger.Errorf("create zke-manager failed %s", err.Error())
 		return nil, err
 	}
 
 	clusterMgr.zkeManager = zkeMgr
+
+	templateMgr, err := newClusterTemplateManager(clusterMgr)
+	if err != nil {
+		return nil, err
+	}
+	clusterMgr.templateManager = templateMgr
 	return clusterMgr, nil
 }
 
@@ -67,14 +77,34 @@ func (m *ClusterManager) Create(ctx *restresource.Context) (restresource.Resourc
 	if isAdmin(getCurrentUser(ctx)) == false {
 		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can create cluster")
 	}
-	return m.zkeManager.Create(ctx)
+
+	cluster := ctx.Resource.(*types.Cluster)
+	cluster.TrimFieldSpace()
+	m.templateManager.lock.Lock()
+	defer m.templateManager.lock.Unlock()
+	if err := m.templateManager.applyTemplate(cluster); err != nil {
+		return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("apply cluster template failed %s", err.Error()))
+	}
+	return hideClusterSecrets(m.zkeManager.Create(ctx))
 }
 
 func (m *ClusterManager) Update(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
 	if isAdmin(getCurrentUser(ctx)) == false {
 		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can update cluster")
 	}
-	return m.zkeManager.Update(ctx)
+	return hideClusterSecrets(m.zkeManager.Update(ctx))
+}
+
+// ssh key and load balance password are never returned
+func hideClusterSecrets(r restresource.Resource, err *resterr.APIError) (restresource.Resource, *resterr.APIError) {
+	if err != nil {
+		return nil, err
+	}
+
+	cp := *r.(*types.Cluster)
+	cp.SSHKey = ""
+	cp.LoadBalance.Password = ""
+	return &cp, nil
 }
 
 func (m *ClusterManager) Get(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
@@ -88,6 +118,7 @@ func (m *ClusterManager) Get(ctx *restresource.Context) (restresource.Resource,
 		return nil, resterr.NewAPIError(resterr.NotFound, "cluster doesn't exist")
 	}
 	sc := cluster.ToScCluster()
+	m.templateManager.setTemplateDrift(sc)
 	if cluster.IsReady() {
 		return getClusterInfo(cluster, sc), nil
 	}
@@ -161,20 +192,43 @@ func (m *ClusterManager) Delete(ctx *restresource.Context) *resterr.APIError {
 		return resterr.NewAPIError(resterr.PermissionDenied, "only admin can delete cluster")
 	}
 	id := ctx.Resource.GetID()
-	return m.zkeManager.Delete(id)
+	return m.zkeManager.Delete(ctx.Request.Context(), id)
 }
 
 func (m *ClusterManager) Action(ctx *restresource.Context) (interface{}, *resterr.APIError) {
+	action := ctx.Resource.GetAction()
+	id := ctx.Resource.GetID()
+	switch action.Name {
+	case types.CSHealthAction:
+		if m.authorizer.Authorize(getCurrentUser(ctx), id, "") == false {
+			return nil, resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("user has no permission to access cluster %s", id))
+		}
+		return m.zkeManager.GetHealthHistory(id)
+	case types.CSSearchAction:
+		return m.search(ctx)
+	}
+
 	if isAdmin(getCurrentUser(ctx)) == false {
 		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can call cluster action apis")
 	}
 
-	action := ctx.Resource.GetAction()
-	id := ctx.Resource.GetID()
-
 	switch action.Name {
 	case types.CSCancelAction:
 		return m.zkeManager.CancelCluster(id)
+	case types.CSPreflightAction:
+		cluster := &types.Cluster{}
+		if takeActionInput(ctx, cluster) == false {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, "action preflight param is not valid")
+		}
+		return m.zkeManager.Preflight(id, cluster)
+	case types.CSRetryNodesAction:
+		return m.zkeManager.RetryFailedNodes(ctx.Request.Context(), id)
+	case types.CSRemoveNodeAction:
+		node := &types.RemoveNode{}
+		if takeActionInput(ctx, node) == false {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, "action remove node param is not valid")
+		}
+		return m.zkeManager.RemoveNode(ctx.Request.Context(), id, node.Name)
 	default:
 		return nil, nil
 	}
@@ -243,4 +297,30 @@ func (m StorageNodeListener) IsStorageNode(cluster *zke.Cluster, node string) (b
 	return false, nil
 }
 
+func (m StorageNodeListener) RemoveNode(cluster *zke.Cluster, node string) error {
+	cli := cluster.GetKubeClient()
+	if cli == nil {
+		return fmt.Errorf("cluster %s kubeClient is nil", cluster.Name)
+	}
+
+	k8sNode, err := getK8SNode(cli, node)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !isNodeDrained(k8sNode) {
+		if err := drainNode(cli, node); err != nil {
+			return fmt.Errorf("drain node failed %s", err.Error())
+		}
+	}
+
+	if err := cli.Delete(context.TODO(), k8sNode); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 var _ zke.NodeListener = StorageNodeListener{}
@@ -0,0 +1,428 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	resterr "github.com/zdnscloud/gorest/error"
+	restresource "github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/kvzoo"
+
+	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/pkg/zke"
+)
+
+type ClusterTemplateManager struct {
+	clusters *ClusterManager
+	table    kvzoo.Table
+	lock     sync.Mutex
+}
+
+func newClusterTemplateManager(clusters *ClusterManager) (*ClusterTemplateManager, error) {
+	tn, _ := kvzoo.TableNameFromSegments(types.ClusterTemplateTable)
+	table, err := db.GetGlobalDB().CreateOrGetTable(tn)
+	if err != nil {
+		return nil, fmt.Errorf("create or get table %s failed: %s", types.ClusterTemplateTable, err.Error())
+	}
+	m := &ClusterTemplateManager{
+		clusters: clusters,
+		table:    table,
+	}
+	if err := m.encryptPlaintextPasswords(); err != nil {
+		return nil, fmt.Errorf("encrypt cluster template passwords failed: %s", err.Error())
+	}
+	return m, nil
+}
+
+func (m *ClusterTemplateManager) Create(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can create cluster template")
+	}
+
+	template := ctx.Resource.(*types.ClusterTemplate)
+	template.TrimFieldSpace()
+	if err := zke.ValidateClusterTemplate(template); err != nil {
+		return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("cluster template validate failed %s", err.Error()))
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, err := m.getRevisions(template.Name); err == nil {
+		return nil, resterr.NewAPIError(resterr.DuplicateResource, fmt.Sprintf("duplicate cluster template %s", template.Name))
+	} else if err != kvzoo.ErrNotFound {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("get cluster template %s failed %s", template.Name, err.Error()))
+	}
+
+	template.SetID(template.Name)
+	template.SetCreationTimestamp(time.Now())
+	template.Revision = 1
+	if err := m.saveRevisions(template.Name, []*types.ClusterTemplate{template}, true); err != nil {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("create cluster template %s failed %s", template.Name, err.Error()))
+	}
+	return hideTemplatePassword(template), nil
+}
+
+func (m *ClusterTemplateManager) Update(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can update cluster template")
+	}
+
+	template := ctx.Resource.(*types.ClusterTemplate)
+	template.TrimFieldSpace()
+	if err := zke.ValidateClusterTemplate(template); err != nil {
+		return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("cluster template validate failed %s", err.Error()))
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	revisions, err := m.getRevisions(template.GetID())
+	if err != nil {
+		return nil, templateDBError(template.GetID(), err)
+	}
+
+	latest := revisions[len(revisions)-1]
+	if template.LoadBalance.Password == "" {
+		template.LoadBalance.Password = latest.LoadBalance.Password
+	}
+	template.Name = latest.Name
+	template.SetCreationTimestamp(latest.GetCreationTimestamp())
+	template.Revision = latest.Revision + 1
+	if err := m.saveRevisions(template.Name, append(revisions, template), false); err != nil {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("update cluster template %s failed %s", template.Name, err.Error()))
+	}
+	return hideTemplatePassword(template), nil
+}
+
+func (m *ClusterTemplateManager) List(ctx *restresource.Context) (interface{}, *resterr.APIError) {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can list cluster templates")
+	}
+
+	tx, err := m.table.Begin()
+	if err != nil {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("list cluster templates failed %s", err.Error()))
+	}
+	defer tx.Commit()
+
+	values, err := tx.List()
+	if err != nil {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("list cluster templates failed %s", err.Error()))
+	}
+
+	var templates []*types.ClusterTemplate
+	for name, value := range values {
+		revisions, err := unmarshalRevisions(value)
+		if err != nil {
+			return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("unmarshal cluster template %s failed %s", name, err.Error()))
+		}
+		if len(revisions) > 0 {
+			templates = append(templates, hideTemplatePassword(revisions[len(revisions)-1]))
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (m *ClusterTemplateManager) Get(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can get cluster template")
+	}
+
+	template, err := m.getLatest(ctx.Resource.GetID())
+	if err != nil {
+		return nil, templateDBError(ctx.Resource.GetID(), err)
+	}
+	return hideTemplatePassword(template), nil
+}
+
+func (m *ClusterTemplateManager) Delete(ctx *restresource.Context) *resterr.APIError {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return resterr.NewAPIError(resterr.PermissionDenied, "only admin can delete cluster template")
+	}
+
+	name := ctx.Resource.GetID()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, c := range m.clusters.zkeManager.List() {
+		if c.ToScCluster().Template == name {
+			return resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("cluster template %s is used by cluster %s", name, c.Name))
+		}
+	}
+
+	tx, err := m.table.Begin()
+	if err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("delete cluster template %s failed %s", name, err.Error()))
+	}
+	defer tx.Rollback()
+
+	if err := tx.Delete(name); err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("delete cluster template %s failed %s", name, err.Error()))
+	}
+	if err := tx.Commit(); err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("delete cluster template %s failed %s", name, err.Error()))
+	}
+	return nil
+}
+
+func (m *ClusterTemplateManager) Action(ctx *restresource.Context) (interface{}, *resterr.APIError) {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can call cluster template action apis")
+	}
+
+	switch ctx.Resource.GetAction().Name {
+	case types.ActionGetHistory:
+		revisions, err := m.getRevisions(ctx.Resource.GetID())
+		if err != nil {
+			return nil, templateDBError(ctx.Resource.GetID(), err)
+		}
+		for i, t := range revisions {
+			revisions[i] = hideTemplatePassword(t)
+		}
+		return &types.ClusterTemplateHistory{Revisions: revisions}, nil
+	default:
+		return nil, resterr.NewAPIError(resterr.InvalidAction, fmt.Sprintf("action %s is unknown", ctx.Resource.GetAction().Name))
+	}
+}
+
+func (m *ClusterTemplateManager) getLatest(name string) (*types.ClusterTemplate, error) {
+	revisions, err := m.getRevisions(name)
+	if err != nil {
+		return nil, err
+	}
+	return revisions[len(revisions)-1], nil
+}
+
+func (m *ClusterTemplateManager) getRevisions(name string) ([]*types.ClusterTemplate, error) {
+	tx, err := m.table.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	value, err := tx.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := unmarshalRevisions(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, kvzoo.ErrNotFound
+	}
+	return revisions, nil
+}
+
+func (m *ClusterTemplateManager) saveRevisions(name string, revisions []*types.ClusterTemplate, isNew bool) error {
+	value, err := marshalRevisions(revisions)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.table.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if isNew {
+		err = tx.Add(name, value)
+	} else {
+		err = tx.Update(name, value)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// encryptPlaintextPasswords encrypts templates saved before encryption
+// is enabled
+func (m *ClusterTemplateManager) encryptPlaintextPasswords() error {
+	if !db.EncryptionEnabled() {
+		return nil
+	}
+
+	tx, err := m.table.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	values, err := tx.List()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		var revisions []*types.ClusterTemplate
+		if err := json.Unmarshal(value, &revisions); err != nil {
+			return err
+		}
+
+		plaintext := false
+		for _, t := range revisions {
+			if t.LoadBalance.Password != "" && !db.IsEncrypted(t.LoadBalance.Password) {
+				plaintext = true
+			}
+		}
+		if !plaintext {
+			continue
+		}
+
+		newValue, err := marshalRevisions(revisions)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(name, newValue); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func marshalRevisions(revisions []*types.ClusterTemplate) ([]byte, error) {
+	encrypted := make([]*types.ClusterTemplate, 0, len(revisions))
+	for _, t := range revisions {
+		password, err := db.EncryptString(t.LoadBalance.Password)
+		if err != nil {
+			return nil, err
+		}
+		cp := *t
+		cp.LoadBalance.Password = password
+		encrypted = append(encrypted, &cp)
+	}
+	return json.Marshal(encrypted)
+}
+
+func unmarshalRevisions(value []byte) ([]*types.ClusterTemplate, error) {
+	var revisions []*types.ClusterTemplate
+	if err := json.Unmarshal(value, &revisions); err != nil {
+		return nil, err
+	}
+
+	for _, t := range revisions {
+		password, err := db.DecryptString(t.LoadBalance.Password)
+		if err != nil {
+			return nil, err
+		}
+		t.LoadBalance.Password = password
+	}
+	return revisions, nil
+}
+
+// applyTemplate fill the cluster fields which aren't set in request with
+// the latest revision of the cluster template, caller should hold m.lock
+// so the template won't be deleted before the cluster is created
+func (m *ClusterTemplateManager) applyTemplate(c *types.Cluster) error {
+	if c.Template == "" {
+		return nil
+	}
+
+	template, err := m.getLatest(c.Template)
+	if err != nil {
+		if err == kvzoo.ErrNotFound {
+			return fmt.Errorf("cluster template %s doesn't exist", c.Template)
+		}
+		return err
+	}
+	applyTemplateToCluster(c, template)
+	return nil
+}
+
+func applyTemplateToCluster(c *types.Cluster, template *types.ClusterTemplate) {
+	if !c.IsFieldSet("network") {
+		c.Network = template.Network
+	}
+	if !c.IsFieldSet("loadBalance") {
+		c.LoadBalance = template.LoadBalance
+	}
+	if !c.IsFieldSet("sshUser") {
+		c.SSHUser = template.SSHUser
+	}
+	if !c.IsFieldSet("sshPort") {
+		c.SSHPort = template.SSHPort
+	}
+	if !c.IsFieldSet("clusterCidr") {
+		c.ClusterCidr = template.ClusterCidr
+	}
+	if !c.IsFieldSet("serviceCidr") {
+		c.ServiceCidr = template.ServiceCidr
+	}
+	if !c.IsFieldSet("clusterDomain") {
+		c.ClusterDomain = template.ClusterDomain
+	}
+	if !c.IsFieldSet("clusterDNSServiceIP") {
+		c.ClusterDNSServiceIP = template.ClusterDNSServiceIP
+	}
+	if !c.IsFieldSet("clusterUpstreamDNS") {
+		c.ClusterUpstreamDNS = template.ClusterUpstreamDNS
+	}
+	c.TemplateRevision = template.Revision
+}
+
+// setTemplateDrift report the fields of cluster which differ from the
+// latest revision of the cluster template it was built from
+func (m *ClusterTemplateManager) setTemplateDrift(c *types.Cluster) {
+	if c.Template == "" {
+		return
+	}
+
+	template, err := m.getLatest(c.Template)
+	if err != nil {
+		return
+	}
+	c.TemplateDrift = getTemplateDrift(c, template)
+}
+
+func getTemplateDrift(c *types.Cluster, template *types.ClusterTemplate) []string {
+	var drift []string
+	if c.TemplateRevision != template.Revision {
+		drift = append(drift, "revision")
+	}
+
+	lb := template.LoadBalance
+	lb.Password = ""
+	fields := []struct {
+		name         string
+		cluster      interface{}
+		fromTemplate interface{}
+	}{
+		{"network", c.Network, template.Network},
+		{"loadBalance", c.LoadBalance, lb},
+		{"sshUser", c.SSHUser, template.SSHUser},
+		{"sshPort", c.SSHPort, template.SSHPort},
+		{"clusterCidr", c.ClusterCidr, template.ClusterCidr},
+		{"serviceCidr", c.ServiceCidr, template.ServiceCidr},
+		{"clusterDomain", c.ClusterDomain, template.ClusterDomain},
+		{"clusterDNSServiceIP", c.ClusterDNSServiceIP, template.ClusterDNSServiceIP},
+		{"clusterUpstreamDNS", c.ClusterUpstreamDNS, template.ClusterUpstreamDNS},
+	}
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.cluster, f.fromTemplate) {
+			drift = append(drift, f.name)
+		}
+	}
+	return drift
+}
+
+func hideTemplatePassword(t *types.ClusterTemplate) *types.ClusterTemplate {
+	cp := *t
+	cp.LoadBalance.Password = ""
+	return &cp
+}
+
+func templateDBError(name string, err error) *resterr.APIError {
+	if err == kvzoo.ErrNotFound {
+		return resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("cluster template %s doesn't exist", name))
+	}
+	return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("get cluster template %s failed %s", name, err.Error()))
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestApplyTemplateToCluster(t *testing.T) {
+	template := &types.ClusterTemplate{
+		Revision:            2,
+		Network:             types.ClusterNetwork{Plugin: "calico"},
+		SSHUser:             "zcloud",
+		SSHPort:             "2222",
+		ClusterCidr:         "10.100.0.0/16",
+		ServiceCidr:         "10.200.0.0/16",
+		ClusterDomain:       "template.local",
+		ClusterDNSServiceIP: "10.200.0.10",
+		ClusterUpstreamDNS:  []string{"8.8.8.8"},
+	}
+
+	c := types.Cluster{}.CreateDefaultResource().(*types.Cluster)
+	body := `{"name": "test", "template": "tpl", "sshPort": "22", "clusterDomain": "cluster.local"}`
+	ut.Assert(t, json.Unmarshal([]byte(body), c) == nil, "")
+	applyTemplateToCluster(c, template)
+
+	ut.Equal(t, c.SSHPort, "22")
+	ut.Equal(t, c.ClusterDomain, "cluster.local")
+	ut.Equal(t, c.SSHUser, "zcloud")
+	ut.Equal(t, c.Network.Plugin, "calico")
+	ut.Equal(t, c.ClusterCidr, "10.100.0.0/16")
+	ut.Equal(t, c.ClusterUpstreamDNS, []string{"8.8.8.8"})
+	ut.Equal(t, c.TemplateRevision, 2)
+	ut.Equal(t, getTemplateDrift(c, template), []string{"sshPort", "clusterDomain"})
+}
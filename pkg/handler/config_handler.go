@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ReloadConfigPath = AdminPrefix + "/reloadconfig"
+)
+
+func (a *App) registerConfigHandler(router gin.IRoutes) {
+	router.POST(ReloadConfigPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can reload config"})
+			return
+		}
+
+		result, err := a.reloader.Reload()
+		if err != nil {
+			logger.Warnf("reload config failed %s", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("reload config failed %s", err.Error())})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	probeFailedEventReason = "Unhealthy"
+	maxPortNumber          = 65535
+)
+
+func scProbeToK8sProbe(probe *types.Probe) (*corev1.Probe, error) {
+	if probe == nil {
+		return nil, nil
+	}
+
+	for _, v := range []int{probe.InitialDelaySeconds, probe.TimeoutSeconds, probe.PeriodSeconds, probe.SuccessThreshold, probe.FailureThreshold} {
+		if v < 0 {
+			return nil, fmt.Errorf("delays, periods and thresholds of probe should not be negative")
+		}
+	}
+
+	var handler corev1.Handler
+	switch probe.Type {
+	case types.ProbeTypeHTTP:
+		port, err := scProbePortToK8sPort(probe)
+		if err != nil {
+			return nil, err
+		}
+		path := probe.Path
+		if path == "" {
+			path = "/"
+		} else if strings.HasPrefix(path, "/") == false {
+			return nil, fmt.Errorf("http probe path %s should start with /", path)
+		}
+		scheme := corev1.URISchemeHTTP
+		if probe.Scheme == "https" {
+			scheme = corev1.URISchemeHTTPS
+		}
+		var headers []corev1.HTTPHeader
+		for _, h := range probe.HTTPHeaders {
+			if h.Name == "" {
+				return nil, fmt.Errorf("http probe header name should not be empty")
+			}
+			headers = append(headers, corev1.HTTPHeader{Name: h.Name, Value: h.Value})
+		}
+		handler.HTTPGet = &corev1.HTTPGetAction{
+			Path:        path,
+			Port:        port,
+			Host:        probe.Host,
+			Scheme:      scheme,
+			HTTPHeaders: headers,
+		}
+	case types.ProbeTypeTCP:
+		port, err := scProbePortToK8sPort(probe)
+		if err != nil {
+			return nil, err
+		}
+		handler.TCPSocket = &corev1.TCPSocketAction{
+			Port: port,
+			Host: probe.Host,
+		}
+	case types.ProbeTypeExec:
+		if len(probe.Command) == 0 {
+			return nil, fmt.Errorf("exec probe should have command")
+		}
+		handler.Exec = &corev1.ExecAction{
+			Command: probe.Command,
+		}
+	default:
+		return nil, fmt.Errorf("probe type %s isn't supported", probe.Type)
+	}
+
+	return &corev1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: int32(probe.InitialDelaySeconds),
+		TimeoutSeconds:      int32(probe.TimeoutSeconds),
+		PeriodSeconds:       int32(probe.PeriodSeconds),
+		SuccessThreshold:    int32(probe.SuccessThreshold),
+		FailureThreshold:    int32(probe.FailureThreshold),
+	}, nil
+}
+
+func scProbePortToK8sPort(probe *types.Probe) (intstr.IntOrString, error) {
+	if probe.PortName != "" {
+		if probe.Port != 0 {
+			return intstr.IntOrString{}, fmt.Errorf("probe port and port name should not be both specified")
+		}
+		if errs := validation.IsValidPortName(probe.PortName); len(errs) > 0 {
+			return intstr.IntOrString{}, fmt.Errorf("invalid probe port name %s: %s", probe.PortName, strings.Join(errs, ","))
+		}
+		return intstr.FromString(probe.PortName), nil
+	}
+
+	if err := validateProbePort(probe.Port); err != nil {
+		return intstr.IntOrString{}, err
+	}
+	return intstr.FromInt(probe.Port), nil
+}
+
+func validateProbePort(port int) error {
+	if port <= 0 || port > maxPortNumber {
+		return fmt.Errorf("probe port %d should be between 1 and %d", port, maxPortNumber)
+	}
+	return nil
+}
+
+func k8sProbePortToScPort(port intstr.IntOrString, probe *types.Probe) {
+	if port.Type == intstr.String {
+		probe.PortName = port.StrVal
+	} else {
+		probe.Port = port.IntValue()
+	}
+}
+
+func k8sProbeToScProbe(k8sProbe *corev1.Probe) *types.Probe {
+	if k8sProbe == nil {
+		return nil
+	}
+
+	probe := &types.Probe{
+		InitialDelaySeconds: int(k8sProbe.InitialDelaySeconds),
+		TimeoutSeconds:      int(k8sProbe.TimeoutSeconds),
+		PeriodSeconds:       int(k8sProbe.PeriodSeconds),
+		SuccessThreshold:    int(k8sProbe.SuccessThreshold),
+		FailureThreshold:    int(k8sProbe.FailureThreshold),
+	}
+	if k8sProbe.HTTPGet != nil {
+		probe.Type = types.ProbeTypeHTTP
+		probe.Path = k8sProbe.HTTPGet.Path
+		k8sProbePortToScPort(k8sProbe.HTTPGet.Port, probe)
+		probe.Host = k8sProbe.HTTPGet.Host
+		probe.Scheme = strings.ToLower(string(k8sProbe.HTTPGet.Scheme))
+		for _, h := range k8sProbe.HTTPGet.HTTPHeaders {
+			probe.HTTPHeaders = append(probe.HTTPHeaders, types.HTTPHeader{Name: h.Name, Value: h.Value})
+		}
+	} else if k8sProbe.TCPSocket != nil {
+		probe.Type = types.ProbeTypeTCP
+		k8sProbePortToScPort(k8sProbe.TCPSocket.Port, probe)
+		probe.Host = k8sProbe.TCPSocket.Host
+	} else if k8sProbe.Exec != nil {
+		probe.Type = types.ProbeTypeExec
+		probe.Command = k8sProbe.Exec.Command
+	}
+	return probe
+}
+
+func scContainerProbesToK8sProbes(c types.Container, k8sContainer *corev1.Container) error {
+	for _, probe := range []*types.Probe{c.LivenessProbe, c.ReadinessProbe, c.StartupProbe} {
+		if probe != nil && probe.PortName != "" && hasExposedPort(c, probe.PortName) == false {
+			return fmt.Errorf("probe port %s isn't exposed by container %s", probe.PortName, c.Name)
+		}
+	}
+
+	var err error
+	if k8sContainer.LivenessProbe, err = scProbeToK8sProbe(c.LivenessProbe); err != nil {
+		return fmt.Errorf("invalid liveness probe: %s", err.Error())
+	}
+	if k8sContainer.ReadinessProbe, err = scProbeToK8sProbe(c.ReadinessProbe); err != nil {
+		return fmt.Errorf("invalid readiness probe: %s", err.Error())
+	}
+	if k8sContainer.StartupProbe, err = scProbeToK8sProbe(c.StartupProbe); err != nil {
+		return fmt.Errorf("invalid startup probe: %s", err.Error())
+	}
+	return nil
+}
+
+func hasExposedPort(c types.Container, name string) bool {
+	for _, p := range c.ExposedPorts {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getPodsProbeFailures returns probe failures of containers in pods,
+// kubelet records probe failure as Unhealthy event with message like
+// "Liveness probe failed: ...", result is keyed by pod uid then
+// container name, since statefulset pods reuse name
+func getPodsProbeFailures(cli client.Client, namespace string) (map[k8stypes.UID]map[string][]types.ProbeFailure, error) {
+	k8sEvents := corev1.EventList{}
+	opts := &client.ListOptions{Namespace: namespace}
+	opts.MatchingField("reason", probeFailedEventReason)
+	if err := cli.List(context.TODO(), opts, &k8sEvents); err != nil {
+		return nil, err
+	}
+
+	failures := make(map[k8stypes.UID]map[string][]types.ProbeFailure)
+	for _, e := range k8sEvents.Items {
+		if e.InvolvedObject.Kind != "Pod" || e.Reason != probeFailedEventReason {
+			continue
+		}
+
+		container := fieldPathToContainerName(e.InvolvedObject.FieldPath)
+		if container == "" {
+			continue
+		}
+
+		probe := ""
+		if i := strings.Index(e.Message, " probe failed"); i > 0 {
+			probe = strings.ToLower(e.Message[:i])
+		}
+
+		lastTimestamp := e.LastTimestamp.Time
+		if lastTimestamp.IsZero() {
+			lastTimestamp = e.EventTime.Time
+		}
+
+		podFailures, ok := failures[e.InvolvedObject.UID]
+		if ok == false {
+			podFailures = make(map[string][]types.ProbeFailure)
+			failures[e.InvolvedObject.UID] = podFailures
+		}
+		podFailures[container] = append(podFailures[container], types.ProbeFailure{
+			Probe:         probe,
+			Message:       e.Message,
+			Count:         e.Count,
+			LastTimestamp: resource.ISOTime(lastTimestamp),
+		})
+	}
+	return failures, nil
+}
+
+// field path of container is like spec.containers{name}
+func fieldPathToContainerName(fieldPath string) string {
+	for _, prefix := range []string{"spec.containers{", "spec.initContainers{"} {
+		if strings.HasPrefix(fieldPath, prefix) && strings.HasSuffix(fieldPath, "}") {
+			return fieldPath[len(prefix) : len(fieldPath)-1]
+		}
+	}
+	return ""
+}
+
+func setPodProbeFailures(pod *types.Pod, uid k8stypes.UID, failures map[k8stypes.UID]map[string][]types.ProbeFailure) {
+	podFailures, ok := failures[uid]
+	if ok == false {
+		return
+	}
+
+	for i, status := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[i].ProbeFailures = podFailures[status.Name]
+	}
+}
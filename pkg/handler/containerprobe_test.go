@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestContainerProbe(t *testing.T) {
+	probe := &types.Probe{
+		Type:             types.ProbeTypeHTTP,
+		Path:             "/healthz",
+		Port:             8080,
+		Scheme:           "https",
+		PeriodSeconds:    5,
+		FailureThreshold: 3,
+	}
+	k8sProbe, err := scProbeToK8sProbe(probe)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, k8sProbe.HTTPGet.Scheme, corev1.URISchemeHTTPS)
+	ut.Equal(t, k8sProbeToScProbe(k8sProbe), probe)
+
+	probe = &types.Probe{
+		Type:        types.ProbeTypeHTTP,
+		Path:        "/healthz",
+		PortName:    "http",
+		Host:        "127.0.0.1",
+		Scheme:      "http",
+		HTTPHeaders: []types.HTTPHeader{{Name: "Host", Value: "example.com"}},
+	}
+	k8sProbe, err = scProbeToK8sProbe(probe)
+	ut.Assert(t, err == nil, "probe with named port should be valid: %v", err)
+	ut.Equal(t, k8sProbe.HTTPGet.Port.StrVal, "http")
+	ut.Equal(t, k8sProbeToScProbe(k8sProbe), probe)
+
+	c := types.Container{Name: "web", LivenessProbe: probe}
+	ut.Assert(t, scContainerProbesToK8sProbes(c, &corev1.Container{}) != nil, "probe port should be exposed by container")
+	c.ExposedPorts = []types.ContainerPort{{Name: "http", Port: 80, Protocol: "tcp"}}
+	ut.Assert(t, scContainerProbesToK8sProbes(c, &corev1.Container{}) == nil, "probe with exposed port should be valid")
+
+	for _, invalid := range []*types.Probe{
+		{Type: types.ProbeTypeTCP, Port: 80, PortName: "http"},
+		{Type: types.ProbeTypeTCP, PortName: "Invalid_Name"},
+		{Type: types.ProbeTypeTCP},
+		{Type: types.ProbeTypeExec},
+		{Type: types.ProbeTypeHTTP, Port: 80, Path: "healthz"},
+		{Type: types.ProbeTypeTCP, Port: 80, PeriodSeconds: -1},
+	} {
+		_, err := scProbeToK8sProbe(invalid)
+		ut.Assert(t, err != nil, "%v should be invalid", invalid)
+	}
+
+	ut.Equal(t, fieldPathToContainerName("spec.containers{web}"), "web")
+	ut.Equal(t, fieldPathToContainerName("spec.volumes"), "")
+}
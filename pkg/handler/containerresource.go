@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	quotaRequestsPrefix = "requests."
+	quotaLimitsPrefix   = "limits."
+	quotaPods           = "pods"
+)
+
+// container resource could be cpu, memory, ephemeral-storage, hugepages
+// or extended resource with domain like nvidia.com/gpu
+func scContainerResourceNameToK8sResourceName(name string) (corev1.ResourceName, error) {
+	switch k8sName := corev1.ResourceName(name); {
+	case k8sName == corev1.ResourceCPU, k8sName == corev1.ResourceMemory, k8sName == corev1.ResourceEphemeralStorage:
+		return k8sName, nil
+	case strings.HasPrefix(name, corev1.ResourceHugePagesPrefix):
+		if _, err := apiresource.ParseQuantity(strings.TrimPrefix(name, corev1.ResourceHugePagesPrefix)); err != nil {
+			return "", fmt.Errorf("invalid hugepages size in %s", name)
+		}
+		return k8sName, nil
+	case isExtendedResourceName(name):
+		return k8sName, nil
+	default:
+		return "", fmt.Errorf("resource %s isn't supported", name)
+	}
+}
+
+func isExtendedResourceName(name string) bool {
+	if !strings.Contains(name, "/") || strings.Contains(name, corev1.ResourceDefaultNamespacePrefix) ||
+		strings.HasPrefix(name, quotaRequestsPrefix) {
+		return false
+	}
+	return len(validation.IsQualifiedName(name)) == 0
+}
+
+func scContainerResourceListToK8sResourceList(resourceList map[string]string) (corev1.ResourceList, error) {
+	if len(resourceList) == 0 {
+		return nil, nil
+	}
+
+	k8sResourceList := make(corev1.ResourceList)
+	for name, quantity := range resourceList {
+		k8sResourceName, err := scContainerResourceNameToK8sResourceName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		k8sQuantity, err := apiresource.ParseQuantity(quantity)
+		if err != nil {
+			return nil, fmt.Errorf("parse resource %s quantity %s failed: %s", name, quantity, err.Error())
+		}
+		if k8sQuantity.Sign() < 0 {
+			return nil, fmt.Errorf("resource %s quantity %s should not be negative", name, quantity)
+		}
+		k8sResourceList[k8sResourceName] = k8sQuantity
+	}
+	return k8sResourceList, nil
+}
+
+// default cpu and memory requests are used only when neither request
+// nor limit is specified, since k8s uses limit as default request
+func scContainerResourcesToK8sResources(resources types.ResourceRequirements) (corev1.ResourceRequirements, error) {
+	requests, err := scContainerResourceListToK8sResourceList(resources.Requests)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid requests: %s", err.Error())
+	}
+
+	limits, err := scContainerResourceListToK8sResourceList(resources.Limits)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid limits: %s", err.Error())
+	}
+
+	for name, request := range requests {
+		limit, ok := limits[name]
+		if isExtendedResourceName(string(name)) || strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+			if !ok || request.Cmp(limit) != 0 {
+				return corev1.ResourceRequirements{}, fmt.Errorf("request of %s must equal its limit", name)
+			}
+		} else if ok && request.Cmp(limit) > 0 {
+			return corev1.ResourceRequirements{}, fmt.Errorf("request %s of %s exceeds limit %s", request.String(), name, limit.String())
+		}
+	}
+
+	for name, defaultRequest := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    DefaultRequestCPU,
+		corev1.ResourceMemory: DefaultRequestMemory,
+	} {
+		_, hasRequest := requests[name]
+		_, hasLimit := limits[name]
+		if !hasRequest && !hasLimit {
+			if requests == nil {
+				requests = make(corev1.ResourceList)
+			}
+			requests[name] = apiresource.MustParse(defaultRequest)
+		}
+	}
+
+	return corev1.ResourceRequirements{
+		Requests: requests,
+		Limits:   limits,
+	}, nil
+}
+
+func k8sResourceListToSCContainerResourceList(k8sResourceList corev1.ResourceList) map[string]string {
+	if len(k8sResourceList) == 0 {
+		return nil
+	}
+
+	resourceList := make(map[string]string)
+	for name, quantity := range k8sResourceList {
+		resourceList[string(name)] = quantity.String()
+	}
+	return resourceList
+}
+
+func k8sResourcesToSCContainerResources(resources corev1.ResourceRequirements) types.ResourceRequirements {
+	return types.ResourceRequirements{
+		Requests: k8sResourceListToSCContainerResourceList(resources.Requests),
+		Limits:   k8sResourceListToSCContainerResourceList(resources.Limits),
+	}
+}
+
+// podSpecQuotaUsage returns the quota usage of pods, keys are named as
+// resource quota, like requests.cpu and limits.memory, init containers
+// run one by one before containers, so pod usage is the max of each init
+// container and the sum of containers
+func podSpecQuotaUsage(spec *corev1.PodSpec, replicas int) corev1.ResourceList {
+	usage := make(corev1.ResourceList)
+	if spec == nil || replicas <= 0 {
+		return usage
+	}
+
+	podUsage := make(corev1.ResourceList)
+	for _, c := range spec.Containers {
+		for name, quantity := range containerQuotaUsage(c) {
+			addQuantity(podUsage, name, quantity, 1)
+		}
+	}
+	for _, c := range spec.InitContainers {
+		for name, quantity := range containerQuotaUsage(c) {
+			if current, ok := podUsage[name]; !ok || quantity.Cmp(current) > 0 {
+				podUsage[name] = quantity
+			}
+		}
+	}
+
+	for name, quantity := range podUsage {
+		addQuantity(usage, name, quantity, replicas)
+	}
+	usage[quotaPods] = *apiresource.NewQuantity(int64(replicas), apiresource.DecimalSI)
+	return usage
+}
+
+func containerQuotaUsage(c corev1.Container) corev1.ResourceList {
+	usage := make(corev1.ResourceList)
+	for name, quantity := range c.Resources.Requests {
+		usage[corev1.ResourceName(quotaRequestsPrefix+string(name))] = quantity
+	}
+	for name, quantity := range c.Resources.Limits {
+		usage[corev1.ResourceName(quotaLimitsPrefix+string(name))] = quantity
+		//request of extended resource is same as limit when omitted
+		if _, ok := c.Resources.Requests[name]; !ok {
+			usage[corev1.ResourceName(quotaRequestsPrefix+string(name))] = quantity
+		}
+	}
+	return usage
+}
+
+func addQuantity(list corev1.ResourceList, name corev1.ResourceName, quantity apiresource.Quantity, replicas int) {
+	total := list[name]
+	for i := 0; i < replicas; i++ {
+		total.Add(quantity)
+	}
+	list[name] = total
+}
+
+// quota of cpu, memory and ephemeral-storage without prefix is for
+// requests
+func quotaUsageName(hardName corev1.ResourceName) corev1.ResourceName {
+	name := string(hardName)
+	if strings.HasPrefix(name, quotaRequestsPrefix) || strings.HasPrefix(name, quotaLimitsPrefix) || name == quotaPods {
+		return hardName
+	}
+	switch hardName {
+	case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return corev1.ResourceName(quotaRequestsPrefix + name)
+	}
+	return ""
+}
+
+// validateResourceQuota checks the increased usage of workload, which
+// is computed from its old and new pod template, against the hard and
+// used of namespace resource quotas
+func validateResourceQuota(cli client.Client, namespace string, oldUsage, newUsage corev1.ResourceList) error {
+	k8sQuotas, err := getResourceQuotas(cli, namespace)
+	if err != nil {
+		return fmt.Errorf("get resource quotas of namespace %s failed: %s", namespace, err.Error())
+	}
+
+	for _, quota := range k8sQuotas.Items {
+		for hardName, hard := range quota.Spec.Hard {
+			usageName := quotaUsageName(hardName)
+			if usageName == "" {
+				continue
+			}
+
+			increased := newUsage[usageName]
+			increased.Sub(oldUsage[usageName])
+			if increased.Sign() <= 0 {
+				continue
+			}
+
+			used := quota.Status.Used[hardName]
+			total := used.DeepCopy()
+			total.Add(increased)
+			if total.Cmp(hard) > 0 {
+				return fmt.Errorf("exceeded quota %s, requested %s: %s, used: %s, limited: %s",
+					quota.Name, hardName, increased.String(), used.String(), hard.String())
+			}
+		}
+	}
+	return nil
+}
+
+// quota with limits needs every container set the limit, otherwise
+// pods are rejected by k8s unless limitrange provides default limit
+func validateQuotaRequiredLimits(cli client.Client, namespace string, spec *corev1.PodSpec) error {
+	k8sQuotas, err := getResourceQuotas(cli, namespace)
+	if err != nil {
+		return fmt.Errorf("get resource quotas of namespace %s failed: %s", namespace, err.Error())
+	}
+
+	var required []corev1.ResourceName
+	for _, quota := range k8sQuotas.Items {
+		for hardName := range quota.Spec.Hard {
+			if name := string(hardName); strings.HasPrefix(name, quotaLimitsPrefix) {
+				required = append(required, corev1.ResourceName(strings.TrimPrefix(name, quotaLimitsPrefix)))
+			}
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	defaults, err := getLimitRangeDefaultLimits(cli, namespace)
+	if err != nil {
+		return err
+	}
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, name := range required {
+			if _, ok := c.Resources.Limits[name]; ok {
+				continue
+			}
+			if _, ok := defaults[name]; !ok {
+				return fmt.Errorf("container %s must specify %s limit since namespace %s has quota on it", c.Name, name, namespace)
+			}
+		}
+	}
+	return nil
+}
+
+func getLimitRangeDefaultLimits(cli client.Client, namespace string) (corev1.ResourceList, error) {
+	k8sLimitRanges, err := getLimitRanges(cli, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("get limit ranges of namespace %s failed: %s", namespace, err.Error())
+	}
+
+	defaults := make(corev1.ResourceList)
+	for _, lr := range k8sLimitRanges.Items {
+		for _, limit := range lr.Spec.Limits {
+			if limit.Type == corev1.LimitTypeContainer {
+				for name, quantity := range limit.Default {
+					defaults[name] = quantity
+				}
+			}
+		}
+	}
+	return defaults, nil
+}
+
+// validateWorkloadResources should be called before workload is
+// submitted, oldSpec is nil for new workload
+func validateWorkloadResources(cli client.Client, namespace string, oldSpec *corev1.PodSpec, oldReplicas int, newSpec *corev1.PodSpec, newReplicas int) error {
+	if err := validateQuotaRequiredLimits(cli, namespace, newSpec); err != nil {
+		return err
+	}
+	return validateResourceQuota(cli, namespace, podSpecQuotaUsage(oldSpec, oldReplicas), podSpecQuotaUsage(newSpec, newReplicas))
+}
+
+// daemonset runs one pod on each node matching its node selector
+func getPodOwnerReplicas(cli client.Client, podOwner interface{}, spec *corev1.PodSpec) (int, error) {
+	if _, ok := podOwner.(*types.DaemonSet); ok {
+		return getDaemonSetPodCount(cli, spec)
+	}
+
+	replicas := reflect.ValueOf(podOwner).Elem().FieldByName("Replicas")
+	if replicas.IsValid() {
+		return int(replicas.Int()), nil
+	}
+	return 1, nil
+}
+
+func getDaemonSetPodCount(cli client.Client, spec *corev1.PodSpec) (int, error) {
+	var nodes corev1.NodeList
+	if err := cli.List(context.TODO(), &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(spec.NodeSelector),
+	}, &nodes); err != nil {
+		return 0, fmt.Errorf("list nodes failed: %s", err.Error())
+	}
+	return len(nodes.Items), nil
+}
+
+func replicasOf(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
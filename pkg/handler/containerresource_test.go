@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestContainerResources(t *testing.T) {
+	resources, err := scContainerResourcesToK8sResources(types.ResourceRequirements{})
+	ut.Assert(t, err == nil, "empty resources should be valid")
+	ut.Equal(t, resources.Requests.Cpu().String(), DefaultRequestCPU)
+	ut.Equal(t, resources.Requests.Memory().String(), DefaultRequestMemory)
+
+	resources, err = scContainerResourcesToK8sResources(types.ResourceRequirements{
+		Limits: map[string]string{"cpu": "1", "nvidia.com/gpu": "1"},
+	})
+	ut.Assert(t, err == nil, "")
+	_, ok := resources.Requests[corev1.ResourceCPU]
+	ut.Assert(t, !ok, "default cpu request shouldn't be set when cpu limit exists")
+	ut.Equal(t, k8sResourcesToSCContainerResources(resources).Limits, map[string]string{"cpu": "1", "nvidia.com/gpu": "1"})
+
+	for _, invalid := range []types.ResourceRequirements{
+		{Requests: map[string]string{"cpu": "2"}, Limits: map[string]string{"cpu": "1"}},
+		{Requests: map[string]string{"nvidia.com/gpu": "1"}},
+		{Requests: map[string]string{"memory": "-1Gi"}},
+		{Requests: map[string]string{"memory": "1G1"}},
+		{Limits: map[string]string{"gpu": "1"}},
+		{Limits: map[string]string{"kubernetes.io/gpu": "1"}},
+	} {
+		_, err := scContainerResourcesToK8sResources(invalid)
+		ut.Assert(t, err != nil, "%v should be invalid", invalid)
+	}
+}
+
+func TestPodSpecQuotaUsage(t *testing.T) {
+	spec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("100m")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("200m"), "nvidia.com/gpu": apiresource.MustParse("1")},
+				},
+			},
+		},
+	}
+
+	usage := podSpecQuotaUsage(spec, 3)
+	for name, expected := range map[corev1.ResourceName]string{
+		"requests.cpu":            "300m",
+		"limits.cpu":              "600m",
+		"requests.nvidia.com/gpu": "3",
+		"limits.nvidia.com/gpu":   "3",
+		"pods":                    "3",
+	} {
+		quantity := usage[name]
+		ut.Equal(t, quantity.Cmp(apiresource.MustParse(expected)), 0)
+	}
+
+	spec.InitContainers = []corev1.Container{
+		{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("500m"), corev1.ResourceMemory: apiresource.MustParse("64Mi")},
+			},
+		},
+	}
+	spec.Containers = append(spec.Containers, corev1.Container{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("100m"), corev1.ResourceMemory: apiresource.MustParse("128Mi")},
+		},
+	})
+	usage = podSpecQuotaUsage(spec, 2)
+	for name, expected := range map[corev1.ResourceName]string{
+		"requests.cpu":    "1",
+		"requests.memory": "256Mi",
+		"limits.cpu":      "400m",
+		"pods":            "2",
+	} {
+		quantity := usage[name]
+		ut.Equal(t, quantity.Cmp(apiresource.MustParse(expected)), 0)
+	}
+
+	ut.Equal(t, quotaUsageName(corev1.ResourceCPU), corev1.ResourceName("requests.cpu"))
+	ut.Equal(t, quotaUsageName("limits.memory"), corev1.ResourceName("limits.memory"))
+	ut.Equal(t, quotaUsageName("services"), corev1.ResourceName(""))
+}
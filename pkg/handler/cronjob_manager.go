@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"fmt"
+	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
@@ -10,6 +11,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
@@ -17,6 +19,8 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
+const cronJobInstantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+
 type CronJobManager struct {
 	clusters *ClusterManager
 }
@@ -33,6 +37,10 @@ func (m *CronJobManager) Create(ctx *resource.Context) (resource.Resource, *rest
 
 	namespace := ctx.Resource.GetParent().GetID()
 	cronJob := ctx.Resource.(*types.CronJob)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), cronJob.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
 	err := createCronJob(cluster.GetKubeClient(), namespace, cronJob)
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
@@ -104,6 +112,47 @@ func (m *CronJobManager) Delete(ctx *resource.Context) *resterror.APIError {
 	return nil
 }
 
+func (m *CronJobManager) Action(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	cronJob := ctx.Resource.(*types.CronJob)
+	k8sCronJob, err := getCronJob(cluster.GetKubeClient(), namespace, cronJob.GetID())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found cronjob %s", cronJob.GetID()))
+		}
+		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get cronJob %s failed %s", cronJob.GetID(), err.Error()))
+	}
+
+	action := ctx.Resource.GetAction().Name
+	switch action {
+	case types.ActionSuspend, types.ActionResume:
+		patch := []byte(fmt.Sprintf(`{"spec":{"suspend":%t}}`, action == types.ActionSuspend))
+		if err := cluster.GetKubeClient().Patch(context.TODO(), k8sCronJob, k8stypes.MergePatchType, patch); err != nil {
+			return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("%s cronjob failed: %v", action, err.Error()))
+		}
+		return nil, nil
+	case types.ActionTrigger:
+		k8sJob, err := createJobFromCronJob(cluster.GetKubeClient(), k8sCronJob)
+		if err != nil {
+			return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("trigger cronjob failed: %v", err.Error()))
+		}
+		return &types.ObjectReference{
+			Kind:       "Job",
+			Namespace:  k8sJob.Namespace,
+			Name:       k8sJob.Name,
+			UID:        string(k8sJob.UID),
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+		}, nil
+	default:
+		return nil, resterror.NewAPIError(resterror.InvalidAction, fmt.Sprintf("action %s is unknown", action))
+	}
+}
+
 func getCronJob(cli client.Client, namespace, name string) (*batchv1beta1.CronJob, error) {
 	cronJob := batchv1beta1.CronJob{}
 	err := cli.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, &cronJob)
@@ -117,7 +166,7 @@ func getCronJobs(cli client.Client, namespace string) (*batchv1beta1.CronJobList
 }
 
 func createCronJob(cli client.Client, namespace string, cronJob *types.CronJob) error {
-	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(nil, cronJob.Containers, nil)
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(nil, getPodOwnerPodSpec(cronJob))
 	if err != nil {
 		return err
 	}
@@ -127,7 +176,26 @@ func createCronJob(cli client.Client, namespace string, cronJob *types.CronJob)
 		return err
 	}
 
+	podLabels := cronJobPodLabels(cronJob.Name)
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, cronJob.Scheduling, podLabels); err != nil {
+		return err
+	}
+
 	k8sPodSpec.RestartPolicy = policy
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: podLabels,
+		},
+		Spec: k8sPodSpec,
+	}
+	if err := setK8sPodTemplateSecurity(&template, cronJob.SecurityContext); err != nil {
+		return err
+	}
+
+	if err := validatePodSecurity(cli, namespace, &template); err != nil {
+		return err
+	}
+
 	k8sCronJob := &batchv1beta1.CronJob{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cronJob.Name,
@@ -135,11 +203,10 @@ func createCronJob(cli client.Client, namespace string, cronJob *types.CronJob)
 		},
 		Spec: batchv1beta1.CronJobSpec{
 			Schedule: cronJob.Schedule,
+			Suspend:  &cronJob.Suspend,
 			JobTemplate: batchv1beta1.JobTemplateSpec{
 				Spec: batchv1.JobSpec{
-					Template: corev1.PodTemplateSpec{
-						Spec: k8sPodSpec,
-					},
+					Template: template,
 				},
 			},
 		},
@@ -147,6 +214,12 @@ func createCronJob(cli client.Client, namespace string, cronJob *types.CronJob)
 	return cli.Create(context.TODO(), k8sCronJob)
 }
 
+// jobs created by cronjob have generated names, so pods of cronjob are
+// labeled with cronjob name
+func cronJobPodLabels(name string) map[string]string {
+	return map[string]string{"cronjob-name": name}
+}
+
 func deleteCronJob(cli client.Client, namespace, name string) error {
 	cronJob := &batchv1beta1.CronJob{
 		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
@@ -155,8 +228,7 @@ func deleteCronJob(cli client.Client, namespace, name string) error {
 }
 
 func k8sCronJobToScCronJob(k8sCronJob *batchv1beta1.CronJob) *types.CronJob {
-	containers, _ := k8sPodSpecToScContainersAndVCTemplates(k8sCronJob.Spec.JobTemplate.Spec.Template.Spec.Containers,
-		k8sCronJob.Spec.JobTemplate.Spec.Template.Spec.Volumes)
+	spec := k8sPodSpecToScPodSpec(k8sCronJob.Spec.JobTemplate.Spec.Template.Spec)
 
 	var objectReferences []types.ObjectReference
 	for _, objectReference := range k8sCronJob.Status.Active {
@@ -177,11 +249,16 @@ func k8sCronJobToScCronJob(k8sCronJob *batchv1beta1.CronJob) *types.CronJob {
 	}
 
 	cronJob := &types.CronJob{
-		Name:          k8sCronJob.Name,
-		Schedule:      k8sCronJob.Spec.Schedule,
-		RestartPolicy: string(k8sCronJob.Spec.JobTemplate.Spec.Template.Spec.RestartPolicy),
-		Containers:    containers,
-		Status:        cronJobStatus,
+		Name:            k8sCronJob.Name,
+		Schedule:        k8sCronJob.Spec.Schedule,
+		RestartPolicy:   string(k8sCronJob.Spec.JobTemplate.Spec.Template.Spec.RestartPolicy),
+		Containers:      spec.Containers,
+		InitContainers:  spec.InitContainers,
+		Volumes:         spec.Volumes,
+		Scheduling:      k8sPodSpecToScScheduling(k8sCronJob.Spec.JobTemplate.Spec.Template.Spec, k8sCronJob.Spec.JobTemplate.Spec.Template.Labels),
+		SecurityContext: k8sPodTemplateToScPodSecurityContext(k8sCronJob.Spec.JobTemplate.Spec.Template),
+		Suspend:         boolPtrValue(k8sCronJob.Spec.Suspend),
+		Status:          cronJobStatus,
 	}
 	cronJob.SetID(k8sCronJob.Name)
 	cronJob.SetCreationTimestamp(k8sCronJob.CreationTimestamp.Time)
@@ -190,3 +267,36 @@ func k8sCronJobToScCronJob(k8sCronJob *batchv1beta1.CronJob) *types.CronJob {
 	}
 	return cronJob
 }
+
+// createJobFromCronJob creates job like kubectl create job --from, the
+// job is owned by cronjob so it's listed in active jobs and deleted with
+// cronjob, suspended cronjob could be triggered too
+func createJobFromCronJob(cli client.Client, k8sCronJob *batchv1beta1.CronJob) (*batchv1.Job, error) {
+	name := k8sCronJob.Name
+	suffix := fmt.Sprintf("-manual-%d", time.Now().Unix())
+	if len(name)+len(suffix) > validation.DNS1123LabelMaxLength {
+		name = name[:validation.DNS1123LabelMaxLength-len(suffix)]
+	}
+
+	annotations := map[string]string{cronJobInstantiateAnnotation: "manual"}
+	for k, v := range k8sCronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	k8sJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name + suffix,
+			Namespace:   k8sCronJob.Namespace,
+			Labels:      k8sCronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(k8sCronJob, batchv1beta1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: k8sCronJob.Spec.JobTemplate.Spec,
+	}
+	if err := cli.Create(context.TODO(), k8sJob); err != nil {
+		return nil, err
+	}
+	return k8sJob, nil
+}
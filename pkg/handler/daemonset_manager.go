@@ -34,6 +34,10 @@ func (m *DaemonSetManager) Create(ctx *resource.Context) (resource.Resource, *re
 
 	namespace := ctx.Resource.GetParent().GetID()
 	daemonSet := ctx.Resource.(*types.DaemonSet)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), daemonSet.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
 	if err := createDaemonSet(cluster.GetKubeClient(), namespace, daemonSet); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			return nil, resterror.NewAPIError(resterror.DuplicateResource, fmt.Sprintf("duplicate daemonSet name %s", daemonSet.Name))
@@ -100,13 +104,44 @@ func (m *DaemonSetManager) Update(ctx *resource.Context) (resource.Resource, *re
 		return nil, apiErr
 	}
 
-	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), daemonSet.Containers, daemonSet.PersistentVolumes)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), daemonSet.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), getPodOwnerPodSpec(daemonSet))
 	if err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update daemonset failed %s", err.Error()))
 	}
 
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, daemonSet.Scheduling, k8sDaemonSet.Spec.Template.Labels); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update daemonset failed %s", err.Error()))
+	}
+
+	replicas, err := getDaemonSetPodCount(cluster.GetKubeClient(), &k8sPodSpec)
+	if err != nil {
+		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update daemonset failed %s", err.Error()))
+	}
+
+	oldReplicas := int(k8sDaemonSet.Status.DesiredNumberScheduled)
+	if err := validateWorkloadResources(cluster.GetKubeClient(), namespace, &k8sDaemonSet.Spec.Template.Spec, oldReplicas, &k8sPodSpec, replicas); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update daemonset failed %s", err.Error()))
+	}
+
 	k8sDaemonSet.Spec.Template.Spec.Containers = k8sPodSpec.Containers
+	k8sDaemonSet.Spec.Template.Spec.InitContainers = k8sPodSpec.InitContainers
 	k8sDaemonSet.Spec.Template.Spec.Volumes = k8sPodSpec.Volumes
+	k8sDaemonSet.Spec.Template.Spec.NodeSelector = k8sPodSpec.NodeSelector
+	k8sDaemonSet.Spec.Template.Spec.Affinity = k8sPodSpec.Affinity
+	k8sDaemonSet.Spec.Template.Spec.TopologySpreadConstraints = k8sPodSpec.TopologySpreadConstraints
+	k8sDaemonSet.Spec.Template.Spec.Tolerations = k8sPodSpec.Tolerations
+	if err := setK8sPodTemplateSecurity(&k8sDaemonSet.Spec.Template, daemonSet.SecurityContext); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update daemonset failed %s", err.Error()))
+	}
+
+	if err := validatePodSecurity(cluster.GetKubeClient(), namespace, &k8sDaemonSet.Spec.Template); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update daemonset failed %s", err.Error()))
+	}
+
 	k8sDaemonSet.Annotations = addWorkloadUpdateMemoToAnnotations(k8sDaemonSet.Annotations, daemonSet.Memo)
 	if err := cluster.GetKubeClient().Update(context.TODO(), k8sDaemonSet); err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update daemonset failed %s", err.Error()))
@@ -136,7 +171,7 @@ func (m *DaemonSetManager) Delete(ctx *resource.Context) *resterror.APIError {
 	if delete, ok := k8sDaemonSet.Annotations[AnnkeyForDeletePVsWhenDeleteWorkload]; ok && delete == "true" {
 		deleteWorkLoadPVCs(cluster.GetKubeClient(), namespace, k8sDaemonSet.Spec.Template.Spec.Volumes)
 	}
-	eb.PublishResourceDeleteEvent(daemonSet)
+	eb.PublishResourceDeleteEvent(ctx.Request.Context(), daemonSet)
 	return nil
 }
 
@@ -146,6 +181,10 @@ func (m *DaemonSetManager) Action(ctx *resource.Context) (interface{}, *resterro
 		return m.getDaemonsetHistory(ctx)
 	case types.ActionRollback:
 		return nil, m.rollback(ctx)
+	case types.ActionDiff:
+		return m.diff(ctx)
+	case types.ActionPause, types.ActionResume, types.ActionRestart:
+		return nil, m.pauseOrRestart(ctx)
 	default:
 		return nil, resterror.NewAPIError(resterror.InvalidAction, fmt.Sprintf("action %s is unknown", ctx.Resource.GetAction().Name))
 	}
@@ -201,10 +240,8 @@ func deleteDaemonSet(cli client.Client, namespace, name string) error {
 }
 
 func k8sDaemonSetToSCDaemonSet(cli client.Client, k8sDaemonSet *appsv1.DaemonSet) (*types.DaemonSet, *resterror.APIError) {
-	containers, templates := k8sPodSpecToScContainersAndVCTemplates(k8sDaemonSet.Spec.Template.Spec.Containers,
-		k8sDaemonSet.Spec.Template.Spec.Volumes)
-
-	pvs, err := getPVCs(cli, k8sDaemonSet.Namespace, templates)
+	spec := k8sPodSpecToScPodSpec(k8sDaemonSet.Spec.Template.Spec)
+	pvs, err := getPVCs(cli, k8sDaemonSet.Namespace, spec.PersistentVolumes)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, resterror.NewAPIError(resterror.NotFound,
@@ -222,10 +259,14 @@ func k8sDaemonSetToSCDaemonSet(cli client.Client, k8sDaemonSet *appsv1.DaemonSet
 	daemonSet := &types.DaemonSet{
 		Name:              k8sDaemonSet.Name,
 		Replicas:          int(k8sDaemonSet.Status.DesiredNumberScheduled),
-		Containers:        containers,
+		Containers:        spec.Containers,
+		InitContainers:    spec.InitContainers,
+		Volumes:           spec.Volumes,
 		AdvancedOptions:   advancedOpts,
 		PersistentVolumes: pvs,
-		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sDaemonSet.Status.NumberReady)},
+		Scheduling:        k8sPodSpecToScScheduling(k8sDaemonSet.Spec.Template.Spec, k8sDaemonSet.Spec.Template.Labels),
+		SecurityContext:   k8sPodTemplateToScPodSecurityContext(k8sDaemonSet.Spec.Template),
+		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sDaemonSet.Status.NumberReady), Paused: isDaemonSetPaused(k8sDaemonSet)},
 	}
 
 	if daemonsetIsUpdate(k8sDaemonSet) {
@@ -358,3 +399,67 @@ func (m *DaemonSetManager) rollback(ctx *resource.Context) *resterror.APIError {
 
 	return nil
 }
+
+func (m *DaemonSetManager) pauseOrRestart(ctx *resource.Context) *resterror.APIError {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	daemonSet := ctx.Resource.(*types.DaemonSet)
+	k8sDaemonSet, apiErr := getDaemonSet(cluster.GetKubeClient(), namespace, daemonSet.GetID())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var patch []byte
+	var err error
+	action := ctx.Resource.GetAction().Name
+	switch action {
+	case types.ActionPause:
+		patch, err = pauseDaemonSetPatch(k8sDaemonSet, true)
+	case types.ActionResume:
+		patch, err = pauseDaemonSetPatch(k8sDaemonSet, false)
+	case types.ActionRestart:
+		if isDaemonSetPaused(k8sDaemonSet) {
+			return resterror.NewAPIError(resterror.InvalidOption, "cannot restart a paused daemonset")
+		}
+		patch = restartWorkloadPatch()
+	}
+	if err != nil {
+		return resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("%s daemonset failed: %s", action, err.Error()))
+	}
+
+	if err := cluster.GetKubeClient().Patch(context.TODO(), k8sDaemonSet, k8stypes.MergePatchType, patch); err != nil {
+		return resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("%s daemonset failed: %v", action, err.Error()))
+	}
+
+	return nil
+}
+
+func (m *DaemonSetManager) diff(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	param := &types.DiffVersions{}
+	if takeActionInput(ctx, param) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action diff versions param is not valid")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	daemonset := ctx.Resource.(*types.DaemonSet)
+	_, controllerRevisions, err := getDaemonSetAndControllerRevisions(cluster.GetKubeClient(), namespace, daemonset.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	templates, parseErr := getControllerRevisionsTemplates(controllerRevisions)
+	if parseErr != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, parseErr.Error())
+	}
+
+	return diffVersions(templates, param)
+}
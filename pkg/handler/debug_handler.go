@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+
+	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	DebugBundlePath      = AdminPrefix + "/debug"
+	maxCPUProfileSeconds = 60
+)
+
+var debugProfiles = []string{"heap", "allocs", "block", "mutex", "threadcreate"}
+
+type runtimeInfo struct {
+	GoVersion    string           `json:"goVersion"`
+	OS           string           `json:"os"`
+	Arch         string           `json:"arch"`
+	NumCPU       int              `json:"numCPU"`
+	NumGoroutine int              `json:"numGoroutine"`
+	MemStats     runtime.MemStats `json:"memStats"`
+}
+
+type clusterDebugInfo struct {
+	Cluster *types.Cluster              `json:"cluster"`
+	Health  *types.ClusterHealthHistory `json:"health,omitempty"`
+}
+
+// debugBundle collects files into tarball, failure of one file is
+// recorded in errors.txt and won't abort the bundle
+type debugBundle struct {
+	tw   *tar.Writer
+	now  time.Time
+	errs []string
+}
+
+func (a *App) registerDebugHandler(router gin.IRoutes) {
+	router.GET(DebugBundlePath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can get debug bundle"})
+			return
+		}
+
+		cpuSeconds := 0
+		if s := c.Query("cpuprofile"); s != "" {
+			seconds, err := strconv.Atoi(s)
+			if err != nil || seconds < 0 || seconds > maxCPUProfileSeconds {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("cpuprofile should be seconds between 0 and %d", maxCPUProfileSeconds)})
+				return
+			}
+			cpuSeconds = seconds
+		}
+
+		var buf bytes.Buffer
+		if err := a.writeDebugBundle(&buf, cpuSeconds); err != nil {
+			logger.Warnf("create debug bundle failed %s", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"message": fmt.Sprintf("create debug bundle failed %s", err.Error())})
+			return
+		}
+
+		fileName := fmt.Sprintf("singlecloud-debug-%s.tar.gz", time.Now().Format("20060102150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+	})
+}
+
+func (a *App) writeDebugBundle(buf *bytes.Buffer, cpuSeconds int) error {
+	gw := gzip.NewWriter(buf)
+	b := &debugBundle{
+		tw:  tar.NewWriter(gw),
+		now: time.Now(),
+	}
+
+	if cpuSeconds > 0 {
+		b.addCPUProfile(time.Duration(cpuSeconds) * time.Second)
+	}
+	b.addProfile("goroutines.txt", "goroutine", 2)
+	for _, name := range debugProfiles {
+		b.addProfile(name+".pprof", name, 0)
+	}
+	b.addRuntimeInfo()
+
+	if data, err := yaml.Marshal(a.conf.Redacted()); err != nil {
+		b.addError("config.yaml", err)
+	} else {
+		b.addFile("config.yaml", data)
+	}
+	b.addJSON("clusters.json", a.getClusterDebugInfos())
+	b.addJSON("health.json", a.checkReadiness())
+	b.addJSON("eventbus.json", eventbus.GetStatus())
+	if m := db.GetFailoverManager(); m != nil {
+		b.addJSON("failover.json", m.GetStatus())
+	}
+	if a.chartManager != nil {
+		b.addJSON("chartsync.json", a.chartManager.getSyncStatus())
+	}
+	b.addJSON("loglevels.json", getLogLevels())
+	if len(b.errs) > 0 {
+		b.addFile("errors.txt", []byte(strings.Join(b.errs, "\n")+"\n"))
+	}
+
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func (a *App) getClusterDebugInfos() []clusterDebugInfo {
+	var infos []clusterDebugInfo
+	for _, cluster := range a.clusterManager.zkeManager.List() {
+		sc, _ := hideClusterSecrets(cluster.ToScCluster(), nil)
+		info := clusterDebugInfo{Cluster: sc.(*types.Cluster)}
+		if health, err := a.clusterManager.zkeManager.GetHealthHistory(cluster.Name); err == nil {
+			info.Health = health
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (b *debugBundle) addCPUProfile(duration time.Duration) {
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		b.addError("cpu.pprof", err)
+		return
+	}
+	time.Sleep(duration)
+	pprof.StopCPUProfile()
+	b.addFile("cpu.pprof", buf.Bytes())
+}
+
+func (b *debugBundle) addProfile(fileName, profile string, debug int) {
+	p := pprof.Lookup(profile)
+	if p == nil {
+		b.addError(fileName, fmt.Errorf("unknown profile %s", profile))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteTo(&buf, debug); err != nil {
+		b.addError(fileName, err)
+		return
+	}
+	b.addFile(fileName, buf.Bytes())
+}
+
+func (b *debugBundle) addRuntimeInfo() {
+	info := runtimeInfo{
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+	}
+	runtime.ReadMemStats(&info.MemStats)
+	b.addJSON("runtime.json", info)
+}
+
+func (b *debugBundle) addJSON(fileName string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.addError(fileName, err)
+		return
+	}
+	b.addFile(fileName, data)
+}
+
+func (b *debugBundle) addFile(fileName string, data []byte) {
+	hdr := &tar.Header{
+		Name:    fileName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		b.addError(fileName, err)
+		return
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		b.addError(fileName, err)
+	}
+}
+
+func (b *debugBundle) addError(fileName string, err error) {
+	b.errs = append(b.errs, fmt.Sprintf("%s: %s", fileName, err.Error()))
+}
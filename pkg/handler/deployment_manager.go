@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
@@ -40,10 +41,29 @@ var AnnotationsToSkip = map[string]bool{
 
 type DeploymentManager struct {
 	clusters *ClusterManager
+	stopCh   chan struct{}
+	stopped  chan struct{}
 }
 
 func newDeploymentManager(clusters *ClusterManager) *DeploymentManager {
-	return &DeploymentManager{clusters: clusters}
+	m := &DeploymentManager{
+		clusters: clusters,
+		stopCh:   make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go m.rolloutLoop()
+	return m
+}
+
+// shutdown stops syncing rollouts and waits for the running sync
+func (m *DeploymentManager) shutdown(ctx context.Context) error {
+	close(m.stopCh)
+	select {
+	case <-m.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait rollout sync to stop failed: %s", ctx.Err().Error())
+	}
 }
 
 func (m *DeploymentManager) Create(ctx *resource.Context) (resource.Resource, *resterror.APIError) {
@@ -54,6 +74,10 @@ func (m *DeploymentManager) Create(ctx *resource.Context) (resource.Resource, *r
 
 	namespace := ctx.Resource.GetParent().GetID()
 	deploy := ctx.Resource.(*types.Deployment)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), deploy.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
 	if err := createDeployment(cluster.GetKubeClient(), namespace, deploy); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			return nil, resterror.NewAPIError(resterror.DuplicateResource, fmt.Sprintf("duplicate deploy name %s", deploy.Name))
@@ -121,13 +145,43 @@ func (m *DeploymentManager) Update(ctx *resource.Context) (resource.Resource, *r
 		return nil, apiErr
 	}
 
-	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), deploy.Containers, deploy.PersistentVolumes)
+	if isDeploymentInRollout(k8sDeploy) {
+		return nil, resterror.NewAPIError(resterror.PermissionDenied, "deployment is in rollout, promote or abort it first")
+	}
+
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), deploy.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), getPodOwnerPodSpec(deploy))
 	if err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update deployment failed %s", err.Error()))
 	}
 
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, deploy.Scheduling, k8sDeploy.Spec.Template.Labels); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update deployment failed %s", err.Error()))
+	}
+
+	replicas := replicasOf(k8sDeploy.Spec.Replicas)
+	if err := validateWorkloadResources(cluster.GetKubeClient(), namespace, &k8sDeploy.Spec.Template.Spec, replicas, &k8sPodSpec, replicas); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update deployment failed %s", err.Error()))
+	}
+
 	k8sDeploy.Spec.Template.Spec.Containers = k8sPodSpec.Containers
+	k8sDeploy.Spec.Template.Spec.InitContainers = k8sPodSpec.InitContainers
 	k8sDeploy.Spec.Template.Spec.Volumes = k8sPodSpec.Volumes
+	k8sDeploy.Spec.Template.Spec.NodeSelector = k8sPodSpec.NodeSelector
+	k8sDeploy.Spec.Template.Spec.Affinity = k8sPodSpec.Affinity
+	k8sDeploy.Spec.Template.Spec.TopologySpreadConstraints = k8sPodSpec.TopologySpreadConstraints
+	k8sDeploy.Spec.Template.Spec.Tolerations = k8sPodSpec.Tolerations
+	if err := setK8sPodTemplateSecurity(&k8sDeploy.Spec.Template, deploy.SecurityContext); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update deployment failed %s", err.Error()))
+	}
+
+	if err := validatePodSecurity(cluster.GetKubeClient(), namespace, &k8sDeploy.Spec.Template); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update deployment failed %s", err.Error()))
+	}
+
 	k8sDeploy.Annotations = addWorkloadUpdateMemoToAnnotations(k8sDeploy.Annotations, deploy.Memo)
 	if err := cluster.GetKubeClient().Update(context.TODO(), k8sDeploy); err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update deployment failed %s", err.Error()))
@@ -149,6 +203,12 @@ func (m *DeploymentManager) Delete(ctx *resource.Context) *resterror.APIError {
 		return err
 	}
 
+	if state, err := getRolloutState(k8sDeploy); err == nil && state != nil {
+		if err := cleanupRollout(cluster.GetKubeClient(), k8sDeploy, state); err != nil {
+			return resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("delete deployment failed %s", err.Error()))
+		}
+	}
+
 	if err := deleteDeployment(cluster.GetKubeClient(), namespace, deploy.GetID()); err != nil {
 		return resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("delete deployment failed %s", err.Error()))
 	}
@@ -156,7 +216,7 @@ func (m *DeploymentManager) Delete(ctx *resource.Context) *resterror.APIError {
 	if delete, ok := k8sDeploy.Annotations[AnnkeyForDeletePVsWhenDeleteWorkload]; ok && delete == "true" {
 		deleteWorkLoadPVCs(cluster.GetKubeClient(), namespace, k8sDeploy.Spec.Template.Spec.Volumes)
 	}
-	eb.PublishResourceDeleteEvent(deploy)
+	eb.PublishResourceDeleteEvent(ctx.Request.Context(), deploy)
 	return nil
 }
 
@@ -166,8 +226,14 @@ func (m *DeploymentManager) Action(ctx *resource.Context) (interface{}, *resterr
 		return m.getDeploymentHistory(ctx)
 	case types.ActionRollback:
 		return nil, m.rollback(ctx)
+	case types.ActionDiff:
+		return m.diff(ctx)
 	case types.ActionSetPodCount:
 		return m.setPodCount(ctx)
+	case types.ActionStartRollout, types.ActionPauseRollout, types.ActionPromoteRollout, types.ActionAbortRollout:
+		return nil, m.rollout(ctx)
+	case types.ActionPause, types.ActionResume, types.ActionRestart:
+		return nil, m.pauseOrRestart(ctx)
 	default:
 		return nil, resterror.NewAPIError(resterror.InvalidAction, fmt.Sprintf("action %s is unknown", ctx.Resource.GetAction().Name))
 	}
@@ -224,10 +290,8 @@ func deleteDeployment(cli client.Client, namespace, name string) error {
 }
 
 func k8sDeployToSCDeploy(cli client.Client, k8sDeploy *appsv1.Deployment) (*types.Deployment, *resterror.APIError) {
-	containers, templates := k8sPodSpecToScContainersAndVCTemplates(k8sDeploy.Spec.Template.Spec.Containers,
-		k8sDeploy.Spec.Template.Spec.Volumes)
-
-	pvs, err := getPVCs(cli, k8sDeploy.Namespace, templates)
+	spec := k8sPodSpecToScPodSpec(k8sDeploy.Spec.Template.Spec)
+	pvs, err := getPVCs(cli, k8sDeploy.Namespace, spec.PersistentVolumes)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("get deploy %s pvc failed: %s", k8sDeploy.Name, err.Error()))
@@ -243,10 +307,14 @@ func k8sDeployToSCDeploy(cli client.Client, k8sDeploy *appsv1.Deployment) (*type
 	deploy := &types.Deployment{
 		Name:              k8sDeploy.Name,
 		Replicas:          int(*k8sDeploy.Spec.Replicas),
-		Containers:        containers,
+		Containers:        spec.Containers,
+		InitContainers:    spec.InitContainers,
+		Volumes:           spec.Volumes,
 		PersistentVolumes: pvs,
+		Scheduling:        k8sPodSpecToScScheduling(k8sDeploy.Spec.Template.Spec, k8sDeploy.Spec.Template.Labels),
+		SecurityContext:   k8sPodTemplateToScPodSecurityContext(k8sDeploy.Spec.Template),
 		AdvancedOptions:   advancedOpts,
-		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sDeploy.Status.ReadyReplicas)},
+		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sDeploy.Status.ReadyReplicas), Paused: k8sDeploy.Spec.Paused},
 	}
 
 	if deployIsUpdating(k8sDeploy) {
@@ -260,6 +328,7 @@ func k8sDeployToSCDeploy(cli client.Client, k8sDeploy *appsv1.Deployment) (*type
 	}
 
 	deploy.Status.Conditions = k8sWorkloadConditionsToScWorkloadConditions(k8sDeploy.Status.Conditions, true)
+	deploy.Rollout = k8sDeployToScRolloutStatus(cli, k8sDeploy)
 	deploy.SetID(k8sDeploy.Name)
 	deploy.SetCreationTimestamp(k8sDeploy.CreationTimestamp.Time)
 	if k8sDeploy.GetDeletionTimestamp() != nil {
@@ -382,6 +451,10 @@ func (m *DeploymentManager) rollback(ctx *resource.Context) *resterror.APIError
 		return resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("cannot rollback a paused deployment"))
 	}
 
+	if isDeploymentInRollout(k8sDeploy) {
+		return resterror.NewAPIError(resterror.PermissionDenied, "cannot rollback a deployment in rollout")
+	}
+
 	var rsForVersion *appsv1.ReplicaSet
 	for _, replicaset := range replicasets {
 		if v, ok := replicaset.Annotations[RevisionAnnotation]; ok {
@@ -429,8 +502,8 @@ func (m *DeploymentManager) setPodCount(ctx *resource.Context) (interface{}, *re
 		return nil, resterror.NewAPIError(resterror.NotFound, "cluster s doesn't exist")
 	}
 
-	param, ok := ctx.Resource.GetAction().Input.(*types.SetPodCount)
-	if ok == false {
+	param := &types.SetPodCount{}
+	if takeActionInput(ctx, param) == false {
 		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action set pod count param is not valid")
 	}
 
@@ -441,6 +514,18 @@ func (m *DeploymentManager) setPodCount(ctx *resource.Context) (interface{}, *re
 		return nil, err
 	}
 
+	if isDeploymentInRollout(k8sDeploy) {
+		return nil, resterror.NewAPIError(resterror.PermissionDenied, "cannot set pod count of a deployment in rollout")
+	}
+
+	//only scaling up needs more quota
+	if replicas := replicasOf(k8sDeploy.Spec.Replicas); param.Replicas > replicas {
+		spec := &k8sDeploy.Spec.Template.Spec
+		if err := validateWorkloadResources(cluster.GetKubeClient(), namespace, spec, replicas, spec, param.Replicas); err != nil {
+			return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("set deployment pod count failed %s", err.Error()))
+		}
+	}
+
 	if int(*k8sDeploy.Spec.Replicas) != param.Replicas {
 		if err := cluster.GetKubeClient().Patch(context.TODO(), k8sDeploy, k8stypes.MergePatchType,
 			[]byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, param.Replicas))); err != nil {
@@ -495,3 +580,109 @@ func getReplicaSets(cli client.Client, k8sDeploy *appsv1.Deployment) ([]appsv1.R
 
 	return replicaSetsByDeployControled, nil
 }
+
+func (m *DeploymentManager) rollout(ctx *resource.Context) *resterror.APIError {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	deploy := ctx.Resource.(*types.Deployment)
+	k8sDeploy, apiErr := getDeployment(cluster.GetKubeClient(), namespace, deploy.GetID())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var err error
+	action := ctx.Resource.GetAction()
+	switch action.Name {
+	case types.ActionStartRollout:
+		param := &types.Rollout{}
+		if takeActionInput(ctx, param) == false {
+			return resterror.NewAPIError(resterror.InvalidFormat, "action start rollout param is not valid")
+		}
+		if k8sDeploy.Spec.Paused {
+			return resterror.NewAPIError(resterror.InvalidOption, "cannot rollout a paused deployment")
+		}
+		err = startRollout(cluster.GetKubeClient(), k8sDeploy, param)
+	case types.ActionPauseRollout:
+		err = pauseRollout(cluster.GetKubeClient(), k8sDeploy)
+	case types.ActionPromoteRollout:
+		err = promoteRollout(cluster.GetKubeClient(), k8sDeploy)
+	case types.ActionAbortRollout:
+		err = abortRollout(cluster.GetKubeClient(), k8sDeploy)
+	}
+
+	if err != nil {
+		return resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("%s failed %s", action.Name, err.Error()))
+	}
+	return nil
+}
+
+func (m *DeploymentManager) pauseOrRestart(ctx *resource.Context) *resterror.APIError {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	deploy := ctx.Resource.(*types.Deployment)
+	k8sDeploy, apiErr := getDeployment(cluster.GetKubeClient(), namespace, deploy.GetID())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if isDeploymentInRollout(k8sDeploy) {
+		return resterror.NewAPIError(resterror.PermissionDenied, "deployment is in rollout, promote or abort it first")
+	}
+
+	var patch []byte
+	action := ctx.Resource.GetAction().Name
+	switch action {
+	case types.ActionPause:
+		patch = pauseDeploymentPatch(true)
+	case types.ActionResume:
+		patch = pauseDeploymentPatch(false)
+	case types.ActionRestart:
+		if k8sDeploy.Spec.Paused {
+			return resterror.NewAPIError(resterror.InvalidOption, "cannot restart a paused deployment")
+		}
+		patch = restartWorkloadPatch()
+	}
+
+	if err := cluster.GetKubeClient().Patch(context.TODO(), k8sDeploy, k8stypes.StrategicMergePatchType, patch); err != nil {
+		return resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("%s deployment failed: %v", action, err.Error()))
+	}
+
+	return nil
+}
+
+func (m *DeploymentManager) diff(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	param := &types.DiffVersions{}
+	if takeActionInput(ctx, param) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action diff versions param is not valid")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	deploy := ctx.Resource.(*types.Deployment)
+	_, replicasets, err := getDeploymentAndReplicaSets(cluster.GetKubeClient(), namespace, deploy.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[int]corev1.PodTemplateSpec)
+	for _, rs := range replicasets {
+		if v, ok := rs.Annotations[RevisionAnnotation]; ok {
+			version, _ := strconv.Atoi(v)
+			templates[version] = rs.Spec.Template
+		}
+	}
+
+	return diffVersions(templates, param)
+}
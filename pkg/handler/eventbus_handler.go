@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zdnscloud/singlecloud/pkg/eventbus"
+)
+
+const (
+	EventBusStatusPath = AdminPrefix + "/eventbus"
+)
+
+func (a *App) registerEventBusHandler(router gin.IRoutes) {
+	router.GET(EventBusStatusPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can get eventbus status"})
+			return
+		}
+		c.JSON(http.StatusOK, eventbus.GetStatus())
+	})
+}
@@ -12,7 +12,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/slice"
 	"github.com/zdnscloud/gok8s/client"
 	resterr "github.com/zdnscloud/gorest/error"
@@ -83,20 +82,23 @@ func newFluentBitConfigManager(clusters *ClusterManager) *FluentBitConfigManager
 }
 
 func (m *FluentBitConfigManager) eventLoop() {
-	eventCh := eb.SubscribeResourceEvent(
+	eventCh := eb.SubscribeDurableResourceEvent("fluentbitconfig",
 		types.Namespace{},
 		types.Deployment{},
 		types.DaemonSet{},
 		types.StatefulSet{})
 	for {
-		event := <-eventCh
+		event, ok := <-eventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceDeleteEvent:
 			switch r := e.Resource.(type) {
 			case *types.Namespace:
 				cluster := m.clusters.GetClusterForSubResource(r)
 				if cluster == nil {
-					log.Warnf("get cluster nil for namespace %s", r.GetID())
+					logger.Warnf("get cluster nil for namespace %s", r.GetID())
 					continue
 				}
 				go deleteNamespaceFluentBitConfig(cluster.GetKubeClient(), r.GetID())
@@ -105,7 +107,7 @@ func (m *FluentBitConfigManager) eventLoop() {
 				name := namespace + "_" + r.GetType() + "_" + r.GetID()
 				cluster := m.clusters.GetClusterForSubResource(r)
 				if cluster == nil {
-					log.Warnf("get cluster nil for workload %s", name)
+					logger.Warnf("get cluster nil for workload %s", name)
 					continue
 				}
 				go deleteWorkLoadFluentBitConfig(cluster.GetKubeClient(), name)
@@ -501,17 +503,17 @@ func getFluentBitConfigsAndConfigMap(cli client.Client) (map[string]*types.Fluen
 func deleteAllFluentBitConfig(cli client.Client) {
 	confs, cm, err := getFluentBitConfigsAndConfigMap(cli)
 	if err != nil {
-		log.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
+		logger.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
 		return
 	}
 	namespaces, err := getNamespaces(cli)
 	if err != nil {
-		log.Warnf("list namespace failed: %s", err.Error())
+		logger.Warnf("list namespace failed: %s", err.Error())
 		return
 	}
 	for _, namespace := range namespaces.Items {
 		if err := DelFBCForNamespace(cli, namespace.Name, confs, cm); err != nil {
-			log.Warnf("delete fluent-bit config for namespace %s failed: %s", namespace.Name, err.Error())
+			logger.Warnf("delete fluent-bit config for namespace %s failed: %s", namespace.Name, err.Error())
 		}
 	}
 	return
@@ -520,11 +522,11 @@ func deleteAllFluentBitConfig(cli client.Client) {
 func deleteNamespaceFluentBitConfig(cli client.Client, namespace string) {
 	confs, cm, err := getFluentBitConfigsAndConfigMap(cli)
 	if err != nil {
-		log.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
+		logger.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
 		return
 	}
 	if err := DelFBCForNamespace(cli, namespace, confs, cm); err != nil {
-		log.Warnf("delete fluent-bit config for namespace %s failed: %s", namespace, err.Error())
+		logger.Warnf("delete fluent-bit config for namespace %s failed: %s", namespace, err.Error())
 	}
 	return
 }
@@ -543,11 +545,11 @@ func DelFBCForNamespace(cli client.Client, namespace string, confs map[string]*t
 func deleteWorkLoadFluentBitConfig(cli client.Client, name string) {
 	cm, err := getFluentBitConfigMap(cli)
 	if err != nil {
-		log.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
+		logger.Warnf("get fluent-bit config and configmap failed: %s", err.Error())
 		return
 	}
 	if err := deleteConfig(cli, name, cm); err != nil {
-		log.Warnf("delete fluent-bit config %s failed: %s", name, err.Error())
+		logger.Warnf("delete fluent-bit config %s failed: %s", name, err.Error())
 	}
 	return
 }
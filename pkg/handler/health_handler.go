@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zdnscloud/singlecloud/config"
+	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/eventbus"
+)
+
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+
+	maxSubscriberLag = eventbus.EventBufLen
+	//chart sync is treated as stale after missing several rounds
+	staleChartSyncRounds = 5
+)
+
+type checkStatus string
+
+const (
+	checkOK      checkStatus = "ok"
+	checkWarning checkStatus = "warning"
+	checkFailed  checkStatus = "failed"
+)
+
+type healthCheck struct {
+	Name    string      `json:"name"`
+	Status  checkStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
+}
+
+// healthReport fails only when critical checks fail, the others are
+// reported as warning, so problems of external services like chart
+// repo won't take singlecloud out of service
+type healthReport struct {
+	Status checkStatus   `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+func newHealthReport() *healthReport {
+	return &healthReport{Status: checkOK}
+}
+
+func (r *healthReport) add(name string, err error, critical bool) {
+	check := healthCheck{Name: name, Status: checkOK}
+	if err != nil {
+		check.Message = err.Error()
+		if critical {
+			check.Status = checkFailed
+			r.Status = checkFailed
+		} else {
+			check.Status = checkWarning
+			if r.Status == checkOK {
+				r.Status = checkWarning
+			}
+		}
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+func (a *App) registerHealthHandler(router gin.IRoutes) {
+	RegisterHealthzHandler(router)
+	router.GET(ReadyzPath, func(c *gin.Context) {
+		writeHealthReport(c, a.checkReadiness())
+	})
+}
+
+func writeHealthReport(c *gin.Context, report *healthReport) {
+	code := http.StatusOK
+	if report.Status == checkFailed {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+// RegisterHealthzHandler serves liveness, it's also used by standby
+// instance which doesn't serve api before it becomes master
+func RegisterHealthzHandler(router gin.IRoutes) {
+	router.GET(HealthzPath, func(c *gin.Context) {
+		writeHealthReport(c, checkLiveness())
+	})
+}
+
+// liveness only means the process serves http, db may be blocked by
+// restore or failover, which shouldn't get singlecloud restarted
+func checkLiveness() *healthReport {
+	return newHealthReport()
+}
+
+func (a *App) checkReadiness() *healthReport {
+	report := checkLiveness()
+	report.add("db", db.CheckHealth(), true)
+	report.add("replicationRole", checkReplicationRole(a.conf), true)
+	report.add("replication", checkReplication(), false)
+	status := eventbus.GetStatus()
+	report.add("eventbus", checkEventBus(status), true)
+	report.add("eventbusSubscribers", checkEventBusSubscribers(status), false)
+	report.add("eventbusEventLoss", checkEventBusEventLoss(status), true)
+	if a.chartManager != nil {
+		report.add("chartSync", checkChartSync(a.chartManager.getRepoUrl(), a.chartManager.getSyncStatus(), time.Now()), false)
+	}
+	return report
+}
+
+// only master serves requests, slave db is read only
+func checkReplicationRole(conf *config.SinglecloudConf) error {
+	role := conf.DB.Role
+	if m := db.GetFailoverManager(); m != nil {
+		role = m.GetStatus().Role
+	}
+	if role != config.Master {
+		return fmt.Errorf("instance is %s, only master serves requests", role)
+	}
+	return nil
+}
+
+func checkReplication() error {
+	m := db.GetFailoverManager()
+	if m == nil {
+		return nil
+	}
+
+	status := m.GetStatus()
+	if status.ReplicationError != "" {
+		return fmt.Errorf("replication to %s is broken for %dms: %s", status.Peer, status.ReplicationLag, status.ReplicationError)
+	}
+	return nil
+}
+
+func checkEventBus(status eventbus.Status) error {
+	if status.Closed {
+		return fmt.Errorf("eventbus is shutdown")
+	}
+	return nil
+}
+
+func checkEventBusSubscribers(status eventbus.Status) error {
+	var lagging []string
+	for _, s := range status.Subscribers {
+		if s.Active && s.Lag > maxSubscriberLag {
+			lagging = append(lagging, fmt.Sprintf("%s(%d)", s.Name, s.Lag))
+		}
+	}
+	if len(lagging) > 0 {
+		return fmt.Errorf("subscribers lag behind more than %d events: %v", maxSubscriberLag, lagging)
+	}
+	return nil
+}
+
+// durable subscriber lagging too much loses events of current run,
+// its state can't be trusted until singlecloud is restarted and the
+// resources are loaded again
+func checkEventBusEventLoss(status eventbus.Status) error {
+	var lost []string
+	for _, s := range status.Subscribers {
+		if s.Durable && s.Dropped > 0 {
+			lost = append(lost, fmt.Sprintf("%s(%d)", s.Name, s.Dropped))
+		}
+	}
+	if len(lost) > 0 {
+		return fmt.Errorf("durable subscribers lost events: %v", lost)
+	}
+	return nil
+}
+
+// new repo isn't checked until it's synced once
+func checkChartSync(repo string, status chartSyncStatus, now time.Time) error {
+	if repo == "" || status.Repo != repo {
+		return nil
+	}
+
+	if status.LastError != "" {
+		return fmt.Errorf("sync charts from %s failed: %s", status.Repo, status.LastError)
+	}
+	if now.Sub(status.LastSuccessTime) > staleChartSyncRounds*syncChartsInterval {
+		return fmt.Errorf("charts haven't been synced from %s since %s", status.Repo, status.LastSuccessTime.Format(time.RFC3339))
+	}
+	return nil
+}
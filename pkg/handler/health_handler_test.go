@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	ut "github.com/zdnscloud/cement/unittest"
+
+	"github.com/zdnscloud/singlecloud/pkg/eventbus"
+)
+
+func TestHealthReport(t *testing.T) {
+	report := newHealthReport()
+	report.add("a", nil, true)
+	ut.Equal(t, report.Status, checkOK)
+	report.add("b", fmt.Errorf("slow"), false)
+	ut.Equal(t, report.Status, checkWarning)
+	report.add("c", fmt.Errorf("down"), true)
+	ut.Equal(t, report.Status, checkFailed)
+	report.add("d", fmt.Errorf("slow"), false)
+	ut.Equal(t, report.Status, checkFailed)
+	ut.Equal(t, report.Checks[2], healthCheck{Name: "c", Status: checkFailed, Message: "down"})
+}
+
+func TestCheckChartSync(t *testing.T) {
+	now := time.Now()
+	repo := "http://repo.example.com"
+	ut.Assert(t, checkChartSync("", chartSyncStatus{}, now) == nil, "no repo should be ok")
+	ut.Assert(t, checkChartSync(repo, chartSyncStatus{}, now) == nil, "repo not synced yet should be ok")
+	ut.Assert(t, checkChartSync(repo, chartSyncStatus{Repo: repo, LastSuccessTime: now}, now) == nil, "")
+	ut.Assert(t, checkChartSync(repo, chartSyncStatus{Repo: repo, LastError: "timeout"}, now) != nil, "failed sync should warn")
+	ut.Assert(t, checkChartSync(repo, chartSyncStatus{Repo: repo, LastSuccessTime: now.Add(-time.Hour)}, now) != nil, "stale sync should warn")
+}
+
+func TestCheckEventBusSubscribers(t *testing.T) {
+	status := eventbus.Status{
+		Subscribers: []eventbus.SubscriberStatus{
+			{Name: "alarm", Active: true, Lag: 1},
+			{Name: "old", Active: false, Lag: maxSubscriberLag + 1},
+		},
+	}
+	ut.Assert(t, checkEventBusSubscribers(status) == nil, "inactive subscriber is ignored")
+	status.Subscribers[0].Lag = maxSubscriberLag + 1
+	ut.Assert(t, checkEventBusSubscribers(status) != nil, "lagging subscriber should warn")
+	ut.Assert(t, checkEventBus(eventbus.Status{Closed: true}) != nil, "closed eventbus should fail")
+
+	ut.Assert(t, checkEventBusEventLoss(status) == nil, "")
+	status.Subscribers = append(status.Subscribers, eventbus.SubscriberStatus{Name: "ws", Active: true, Dropped: 10})
+	ut.Assert(t, checkEventBusEventLoss(status) == nil, "anonymous subscriber losing events is ignored")
+	status.Subscribers[0].Durable = true
+	status.Subscribers[0].Dropped = 1
+	ut.Assert(t, checkEventBusEventLoss(status) != nil, "durable subscriber losing events should fail")
+}
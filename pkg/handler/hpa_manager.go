@@ -19,7 +19,6 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -58,7 +57,7 @@ type HorizontalPodAutoscalerManager struct {
 func newHorizontalPodAutoscalerManager(clusters *ClusterManager) *HorizontalPodAutoscalerManager {
 	m := &HorizontalPodAutoscalerManager{
 		clusters:        clusters,
-		workloadEventCh: eb.SubscribeResourceEvent(types.Deployment{}, types.StatefulSet{}),
+		workloadEventCh: eb.SubscribeDurableResourceEvent("hpa", types.Deployment{}, types.StatefulSet{}),
 	}
 	go m.eventLoop()
 	return m
@@ -66,11 +65,14 @@ func newHorizontalPodAutoscalerManager(clusters *ClusterManager) *HorizontalPodA
 
 func (m *HorizontalPodAutoscalerManager) eventLoop() {
 	for {
-		event := <-m.workloadEventCh
+		event, ok := <-m.workloadEventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceDeleteEvent:
 			if err := m.deleteHPAWhenDeleteWorkload(e.Resource); err != nil {
-				log.Warnf("delete workload %s/%s hpa failed: %s", e.Resource.GetType(), e.Resource.GetID(), err.Error())
+				logger.Warnf("delete workload %s/%s hpa failed: %s", e.Resource.GetType(), e.Resource.GetID(), err.Error())
 			}
 		}
 	}
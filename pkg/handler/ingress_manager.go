@@ -12,7 +12,6 @@ import (
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -244,11 +243,11 @@ func k8sIngressToSCIngress(k8sIngress *extv1beta1.Ingress) *types.Ingress {
 			if err == nil {
 				ingress.MaxBodySize = bs
 			} else {
-				log.Warnf("ingress has invalide annotation %s", bss)
+				logger.Warnf("ingress has invalide annotation %s", bss)
 			}
 			ingress.MaxBodySizeUnit = bss[l-1:]
 		} else {
-			log.Warnf("ingress has invalide annotation %s", bss)
+			logger.Warnf("ingress has invalide annotation %s", bss)
 		}
 	}
 
@@ -32,6 +32,10 @@ func (m *JobManager) Create(ctx *resource.Context) (resource.Resource, *resterro
 
 	namespace := ctx.Resource.GetParent().GetID()
 	job := ctx.Resource.(*types.Job)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), job.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
 	err := createJob(cluster.GetKubeClient(), namespace, job)
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
@@ -118,7 +122,7 @@ func getJobs(cli client.Client, namespace string) (*batchv1.JobList, error) {
 }
 
 func createJob(cli client.Client, namespace string, job *types.Job) error {
-	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(nil, job.Containers, nil)
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(nil, getPodOwnerPodSpec(job))
 	if err != nil {
 		return err
 	}
@@ -128,7 +132,22 @@ func createJob(cli client.Client, namespace string, job *types.Job) error {
 		return err
 	}
 
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, job.Scheduling, jobPodLabels(job.Name)); err != nil {
+		return err
+	}
+
 	k8sPodSpec.RestartPolicy = policy
+	template := corev1.PodTemplateSpec{
+		Spec: k8sPodSpec,
+	}
+	if err := setK8sPodTemplateSecurity(&template, job.SecurityContext); err != nil {
+		return err
+	}
+
+	if err := validatePodSecurity(cli, namespace, &template); err != nil {
+		return err
+	}
+
 	k8sJob := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      job.Name,
@@ -136,16 +155,19 @@ func createJob(cli client.Client, namespace string, job *types.Job) error {
 		},
 		Spec: batchv1.JobSpec{
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{"job-name": job.Name},
-			},
-			Template: corev1.PodTemplateSpec{
-				Spec: k8sPodSpec,
+				MatchLabels: jobPodLabels(job.Name),
 			},
+			Template: template,
 		},
 	}
 	return cli.Create(context.TODO(), k8sJob)
 }
 
+// job controller adds job-name label to pods of job
+func jobPodLabels(name string) map[string]string {
+	return map[string]string{"job-name": name}
+}
+
 func deleteJob(cli client.Client, namespace, name string) error {
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
@@ -154,8 +176,7 @@ func deleteJob(cli client.Client, namespace, name string) error {
 }
 
 func k8sJobToSCJob(k8sJob *batchv1.Job) *types.Job {
-	containers, _ := k8sPodSpecToScContainersAndVCTemplates(k8sJob.Spec.Template.Spec.Containers,
-		k8sJob.Spec.Template.Spec.Volumes)
+	spec := k8sPodSpecToScPodSpec(k8sJob.Spec.Template.Spec)
 
 	var conditions []types.JobCondition
 	for _, condition := range k8sJob.Status.Conditions {
@@ -179,10 +200,14 @@ func k8sJobToSCJob(k8sJob *batchv1.Job) *types.Job {
 	}
 
 	job := &types.Job{
-		Name:          k8sJob.Name,
-		RestartPolicy: string(k8sJob.Spec.Template.Spec.RestartPolicy),
-		Containers:    containers,
-		Status:        jobStatus,
+		Name:            k8sJob.Name,
+		RestartPolicy:   string(k8sJob.Spec.Template.Spec.RestartPolicy),
+		Containers:      spec.Containers,
+		InitContainers:  spec.InitContainers,
+		Volumes:         spec.Volumes,
+		Scheduling:      k8sPodSpecToScScheduling(k8sJob.Spec.Template.Spec, jobPodLabels(k8sJob.Name)),
+		SecurityContext: k8sPodTemplateToScPodSecurityContext(k8sJob.Spec.Template),
+		Status:          jobStatus,
 	}
 	job.SetID(k8sJob.Name)
 	job.SetCreationTimestamp(k8sJob.CreationTimestamp.Time)
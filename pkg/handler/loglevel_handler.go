@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+const (
+	LogLevelPath = AdminPrefix + "/loglevels"
+)
+
+type logLevels struct {
+	Default string                `json:"default"`
+	Modules []logging.ModuleLevel `json:"modules"`
+}
+
+// setLogLevel changes default level when module is empty, module level
+// is reset to default when level is empty
+type setLogLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+func (a *App) registerLogLevelHandler(router gin.IRoutes) {
+	router.GET(LogLevelPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can get log levels"})
+			return
+		}
+		c.JSON(http.StatusOK, getLogLevels())
+	})
+
+	router.PUT(LogLevelPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can set log level"})
+			return
+		}
+
+		var req setLogLevel
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid log level request " + err.Error()})
+			return
+		}
+
+		if req.Module != "" && req.Level == "" {
+			logging.ResetLevel(req.Module)
+			c.JSON(http.StatusOK, getLogLevels())
+			return
+		}
+
+		level, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if req.Module == "" {
+			logging.SetDefaultLevel(level)
+		} else {
+			logging.SetLevel(req.Module, level)
+		}
+		c.JSON(http.StatusOK, getLogLevels())
+	})
+}
+
+func getLogLevels() logLevels {
+	return logLevels{
+		Default: logging.GetDefaultLevel().String(),
+		Modules: logging.GetLevels(),
+	}
+}
@@ -0,0 +1,759 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	asv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	"github.com/zdnscloud/gok8s/client"
+	resterror "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	manifestDocumentSeparator = "---\n"
+	annPVBindCompleted        = "pv.kubernetes.io/bind-completed"
+	annPVBoundByController    = "pv.kubernetes.io/bound-by-controller"
+)
+
+// supported kinds in the order they are created, resources referenced by
+// others come first
+var manifestKinds = []schema.GroupVersionKind{
+	corev1.SchemeGroupVersion.WithKind("LimitRange"),
+	corev1.SchemeGroupVersion.WithKind("ResourceQuota"),
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+	corev1.SchemeGroupVersion.WithKind("Secret"),
+	corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+	appsv1.SchemeGroupVersion.WithKind("DaemonSet"),
+	batchv1.SchemeGroupVersion.WithKind("Job"),
+	batchv1beta1.SchemeGroupVersion.WithKind("CronJob"),
+	extv1beta1.SchemeGroupVersion.WithKind("Ingress"),
+	asv2beta2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"),
+}
+
+var manifestAnnotationsToSkip = map[string]bool{
+	AnnKeyForRolloutState:  true,
+	annPVBindCompleted:     true,
+	annPVBoundByController: true,
+	annStorageProvisioner:  true,
+}
+
+type manifestObject struct {
+	kind   string
+	name   string
+	object runtime.Object
+}
+
+type manifestUnsupportedError string
+
+func (e manifestUnsupportedError) Error() string {
+	return string(e)
+}
+
+func manifestKindOrder(kind string) int {
+	for i, gvk := range manifestKinds {
+		if gvk.Kind == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *NamespaceManager) importYAML(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetID()
+	if m.clusters.authorizer.Authorize(getCurrentUser(ctx), cluster.Name, namespace) == false {
+		return nil, resterror.NewAPIError(resterror.Unauthorized, "user has no permission to access the namespace")
+	}
+
+	manifest := &types.Manifest{}
+	if takeActionInput(ctx, manifest) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action importYAML input invalid")
+	}
+	if strings.TrimSpace(manifest.Yaml) == "" {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "yaml is empty")
+	}
+
+	objs, result, err := parseManifest(namespace, manifest.Yaml)
+	if err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, fmt.Sprintf("parse yaml failed %s", err.Error()))
+	}
+
+	applyManifestObjects(cluster.GetKubeClient(), namespace, getCurrentUser(ctx), objs, result)
+	return result, nil
+}
+
+// parseManifest splits yaml into objects sorted by creation order, documents
+// could't be decoded or belong to other namespace are reported as failed
+func parseManifest(namespace, manifest string) ([]manifestObject, *types.ManifestImportResult, error) {
+	result := &types.ManifestImportResult{}
+	var objs []manifestObject
+	reader := yamlutil.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		var partial metav1.PartialObjectMetadata
+		if err := yaml.Unmarshal(doc, &partial); err != nil {
+			return nil, nil, err
+		}
+
+		if partial.Kind == "" {
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			return nil, nil, fmt.Errorf("document has no kind")
+		}
+
+		res := types.ManifestResource{Kind: partial.Kind, Name: partial.Name}
+		if partial.Namespace != "" && partial.Namespace != namespace {
+			res.Message = fmt.Sprintf("namespace %s doesn't match %s", partial.Namespace, namespace)
+			result.Failed = append(result.Failed, res)
+			continue
+		}
+
+		if manifestKindOrder(partial.Kind) == -1 {
+			res.Message = fmt.Sprintf("kind %s isn't supported", partial.Kind)
+			result.Unsupported = append(result.Unsupported, res)
+			continue
+		}
+
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			if runtime.IsNotRegisteredError(err) {
+				res.Message = fmt.Sprintf("%s isn't supported", partial.APIVersion)
+				result.Unsupported = append(result.Unsupported, res)
+			} else {
+				res.Message = err.Error()
+				result.Failed = append(result.Failed, res)
+			}
+			continue
+		}
+
+		if accessor, err := meta.Accessor(obj); err == nil {
+			accessor.SetNamespace(namespace)
+		}
+		objs = append(objs, manifestObject{kind: partial.Kind, name: partial.Name, object: obj})
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		return manifestKindOrder(objs[i].kind) < manifestKindOrder(objs[j].kind)
+	})
+	return objs, result, nil
+}
+
+// pvcs are created with the deployment or daemonset mounting them, creation
+// of one resource failed won't stop others
+func applyManifestObjects(cli client.Client, namespace, user string, objs []manifestObject, result *types.ManifestImportResult) {
+	pvcs := make(map[string]*corev1.PersistentVolumeClaim)
+	for _, obj := range objs {
+		if pvc, ok := obj.object.(*corev1.PersistentVolumeClaim); ok {
+			pvcs[pvc.Name] = pvc
+		}
+	}
+
+	usedPVCs := make(map[string]bool)
+	for _, obj := range objs {
+		if _, ok := obj.object.(*corev1.PersistentVolumeClaim); ok {
+			continue
+		}
+
+		res := types.ManifestResource{Kind: obj.kind, Name: obj.name}
+		scResource, err := k8sObjectToSCResource(obj.object, pvcs, usedPVCs)
+		if err != nil {
+			res.Message = err.Error()
+			if _, ok := err.(manifestUnsupportedError); ok {
+				result.Unsupported = append(result.Unsupported, res)
+			} else {
+				result.Failed = append(result.Failed, res)
+			}
+			continue
+		}
+
+		if apiErr := validatePodVolumesPermission(user, getPodOwnerPodSpec(scResource).Volumes); apiErr != nil {
+			res.Message = apiErr.Message
+			result.Failed = append(result.Failed, res)
+			continue
+		}
+
+		if err := createSCResource(cli, namespace, scResource); err != nil {
+			res.Message = err.Error()
+			result.Failed = append(result.Failed, res)
+			continue
+		}
+		result.Created = append(result.Created, res)
+	}
+
+	for _, obj := range objs {
+		if _, ok := obj.object.(*corev1.PersistentVolumeClaim); ok && usedPVCs[obj.name] == false {
+			result.Unsupported = append(result.Unsupported, types.ManifestResource{
+				Kind:    obj.kind,
+				Name:    obj.name,
+				Message: "persistentvolumeclaim isn't used by any deployment or daemonset",
+			})
+		}
+	}
+}
+
+func k8sObjectToSCResource(obj runtime.Object, pvcs map[string]*corev1.PersistentVolumeClaim, usedPVCs map[string]bool) (interface{}, error) {
+	switch o := obj.(type) {
+	case *corev1.LimitRange:
+		return k8sLimitRangeToSCLimitRange(o), nil
+	case *corev1.ResourceQuota:
+		return k8sResourceQuotaToSCResourceQuota(o), nil
+	case *corev1.ConfigMap:
+		if len(o.BinaryData) != 0 {
+			return nil, manifestUnsupportedError("configmap binaryData isn't supported")
+		}
+		return k8sConfigMapToSCConfigMap(o), nil
+	case *corev1.Secret:
+		if o.Type != "" && o.Type != corev1.SecretTypeOpaque {
+			return nil, manifestUnsupportedError(fmt.Sprintf("secret type %s isn't supported", o.Type))
+		}
+		for k, v := range o.StringData {
+			if o.Data == nil {
+				o.Data = make(map[string][]byte)
+			}
+			o.Data[k] = []byte(v)
+		}
+		return k8sSecretToSCSecret(o), nil
+	case *corev1.Service:
+		if len(o.Spec.Selector) != 1 || o.Spec.Selector["app"] != o.Name {
+			return nil, manifestUnsupportedError(fmt.Sprintf("service selector should be app=%s", o.Name))
+		}
+		service := k8sServiceToSCService(o)
+		service.Headless = o.Spec.ClusterIP == NoneClusterIP
+		return service, nil
+	case *appsv1.Deployment:
+		if err := checkManifestPodSpec(o.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		spec := k8sPodSpecToScPodSpec(o.Spec.Template.Spec)
+		pvs, err := manifestPersistentVolumes(spec.PersistentVolumes, pvcs, usedPVCs)
+		if err != nil {
+			return nil, err
+		}
+		replicas := 1
+		if o.Spec.Replicas != nil {
+			replicas = int(*o.Spec.Replicas)
+		}
+		return &types.Deployment{
+			Name:              o.Name,
+			Replicas:          replicas,
+			Containers:        spec.Containers,
+			InitContainers:    spec.InitContainers,
+			Volumes:           spec.Volumes,
+			PersistentVolumes: pvs,
+			Scheduling:        k8sPodSpecToScScheduling(o.Spec.Template.Spec, o.Spec.Template.Labels),
+			SecurityContext:   k8sPodTemplateToScPodSecurityContext(o.Spec.Template),
+			AdvancedOptions:   k8sWorkloadToScAdvancedOptions(o.Annotations, o.Spec.Template.Annotations),
+		}, nil
+	case *appsv1.DaemonSet:
+		if err := checkManifestPodSpec(o.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		spec := k8sPodSpecToScPodSpec(o.Spec.Template.Spec)
+		pvs, err := manifestPersistentVolumes(spec.PersistentVolumes, pvcs, usedPVCs)
+		if err != nil {
+			return nil, err
+		}
+		return &types.DaemonSet{
+			Name:              o.Name,
+			Containers:        spec.Containers,
+			InitContainers:    spec.InitContainers,
+			Volumes:           spec.Volumes,
+			PersistentVolumes: pvs,
+			Scheduling:        k8sPodSpecToScScheduling(o.Spec.Template.Spec, o.Spec.Template.Labels),
+			SecurityContext:   k8sPodTemplateToScPodSecurityContext(o.Spec.Template),
+			AdvancedOptions:   k8sWorkloadToScAdvancedOptions(o.Annotations, o.Spec.Template.Annotations),
+		}, nil
+	case *appsv1.StatefulSet:
+		if err := checkManifestPodSpec(o.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		if o.Spec.Replicas == nil {
+			replicas := int32(1)
+			o.Spec.Replicas = &replicas
+		}
+		statefulset := k8sStatefulSetToSCStatefulSet(o)
+		statefulset.Status = types.WorkloadStatus{}
+		return statefulset, nil
+	case *batchv1.Job:
+		job := k8sJobToSCJob(o)
+		job.Status = types.JobStatus{}
+		return job, nil
+	case *batchv1beta1.CronJob:
+		cronJob := k8sCronJobToScCronJob(o)
+		cronJob.Status = types.CronJobStatus{}
+		return cronJob, nil
+	case *networkingv1beta1.Ingress:
+		var ingress extv1beta1.Ingress
+		if err := convertByJSON(o, &ingress); err != nil {
+			return nil, err
+		}
+		return k8sObjectToSCResource(&ingress, pvcs, usedPVCs)
+	case *extv1beta1.Ingress:
+		if o.Spec.Backend != nil {
+			return nil, manifestUnsupportedError("ingress default backend isn't supported")
+		}
+		for _, rule := range o.Spec.Rules {
+			if rule.HTTP == nil {
+				return nil, manifestUnsupportedError("ingress rule without http paths isn't supported")
+			}
+		}
+		return k8sIngressToSCIngress(o), nil
+	case *asv2beta2.HorizontalPodAutoscaler:
+		hpa := k8sHpaToScHpa(o)
+		hpa.Status = types.HorizontalPodAutoscalerStatus{}
+		return hpa, nil
+	default:
+		return nil, manifestUnsupportedError(fmt.Sprintf("%s isn't supported", obj.GetObjectKind().GroupVersionKind().String()))
+	}
+}
+
+// checkManifestPodSpec reports pod spec fields which can't be kept by
+// workload of singlecloud, default values set by apiserver are allowed so
+// exported yaml could be imported again
+func checkManifestPodSpec(spec corev1.PodSpec) error {
+	var fields []string
+	if spec.ServiceAccountName != "" && spec.ServiceAccountName != "default" {
+		fields = append(fields, "serviceAccountName")
+	} else if spec.DeprecatedServiceAccount != "" && spec.DeprecatedServiceAccount != "default" {
+		fields = append(fields, "serviceAccount")
+	}
+	if spec.AutomountServiceAccountToken != nil {
+		fields = append(fields, "automountServiceAccountToken")
+	}
+	if len(spec.ImagePullSecrets) != 0 {
+		fields = append(fields, "imagePullSecrets")
+	}
+	if len(spec.HostAliases) != 0 {
+		fields = append(fields, "hostAliases")
+	}
+	if spec.DNSPolicy != "" && spec.DNSPolicy != corev1.DNSClusterFirst {
+		fields = append(fields, "dnsPolicy")
+	}
+	if spec.DNSConfig != nil {
+		fields = append(fields, "dnsConfig")
+	}
+	if spec.Hostname != "" {
+		fields = append(fields, "hostname")
+	}
+	if spec.Subdomain != "" {
+		fields = append(fields, "subdomain")
+	}
+	if spec.PriorityClassName != "" {
+		fields = append(fields, "priorityClassName")
+	}
+	if spec.RuntimeClassName != nil {
+		fields = append(fields, "runtimeClassName")
+	}
+	if spec.SchedulerName != "" && spec.SchedulerName != corev1.DefaultSchedulerName {
+		fields = append(fields, "schedulerName")
+	}
+	if spec.TerminationGracePeriodSeconds != nil && *spec.TerminationGracePeriodSeconds != corev1.DefaultTerminationGracePeriodSeconds {
+		fields = append(fields, "terminationGracePeriodSeconds")
+	}
+	if spec.ActiveDeadlineSeconds != nil {
+		fields = append(fields, "activeDeadlineSeconds")
+	}
+	if spec.ShareProcessNamespace != nil {
+		fields = append(fields, "shareProcessNamespace")
+	}
+	for _, v := range spec.Volumes {
+		if isPodVolume(v) == false && v.ConfigMap == nil && v.Secret == nil && v.PersistentVolumeClaim == nil && v.EmptyDir == nil {
+			fields = append(fields, fmt.Sprintf("volumes[%s]", v.Name))
+		}
+	}
+
+	if len(fields) != 0 {
+		return manifestUnsupportedError(fmt.Sprintf("fields %s aren't supported", strings.Join(fields, ", ")))
+	}
+	return nil
+}
+
+// emptydir keeps as template, pvc is created from the one in yaml
+func manifestPersistentVolumes(templates []types.PersistentVolumeTemplate, pvcs map[string]*corev1.PersistentVolumeClaim, usedPVCs map[string]bool) ([]types.PersistentVolumeTemplate, error) {
+	var pvs []types.PersistentVolumeTemplate
+	for _, template := range templates {
+		if template.StorageClassName == types.StorageClassNameTemp {
+			pvs = append(pvs, template)
+			continue
+		}
+
+		k8sPVC, ok := pvcs[template.Name]
+		if ok == false {
+			return nil, fmt.Errorf("persistentvolumeclaim %s isn't in yaml", template.Name)
+		}
+
+		pvc := k8sPVCToSCPVC(k8sPVC)
+		pvs = append(pvs, types.PersistentVolumeTemplate{
+			Name:             pvc.Name,
+			Size:             pvc.RequestStorageSize,
+			StorageClassName: pvc.StorageClassName,
+		})
+		usedPVCs[template.Name] = true
+	}
+	return pvs, nil
+}
+
+func k8sWorkloadToScAdvancedOptions(annotations, podAnnotations map[string]string) types.AdvancedOptions {
+	var advancedOpts types.AdvancedOptions
+	if opts, ok := annotations[AnnkeyForWordloadAdvancedoption]; ok {
+		json.Unmarshal([]byte(opts), &advancedOpts)
+	}
+	advancedOpts.ExposedMetric = k8sAnnotationsToScExposedMetric(podAnnotations)
+	return advancedOpts
+}
+
+func convertByJSON(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+func createSCResource(cli client.Client, namespace string, r interface{}) error {
+	switch sr := r.(type) {
+	case *types.LimitRange:
+		return createLimitRange(cli, namespace, sr)
+	case *types.ResourceQuota:
+		return createResourceQuota(cli, namespace, sr)
+	case *types.ConfigMap:
+		return createConfigMap(cli, namespace, sr)
+	case *types.Secret:
+		return createSecret(cli, namespace, sr)
+	case *types.Service:
+		if err := validateIfLoadBalancerService(sr); err != nil {
+			return err
+		}
+		return createService(cli, namespace, sr)
+	case *types.Deployment:
+		return createDeployment(cli, namespace, sr)
+	case *types.StatefulSet:
+		return createStatefulSet(cli, namespace, sr)
+	case *types.DaemonSet:
+		return createDaemonSet(cli, namespace, sr)
+	case *types.Job:
+		return createJob(cli, namespace, sr)
+	case *types.CronJob:
+		return createCronJob(cli, namespace, sr)
+	case *types.Ingress:
+		return createIngress(cli, namespace, sr)
+	case *types.HorizontalPodAutoscaler:
+		return createHPA(cli, namespace, sr)
+	default:
+		return fmt.Errorf("unknown resource %T", r)
+	}
+}
+
+func (m *NamespaceManager) exportYAML(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetID()
+	if m.clusters.authorizer.Authorize(getCurrentUser(ctx), cluster.Name, namespace) == false {
+		return nil, resterror.NewAPIError(resterror.Unauthorized, "user has no permission to access the namespace")
+	}
+
+	param := &types.ManifestExport{}
+	if takeActionInput(ctx, param) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action exportYAML input invalid")
+	}
+
+	cli := cluster.GetKubeClient()
+	var objs []runtime.Object
+	var apiErr *resterror.APIError
+	if len(param.Resources) == 0 {
+		objs, apiErr = getNamespaceManifestObjects(cli, namespace)
+	} else {
+		objs, apiErr = getManifestObjects(cli, namespace, param.Resources)
+	}
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	pvcs, err := getManifestPVCs(cli, namespace, objs)
+	if err != nil {
+		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get persistentvolumeclaims failed %s", err.Error()))
+	}
+	objs = append(objs, pvcs...)
+	sort.SliceStable(objs, func(i, j int) bool {
+		return manifestKindOrder(manifestObjectKind(objs[i])) < manifestKindOrder(manifestObjectKind(objs[j]))
+	})
+
+	manifest, err := k8sObjectsToManifest(objs)
+	if err != nil {
+		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("export yaml failed %s", err.Error()))
+	}
+	return &types.Manifest{Yaml: manifest}, nil
+}
+
+// objects created by controller, tokens of service account and temporary
+// deployments of rollout are skipped, pvcs are exported with workloads
+func getNamespaceManifestObjects(cli client.Client, namespace string) ([]runtime.Object, *resterror.APIError) {
+	var objs []runtime.Object
+	for _, gvk := range manifestKinds {
+		if gvk.Kind == "PersistentVolumeClaim" {
+			continue
+		}
+
+		list, err := scheme.Scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err != nil {
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("new %s list failed %s", gvk.Kind, err.Error()))
+		}
+
+		if err := cli.List(context.TODO(), &client.ListOptions{Namespace: namespace}, list); err != nil {
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("list %s failed %s", gvk.Kind, err.Error()))
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("extract %s list failed %s", gvk.Kind, err.Error()))
+		}
+
+		for _, item := range items {
+			if isManifestObjectExported(item) {
+				objs = append(objs, item)
+			}
+		}
+	}
+	return objs, nil
+}
+
+func isManifestObjectExported(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	if metav1.GetControllerOf(accessor) != nil {
+		return false
+	}
+
+	if _, ok := accessor.GetAnnotations()[AnnKeyForRolloutOwner]; ok {
+		return false
+	}
+
+	if secret, ok := obj.(*corev1.Secret); ok && secret.Type == corev1.SecretTypeServiceAccountToken {
+		return false
+	}
+	return true
+}
+
+func getManifestObjects(cli client.Client, namespace string, resources []types.ManifestResource) ([]runtime.Object, *resterror.APIError) {
+	var objs []runtime.Object
+	for _, r := range resources {
+		order := manifestKindOrder(r.Kind)
+		if order == -1 {
+			return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("kind %s isn't supported", r.Kind))
+		}
+
+		obj, err := scheme.Scheme.New(manifestKinds[order])
+		if err != nil {
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("new %s failed %s", r.Kind, err.Error()))
+		}
+
+		if err := cli.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: r.Name}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found %s %s", r.Kind, r.Name))
+			}
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get %s %s failed %s", r.Kind, r.Name, err.Error()))
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// pvcs mounted by deployments and daemonsets, the ones of statefulset are
+// created from its volume claim templates
+func getManifestPVCs(cli client.Client, namespace string, objs []runtime.Object) ([]runtime.Object, error) {
+	exported := make(map[string]bool)
+	var names []string
+	for _, obj := range objs {
+		var volumes []corev1.Volume
+		switch o := obj.(type) {
+		case *corev1.PersistentVolumeClaim:
+			exported[o.Name] = true
+		case *appsv1.Deployment:
+			volumes = o.Spec.Template.Spec.Volumes
+		case *appsv1.DaemonSet:
+			volumes = o.Spec.Template.Spec.Volumes
+		}
+
+		for _, v := range volumes {
+			if v.PersistentVolumeClaim != nil {
+				names = append(names, v.PersistentVolumeClaim.ClaimName)
+			}
+		}
+	}
+
+	var pvcs []runtime.Object
+	for _, name := range names {
+		if exported[name] {
+			continue
+		}
+
+		k8sPVC, err := getPersistentVolumeClaim(cli, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		exported[name] = true
+		pvcs = append(pvcs, k8sPVC)
+	}
+	return pvcs, nil
+}
+
+func manifestObjectKind(obj runtime.Object) string {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return ""
+	}
+	return gvks[0].Kind
+}
+
+func k8sObjectsToManifest(objs []runtime.Object) (string, error) {
+	var docs []string
+	for _, obj := range objs {
+		doc, err := k8sObjectToCleanYAML(obj)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+	return strings.Join(docs, manifestDocumentSeparator), nil
+}
+
+func k8sObjectToCleanYAML(obj runtime.Object) (string, error) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", err
+	}
+
+	content["apiVersion"] = gvks[0].GroupVersion().String()
+	content["kind"] = gvks[0].Kind
+	cleanManifestObject(gvks[0].Kind, content)
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// remove status and fields set by server, so the yaml could be imported to
+// other namespace or cluster
+func cleanManifestObject(kind string, content map[string]interface{}) {
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"namespace", "uid", "resourceVersion", "generation", "creationTimestamp",
+			"selfLink", "managedFields", "deletionTimestamp", "deletionGracePeriodSeconds", "ownerReferences", "finalizers"} {
+			delete(metadata, field)
+		}
+		deleteMapKeys(metadata, "annotations", func(key string) bool {
+			return AnnotationsToSkip[key] || manifestAnnotationsToSkip[key]
+		})
+		deleteMapKeys(metadata, "labels", func(key string) bool {
+			return key == LabelKeyForRollout
+		})
+	}
+
+	spec, _ := content["spec"].(map[string]interface{})
+	switch kind {
+	case "Service":
+		if spec != nil && spec["clusterIP"] != NoneClusterIP {
+			delete(spec, "clusterIP")
+		}
+	case "PersistentVolumeClaim":
+		if spec != nil {
+			delete(spec, "volumeName")
+		}
+	case "Job":
+		// selector and labels are generated by server
+		if spec != nil {
+			delete(spec, "selector")
+			if template, ok := spec["template"].(map[string]interface{}); ok {
+				if metadata, ok := template["metadata"].(map[string]interface{}); ok {
+					deleteMapKeys(metadata, "labels", func(key string) bool {
+						return key == "controller-uid" || key == "job-name"
+					})
+				}
+			}
+		}
+	}
+	deleteNilValues(content)
+}
+
+func deleteMapKeys(content map[string]interface{}, field string, shouldDelete func(string) bool) {
+	m, ok := content[field].(map[string]interface{})
+	if ok == false {
+		return
+	}
+
+	for key := range m {
+		if shouldDelete(key) {
+			delete(m, key)
+		}
+	}
+	if len(m) == 0 {
+		delete(content, field)
+	}
+}
+
+func deleteNilValues(content map[string]interface{}) {
+	for key, value := range content {
+		switch v := value.(type) {
+		case nil:
+			delete(content, key)
+		case map[string]interface{}:
+			deleteNilValues(v)
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					deleteNilValues(m)
+				}
+			}
+		}
+	}
+}
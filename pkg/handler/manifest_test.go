@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const testManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        volumeMounts:
+        - name: data
+          mountPath: /data
+      volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: web-data
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: web-data
+spec:
+  storageClassName: lvm
+  resources:
+    requests:
+      storage: 1Gi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: conf
+  namespace: other
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-svc
+spec:
+  selector:
+    app: web
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+`
+
+func TestParseManifest(t *testing.T) {
+	objs, result, err := parseManifest("default", testManifest)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, len(result.Failed), 1)
+	ut.Equal(t, result.Failed[0].Name, "conf")
+	ut.Equal(t, len(result.Unsupported), 1)
+	ut.Equal(t, result.Unsupported[0].Kind, "ServiceMonitor")
+
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.kind+"/"+obj.name)
+	}
+	ut.Equal(t, kinds, []string{"PersistentVolumeClaim/web-data", "Service/web", "Service/web-svc", "Deployment/web"})
+
+	pvcs := map[string]*corev1.PersistentVolumeClaim{"web-data": objs[0].object.(*corev1.PersistentVolumeClaim)}
+	usedPVCs := make(map[string]bool)
+	_, err = k8sObjectToSCResource(objs[2].object, pvcs, usedPVCs)
+	_, ok := err.(manifestUnsupportedError)
+	ut.Assert(t, ok, "service with other selector should be unsupported")
+
+	r, err := k8sObjectToSCResource(objs[3].object, pvcs, usedPVCs)
+	ut.Assert(t, err == nil, "")
+	deploy := r.(*types.Deployment)
+	ut.Equal(t, deploy.Replicas, 1)
+	ut.Equal(t, deploy.PersistentVolumes, []types.PersistentVolumeTemplate{
+		{Name: "web-data", Size: "1Gi", StorageClassName: "lvm"},
+	})
+	ut.Assert(t, usedPVCs["web-data"], "")
+
+	_, err = k8sObjectToSCResource(objs[3].object, nil, usedPVCs)
+	ut.Assert(t, err != nil, "deployment with pvc not in yaml should fail")
+}
+
+func TestCheckManifestPodSpec(t *testing.T) {
+	grace := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	spec := corev1.PodSpec{
+		ServiceAccountName:            "default",
+		DNSPolicy:                     corev1.DNSClusterFirst,
+		SchedulerName:                 corev1.DefaultSchedulerName,
+		TerminationGracePeriodSeconds: &grace,
+		Volumes: []corev1.Volume{
+			{Name: "conf", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}},
+		},
+	}
+	ut.Assert(t, checkManifestPodSpec(spec) == nil, "default values should be supported")
+
+	spec.ServiceAccountName = "operator"
+	spec.Volumes = append(spec.Volumes, corev1.Volume{Name: "nfs", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{}}})
+	err := checkManifestPodSpec(spec)
+	_, ok := err.(manifestUnsupportedError)
+	ut.Assert(t, ok, "")
+	ut.Equal(t, err.Error(), "fields serviceAccountName, volumes[nfs] aren't supported")
+}
+
+func TestK8sObjectToCleanYAML(t *testing.T) {
+	k8sService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web",
+			Namespace:       "default",
+			UID:             "123",
+			ResourceVersion: "10",
+			Annotations:     map[string]string{LastAppliedConfigAnnotation: "{}"},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.43.0.10",
+			Selector:  map[string]string{"app": "web"},
+			Ports:     []corev1.ServicePort{{Port: 80}},
+		},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{}},
+	}
+
+	doc, err := k8sObjectToCleanYAML(k8sService)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, doc, strings.Join([]string{
+		"apiVersion: v1",
+		"kind: Service",
+		"metadata:",
+		"  name: web",
+		"spec:",
+		"  ports:",
+		"  - port: 80",
+		"    targetPort: 0",
+		"  selector:",
+		"    app: web",
+		"",
+	}, "\n"))
+}
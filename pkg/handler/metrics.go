@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	chartSyncDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "singlecloud",
+		Subsystem: "chart",
+		Name:      "sync_duration_seconds",
+		Help:      "Duration of syncing charts from chart repo.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+
+	chartSyncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "singlecloud",
+		Subsystem: "chart",
+		Name:      "sync_errors_total",
+		Help:      "Number of failed chart syncs.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(chartSyncDuration, chartSyncErrors)
+}
+
+func observeChartSync(start time.Time, err error) {
+	chartSyncDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		chartSyncErrors.Inc()
+	}
+}
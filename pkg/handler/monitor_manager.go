@@ -11,7 +11,6 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/zke"
 
 	appv1beta1 "github.com/zdnscloud/application-operator/pkg/apis/app/v1beta1"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterr "github.com/zdnscloud/gorest/error"
 	restresource "github.com/zdnscloud/gorest/resource"
@@ -246,7 +245,7 @@ func ensureApplicationSucceedOrDie(cli client.Client, appName string) {
 		app, err := getApplication(cli, ZCloudNamespace, appName, true)
 		if err != nil {
 			if apierrors.IsNotFound(err) == false {
-				log.Warnf("get system application %s failed %s", appName, err.Error())
+				logger.Warnf("get system application %s failed %s", appName, err.Error())
 				return
 			} else {
 				time.Sleep(sysApplicationCheckInterval)
@@ -257,11 +256,11 @@ func ensureApplicationSucceedOrDie(cli client.Client, appName string) {
 		switch app.Status.State {
 		case appv1beta1.ApplicationStatusStateFailed:
 			if err := deleteApplication(cli, ZCloudNamespace, appName, true); err != nil {
-				log.Warnf("delete system application %s failed %s", appName, err.Error())
+				logger.Warnf("delete system application %s failed %s", appName, err.Error())
 				return
 			}
 		case appv1beta1.ApplicationStatusStateSucceed:
-			log.Infof("create system application %s succeed", appName)
+			logger.Infof("create system application %s succeed", appName)
 			return
 		}
 	}
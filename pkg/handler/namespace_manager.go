@@ -12,7 +12,6 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -60,7 +59,10 @@ func (m *NamespaceManager) Create(ctx *resource.Context) (resource.Resource, *re
 	}
 
 	namespace := ctx.Resource.(*types.Namespace)
-	err := createNamespace(cluster.GetKubeClient(), namespace.Name)
+	if namespace.SecurityProfile != "" && isValidSecurityProfile(namespace.SecurityProfile) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("unknown security profile %s", namespace.SecurityProfile))
+	}
+	err := createNamespace(cluster.GetKubeClient(), namespace.Name, namespace.SecurityProfile)
 	if err == nil {
 		namespace.SetID(namespace.Name)
 		return namespace, nil
@@ -161,7 +163,7 @@ func (m *NamespaceManager) Delete(ctx *resource.Context) *resterror.APIError {
 			return resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("delete namespace failed %s", err.Error()))
 		}
 	} else {
-		eb.PublishResourceDeleteEvent(namespace)
+		eb.PublishResourceDeleteEvent(ctx.Request.Context(), namespace)
 	}
 	return nil
 }
@@ -183,10 +185,13 @@ func getNamespaces(cli client.Client) (*corev1.NamespaceList, error) {
 	return &namespaces, err
 }
 
-func createNamespace(cli client.Client, name string) error {
+func createNamespace(cli client.Client, name, securityProfile string) error {
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{Name: name},
 	}
+	if securityProfile != "" {
+		ns.Labels = map[string]string{LabelKeyForSecurityProfile: securityProfile}
+	}
 	return cli.Create(context.TODO(), ns)
 }
 
@@ -214,7 +219,8 @@ func hasNamespace(cli client.Client, name string) bool {
 
 func k8sNamespaceToSCNamespace(k8sNamespace *corev1.Namespace) *types.Namespace {
 	ns := &types.Namespace{
-		Name: k8sNamespace.Name,
+		Name:            k8sNamespace.Name,
+		SecurityProfile: k8sNamespaceSecurityProfile(k8sNamespace),
 	}
 	ns.SetID(k8sNamespace.Name)
 	ns.SetCreationTimestamp(k8sNamespace.CreationTimestamp.Time)
@@ -234,7 +240,7 @@ func getNamespaceInfo(cli client.Client, name string) (*types.Namespace, error)
 
 	nodes, err := getNodes(cli)
 	if err != nil {
-		log.Warnf("get node info failed:%s", err.Error())
+		logger.Warnf("get node info failed:%s", err.Error())
 		return namespace, nil
 	}
 
@@ -249,7 +255,7 @@ func getNamespaceInfo(cli client.Client, name string) (*types.Namespace, error)
 
 	podMetricsList, err := cli.GetPodMetrics(name, "", labels.Everything())
 	if err != nil {
-		log.Warnf("get pod metrcis failed:%s", err.Error())
+		logger.Warnf("get pod metrcis failed:%s", err.Error())
 		return namespace, nil
 	}
 
@@ -306,11 +312,55 @@ func (m *NamespaceManager) Action(ctx *resource.Context) (interface{}, *resterro
 	switch action.Name {
 	case types.ActionSearchPod:
 		return m.searchPod(ctx)
+	case types.ActionSetSecurityProfile:
+		return nil, m.setSecurityProfile(ctx)
+	case types.ActionImportYAML:
+		return m.importYAML(ctx)
+	case types.ActionExportYAML:
+		return m.exportYAML(ctx)
 	default:
 		return nil, nil
 	}
 }
 
+// profile only affects workloads created or updated later
+func (m *NamespaceManager) setSecurityProfile(ctx *resource.Context) *resterror.APIError {
+	if isAdmin(getCurrentUser(ctx)) == false {
+		return resterror.NewAPIError(resterror.PermissionDenied, "only admin can set security profile of namespace")
+	}
+
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	profile := &types.NamespaceSecurityProfile{}
+	if takeActionInput(ctx, profile) == false {
+		return resterror.NewAPIError(resterror.InvalidFormat, "action setSecurityProfile input invalid")
+	}
+	if isValidSecurityProfile(profile.Profile) == false {
+		return resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("security profile should be one of privileged, baseline and restricted, but get %q", profile.Profile))
+	}
+
+	name := ctx.Resource.GetID()
+	k8sNamespace, err := getNamespace(cluster.GetKubeClient(), name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found namespace %s", name))
+		}
+		return resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get namespace %s failed %s", name, err.Error()))
+	}
+
+	if k8sNamespace.Labels == nil {
+		k8sNamespace.Labels = make(map[string]string)
+	}
+	k8sNamespace.Labels[LabelKeyForSecurityProfile] = profile.Profile
+	if err := cluster.GetKubeClient().Update(context.TODO(), k8sNamespace); err != nil {
+		return resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("set security profile of namespace %s failed %s", name, err.Error()))
+	}
+	return nil
+}
+
 func (m *NamespaceManager) searchPod(ctx *resource.Context) (interface{}, *resterror.APIError) {
 	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
 	if cluster == nil {
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -10,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	metricsapi "k8s.io/metrics/pkg/apis/metrics"
 
 	"github.com/zdnscloud/gok8s/client"
@@ -142,6 +144,7 @@ func k8sNodeToSCNode(k8sNode *corev1.Node, nodeMetrics map[string]metricsapi.Nod
 		Roles:                getRoleFromLabels(k8sNode.Labels),
 		Labels:               k8sNode.Labels,
 		Annotations:          k8sNode.Annotations,
+		Taints:               k8sTaintsToScTaints(k8sNode.Spec.Taints),
 		OperatingSystem:      os,
 		OperatingSystemImage: osImage,
 		DockerVersion:        dockderVersion,
@@ -236,6 +239,18 @@ func (m *NodeManager) Action(ctx *restresource.Context) (interface{}, *resterr.A
 		return nil, uncordonNode(cluster.GetKubeClient(), node)
 	case types.NodeDrain:
 		return nil, drainNode(cluster.GetKubeClient(), node)
+	case types.NodeSetLabels:
+		labels := &types.NodeLabels{}
+		if takeActionInput(ctx, labels) == false {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, "action setLabels input invalid")
+		}
+		return nil, setNodeLabels(cluster.GetKubeClient(), node, labels.Labels)
+	case types.NodeSetTaints:
+		taints := &types.NodeTaints{}
+		if takeActionInput(ctx, taints) == false {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, "action setTaints input invalid")
+		}
+		return nil, setNodeTaints(cluster.GetKubeClient(), node, taints.Taints)
 	default:
 		return nil, nil
 	}
@@ -310,6 +325,132 @@ func uncordonNode(cli client.Client, name string) *resterr.APIError {
 	return nil
 }
 
+func setNodeLabels(cli client.Client, name string, labels map[string]string) *resterr.APIError {
+	for k := range labels {
+		if isReservedLabelKey(k) {
+			return resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("label %s is reserved", k))
+		}
+	}
+	if err := validateLabels(labels); err != nil {
+		return resterr.NewAPIError(resterr.InvalidOption, err.Error())
+	}
+
+	node, apiErr := getK8sNodeForUpdate(cli, name)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	newLabels := make(map[string]string)
+	for k, v := range node.Labels {
+		if isReservedLabelKey(k) {
+			newLabels[k] = v
+		}
+	}
+	for k, v := range labels {
+		newLabels[k] = v
+	}
+
+	node.Labels = newLabels
+	if err := cli.Update(context.TODO(), node); err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("update node %s failed %s", name, err.Error()))
+	}
+	return nil
+}
+
+func setNodeTaints(cli client.Client, name string, taints []types.Taint) *resterr.APIError {
+	k8sTaints, err := scTaintsToK8sTaints(taints)
+	if err != nil {
+		return resterr.NewAPIError(resterr.InvalidOption, err.Error())
+	}
+
+	node, apiErr := getK8sNodeForUpdate(cli, name)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var newTaints []corev1.Taint
+	for _, t := range node.Spec.Taints {
+		if isReservedLabelKey(t.Key) {
+			newTaints = append(newTaints, t)
+			continue
+		}
+		//keep added time of unchanged NoExecute taint
+		for i, k8sTaint := range k8sTaints {
+			if k8sTaint.MatchTaint(&t) && k8sTaint.Value == t.Value && t.TimeAdded != nil {
+				k8sTaints[i].TimeAdded = t.TimeAdded
+			}
+		}
+	}
+
+	node.Spec.Taints = append(newTaints, k8sTaints...)
+	if err := cli.Update(context.TODO(), node); err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("update node %s failed %s", name, err.Error()))
+	}
+	return nil
+}
+
+func getK8sNodeForUpdate(cli client.Client, name string) (*corev1.Node, *resterr.APIError) {
+	node, err := getK8SNode(cli, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("node %s desn't exist", name))
+		}
+		return nil, resterr.NewAPIError(resterr.ServerError, err.Error())
+	}
+	return node, nil
+}
+
+func scTaintsToK8sTaints(taints []types.Taint) ([]corev1.Taint, error) {
+	var k8sTaints []corev1.Taint
+	for _, t := range taints {
+		if isReservedLabelKey(t.Key) {
+			return nil, fmt.Errorf("taint %s is reserved", t.Key)
+		}
+		if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid taint key %s: %s", t.Key, strings.Join(errs, ","))
+		}
+		if errs := validation.IsValidLabelValue(t.Value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid taint value %s: %s", t.Value, strings.Join(errs, ","))
+		}
+
+		effect := corev1.TaintEffect(t.Effect)
+		switch effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("taint effect %s isn't supported", t.Effect)
+		}
+
+		for _, k8sTaint := range k8sTaints {
+			if k8sTaint.Key == t.Key && k8sTaint.Effect == effect {
+				return nil, fmt.Errorf("duplicate taint %s with effect %s", t.Key, t.Effect)
+			}
+		}
+
+		k8sTaint := corev1.Taint{
+			Key:    t.Key,
+			Value:  t.Value,
+			Effect: effect,
+		}
+		if effect == corev1.TaintEffectNoExecute {
+			k8sTaint.TimeAdded = &metav1.Time{Time: time.Now()}
+		}
+		k8sTaints = append(k8sTaints, k8sTaint)
+	}
+	return k8sTaints, nil
+}
+
+func k8sTaintsToScTaints(k8sTaints []corev1.Taint) []types.Taint {
+	var taints []types.Taint
+	for _, t := range k8sTaints {
+		taints = append(taints, types.Taint{
+			Key:    t.Key,
+			Value:  t.Value,
+			Effect: string(t.Effect),
+		})
+	}
+	return taints
+}
+
 func isNodeCordoned(node *corev1.Node) bool {
 	return node.Spec.Unschedulable
 }
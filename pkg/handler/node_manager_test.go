@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	restresource "github.com/zdnscloud/gorest/resource"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestTakeNodeLabelsInput(t *testing.T) {
+	//gorest decodes every request into the same input
+	input := &types.NodeLabels{}
+	node := &types.Node{}
+	node.SetAction(&restresource.Action{Name: types.NodeSetLabels, Input: input})
+	ctx := &restresource.Context{Resource: node}
+
+	ut.Assert(t, json.Unmarshal([]byte(`{"labels":{"disk":"ssd","zone":"a"}}`), input) == nil, "")
+	labels := &types.NodeLabels{}
+	ut.Assert(t, takeActionInput(ctx, labels), "")
+	ut.Equal(t, labels.Labels, map[string]string{"disk": "ssd", "zone": "a"})
+
+	//label removed in next request isn't merged from previous one
+	ut.Assert(t, json.Unmarshal([]byte(`{"labels":{"disk":"ssd"}}`), input) == nil, "")
+	labels = &types.NodeLabels{}
+	ut.Assert(t, takeActionInput(ctx, labels), "")
+	ut.Equal(t, labels.Labels, map[string]string{"disk": "ssd"})
+
+	ut.Assert(t, json.Unmarshal([]byte(`{}`), input) == nil, "")
+	labels = &types.NodeLabels{}
+	ut.Assert(t, takeActionInput(ctx, labels), "")
+	ut.Equal(t, len(labels.Labels), 0)
+}
@@ -7,11 +7,11 @@ import (
 	"time"
 
 	"github.com/zdnscloud/singlecloud/pkg/zke"
+	"github.com/zdnscloud/singlecloud/server"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
 )
 
 var (
@@ -69,20 +69,20 @@ func (m *ClusterManager) openPodLog(cluster *zke.Cluster, namespace, pod, contai
 func (m *ClusterManager) OpenPodLog(clusterID, namespace, pod, container string, r *http.Request, w http.ResponseWriter) {
 	cluster := m.GetClusterByName(clusterID)
 	if cluster == nil {
-		log.Warnf("cluster %s isn't found to open console", clusterID)
+		logger.Warnf("cluster %s isn't found to open console", clusterID)
 		return
 	}
 
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
-		log.Warnf("pod %s-%s-%s-%s log websocket upgrade failed %s", clusterID, namespace, pod, container, err.Error())
+		logger.Warnf("pod %s-%s-%s-%s log websocket upgrade failed %s", clusterID, namespace, pod, container, err.Error())
 		return
 	}
 	defer conn.Close()
 
 	readCloser, err := m.openPodLog(cluster, namespace, pod, container)
 	if err != nil {
-		log.Warnf("openPodLog %s-%s-%s-%s failed %s", clusterID, namespace, pod, container, err.Error())
+		logger.Warnf("openPodLog %s-%s-%s-%s failed %s", clusterID, namespace, pod, container, err.Error())
 		return
 	}
 
@@ -90,7 +90,7 @@ func (m *ClusterManager) OpenPodLog(clusterID, namespace, pod, container string,
 	for s.Scan() {
 		err := conn.WriteMessage(websocket.TextMessage, s.Bytes())
 		if err != nil {
-			log.Warnf("send %s-%s-%s-%s log failed:%s", clusterID, namespace, pod, container, err.Error())
+			logger.Warnf("send %s-%s-%s-%s log failed:%s", clusterID, namespace, pod, container, err.Error())
 			break
 		}
 	}
@@ -50,9 +50,16 @@ func (m *PodManager) List(ctx *resource.Context) (interface{}, *resterror.APIErr
 		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("list pods failed %s", err.Error()))
 	}
 
+	failures, err := getPodsProbeFailures(cluster.GetKubeClient(), namespace)
+	if err != nil {
+		logger.Warnf("get probe failures of pods in namespace %s failed %s", namespace, err.Error())
+	}
+
 	var pods []*types.Pod
 	for _, k8sPod := range k8sPods.Items {
-		pods = append(pods, k8sPodToSCPod(&k8sPod))
+		pod := k8sPodToSCPod(&k8sPod)
+		setPodProbeFailures(pod, k8sPod.UID, failures)
+		pods = append(pods, pod)
 	}
 	return pods, nil
 }
@@ -73,7 +80,14 @@ func (m *PodManager) Get(ctx *resource.Context) (resource.Resource, *resterror.A
 		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get pod %s failed %s", pod.GetID(), err.Error()))
 	}
 
-	return k8sPodToSCPod(k8sPod), nil
+	failures, err := getPodsProbeFailures(cluster.GetKubeClient(), namespace)
+	if err != nil {
+		logger.Warnf("get probe failures of pods in namespace %s failed %s", namespace, err.Error())
+	}
+
+	scPod := k8sPodToSCPod(k8sPod)
+	setPodProbeFailures(scPod, k8sPod.UID, failures)
+	return scPod, nil
 }
 
 func (m *PodManager) Delete(ctx *resource.Context) *resterror.APIError {
@@ -233,6 +247,7 @@ func k8sPodToSCPod(k8sPod *corev1.Pod) *types.Pod {
 			ContainerID:  status.ContainerID,
 			LastState:    k8sContainerStateToScContainerState(status.LastTerminationState),
 			State:        k8sContainerStateToScContainerState(status.State),
+			Started:      status.Started,
 		})
 	}
 
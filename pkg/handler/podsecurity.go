@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	LabelKeyForSecurityProfile = "security.zcloud.cn/profile"
+
+	AnnKeyForPodSeccompProfile = "seccomp.security.alpha.kubernetes.io/pod"
+	seccompProfileLocalPrefix  = "localhost/"
+	capabilityAll              = "ALL"
+)
+
+var (
+	capabilityRegexp = regexp.MustCompile("^[A-Z_]+$")
+
+	baselineAllowedCapabilities = map[string]bool{
+		"AUDIT_WRITE":      true,
+		"CHOWN":            true,
+		"DAC_OVERRIDE":     true,
+		"FOWNER":           true,
+		"FSETID":           true,
+		"KILL":             true,
+		"MKNOD":            true,
+		"NET_BIND_SERVICE": true,
+		"SETFCAP":          true,
+		"SETGID":           true,
+		"SETPCAP":          true,
+		"SETUID":           true,
+		"SYS_CHROOT":       true,
+	}
+)
+
+func scSecurityContextToK8sSecurityContext(sc *types.SecurityContext) (*corev1.SecurityContext, error) {
+	if sc == nil {
+		return nil, nil
+	}
+
+	add, err := scCapabilitiesToK8sCapabilities(sc.CapabilitiesAdd)
+	if err != nil {
+		return nil, err
+	}
+	drop, err := scCapabilitiesToK8sCapabilities(sc.CapabilitiesDrop)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sSecurityContext := &corev1.SecurityContext{
+		RunAsUser:                sc.RunAsUser,
+		RunAsGroup:               sc.RunAsGroup,
+		AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+	}
+	if sc.Privileged {
+		k8sSecurityContext.Privileged = &sc.Privileged
+	}
+	if sc.RunAsNonRoot {
+		k8sSecurityContext.RunAsNonRoot = &sc.RunAsNonRoot
+	}
+	if sc.ReadOnlyRootFilesystem {
+		k8sSecurityContext.ReadOnlyRootFilesystem = &sc.ReadOnlyRootFilesystem
+	}
+	if len(add) > 0 || len(drop) > 0 {
+		k8sSecurityContext.Capabilities = &corev1.Capabilities{Add: add, Drop: drop}
+	}
+	return k8sSecurityContext, nil
+}
+
+// capability is accepted with or without CAP_ prefix
+func scCapabilitiesToK8sCapabilities(capabilities []string) ([]corev1.Capability, error) {
+	var k8sCapabilities []corev1.Capability
+	for _, c := range capabilities {
+		c = strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+		if capabilityRegexp.MatchString(c) == false {
+			return nil, fmt.Errorf("invalid capability %s", c)
+		}
+		k8sCapabilities = append(k8sCapabilities, corev1.Capability(c))
+	}
+	return k8sCapabilities, nil
+}
+
+func k8sSecurityContextToScSecurityContext(k8sSecurityContext *corev1.SecurityContext) *types.SecurityContext {
+	if k8sSecurityContext == nil {
+		return nil
+	}
+
+	sc := &types.SecurityContext{
+		Privileged:               boolPtrValue(k8sSecurityContext.Privileged),
+		RunAsUser:                k8sSecurityContext.RunAsUser,
+		RunAsGroup:               k8sSecurityContext.RunAsGroup,
+		RunAsNonRoot:             boolPtrValue(k8sSecurityContext.RunAsNonRoot),
+		ReadOnlyRootFilesystem:   boolPtrValue(k8sSecurityContext.ReadOnlyRootFilesystem),
+		AllowPrivilegeEscalation: k8sSecurityContext.AllowPrivilegeEscalation,
+	}
+	if k8sSecurityContext.Capabilities != nil {
+		for _, c := range k8sSecurityContext.Capabilities.Add {
+			sc.CapabilitiesAdd = append(sc.CapabilitiesAdd, string(c))
+		}
+		for _, c := range k8sSecurityContext.Capabilities.Drop {
+			sc.CapabilitiesDrop = append(sc.CapabilitiesDrop, string(c))
+		}
+	}
+	return sc
+}
+
+func boolPtrValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func getPodOwnerSecurityContext(podOwner interface{}) types.PodSecurityContext {
+	return getPodOwnerField(podOwner, "SecurityContext", types.PodSecurityContext{}).(types.PodSecurityContext)
+}
+
+func validateSeccompProfile(profile string) error {
+	switch {
+	case profile == "", profile == types.SeccompProfileRuntimeDefault, profile == types.SeccompProfileUnconfined:
+		return nil
+	case strings.HasPrefix(profile, seccompProfileLocalPrefix) && len(profile) > len(seccompProfileLocalPrefix):
+		return nil
+	default:
+		return fmt.Errorf("seccomp profile %s isn't supported", profile)
+	}
+}
+
+// setK8sPodTemplateSecurity overwrites pod level security settings,
+// seccomp is set by annotation since k8s 1.17 has no field for it
+func setK8sPodTemplateSecurity(template *corev1.PodTemplateSpec, psc types.PodSecurityContext) error {
+	if err := validateSeccompProfile(psc.SeccompProfile); err != nil {
+		return err
+	}
+
+	var k8sPodSecurityContext *corev1.PodSecurityContext
+	if psc.RunAsUser != nil || psc.RunAsGroup != nil || psc.RunAsNonRoot || psc.FSGroup != nil {
+		k8sPodSecurityContext = &corev1.PodSecurityContext{
+			RunAsUser:  psc.RunAsUser,
+			RunAsGroup: psc.RunAsGroup,
+			FSGroup:    psc.FSGroup,
+		}
+		if psc.RunAsNonRoot {
+			k8sPodSecurityContext.RunAsNonRoot = &psc.RunAsNonRoot
+		}
+	}
+
+	template.Spec.SecurityContext = k8sPodSecurityContext
+	template.Spec.HostNetwork = psc.HostNetwork
+	template.Spec.HostPID = psc.HostPID
+	template.Spec.HostIPC = psc.HostIPC
+	if psc.SeccompProfile != "" {
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations[AnnKeyForPodSeccompProfile] = psc.SeccompProfile
+	} else {
+		delete(template.Annotations, AnnKeyForPodSeccompProfile)
+	}
+	return nil
+}
+
+func k8sPodTemplateToScPodSecurityContext(template corev1.PodTemplateSpec) types.PodSecurityContext {
+	psc := types.PodSecurityContext{
+		SeccompProfile: template.Annotations[AnnKeyForPodSeccompProfile],
+		HostNetwork:    template.Spec.HostNetwork,
+		HostPID:        template.Spec.HostPID,
+		HostIPC:        template.Spec.HostIPC,
+	}
+	if sc := template.Spec.SecurityContext; sc != nil {
+		psc.RunAsUser = sc.RunAsUser
+		psc.RunAsGroup = sc.RunAsGroup
+		psc.RunAsNonRoot = boolPtrValue(sc.RunAsNonRoot)
+		psc.FSGroup = sc.FSGroup
+	}
+	return psc
+}
+
+// namespace without profile label or with unknown profile is baseline,
+// privileged profile has to be set explicitly by admin
+func getNamespaceSecurityProfile(cli client.Client, namespace string) (string, error) {
+	k8sNamespace, err := getNamespace(cli, namespace)
+	if err != nil {
+		return "", err
+	}
+	return k8sNamespaceSecurityProfile(k8sNamespace), nil
+}
+
+func k8sNamespaceSecurityProfile(k8sNamespace *corev1.Namespace) string {
+	if profile := k8sNamespace.Labels[LabelKeyForSecurityProfile]; isValidSecurityProfile(profile) {
+		return profile
+	}
+	return types.SecurityProfileBaseline
+}
+
+func isValidSecurityProfile(profile string) bool {
+	switch profile {
+	case types.SecurityProfilePrivileged, types.SecurityProfileBaseline, types.SecurityProfileRestricted:
+		return true
+	default:
+		return false
+	}
+}
+
+func validatePodSecurity(cli client.Client, namespace string, template *corev1.PodTemplateSpec) error {
+	profile, err := getNamespaceSecurityProfile(cli, namespace)
+	if err != nil {
+		return fmt.Errorf("get security profile of namespace %s failed: %s", namespace, err.Error())
+	}
+
+	if errs := checkPodSecurityProfile(profile, template); len(errs) > 0 {
+		return fmt.Errorf("violate %s security profile of namespace %s: %s", profile, namespace, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkPodSecurityProfile returns field level errors of pod template
+// according to pod security standards
+func checkPodSecurityProfile(profile string, template *corev1.PodTemplateSpec) []string {
+	if profile != types.SecurityProfileBaseline && profile != types.SecurityProfileRestricted {
+		return nil
+	}
+
+	var errs []string
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	spec := &template.Spec
+	if spec.HostNetwork {
+		addErr("securityContext.hostNetwork", "host network is not allowed")
+	}
+	if spec.HostPID {
+		addErr("securityContext.hostPID", "host pid is not allowed")
+	}
+	if spec.HostIPC {
+		addErr("securityContext.hostIPC", "host ipc is not allowed")
+	}
+
+	seccomp := template.Annotations[AnnKeyForPodSeccompProfile]
+	if seccomp == types.SeccompProfileUnconfined {
+		addErr("securityContext.seccompProfile", "unconfined seccomp profile is not allowed")
+	}
+
+	restricted := profile == types.SecurityProfileRestricted
+	if restricted && seccomp == "" {
+		addErr("securityContext.seccompProfile", "seccomp profile should be %s or localhost", types.SeccompProfileRuntimeDefault)
+	}
+
+	podNonRoot := spec.SecurityContext != nil && boolPtrValue(spec.SecurityContext.RunAsNonRoot)
+	if restricted && spec.SecurityContext != nil && spec.SecurityContext.RunAsUser != nil && *spec.SecurityContext.RunAsUser == 0 {
+		addErr("securityContext.runAsUser", "running as root is not allowed")
+	}
+
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			addErr("volumes["+v.Name+"]", "host path volume is not allowed")
+		} else if restricted && !isRestrictedVolume(v) {
+			addErr("volumes["+v.Name+"]", "volume type is not allowed")
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		field := "containers[" + c.Name + "].securityContext"
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if boolPtrValue(sc.Privileged) {
+			addErr(field+".privileged", "privileged container is not allowed")
+		}
+
+		var add, drop []corev1.Capability
+		if sc.Capabilities != nil {
+			add, drop = sc.Capabilities.Add, sc.Capabilities.Drop
+		}
+		for _, capability := range add {
+			if restricted {
+				if capability != "NET_BIND_SERVICE" {
+					addErr(field+".capabilitiesAdd", "capability %s is not allowed, only NET_BIND_SERVICE could be added", capability)
+				}
+			} else if baselineAllowedCapabilities[string(capability)] == false {
+				addErr(field+".capabilitiesAdd", "capability %s is not allowed", capability)
+			}
+		}
+
+		if restricted == false {
+			continue
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			addErr(field+".allowPrivilegeEscalation", "should be false")
+		}
+		if podNonRoot == false && boolPtrValue(sc.RunAsNonRoot) == false {
+			addErr(field+".runAsNonRoot", "should be true")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			addErr(field+".runAsUser", "running as root is not allowed")
+		}
+		dropAll := false
+		for _, capability := range drop {
+			if capability == capabilityAll {
+				dropAll = true
+			}
+		}
+		if dropAll == false {
+			addErr(field+".capabilitiesDrop", "should drop ALL")
+		}
+	}
+	return errs
+}
+
+func isRestrictedVolume(v corev1.Volume) bool {
+	return v.ConfigMap != nil || v.Secret != nil || v.EmptyDir != nil || v.PersistentVolumeClaim != nil ||
+		v.Projected != nil || v.DownwardAPI != nil || v.CSI != nil
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestSecurityContext(t *testing.T) {
+	user := int64(1000)
+	noEscalation := false
+	sc := &types.SecurityContext{
+		RunAsUser:                &user,
+		RunAsNonRoot:             true,
+		ReadOnlyRootFilesystem:   true,
+		AllowPrivilegeEscalation: &noEscalation,
+		CapabilitiesAdd:          []string{"NET_BIND_SERVICE"},
+		CapabilitiesDrop:         []string{"ALL"},
+	}
+	k8sSecurityContext, err := scSecurityContextToK8sSecurityContext(sc)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, k8sSecurityContextToScSecurityContext(k8sSecurityContext), sc)
+
+	_, err = scSecurityContextToK8sSecurityContext(&types.SecurityContext{CapabilitiesAdd: []string{"net admin"}})
+	ut.Assert(t, err != nil, "invalid capability should be rejected")
+
+	var template corev1.PodTemplateSpec
+	psc := types.PodSecurityContext{RunAsNonRoot: true, SeccompProfile: types.SeccompProfileRuntimeDefault}
+	ut.Assert(t, setK8sPodTemplateSecurity(&template, psc) == nil, "")
+	ut.Equal(t, k8sPodTemplateToScPodSecurityContext(template), psc)
+	ut.Assert(t, setK8sPodTemplateSecurity(&template, types.PodSecurityContext{SeccompProfile: "docker"}) != nil, "")
+}
+
+func TestCheckPodSecurityProfile(t *testing.T) {
+	privileged := true
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			HostNetwork: true,
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+					SecurityContext: &corev1.SecurityContext{
+						Privileged:   &privileged,
+						Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+					},
+				},
+			},
+		},
+	}
+	ut.Equal(t, len(checkPodSecurityProfile(types.SecurityProfilePrivileged, template)), 0)
+	errs := checkPodSecurityProfile(types.SecurityProfileBaseline, template)
+	ut.Equal(t, len(errs), 3)
+	ut.Assert(t, strings.HasPrefix(errs[1], "containers[web].securityContext.privileged:"), "unexpected error %s", errs[1])
+
+	noEscalation := false
+	nonRoot := true
+	template = &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: &noEscalation,
+						Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+					},
+				},
+			},
+		},
+	}
+	ut.Equal(t, len(checkPodSecurityProfile(types.SecurityProfileRestricted, template)), 1)
+	template.Annotations = map[string]string{AnnKeyForPodSeccompProfile: types.SeccompProfileRuntimeDefault}
+	ut.Equal(t, len(checkPodSecurityProfile(types.SecurityProfileRestricted, template)), 0)
+}
+
+func TestNamespaceSecurityProfile(t *testing.T) {
+	ns := &corev1.Namespace{}
+	ut.Equal(t, k8sNamespaceSecurityProfile(ns), types.SecurityProfileBaseline)
+	ns.Labels = map[string]string{LabelKeyForSecurityProfile: "unknown"}
+	ut.Equal(t, k8sNamespaceSecurityProfile(ns), types.SecurityProfileBaseline)
+	ns.Labels[LabelKeyForSecurityProfile] = types.SecurityProfilePrivileged
+	ut.Equal(t, k8sNamespaceSecurityProfile(ns), types.SecurityProfilePrivileged)
+}
@@ -12,10 +12,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
-	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resttypes "github.com/zdnscloud/gorest/resource"
 	"github.com/zdnscloud/singlecloud/pkg/types"
@@ -40,32 +38,73 @@ const (
 )
 
 func createPodTempateSpec(namespace string, podOwner interface{}, cli client.Client) (*corev1.PodTemplateSpec, []corev1.PersistentVolumeClaim, error) {
+	template, k8sPVCs, err := buildPodTempateSpec(namespace, podOwner, cli, nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := podOwner.(*types.StatefulSet); ok == false {
+		if err := createPVCs(cli, namespace, k8sPVCs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return template, k8sPVCs, nil
+}
+
+// buildPodTempateSpec runs security and quota checks without creating
+// pvcs, oldSpec is the pod spec of the workload to be replaced, it's nil
+// for new workload
+func buildPodTempateSpec(namespace string, podOwner interface{}, cli client.Client, oldSpec *corev1.PodSpec, oldReplicas int) (*corev1.PodTemplateSpec, []corev1.PersistentVolumeClaim, error) {
 	structVal := reflect.ValueOf(podOwner).Elem()
 	advancedOpts := structVal.FieldByName("AdvancedOptions").Interface().(types.AdvancedOptions)
-	containers := structVal.FieldByName("Containers").Interface().([]types.Container)
-	pvs := structVal.FieldByName("PersistentVolumes").Interface().([]types.PersistentVolumeTemplate)
-
-	k8sPodSpec, k8sPVCs, err := scPodSpecToK8sPodSpecAndPVCs(cli, containers, pvs)
+	spec := getPodOwnerPodSpec(podOwner)
+	k8sPodSpec, k8sPVCs, err := scPodSpecToK8sPodSpecAndPVCs(cli, spec)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	name := structVal.FieldByName("Name").String()
-	meta, err := createPodTempateObjectMeta(name, namespace, cli, advancedOpts, containers)
+	meta, err := createPodTempateObjectMeta(name, namespace, cli, advancedOpts, append(append([]types.Container{}, spec.InitContainers...), spec.Containers...))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if _, ok := podOwner.(*types.StatefulSet); ok == false {
-		if err := createPVCs(cli, namespace, k8sPVCs); err != nil {
-			return nil, nil, err
-		}
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, getPodOwnerScheduling(podOwner), meta.Labels); err != nil {
+		return nil, nil, err
 	}
 
-	return &corev1.PodTemplateSpec{
+	template := &corev1.PodTemplateSpec{
 		ObjectMeta: meta,
 		Spec:       k8sPodSpec,
-	}, k8sPVCs, nil
+	}
+	if err := setK8sPodTemplateSecurity(template, getPodOwnerSecurityContext(podOwner)); err != nil {
+		return nil, nil, err
+	}
+
+	if err := validatePodSecurity(cli, namespace, template); err != nil {
+		return nil, nil, err
+	}
+
+	replicas, err := getPodOwnerReplicas(cli, podOwner, &template.Spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateWorkloadResources(cli, namespace, oldSpec, oldReplicas, &template.Spec, replicas); err != nil {
+		return nil, nil, err
+	}
+
+	return template, k8sPVCs, nil
+}
+
+// getPodOwnerField returns defaultValue if pod owner has no such field
+func getPodOwnerField(podOwner interface{}, name string, defaultValue interface{}) interface{} {
+	field := reflect.ValueOf(podOwner).Elem().FieldByName(name)
+	if field.IsValid() {
+		return field.Interface()
+	}
+	return defaultValue
 }
 
 func generatePodOwnerObjectMeta(namespace string, podOwner interface{}) metav1.ObjectMeta {
@@ -139,22 +178,22 @@ func deletePVCs(cli client.Client, namespace string, k8sPVCs []corev1.Persistent
 func deletePVC(cli client.Client, namespace, pvcName string) {
 	k8sPVC, err := getPersistentVolumeClaim(cli, namespace, pvcName)
 	if err != nil {
-		log.Warnf("get persistentvolumeclaim %s failed:%s", pvcName, err.Error())
+		logger.Warnf("get persistentvolumeclaim %s failed:%s", pvcName, err.Error())
 		return
 	}
 
 	if err := deletePersistentVolumeClaim(cli, namespace, pvcName); err != nil {
-		log.Warnf("delete persistentvolumeclaim %s failed:%s", pvcName, err.Error())
+		logger.Warnf("delete persistentvolumeclaim %s failed:%s", pvcName, err.Error())
 	}
 
 	if volumeName := k8sPVC.Spec.VolumeName; volumeName != "" {
 		if _, err := getPersistentVolume(cli, volumeName); err != nil {
 			if apierrors.IsNotFound(err) == false {
-				log.Warnf("get persistentvolume %s failed:%s", volumeName, err.Error())
+				logger.Warnf("get persistentvolume %s failed:%s", volumeName, err.Error())
 			}
 		} else {
 			if err := deletePersistentVolume(cli, volumeName); err != nil {
-				log.Warnf("delete persistentvolume %s failed:%s", volumeName, err.Error())
+				logger.Warnf("delete persistentvolume %s failed:%s", volumeName, err.Error())
 			}
 		}
 	}
@@ -168,14 +207,18 @@ func deleteWorkLoadPVCs(cli client.Client, namespace string, k8sVolumes []corev1
 	}
 }
 
-func scPodSpecToK8sPodSpecAndPVCs(cli client.Client, containers []types.Container, pvs []types.PersistentVolumeTemplate) (corev1.PodSpec, []corev1.PersistentVolumeClaim, error) {
+func scPodSpecToK8sPodSpecAndPVCs(cli client.Client, spec scPodSpec) (corev1.PodSpec, []corev1.PersistentVolumeClaim, error) {
 	var k8sPodSpec corev1.PodSpec
-	k8sEmptyDirs, k8sPVCs, err := scPVCsToK8sVolumesAndPVCs(cli, pvs)
+	if err := validatePodVolumes(spec.Volumes, spec.PersistentVolumes); err != nil {
+		return k8sPodSpec, nil, err
+	}
+
+	k8sEmptyDirs, k8sPVCs, err := scPVCsToK8sVolumesAndPVCs(cli, spec.PersistentVolumes)
 	if err != nil {
 		return k8sPodSpec, nil, err
 	}
 
-	k8sPodSpec, err = scContainersAndPVToK8sPodSpec(containers, k8sEmptyDirs, k8sPVCs)
+	k8sPodSpec, err = scContainersAndPVToK8sPodSpec(spec, k8sEmptyDirs, k8sPVCs)
 	return k8sPodSpec, k8sPVCs, err
 }
 
@@ -248,141 +291,186 @@ func scPVCsToK8sVolumesAndPVCs(cli client.Client, pvs []types.PersistentVolumeTe
 	return k8sEmptydirVolumes, k8sPVCs, nil
 }
 
-func scContainersAndPVToK8sPodSpec(containers []types.Container, k8sEmptyDirs []corev1.Volume, k8sPVCs []corev1.PersistentVolumeClaim) (corev1.PodSpec, error) {
-	var k8sContainers []corev1.Container
-	var k8sVolumes []corev1.Volume
-	for _, c := range containers {
-		var mounts []corev1.VolumeMount
-		var ports []corev1.ContainerPort
-		var env []corev1.EnvVar
-		for i, volume := range c.Volumes {
-			readOnly := true
-			exists := false
-			volumeName := c.Name + "-" + VolumeNamePrefix + strconv.Itoa(i)
-			var volumeSource corev1.VolumeSource
-			switch volume.Type {
-			case types.VolumeTypeConfigMap:
-				volumeSource = corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: volume.Name,
-						},
-					},
-				}
-			case types.VolumeTypeSecret:
-				volumeSource = corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName: volume.Name,
-					},
-				}
-			case types.VolumeTypePersistentVolume:
-				readOnly = false
-				found := false
-				for _, emptydir := range k8sEmptyDirs {
-					if emptydir.Name == volume.Name {
-						volumeName = emptydir.Name
-						volumeSource = emptydir.VolumeSource
-						found = true
-						break
-					}
-				}
+// init containers and containers share volumes, names of them should be
+// unique in pod
+func scContainersAndPVToK8sPodSpec(spec scPodSpec, k8sEmptyDirs []corev1.Volume, k8sPVCs []corev1.PersistentVolumeClaim) (corev1.PodSpec, error) {
+	var k8sPodSpec corev1.PodSpec
+	names := make(map[string]bool)
+	for _, c := range append(append([]types.Container{}, spec.InitContainers...), spec.Containers...) {
+		if names[c.Name] {
+			return corev1.PodSpec{}, fmt.Errorf("duplicate container name %s", c.Name)
+		}
+		names[c.Name] = true
+	}
 
-				if found == false {
-					for _, pvc := range k8sPVCs {
-						if pvc.Name == volume.Name {
-							volumeName = pvc.Name
-							volumeSource = corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: volume.Name,
-								},
-							}
-							found = true
-							break
-						}
-					}
+	for _, c := range spec.InitContainers {
+		if c.LivenessProbe != nil || c.ReadinessProbe != nil || c.StartupProbe != nil {
+			return corev1.PodSpec{}, fmt.Errorf("init container %s shouldn't have probe", c.Name)
+		}
+
+		k8sContainer, err := scContainerToK8sContainer(c, spec.Volumes, k8sEmptyDirs, k8sPVCs, &k8sPodSpec.Volumes)
+		if err != nil {
+			return corev1.PodSpec{}, err
+		}
+		k8sPodSpec.InitContainers = append(k8sPodSpec.InitContainers, k8sContainer)
+	}
+
+	for _, c := range spec.Containers {
+		k8sContainer, err := scContainerToK8sContainer(c, spec.Volumes, k8sEmptyDirs, k8sPVCs, &k8sPodSpec.Volumes)
+		if err != nil {
+			return corev1.PodSpec{}, err
+		}
+		k8sPodSpec.Containers = append(k8sPodSpec.Containers, k8sContainer)
+	}
+	return k8sPodSpec, nil
+}
+
+// scContainerToK8sContainer appends volumes used by container to k8sVolumes
+func scContainerToK8sContainer(c types.Container, podVolumes []types.PodVolume, k8sEmptyDirs []corev1.Volume, k8sPVCs []corev1.PersistentVolumeClaim, k8sVolumes *[]corev1.Volume) (corev1.Container, error) {
+	var mounts []corev1.VolumeMount
+	var ports []corev1.ContainerPort
+	var env []corev1.EnvVar
+	for i, volume := range c.Volumes {
+		readOnly := true
+		exists := false
+		volumeName := c.Name + "-" + VolumeNamePrefix + strconv.Itoa(i)
+		var volumeSource corev1.VolumeSource
+		switch volume.Type {
+		case types.VolumeTypeConfigMap:
+			volumeSource = corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: volume.Name,
+					},
+				},
+			}
+		case types.VolumeTypeSecret:
+			volumeSource = corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: volume.Name,
+				},
+			}
+		case types.VolumeTypePersistentVolume:
+			readOnly = false
+			found := false
+			for _, emptydir := range k8sEmptyDirs {
+				if emptydir.Name == volume.Name {
+					volumeName = emptydir.Name
+					volumeSource = emptydir.VolumeSource
+					found = true
+					break
 				}
+			}
 
-				if found == false {
-					return corev1.PodSpec{}, fmt.Errorf("no found volume %s in persistent volume", volume.Name)
-				} else {
-					for _, k8sVolume := range k8sVolumes {
-						if k8sVolume.Name == volumeName {
-							exists = true
-							break
+			if found == false {
+				for _, pvc := range k8sPVCs {
+					if pvc.Name == volume.Name {
+						volumeName = pvc.Name
+						volumeSource = corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: volume.Name,
+							},
 						}
+						found = true
+						break
 					}
 				}
-			default:
-				return corev1.PodSpec{}, fmt.Errorf("volume type %s is unsupported", volume.Type)
 			}
 
-			if exists == false {
-				k8sVolumes = append(k8sVolumes, corev1.Volume{
-					Name:         volumeName,
-					VolumeSource: volumeSource,
-				})
+			if found == false {
+				return corev1.Container{}, fmt.Errorf("no found volume %s in persistent volume", volume.Name)
 			}
-
-			mounts = append(mounts, corev1.VolumeMount{
-				Name:      volumeName,
-				MountPath: volume.MountPath,
-				ReadOnly:  readOnly,
-			})
-		}
-
-		var portNames []string
-		for _, spec := range c.ExposedPorts {
-			protocol, err := scPortProtocolToK8SProtocol(spec.Protocol)
+		case types.VolumeTypeEmptyDir, types.VolumeTypeHostPath, types.VolumeTypeProjected:
+			podVolume, err := getPodVolume(podVolumes, volume.Name, volume.Type)
 			if err != nil {
-				return corev1.PodSpec{}, fmt.Errorf("invalid protocol %s for container port", spec.Protocol)
+				return corev1.Container{}, err
 			}
 
-			if err := validatePortName(spec.Name); err != nil {
-				return corev1.PodSpec{}, fmt.Errorf("exposed port name %s invalid: %s", spec.Name, err.Error())
+			if volumeSource, err = scPodVolumeToK8sVolumeSource(podVolume); err != nil {
+				return corev1.Container{}, err
 			}
+			volumeName = podVolume.Name
+			readOnly = volume.Type == types.VolumeTypeProjected
+		default:
+			return corev1.Container{}, fmt.Errorf("volume type %s is unsupported", volume.Type)
+		}
 
-			for _, pn := range portNames {
-				if pn == spec.Name {
-					return corev1.PodSpec{}, fmt.Errorf("duplicate container port name %s", pn)
-				}
+		for _, k8sVolume := range *k8sVolumes {
+			if k8sVolume.Name == volumeName {
+				exists = true
+				break
 			}
-			portNames = append(portNames, spec.Name)
+		}
 
-			ports = append(ports, corev1.ContainerPort{
-				Name:          spec.Name,
-				ContainerPort: int32(spec.Port),
-				Protocol:      protocol,
+		if exists == false {
+			*k8sVolumes = append(*k8sVolumes, corev1.Volume{
+				Name:         volumeName,
+				VolumeSource: volumeSource,
 			})
 		}
 
-		for _, e := range c.Env {
-			env = append(env, corev1.EnvVar{
-				Name:  e.Name,
-				Value: e.Value,
-			})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: volume.MountPath,
+			SubPath:   volume.SubPath,
+			ReadOnly:  readOnly,
+		})
+	}
+
+	var portNames []string
+	for _, spec := range c.ExposedPorts {
+		protocol, err := scPortProtocolToK8SProtocol(spec.Protocol)
+		if err != nil {
+			return corev1.Container{}, fmt.Errorf("invalid protocol %s for container port", spec.Protocol)
 		}
 
-		k8sContainers = append(k8sContainers, corev1.Container{
-			Name:         c.Name,
-			Image:        c.Image,
-			Command:      c.Command,
-			Args:         c.Args,
-			VolumeMounts: mounts,
-			Ports:        ports,
-			Env:          env,
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceCPU:    apiresource.MustParse(DefaultRequestCPU),
-					corev1.ResourceMemory: apiresource.MustParse(DefaultRequestMemory),
-				},
-			},
+		if err := validatePortName(spec.Name); err != nil {
+			return corev1.Container{}, fmt.Errorf("exposed port name %s invalid: %s", spec.Name, err.Error())
+		}
+
+		for _, pn := range portNames {
+			if pn == spec.Name {
+				return corev1.Container{}, fmt.Errorf("duplicate container port name %s", pn)
+			}
+		}
+		portNames = append(portNames, spec.Name)
+
+		ports = append(ports, corev1.ContainerPort{
+			Name:          spec.Name,
+			ContainerPort: int32(spec.Port),
+			Protocol:      protocol,
 		})
 	}
 
-	return corev1.PodSpec{
-		Containers: k8sContainers,
-		Volumes:    k8sVolumes,
-	}, nil
+	for _, e := range c.Env {
+		env = append(env, corev1.EnvVar{
+			Name:  e.Name,
+			Value: e.Value,
+		})
+	}
+
+	resources, err := scContainerResourcesToK8sResources(c.Resources)
+	if err != nil {
+		return corev1.Container{}, fmt.Errorf("container %s has invalid resources: %s", c.Name, err.Error())
+	}
+
+	k8sContainer := corev1.Container{
+		Name:         c.Name,
+		Image:        c.Image,
+		Command:      c.Command,
+		Args:         c.Args,
+		VolumeMounts: mounts,
+		Ports:        ports,
+		Env:          env,
+		Resources:    resources,
+	}
+	if err := scContainerProbesToK8sProbes(c, &k8sContainer); err != nil {
+		return corev1.Container{}, fmt.Errorf("container %s has %s", c.Name, err.Error())
+	}
+	if k8sContainer.SecurityContext, err = scSecurityContextToK8sSecurityContext(c.SecurityContext); err != nil {
+		return corev1.Container{}, fmt.Errorf("container %s has invalid security context: %s", c.Name, err.Error())
+	}
+	return k8sContainer, nil
 }
 
 var (
@@ -420,23 +508,33 @@ func k8sPodSpecToScContainersAndVCTemplates(k8sContainers []corev1.Container, k8
 			for _, v := range k8sVolumes {
 				if v.Name == vm.Name {
 					var template types.PersistentVolumeTemplate
-					if v.ConfigMap != nil {
+					if isPodVolume(v) {
+						volumes = append(volumes, types.Volume{
+							Type:      k8sVolumeToScPodVolumeType(v),
+							Name:      v.Name,
+							MountPath: vm.MountPath,
+							SubPath:   vm.SubPath,
+						})
+					} else if v.ConfigMap != nil {
 						volumes = append(volumes, types.Volume{
 							Type:      types.VolumeTypeConfigMap,
 							Name:      v.ConfigMap.Name,
 							MountPath: vm.MountPath,
+							SubPath:   vm.SubPath,
 						})
 					} else if v.Secret != nil {
 						volumes = append(volumes, types.Volume{
 							Type:      types.VolumeTypeSecret,
 							Name:      v.Secret.SecretName,
 							MountPath: vm.MountPath,
+							SubPath:   vm.SubPath,
 						})
 					} else if v.PersistentVolumeClaim != nil {
 						volumes = append(volumes, types.Volume{
 							Type:      types.VolumeTypePersistentVolume,
 							Name:      v.PersistentVolumeClaim.ClaimName,
 							MountPath: vm.MountPath,
+							SubPath:   vm.SubPath,
 						})
 						template.Name = v.PersistentVolumeClaim.ClaimName
 					} else if v.EmptyDir != nil {
@@ -444,6 +542,7 @@ func k8sPodSpecToScContainersAndVCTemplates(k8sContainers []corev1.Container, k8
 							Type:      types.VolumeTypePersistentVolume,
 							Name:      v.Name,
 							MountPath: vm.MountPath,
+							SubPath:   vm.SubPath,
 						})
 						template.Name = v.Name
 						template.StorageClassName = types.StorageClassNameTemp
@@ -486,19 +585,49 @@ func k8sPodSpecToScContainersAndVCTemplates(k8sContainers []corev1.Container, k8
 		}
 
 		containers = append(containers, types.Container{
-			Name:         c.Name,
-			Image:        c.Image,
-			Command:      c.Command,
-			Args:         c.Args,
-			ExposedPorts: exposedPorts,
-			Env:          env,
-			Volumes:      volumes,
+			Name:            c.Name,
+			Image:           c.Image,
+			Command:         c.Command,
+			Args:            c.Args,
+			ExposedPorts:    exposedPorts,
+			Env:             env,
+			Volumes:         volumes,
+			Resources:       k8sResourcesToSCContainerResources(c.Resources),
+			LivenessProbe:   k8sProbeToScProbe(c.LivenessProbe),
+			ReadinessProbe:  k8sProbeToScProbe(c.ReadinessProbe),
+			StartupProbe:    k8sProbeToScProbe(c.StartupProbe),
+			SecurityContext: k8sSecurityContextToScSecurityContext(c.SecurityContext),
 		})
 	}
 
 	return containers, templates
 }
 
+// templates of init containers are merged into the ones of containers
+func k8sPodSpecToScPodSpec(k8sPodSpec corev1.PodSpec) scPodSpec {
+	initContainers, initTemplates := k8sPodSpecToScContainersAndVCTemplates(k8sPodSpec.InitContainers, k8sPodSpec.Volumes)
+	containers, templates := k8sPodSpecToScContainersAndVCTemplates(k8sPodSpec.Containers, k8sPodSpec.Volumes)
+	for _, initTemplate := range initTemplates {
+		exists := false
+		for _, template := range templates {
+			if template.Name == initTemplate.Name {
+				exists = true
+				break
+			}
+		}
+		if exists == false {
+			templates = append(templates, initTemplate)
+		}
+	}
+
+	return scPodSpec{
+		Containers:        containers,
+		InitContainers:    initContainers,
+		Volumes:           k8sVolumesToScPodVolumes(k8sPodSpec.Volumes),
+		PersistentVolumes: templates,
+	}
+}
+
 func createPodTempateObjectMeta(name, namespace string, cli client.Client, advancedOpts types.AdvancedOptions, containers []types.Container) (metav1.ObjectMeta, error) {
 	meta := metav1.ObjectMeta{
 		Labels:      map[string]string{"app": name},
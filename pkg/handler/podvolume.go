@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	resterror "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+// scPodSpec is the part of pod owner which is converted to k8s pod spec
+type scPodSpec struct {
+	Containers        []types.Container
+	InitContainers    []types.Container
+	Volumes           []types.PodVolume
+	PersistentVolumes []types.PersistentVolumeTemplate
+}
+
+func getPodOwnerPodSpec(podOwner interface{}) scPodSpec {
+	return scPodSpec{
+		Containers:        getPodOwnerField(podOwner, "Containers", []types.Container(nil)).([]types.Container),
+		InitContainers:    getPodOwnerField(podOwner, "InitContainers", []types.Container(nil)).([]types.Container),
+		Volumes:           getPodOwnerField(podOwner, "Volumes", []types.PodVolume(nil)).([]types.PodVolume),
+		PersistentVolumes: getPodOwnerField(podOwner, "PersistentVolumes", []types.PersistentVolumeTemplate(nil)).([]types.PersistentVolumeTemplate),
+	}
+}
+
+// hostPath volume exposes node filesystem, only admin could use it
+func validatePodVolumesPermission(user string, volumes []types.PodVolume) *resterror.APIError {
+	for _, v := range volumes {
+		if v.Type == types.VolumeTypeHostPath && isAdmin(user) == false {
+			return resterror.NewAPIError(resterror.PermissionDenied, fmt.Sprintf("only admin can use hostPath volume %s", v.Name))
+		}
+	}
+	return nil
+}
+
+func validatePodVolumes(volumes []types.PodVolume, pvs []types.PersistentVolumeTemplate) error {
+	names := make(map[string]bool)
+	for _, pv := range pvs {
+		names[pv.Name] = true
+	}
+
+	for _, v := range volumes {
+		if names[v.Name] {
+			return fmt.Errorf("duplicate volume name %s", v.Name)
+		}
+		names[v.Name] = true
+	}
+	return nil
+}
+
+func getPodVolume(volumes []types.PodVolume, name, typ string) (types.PodVolume, error) {
+	for _, v := range volumes {
+		if v.Name == name {
+			if v.Type != typ {
+				return v, fmt.Errorf("volume %s is %s not %s", name, v.Type, typ)
+			}
+			return v, nil
+		}
+	}
+	return types.PodVolume{}, fmt.Errorf("no found %s volume %s", typ, name)
+}
+
+func scPodVolumeToK8sVolumeSource(v types.PodVolume) (corev1.VolumeSource, error) {
+	switch v.Type {
+	case types.VolumeTypeEmptyDir:
+		emptyDir := &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMedium(v.Medium)}
+		if v.SizeLimit != "" {
+			quantity, err := resource.ParseQuantity(v.SizeLimit)
+			if err != nil {
+				return corev1.VolumeSource{}, fmt.Errorf("parse volume %s size limit %s failed: %s", v.Name, v.SizeLimit, err.Error())
+			}
+			emptyDir.SizeLimit = &quantity
+		}
+		return corev1.VolumeSource{EmptyDir: emptyDir}, nil
+	case types.VolumeTypeHostPath:
+		if v.Path == "" {
+			return corev1.VolumeSource{}, fmt.Errorf("hostPath volume %s has no path", v.Name)
+		}
+		hostPathType := corev1.HostPathType(v.HostPathType)
+		return corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: v.Path, Type: &hostPathType}}, nil
+	case types.VolumeTypeProjected:
+		if len(v.Sources) == 0 {
+			return corev1.VolumeSource{}, fmt.Errorf("projected volume %s has no source", v.Name)
+		}
+		var sources []corev1.VolumeProjection
+		for _, s := range v.Sources {
+			source, err := scProjectedSourceToK8sVolumeProjection(s)
+			if err != nil {
+				return corev1.VolumeSource{}, fmt.Errorf("projected volume %s has invalid source: %s", v.Name, err.Error())
+			}
+			sources = append(sources, source)
+		}
+		return corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: sources}}, nil
+	default:
+		return corev1.VolumeSource{}, fmt.Errorf("volume type %s is unsupported", v.Type)
+	}
+}
+
+func scProjectedSourceToK8sVolumeProjection(s types.ProjectedSource) (corev1.VolumeProjection, error) {
+	switch s.Type {
+	case types.ProjectedSourceTypeConfigMap, types.ProjectedSourceTypeSecret:
+		if s.Name == "" {
+			return corev1.VolumeProjection{}, fmt.Errorf("%s source has no name", s.Type)
+		}
+		var items []corev1.KeyToPath
+		for _, key := range sortedKeys(s.Items) {
+			items = append(items, corev1.KeyToPath{Key: key, Path: s.Items[key]})
+		}
+		if s.Type == types.ProjectedSourceTypeConfigMap {
+			return corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: s.Name},
+				Items:                items,
+			}}, nil
+		}
+		return corev1.VolumeProjection{Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{Name: s.Name},
+			Items:                items,
+		}}, nil
+	case types.ProjectedSourceTypeDownwardAPI:
+		if len(s.Items) == 0 {
+			return corev1.VolumeProjection{}, fmt.Errorf("downwardAPI source has no item")
+		}
+		var items []corev1.DownwardAPIVolumeFile
+		for _, path := range sortedKeys(s.Items) {
+			items = append(items, corev1.DownwardAPIVolumeFile{
+				Path:     path,
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: s.Items[path]},
+			})
+		}
+		return corev1.VolumeProjection{DownwardAPI: &corev1.DownwardAPIProjection{Items: items}}, nil
+	case types.ProjectedSourceTypeServiceAccountToken:
+		if s.Path == "" {
+			return corev1.VolumeProjection{}, fmt.Errorf("serviceAccountToken source has no path")
+		}
+		token := &corev1.ServiceAccountTokenProjection{Path: s.Path, Audience: s.Audience}
+		if s.ExpirationSeconds != 0 {
+			expiration := int64(s.ExpirationSeconds)
+			token.ExpirationSeconds = &expiration
+		}
+		return corev1.VolumeProjection{ServiceAccountToken: token}, nil
+	default:
+		return corev1.VolumeProjection{}, fmt.Errorf("source type %s is unsupported", s.Type)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isPodVolume returns whether k8s volume is pod volume, emptyDir on disk is
+// persistent volume with temporary storage class for compatibility
+func isPodVolume(v corev1.Volume) bool {
+	return v.HostPath != nil || v.Projected != nil ||
+		(v.EmptyDir != nil && v.EmptyDir.Medium == corev1.StorageMediumMemory)
+}
+
+func k8sVolumeToScPodVolumeType(v corev1.Volume) string {
+	switch {
+	case v.HostPath != nil:
+		return types.VolumeTypeHostPath
+	case v.Projected != nil:
+		return types.VolumeTypeProjected
+	default:
+		return types.VolumeTypeEmptyDir
+	}
+}
+
+func k8sVolumesToScPodVolumes(k8sVolumes []corev1.Volume) []types.PodVolume {
+	var volumes []types.PodVolume
+	for _, v := range k8sVolumes {
+		if isPodVolume(v) == false {
+			continue
+		}
+
+		volume := types.PodVolume{Name: v.Name, Type: k8sVolumeToScPodVolumeType(v)}
+		switch {
+		case v.EmptyDir != nil:
+			volume.Medium = string(v.EmptyDir.Medium)
+			if v.EmptyDir.SizeLimit != nil {
+				volume.SizeLimit = v.EmptyDir.SizeLimit.String()
+			}
+		case v.HostPath != nil:
+			volume.Path = v.HostPath.Path
+			if v.HostPath.Type != nil {
+				volume.HostPathType = string(*v.HostPath.Type)
+			}
+		case v.Projected != nil:
+			volume.Sources = k8sVolumeProjectionsToScProjectedSources(v.Projected.Sources)
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+func k8sVolumeProjectionsToScProjectedSources(k8sSources []corev1.VolumeProjection) []types.ProjectedSource {
+	var sources []types.ProjectedSource
+	for _, k8sSource := range k8sSources {
+		var source types.ProjectedSource
+		switch {
+		case k8sSource.ConfigMap != nil:
+			source.Type = types.ProjectedSourceTypeConfigMap
+			source.Name = k8sSource.ConfigMap.Name
+			source.Items = k8sKeyToPathsToScItems(k8sSource.ConfigMap.Items)
+		case k8sSource.Secret != nil:
+			source.Type = types.ProjectedSourceTypeSecret
+			source.Name = k8sSource.Secret.Name
+			source.Items = k8sKeyToPathsToScItems(k8sSource.Secret.Items)
+		case k8sSource.DownwardAPI != nil:
+			source.Type = types.ProjectedSourceTypeDownwardAPI
+			source.Items = make(map[string]string)
+			for _, item := range k8sSource.DownwardAPI.Items {
+				if item.FieldRef != nil {
+					source.Items[item.Path] = item.FieldRef.FieldPath
+				}
+			}
+		case k8sSource.ServiceAccountToken != nil:
+			source.Type = types.ProjectedSourceTypeServiceAccountToken
+			source.Path = k8sSource.ServiceAccountToken.Path
+			source.Audience = k8sSource.ServiceAccountToken.Audience
+			if k8sSource.ServiceAccountToken.ExpirationSeconds != nil {
+				source.ExpirationSeconds = int(*k8sSource.ServiceAccountToken.ExpirationSeconds)
+			}
+		default:
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func k8sKeyToPathsToScItems(k8sItems []corev1.KeyToPath) map[string]string {
+	if len(k8sItems) == 0 {
+		return nil
+	}
+
+	items := make(map[string]string)
+	for _, item := range k8sItems {
+		items[item.Key] = item.Path
+	}
+	return items
+}
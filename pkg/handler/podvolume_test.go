@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestPodVolumesRoundTrip(t *testing.T) {
+	spec := scPodSpec{
+		InitContainers: []types.Container{
+			{
+				Name:    "init",
+				Image:   "busybox",
+				Volumes: []types.Volume{{Type: types.VolumeTypeEmptyDir, Name: "cache", MountPath: "/cache"}},
+			},
+		},
+		Containers: []types.Container{
+			{
+				Name:  "web",
+				Image: "nginx",
+				Volumes: []types.Volume{
+					{Type: types.VolumeTypeEmptyDir, Name: "cache", MountPath: "/usr/share/nginx/html", SubPath: "html"},
+					{Type: types.VolumeTypeHostPath, Name: "log", MountPath: "/var/log/nginx"},
+					{Type: types.VolumeTypeProjected, Name: "info", MountPath: "/etc/info"},
+				},
+			},
+		},
+		Volumes: []types.PodVolume{
+			{Name: "cache", Type: types.VolumeTypeEmptyDir, Medium: types.StorageMediumMemory, SizeLimit: "64Mi"},
+			{Name: "log", Type: types.VolumeTypeHostPath, Path: "/var/log/web", HostPathType: "DirectoryOrCreate"},
+			{Name: "info", Type: types.VolumeTypeProjected, Sources: []types.ProjectedSource{
+				{Type: types.ProjectedSourceTypeDownwardAPI, Items: map[string]string{"labels": "metadata.labels"}},
+				{Type: types.ProjectedSourceTypeServiceAccountToken, Path: "token", ExpirationSeconds: 3600},
+			}},
+		},
+	}
+
+	k8sPodSpec, err := scContainersAndPVToK8sPodSpec(spec, nil, nil)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, len(k8sPodSpec.InitContainers), 1)
+	ut.Equal(t, len(k8sPodSpec.Volumes), 3)
+	ut.Equal(t, k8sPodSpec.Volumes[0].EmptyDir.Medium, corev1.StorageMediumMemory)
+	ut.Equal(t, k8sPodSpec.Containers[0].VolumeMounts[0].SubPath, "html")
+	ut.Equal(t, k8sPodSpec.Containers[0].VolumeMounts[2].ReadOnly, true)
+
+	got := k8sPodSpecToScPodSpec(k8sPodSpec)
+	ut.Equal(t, got.Volumes, spec.Volumes)
+	ut.Equal(t, got.InitContainers[0].Volumes, spec.InitContainers[0].Volumes)
+	ut.Equal(t, got.Containers[0].Volumes, spec.Containers[0].Volumes)
+	ut.Equal(t, len(got.PersistentVolumes), 0)
+
+	ut.Equal(t, validatePodVolumesPermission(types.Administrator, spec.Volumes) == nil, true)
+	ut.Equal(t, validatePodVolumesPermission("user", spec.Volumes) == nil, false)
+}
+
+func TestPodVolumesInvalid(t *testing.T) {
+	spec := scPodSpec{
+		Containers: []types.Container{
+			{
+				Name:    "web",
+				Image:   "nginx",
+				Volumes: []types.Volume{{Type: types.VolumeTypeHostPath, Name: "cache", MountPath: "/cache"}},
+			},
+		},
+		Volumes: []types.PodVolume{{Name: "cache", Type: types.VolumeTypeEmptyDir}},
+	}
+	_, err := scContainersAndPVToK8sPodSpec(spec, nil, nil)
+	ut.Assert(t, err != nil, "mount type should match pod volume type")
+
+	spec.Containers[0].Volumes[0].Type = types.VolumeTypeEmptyDir
+	spec.InitContainers = []types.Container{{Name: "web", Image: "busybox"}}
+	_, err = scContainersAndPVToK8sPodSpec(spec, nil, nil)
+	ut.Assert(t, err != nil, "container name should be unique in pod")
+
+	spec.InitContainers = []types.Container{{Name: "init", Image: "busybox", ReadinessProbe: &types.Probe{Type: types.ProbeTypeTCP, Port: 80}}}
+	_, err = scContainersAndPVToK8sPodSpec(spec, nil, nil)
+	ut.Assert(t, err != nil, "init container shouldn't have probe")
+
+	err = validatePodVolumes(spec.Volumes, []types.PersistentVolumeTemplate{{Name: "cache"}})
+	ut.Assert(t, err != nil, "pod volume name should differ from persistent volume")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path"
+	"sync"
 
 	"github.com/zdnscloud/singlecloud/config"
 	"github.com/zdnscloud/singlecloud/pkg/charts"
@@ -28,6 +29,7 @@ const (
 
 type RegistryManager struct {
 	clusters *ClusterManager
+	caLock   sync.RWMutex
 	ca       x509.Certificate
 	chartDir string
 }
@@ -63,6 +65,26 @@ func loadRegistryCA(caConfig config.RegistryCAConf) (x509.Certificate, error) {
 	return ca, nil
 }
 
+func (m *RegistryManager) getCA() x509.Certificate {
+	m.caLock.RLock()
+	defer m.caLock.RUnlock()
+	return m.ca
+}
+
+// new ca only affects registries created later
+func (m *RegistryManager) ReloadConfig(conf *config.SinglecloudConf) (func(), error) {
+	ca, err := loadRegistryCA(conf.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		m.caLock.Lock()
+		defer m.caLock.Unlock()
+		m.ca = ca
+	}, nil
+}
+
 func (m *RegistryManager) Create(ctx *restresource.Context) (restresource.Resource, *resterr.APIError) {
 	if isAdmin(getCurrentUser(ctx)) == false {
 		return nil, resterr.NewAPIError(resterr.PermissionDenied, "only admin can create registry")
@@ -74,7 +96,7 @@ func (m *RegistryManager) Create(ctx *restresource.Context) (restresource.Resour
 	}
 
 	registry := ctx.Resource.(*types.Registry)
-	app, err := genRegistryApplication(cluster, registry, m.ca)
+	app, err := genRegistryApplication(cluster, registry, m.getCA())
 	if err != nil {
 		return nil, resterr.NewAPIError(types.ConnectClusterFailed, err.Error())
 	}
@@ -84,7 +106,9 @@ func (m *RegistryManager) Create(ctx *restresource.Context) (restresource.Resour
 	}
 
 	registry.SetID(registryAppName)
-	return registry, nil
+	cp := *registry
+	cp.AdminPassword = ""
+	return &cp, nil
 }
 
 func (m *RegistryManager) List(ctx *restresource.Context) (interface{}, *resterr.APIError) {
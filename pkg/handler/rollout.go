@@ -0,0 +1,717 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/gok8s/cache"
+	"github.com/zdnscloud/gok8s/client"
+	resterror "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	AnnKeyForRolloutState   = "rollout.zcloud.cn/state"
+	AnnKeyForRolloutOwner   = "rollout.zcloud.cn/owner"
+	LabelKeyForRollout      = "rollout.zcloud.cn/active"
+	LabelKeyForRolloutTrack = "rollout.zcloud.cn/track"
+
+	rolloutTrackCanary  = "canary"
+	rolloutTrackGreen   = "green"
+	canaryServiceSuffix = "-canary"
+	trafficSplitSuffix  = "-rollout"
+
+	trafficSplitAPIVersion = "split.smi-spec.io/v1alpha1"
+	trafficSplitKind       = "TrafficSplit"
+
+	rolloutSyncInterval = 10 * time.Second
+	maxRolloutWeight    = 100
+)
+
+var defaultCanarySteps = []int{10, 50}
+
+// rolloutState is saved in annotation of the deployment, new version
+// runs in another deployment named with track suffix until promoted
+type rolloutState struct {
+	Strategy            string    `json:"strategy"`
+	Phase               string    `json:"phase"`
+	Steps               []int     `json:"steps,omitempty"`
+	StepIntervalSeconds int       `json:"stepIntervalSeconds,omitempty"`
+	CurrentStep         int       `json:"currentStep"`
+	Replicas            int       `json:"replicas"`
+	TrafficSplit        bool      `json:"trafficSplit,omitempty"`
+	Services            []string  `json:"services,omitempty"`
+	Memo                string    `json:"memo,omitempty"`
+	Message             string    `json:"message,omitempty"`
+	LastTransitionTime  time.Time `json:"lastTransitionTime"`
+}
+
+func (s *rolloutState) transit(phase, message string) {
+	s.Phase = phase
+	s.Message = message
+	s.LastTransitionTime = time.Now()
+}
+
+func (s *rolloutState) currentWeight() int {
+	if s.CurrentStep < len(s.Steps) {
+		return s.Steps[s.CurrentStep]
+	}
+	return maxRolloutWeight
+}
+
+func getRolloutState(k8sDeploy *appsv1.Deployment) (*rolloutState, error) {
+	data, ok := k8sDeploy.Annotations[AnnKeyForRolloutState]
+	if ok == false {
+		return nil, nil
+	}
+
+	var state rolloutState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("unmarshal rollout state of deployment %s failed: %s", k8sDeploy.Name, err.Error())
+	}
+	return &state, nil
+}
+
+// setRolloutState removes rollout state when state is nil, the
+// deployment should be updated by caller
+func setRolloutState(k8sDeploy *appsv1.Deployment, state *rolloutState) {
+	if state == nil {
+		delete(k8sDeploy.Annotations, AnnKeyForRolloutState)
+		delete(k8sDeploy.Labels, LabelKeyForRollout)
+		return
+	}
+
+	data, _ := json.Marshal(state)
+	if k8sDeploy.Annotations == nil {
+		k8sDeploy.Annotations = make(map[string]string)
+	}
+	if k8sDeploy.Labels == nil {
+		k8sDeploy.Labels = make(map[string]string)
+	}
+	k8sDeploy.Annotations[AnnKeyForRolloutState] = string(data)
+	k8sDeploy.Labels[LabelKeyForRollout] = "true"
+}
+
+func isDeploymentInRollout(k8sDeploy *appsv1.Deployment) bool {
+	_, ok := k8sDeploy.Annotations[AnnKeyForRolloutState]
+	return ok
+}
+
+func getRolloutDeployName(name, strategy string) string {
+	if strategy == types.RolloutStrategyBlueGreen {
+		return name + "-" + rolloutTrackGreen
+	}
+	return name + "-" + rolloutTrackCanary
+}
+
+func canaryReplicas(replicas, weight int) int {
+	n := (replicas*weight + maxRolloutWeight - 1) / maxRolloutWeight
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+func isDeploymentReady(k8sDeploy *appsv1.Deployment) bool {
+	replicas := replicasOf(k8sDeploy.Spec.Replicas)
+	status := k8sDeploy.Status
+	return status.ObservedGeneration >= k8sDeploy.Generation &&
+		int(status.UpdatedReplicas) == replicas &&
+		int(status.AvailableReplicas) == replicas &&
+		int(status.Replicas) == replicas
+}
+
+func validateRollout(rollout *types.Rollout) error {
+	switch rollout.Strategy {
+	case types.RolloutStrategyCanary:
+		last := 0
+		for _, step := range rollout.Steps {
+			if step <= last || step >= maxRolloutWeight {
+				return fmt.Errorf("canary steps should be increasing percentages between 1 and 99")
+			}
+			last = step
+		}
+	case types.RolloutStrategyBlueGreen:
+		if len(rollout.Steps) > 0 {
+			return fmt.Errorf("blue green rollout has no steps")
+		}
+	default:
+		return fmt.Errorf("rollout strategy %s isn't supported", rollout.Strategy)
+	}
+
+	if rollout.StepIntervalSeconds < 0 {
+		return fmt.Errorf("step interval should not be negative")
+	}
+	return nil
+}
+
+func startRollout(cli client.Client, k8sDeploy *appsv1.Deployment, rollout *types.Rollout) error {
+	if isDeploymentInRollout(k8sDeploy) {
+		return fmt.Errorf("deployment %s is already in rollout", k8sDeploy.Name)
+	}
+
+	if err := validateRollout(rollout); err != nil {
+		return err
+	}
+
+	namespace := k8sDeploy.Namespace
+	spec := k8sPodSpecToScPodSpec(k8sDeploy.Spec.Template.Spec)
+	spec.Containers = rollout.Containers
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cli, spec)
+	if err != nil {
+		return err
+	}
+
+	state := &rolloutState{
+		Strategy:            rollout.Strategy,
+		Steps:               rollout.Steps,
+		StepIntervalSeconds: rollout.StepIntervalSeconds,
+		Replicas:            replicasOf(k8sDeploy.Spec.Replicas),
+		Memo:                rollout.Memo,
+	}
+	if state.Strategy == types.RolloutStrategyCanary && len(state.Steps) == 0 {
+		state.Steps = defaultCanarySteps
+	}
+
+	services, err := getWorkloadServices(cli, namespace, k8sDeploy.Spec.Template.Labels)
+	if err != nil {
+		return fmt.Errorf("get services of deployment failed: %s", err.Error())
+	}
+	for _, svc := range services {
+		state.Services = append(state.Services, svc.Name)
+	}
+
+	newName := getRolloutDeployName(k8sDeploy.Name, state.Strategy)
+	template := k8sDeploy.Spec.Template.DeepCopy()
+	template.Spec.Containers = k8sPodSpec.Containers
+	template.Spec.InitContainers = k8sPodSpec.InitContainers
+	template.Spec.Volumes = k8sPodSpec.Volumes
+	template.Labels = make(map[string]string)
+	for k, v := range k8sDeploy.Spec.Template.Labels {
+		template.Labels[k] = v
+	}
+
+	newReplicas := state.Replicas
+	if state.Strategy == types.RolloutStrategyCanary {
+		newReplicas = canaryReplicas(state.Replicas, state.currentWeight())
+		template.Labels[LabelKeyForRolloutTrack] = rolloutTrackCanary
+		//with service mesh, canary pods are excluded from services by
+		//app label and traffic is split by weight, so services should
+		//only select app label
+		state.TrafficSplit = len(services) > 0 && template.Annotations[AnnKeyForInjectServiceMesh] == "enabled" &&
+			isAppSelectorServices(services, k8sDeploy.Name)
+		if state.TrafficSplit {
+			template.Labels["app"] = newName
+		}
+	} else {
+		//green pods shouldn't get traffic before services are switched
+		template.Labels = greenPodLabels(template.Labels, services, newName)
+	}
+
+	if err := validatePodSecurity(cli, namespace, template); err != nil {
+		return err
+	}
+	if err := validateWorkloadResources(cli, namespace, nil, 0, &template.Spec, newReplicas); err != nil {
+		return err
+	}
+
+	replicas := int32(newReplicas)
+	newDeploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      newName,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				AnnKeyForRolloutOwner: k8sDeploy.Name,
+				ChangeCauseAnnotation: rollout.Memo,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: template.Labels},
+			Template: *template,
+		},
+	}
+	if err := cli.Create(context.TODO(), newDeploy); err != nil {
+		return fmt.Errorf("create deployment %s failed: %s", newName, err.Error())
+	}
+
+	if state.TrafficSplit {
+		for _, svc := range services {
+			if err := createCanaryService(cli, &svc, template.Labels); err != nil {
+				cleanupRollout(cli, k8sDeploy, state)
+				return err
+			}
+		}
+	}
+
+	state.transit(types.RolloutPhaseProgressing, "")
+	if err := applyCanaryWeight(cli, k8sDeploy, state); err != nil {
+		cleanupRollout(cli, k8sDeploy, state)
+		return err
+	}
+
+	setRolloutState(k8sDeploy, state)
+	if err := cli.Update(context.TODO(), k8sDeploy); err != nil {
+		cleanupRollout(cli, k8sDeploy, state)
+		return fmt.Errorf("update deployment %s failed: %s", k8sDeploy.Name, err.Error())
+	}
+	return nil
+}
+
+// applyCanaryWeight scales canary deployment to current step, the
+// deployment itself is scaled down if traffic isn't split by service
+// mesh, so proportion of replicas is the proportion of traffic, the
+// deployment is updated by caller
+func applyCanaryWeight(cli client.Client, k8sDeploy *appsv1.Deployment, state *rolloutState) error {
+	if state.Strategy != types.RolloutStrategyCanary {
+		return nil
+	}
+
+	weight := state.currentWeight()
+	newReplicas := canaryReplicas(state.Replicas, weight)
+	if err := scaleDeployment(cli, k8sDeploy.Namespace, getRolloutDeployName(k8sDeploy.Name, state.Strategy), newReplicas); err != nil {
+		return err
+	}
+
+	if state.TrafficSplit {
+		for _, svc := range state.Services {
+			if err := applyTrafficSplit(cli, k8sDeploy.Namespace, svc, maxRolloutWeight-weight, weight); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	replicas := int32(state.Replicas - newReplicas)
+	if replicas < 0 {
+		replicas = 0
+	}
+	k8sDeploy.Spec.Replicas = &replicas
+	return nil
+}
+
+func scaleDeployment(cli client.Client, namespace, name string, replicas int) error {
+	k8sDeploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	if err := cli.Patch(context.TODO(), k8sDeploy, k8stypes.MergePatchType,
+		[]byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))); err != nil {
+		return fmt.Errorf("scale deployment %s failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// syncRollout moves rollout forward, it's called periodically and
+// after rollout actions
+func syncRollout(cli client.Client, k8sDeploy *appsv1.Deployment) error {
+	state, err := getRolloutState(k8sDeploy)
+	if err != nil || state == nil {
+		return err
+	}
+
+	newDeploy, apiErr := getDeployment(cli, k8sDeploy.Namespace, getRolloutDeployName(k8sDeploy.Name, state.Strategy))
+	if apiErr != nil {
+		if apiErr.ErrorCode == resterror.NotFound && state.Phase != types.RolloutPhasePromoting {
+			logger.Warnf("deployment %s of rollout is removed, abort rollout of %s/%s", getRolloutDeployName(k8sDeploy.Name, state.Strategy),
+				k8sDeploy.Namespace, k8sDeploy.Name)
+			return abortRollout(cli, k8sDeploy)
+		} else if apiErr.ErrorCode != resterror.NotFound {
+			return fmt.Errorf("%s", apiErr.Message)
+		}
+	}
+
+	switch state.Phase {
+	case types.RolloutPhaseProgressing:
+		if newDeploy == nil || isDeploymentReady(newDeploy) == false {
+			return nil
+		}
+
+		if state.Strategy == types.RolloutStrategyBlueGreen {
+			if err := switchServices(cli, k8sDeploy.Namespace, state.Services, newDeploy.Spec.Template.Labels); err != nil {
+				return err
+			}
+			state.transit(types.RolloutPhaseSwitched, "services are switched to new version")
+			return updateRolloutState(cli, k8sDeploy, state)
+		}
+
+		interval := time.Duration(state.StepIntervalSeconds) * time.Second
+		if interval > 0 && time.Since(state.LastTransitionTime) >= interval {
+			return advanceRollout(cli, k8sDeploy, newDeploy, state)
+		}
+	case types.RolloutPhasePromoting:
+		if isDeploymentReady(k8sDeploy) == false {
+			return nil
+		}
+
+		if err := cleanupRollout(cli, k8sDeploy, state); err != nil {
+			return err
+		}
+		setRolloutState(k8sDeploy, nil)
+		return cli.Update(context.TODO(), k8sDeploy)
+	}
+	return nil
+}
+
+func updateRolloutState(cli client.Client, k8sDeploy *appsv1.Deployment, state *rolloutState) error {
+	setRolloutState(k8sDeploy, state)
+	if err := cli.Update(context.TODO(), k8sDeploy); err != nil {
+		return fmt.Errorf("update deployment %s failed: %s", k8sDeploy.Name, err.Error())
+	}
+	return nil
+}
+
+func advanceRollout(cli client.Client, k8sDeploy, newDeploy *appsv1.Deployment, state *rolloutState) error {
+	state.CurrentStep += 1
+	if state.CurrentStep >= len(state.Steps) {
+		return promoteRolloutToDeployment(cli, k8sDeploy, newDeploy, state)
+	}
+
+	state.transit(types.RolloutPhaseProgressing, fmt.Sprintf("step %d with weight %d%%", state.CurrentStep+1, state.currentWeight()))
+	if err := applyCanaryWeight(cli, k8sDeploy, state); err != nil {
+		return err
+	}
+	return updateRolloutState(cli, k8sDeploy, state)
+}
+
+// promoteRolloutToDeployment updates the deployment to new version,
+// resources of rollout are removed after the deployment is ready
+func promoteRolloutToDeployment(cli client.Client, k8sDeploy, newDeploy *appsv1.Deployment, state *rolloutState) error {
+	template := newDeploy.Spec.Template.DeepCopy()
+	template.Labels = k8sDeploy.Spec.Template.Labels
+	k8sDeploy.Spec.Template = *template
+	replicas := int32(state.Replicas)
+	k8sDeploy.Spec.Replicas = &replicas
+	k8sDeploy.Annotations = addWorkloadUpdateMemoToAnnotations(k8sDeploy.Annotations, state.Memo)
+	state.transit(types.RolloutPhasePromoting, "deployment is updating to new version")
+	return updateRolloutState(cli, k8sDeploy, state)
+}
+
+func pauseRollout(cli client.Client, k8sDeploy *appsv1.Deployment) error {
+	state, err := getRolloutStateForAction(k8sDeploy)
+	if err != nil {
+		return err
+	}
+
+	if state.Strategy != types.RolloutStrategyCanary || state.Phase != types.RolloutPhaseProgressing {
+		return fmt.Errorf("only progressing canary rollout could be paused")
+	}
+
+	state.transit(types.RolloutPhasePaused, "paused by user")
+	return updateRolloutState(cli, k8sDeploy, state)
+}
+
+// promote moves canary to next step and resumes paused canary, blue
+// green is promoted after services are switched
+func promoteRollout(cli client.Client, k8sDeploy *appsv1.Deployment) error {
+	state, err := getRolloutStateForAction(k8sDeploy)
+	if err != nil {
+		return err
+	}
+
+	newDeploy, apiErr := getDeployment(cli, k8sDeploy.Namespace, getRolloutDeployName(k8sDeploy.Name, state.Strategy))
+	if apiErr != nil {
+		return fmt.Errorf("%s", apiErr.Message)
+	}
+
+	switch state.Phase {
+	case types.RolloutPhaseProgressing, types.RolloutPhasePaused:
+		if state.Strategy == types.RolloutStrategyBlueGreen {
+			return fmt.Errorf("new version isn't ready, services aren't switched")
+		}
+		return advanceRollout(cli, k8sDeploy, newDeploy, state)
+	case types.RolloutPhaseSwitched:
+		return promoteRolloutToDeployment(cli, k8sDeploy, newDeploy, state)
+	default:
+		return fmt.Errorf("rollout is already promoting")
+	}
+}
+
+// abortRollout restores the deployment to the state before rollout,
+// promoting rollout can't be aborted since the deployment is updating
+func abortRollout(cli client.Client, k8sDeploy *appsv1.Deployment) error {
+	state, err := getRolloutStateForAction(k8sDeploy)
+	if err != nil {
+		return err
+	}
+
+	if state.Phase == types.RolloutPhasePromoting {
+		return fmt.Errorf("promoting rollout can't be aborted, rollback the deployment instead")
+	}
+
+	if err := cleanupRollout(cli, k8sDeploy, state); err != nil {
+		return err
+	}
+
+	replicas := int32(state.Replicas)
+	k8sDeploy.Spec.Replicas = &replicas
+	setRolloutState(k8sDeploy, nil)
+	return cli.Update(context.TODO(), k8sDeploy)
+}
+
+func getRolloutStateForAction(k8sDeploy *appsv1.Deployment) (*rolloutState, error) {
+	state, err := getRolloutState(k8sDeploy)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("deployment %s isn't in rollout", k8sDeploy.Name)
+	}
+	return state, nil
+}
+
+// cleanupRollout switches traffic back to the deployment and removes
+// resources created by rollout
+func cleanupRollout(cli client.Client, k8sDeploy *appsv1.Deployment, state *rolloutState) error {
+	namespace := k8sDeploy.Namespace
+	if state.Strategy == types.RolloutStrategyBlueGreen {
+		if err := switchServices(cli, namespace, state.Services, k8sDeploy.Spec.Template.Labels); err != nil {
+			return err
+		}
+	} else if state.TrafficSplit {
+		for _, svc := range state.Services {
+			if err := deleteIgnoreNotFound(cli, newTrafficSplit(namespace, svc, 0, 0)); err != nil {
+				return err
+			}
+			canarySvc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: svc + canaryServiceSuffix, Namespace: namespace}}
+			if err := deleteIgnoreNotFound(cli, canarySvc); err != nil {
+				return err
+			}
+		}
+	}
+
+	newDeploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: getRolloutDeployName(k8sDeploy.Name, state.Strategy), Namespace: namespace}}
+	return deleteIgnoreNotFound(cli, newDeploy)
+}
+
+func deleteIgnoreNotFound(cli client.Client, obj runtime.Object) error {
+	if err := cli.Delete(context.TODO(), obj); err != nil && apierrors.IsNotFound(err) == false {
+		return fmt.Errorf("delete %s failed: %s", obj.(metav1.Object).GetName(), err.Error())
+	}
+	return nil
+}
+
+// getWorkloadServices returns services whose selector selects pods
+// with podLabels
+func getWorkloadServices(cli client.Client, namespace string, podLabels map[string]string) ([]corev1.Service, error) {
+	k8sServices := corev1.ServiceList{}
+	if err := cli.List(context.TODO(), &client.ListOptions{Namespace: namespace}, &k8sServices); err != nil {
+		return nil, err
+	}
+
+	var services []corev1.Service
+	for _, svc := range k8sServices.Items {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		matched := true
+		for k, v := range svc.Spec.Selector {
+			if podLabels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+func isAppSelectorServices(services []corev1.Service, app string) bool {
+	for _, svc := range services {
+		if len(svc.Spec.Selector) != 1 || svc.Spec.Selector["app"] != app {
+			return false
+		}
+	}
+	return true
+}
+
+// greenPodLabels replaces values of labels selected by services with name
+// of green deployment, so green pods are selected only after switch
+func greenPodLabels(podLabels map[string]string, services []corev1.Service, greenName string) map[string]string {
+	labels := copyLabels(podLabels)
+	for _, svc := range services {
+		for k := range svc.Spec.Selector {
+			labels[k] = greenName
+		}
+	}
+	labels[LabelKeyForRolloutTrack] = rolloutTrackGreen
+	return labels
+}
+
+// switchServices sets selector of services to podLabels, which are
+// labels of green pods when switching to new version, otherwise labels of
+// the deployment
+func switchServices(cli client.Client, namespace string, services []string, podLabels map[string]string) error {
+	toGreen := podLabels[LabelKeyForRolloutTrack] == rolloutTrackGreen
+	for _, name := range services {
+		svc := corev1.Service{}
+		if err := cli.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, &svc); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("get service %s failed: %s", name, err.Error())
+		}
+
+		for k := range svc.Spec.Selector {
+			if v, ok := podLabels[k]; ok {
+				svc.Spec.Selector[k] = v
+			}
+		}
+		if toGreen {
+			svc.Spec.Selector[LabelKeyForRolloutTrack] = rolloutTrackGreen
+		} else {
+			delete(svc.Spec.Selector, LabelKeyForRolloutTrack)
+		}
+		if err := cli.Update(context.TODO(), &svc); err != nil {
+			return fmt.Errorf("update service %s failed: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func createCanaryService(cli client.Client, svc *corev1.Service, canaryLabels map[string]string) error {
+	var ports []corev1.ServicePort
+	for _, p := range svc.Spec.Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   p.Protocol,
+			Port:       p.Port,
+			TargetPort: p.TargetPort,
+		})
+	}
+
+	canarySvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        svc.Name + canaryServiceSuffix,
+			Namespace:   svc.Namespace,
+			Annotations: map[string]string{AnnKeyForRolloutOwner: svc.Name},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: canaryLabels,
+			Ports:    ports,
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+	if err := cli.Create(context.TODO(), canarySvc); err != nil && apierrors.IsAlreadyExists(err) == false {
+		return fmt.Errorf("create canary service %s failed: %s", canarySvc.Name, err.Error())
+	}
+	return nil
+}
+
+func newTrafficSplit(namespace, service string, stableWeight, canaryWeight int) *unstructured.Unstructured {
+	ts := &unstructured.Unstructured{}
+	ts.SetAPIVersion(trafficSplitAPIVersion)
+	ts.SetKind(trafficSplitKind)
+	ts.SetNamespace(namespace)
+	ts.SetName(service + trafficSplitSuffix)
+	ts.Object["spec"] = map[string]interface{}{
+		"service": service,
+		"backends": []interface{}{
+			map[string]interface{}{
+				"service": service,
+				"weight":  int64(stableWeight),
+			},
+			map[string]interface{}{
+				"service": service + canaryServiceSuffix,
+				"weight":  int64(canaryWeight),
+			},
+		},
+	}
+	return ts
+}
+
+func applyTrafficSplit(cli client.Client, namespace, service string, stableWeight, canaryWeight int) error {
+	ts := newTrafficSplit(namespace, service, stableWeight, canaryWeight)
+	current := &unstructured.Unstructured{}
+	current.SetAPIVersion(trafficSplitAPIVersion)
+	current.SetKind(trafficSplitKind)
+	err := cli.Get(context.TODO(), k8stypes.NamespacedName{namespace, ts.GetName()}, current)
+	if err != nil {
+		if apierrors.IsNotFound(err) == false {
+			return fmt.Errorf("get traffic split %s failed: %s", ts.GetName(), err.Error())
+		}
+		if err := cli.Create(context.TODO(), ts); err != nil {
+			return fmt.Errorf("create traffic split %s failed: %s", ts.GetName(), err.Error())
+		}
+		return nil
+	}
+
+	current.Object["spec"] = ts.Object["spec"]
+	if err := cli.Update(context.TODO(), current); err != nil {
+		return fmt.Errorf("update traffic split %s failed: %s", ts.GetName(), err.Error())
+	}
+	return nil
+}
+
+func k8sDeployToScRolloutStatus(cli client.Client, k8sDeploy *appsv1.Deployment) *types.RolloutStatus {
+	state, err := getRolloutState(k8sDeploy)
+	if err != nil || state == nil {
+		return nil
+	}
+
+	status := &types.RolloutStatus{
+		Strategy:           state.Strategy,
+		Phase:              state.Phase,
+		Steps:              state.Steps,
+		CurrentStep:        state.CurrentStep,
+		TrafficSplit:       state.TrafficSplit,
+		Message:            state.Message,
+		LastTransitionTime: resource.ISOTime(state.LastTransitionTime),
+	}
+	if newDeploy, err := getDeployment(cli, k8sDeploy.Namespace, getRolloutDeployName(k8sDeploy.Name, state.Strategy)); err == nil {
+		status.NewReplicas = replicasOf(newDeploy.Spec.Replicas)
+		status.ReadyNewReplicas = int(newDeploy.Status.ReadyReplicas)
+	}
+	return status
+}
+
+func (m *DeploymentManager) rolloutLoop() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(rolloutSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		for _, cluster := range m.clusters.zkeManager.ListReady() {
+			select {
+			case <-m.stopCh:
+				return
+			default:
+			}
+			syncClusterRollouts(cluster.GetKubeClient(), cluster.GetKubeCache())
+		}
+	}
+}
+
+// deployments in rollout are listed from cache, which is updated by
+// watch, so idle clusters don't cost requests to apiserver
+func syncClusterRollouts(cli client.Client, c cache.Cache) {
+	k8sDeploys := appsv1.DeploymentList{}
+	opts := &client.ListOptions{}
+	opts.MatchingLabels(map[string]string{LabelKeyForRollout: "true"})
+	if err := c.List(context.TODO(), opts, &k8sDeploys); err != nil {
+		logger.Warnf("list deployments in rollout failed %s", err.Error())
+		return
+	}
+
+	for i := range k8sDeploys.Items {
+		k8sDeploy := k8sDeploys.Items[i].DeepCopy()
+		if err := syncRollout(cli, k8sDeploy); err != nil {
+			logger.Warnf("sync rollout of deployment %s/%s failed %s", k8sDeploy.Namespace, k8sDeploy.Name, err.Error())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestCanaryReplicas(t *testing.T) {
+	ut.Equal(t, canaryReplicas(10, 10), 1)
+	ut.Equal(t, canaryReplicas(10, 25), 3)
+	ut.Equal(t, canaryReplicas(3, 10), 1)
+	ut.Equal(t, canaryReplicas(0, 50), 1)
+	ut.Equal(t, canaryReplicas(4, 100), 4)
+
+	state := &rolloutState{Steps: []int{20, 50}, CurrentStep: 1}
+	ut.Equal(t, state.currentWeight(), 50)
+	state.CurrentStep = 2
+	ut.Equal(t, state.currentWeight(), maxRolloutWeight)
+}
+
+func TestValidateRollout(t *testing.T) {
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: types.RolloutStrategyCanary}) == nil, "")
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: types.RolloutStrategyCanary, Steps: []int{10, 30, 60}}) == nil, "")
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: types.RolloutStrategyCanary, Steps: []int{30, 10}}) != nil, "")
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: types.RolloutStrategyCanary, Steps: []int{100}}) != nil, "")
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: types.RolloutStrategyBlueGreen, Steps: []int{50}}) != nil, "")
+	ut.Assert(t, validateRollout(&types.Rollout{Strategy: "recreate"}) != nil, "")
+}
+
+func TestRolloutState(t *testing.T) {
+	k8sDeploy := &appsv1.Deployment{}
+	state, err := getRolloutState(k8sDeploy)
+	ut.Assert(t, err == nil && state == nil, "")
+
+	state = &rolloutState{Strategy: types.RolloutStrategyBlueGreen, Replicas: 3, Services: []string{"web"}}
+	state.transit(types.RolloutPhaseSwitched, "")
+	setRolloutState(k8sDeploy, state)
+	ut.Assert(t, isDeploymentInRollout(k8sDeploy), "")
+	ut.Equal(t, k8sDeploy.Labels[LabelKeyForRollout], "true")
+
+	saved, err := getRolloutState(k8sDeploy)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, saved.Phase, types.RolloutPhaseSwitched)
+	ut.Equal(t, saved.Services, state.Services)
+
+	setRolloutState(k8sDeploy, nil)
+	ut.Assert(t, isDeploymentInRollout(k8sDeploy) == false, "")
+	ut.Equal(t, len(k8sDeploy.Labels), 0)
+}
+
+func TestGreenPodLabels(t *testing.T) {
+	podLabels := map[string]string{"app": "web", "tier": "frontend"}
+	services := []corev1.Service{{Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web"}}}}
+	labels := greenPodLabels(podLabels, services, "web-green")
+	ut.Equal(t, labels, map[string]string{"app": "web-green", "tier": "frontend", LabelKeyForRolloutTrack: rolloutTrackGreen})
+	ut.Equal(t, podLabels["app"], "web")
+}
+
+func TestIsAppSelectorServices(t *testing.T) {
+	services := []corev1.Service{{Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web"}}}}
+	ut.Assert(t, isAppSelectorServices(services, "web"), "")
+	services = append(services, corev1.Service{Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web", "tier": "frontend"}}})
+	ut.Assert(t, isAppSelectorServices(services, "web") == false, "service selecting other labels can't split traffic")
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	defaultTopologyKey       = "kubernetes.io/hostname"
+	preferredAffinityWeight  = 100
+	defaultTopologySpreadMax = 1
+)
+
+// labels and taints in these domains are managed by kubernetes, zke
+// and singlecloud, user can't change them
+var reservedLabelDomains = []string{"kubernetes.io", "k8s.io", "zcloud.cn"}
+
+func isReservedLabelKey(key string) bool {
+	i := strings.Index(key, "/")
+	if i == -1 {
+		return false
+	}
+
+	domain := key[:i]
+	for _, reserved := range reservedLabelDomains {
+		if domain == reserved || strings.HasSuffix(domain, "."+reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %s: %s", k, strings.Join(errs, ","))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %s: %s", v, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// selectors get their own copy of labels, so changing pod labels of
+// template or labels of request won't change them
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	cp := make(map[string]string, len(labels))
+	for k, v := range labels {
+		cp[k] = v
+	}
+	return cp
+}
+
+func getPodOwnerScheduling(podOwner interface{}) types.Scheduling {
+	return getPodOwnerField(podOwner, "Scheduling", types.Scheduling{}).(types.Scheduling)
+}
+
+// setK8sPodSpecScheduling overwrites scheduling fields of pod spec,
+// podLabels are labels of pods belong to the workload, they are used
+// when pod affinity and topology spread refer to the workload itself
+func setK8sPodSpecScheduling(k8sPodSpec *corev1.PodSpec, scheduling types.Scheduling, podLabels map[string]string) error {
+	if err := validateLabels(scheduling.NodeSelector); err != nil {
+		return fmt.Errorf("invalid node selector: %s", err.Error())
+	}
+
+	affinity, err := scPodAffinitiesToK8sAffinity(scheduling.PodAffinities, podLabels)
+	if err != nil {
+		return err
+	}
+
+	constraints, err := scTopologySpreadsToK8sConstraints(scheduling.TopologySpreads, podLabels)
+	if err != nil {
+		return err
+	}
+
+	tolerations, err := scTolerationsToK8sTolerations(scheduling.Tolerations)
+	if err != nil {
+		return err
+	}
+
+	k8sPodSpec.NodeSelector = copyLabels(scheduling.NodeSelector)
+	k8sPodSpec.Affinity = affinity
+	k8sPodSpec.TopologySpreadConstraints = constraints
+	k8sPodSpec.Tolerations = tolerations
+	return nil
+}
+
+func scPodAffinitiesToK8sAffinity(affinities []types.PodAffinity, podLabels map[string]string) (*corev1.Affinity, error) {
+	if len(affinities) == 0 {
+		return nil, nil
+	}
+
+	var podAffinity, podAntiAffinity corev1.PodAffinity
+	for _, a := range affinities {
+		matchLabels := copyLabels(a.PodLabels)
+		if len(matchLabels) == 0 {
+			matchLabels = copyLabels(podLabels)
+		} else if err := validateLabels(matchLabels); err != nil {
+			return nil, fmt.Errorf("invalid pod affinity: %s", err.Error())
+		}
+
+		topologyKey := a.TopologyKey
+		if topologyKey == "" {
+			topologyKey = defaultTopologyKey
+		}
+
+		var target *corev1.PodAffinity
+		switch a.Type {
+		case types.PodAffinityTypeAffinity:
+			target = &podAffinity
+		case types.PodAffinityTypeAntiAffinity:
+			target = &podAntiAffinity
+		default:
+			return nil, fmt.Errorf("pod affinity type %s isn't supported", a.Type)
+		}
+
+		term := corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: matchLabels},
+			TopologyKey:   topologyKey,
+		}
+		if a.Required {
+			target.RequiredDuringSchedulingIgnoredDuringExecution = append(target.RequiredDuringSchedulingIgnoredDuringExecution, term)
+		} else {
+			target.PreferredDuringSchedulingIgnoredDuringExecution = append(target.PreferredDuringSchedulingIgnoredDuringExecution,
+				corev1.WeightedPodAffinityTerm{
+					Weight:          preferredAffinityWeight,
+					PodAffinityTerm: term,
+				})
+		}
+	}
+
+	affinity := &corev1.Affinity{}
+	if !reflect.DeepEqual(podAffinity, corev1.PodAffinity{}) {
+		affinity.PodAffinity = &podAffinity
+	}
+	if !reflect.DeepEqual(podAntiAffinity, corev1.PodAffinity{}) {
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			PreferredDuringSchedulingIgnoredDuringExecution: podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		}
+	}
+	return affinity, nil
+}
+
+func scTopologySpreadsToK8sConstraints(spreads []types.TopologySpread, podLabels map[string]string) ([]corev1.TopologySpreadConstraint, error) {
+	var constraints []corev1.TopologySpreadConstraint
+	for _, s := range spreads {
+		if s.TopologyKey == "" {
+			return nil, fmt.Errorf("topology spread should have topology key")
+		}
+
+		maxSkew := s.MaxSkew
+		if maxSkew == 0 {
+			maxSkew = defaultTopologySpreadMax
+		} else if maxSkew < 0 {
+			return nil, fmt.Errorf("max skew of topology spread should be positive")
+		}
+
+		whenUnsatisfiable := corev1.DoNotSchedule
+		if s.WhenUnsatisfiable == string(corev1.ScheduleAnyway) {
+			whenUnsatisfiable = corev1.ScheduleAnyway
+		}
+
+		constraints = append(constraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           int32(maxSkew),
+			TopologyKey:       s.TopologyKey,
+			WhenUnsatisfiable: whenUnsatisfiable,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: copyLabels(podLabels)},
+		})
+	}
+	return constraints, nil
+}
+
+func scTolerationsToK8sTolerations(tolerations []types.Toleration) ([]corev1.Toleration, error) {
+	var k8sTolerations []corev1.Toleration
+	for _, t := range tolerations {
+		operator := corev1.TolerationOpEqual
+		if t.Operator == string(corev1.TolerationOpExists) {
+			operator = corev1.TolerationOpExists
+		}
+
+		if t.Key == "" && operator != corev1.TolerationOpExists {
+			return nil, fmt.Errorf("toleration without key should use Exists operator")
+		}
+		if operator == corev1.TolerationOpExists && t.Value != "" {
+			return nil, fmt.Errorf("toleration with Exists operator should not have value")
+		}
+		if t.TolerationSeconds != nil && t.Effect != string(corev1.TaintEffectNoExecute) {
+			return nil, fmt.Errorf("toleration seconds is only valid for NoExecute effect")
+		}
+
+		k8sTolerations = append(k8sTolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          operator,
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return k8sTolerations, nil
+}
+
+func k8sPodSpecToScScheduling(k8sPodSpec corev1.PodSpec, podLabels map[string]string) types.Scheduling {
+	scheduling := types.Scheduling{
+		NodeSelector: k8sPodSpec.NodeSelector,
+	}
+
+	if affinity := k8sPodSpec.Affinity; affinity != nil {
+		if affinity.PodAffinity != nil {
+			scheduling.PodAffinities = append(scheduling.PodAffinities,
+				k8sPodAffinityTermsToScPodAffinities(types.PodAffinityTypeAffinity, affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+					affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, podLabels)...)
+		}
+		if affinity.PodAntiAffinity != nil {
+			scheduling.PodAffinities = append(scheduling.PodAffinities,
+				k8sPodAffinityTermsToScPodAffinities(types.PodAffinityTypeAntiAffinity, affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+					affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, podLabels)...)
+		}
+	}
+
+	for _, c := range k8sPodSpec.TopologySpreadConstraints {
+		scheduling.TopologySpreads = append(scheduling.TopologySpreads, types.TopologySpread{
+			TopologyKey:       c.TopologyKey,
+			MaxSkew:           int(c.MaxSkew),
+			WhenUnsatisfiable: string(c.WhenUnsatisfiable),
+		})
+	}
+
+	for _, t := range k8sPodSpec.Tolerations {
+		scheduling.Tolerations = append(scheduling.Tolerations, types.Toleration{
+			Key:               t.Key,
+			Operator:          string(t.Operator),
+			Value:             t.Value,
+			Effect:            string(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return scheduling
+}
+
+func k8sPodAffinityTermsToScPodAffinities(typ string, required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm, podLabels map[string]string) []types.PodAffinity {
+	var affinities []types.PodAffinity
+	toScPodAffinity := func(term corev1.PodAffinityTerm, required bool) types.PodAffinity {
+		affinity := types.PodAffinity{
+			Type:        typ,
+			Required:    required,
+			TopologyKey: term.TopologyKey,
+		}
+		if term.LabelSelector != nil && !reflect.DeepEqual(term.LabelSelector.MatchLabels, podLabels) {
+			affinity.PodLabels = term.LabelSelector.MatchLabels
+		}
+		return affinity
+	}
+
+	for _, term := range required {
+		affinities = append(affinities, toScPodAffinity(term, true))
+	}
+	for _, term := range preferred {
+		affinities = append(affinities, toScPodAffinity(term.PodAffinityTerm, false))
+	}
+	return affinities
+}
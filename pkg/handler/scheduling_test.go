@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestPodSpecScheduling(t *testing.T) {
+	podLabels := map[string]string{"app": "web"}
+	seconds := int64(30)
+	scheduling := types.Scheduling{
+		NodeSelector: map[string]string{"disk": "ssd"},
+		PodAffinities: []types.PodAffinity{
+			{Type: types.PodAffinityTypeAntiAffinity, TopologyKey: defaultTopologyKey},
+			{Type: types.PodAffinityTypeAffinity, Required: true, PodLabels: map[string]string{"app": "cache"}, TopologyKey: "zone"},
+		},
+		TopologySpreads: []types.TopologySpread{
+			{TopologyKey: "zone", MaxSkew: 1, WhenUnsatisfiable: string(corev1.DoNotSchedule)},
+		},
+		Tolerations: []types.Toleration{
+			{Key: "dedicated", Operator: string(corev1.TolerationOpEqual), Value: "db", Effect: string(corev1.TaintEffectNoExecute), TolerationSeconds: &seconds},
+		},
+	}
+
+	var k8sPodSpec corev1.PodSpec
+	ut.Assert(t, setK8sPodSpecScheduling(&k8sPodSpec, scheduling, podLabels) == nil, "")
+	ut.Equal(t, k8sPodSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.LabelSelector.MatchLabels, podLabels)
+	ut.Equal(t, len(k8sPodSpec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution), 1)
+	ut.Equal(t, k8sPodSpecToScScheduling(k8sPodSpec, podLabels), types.Scheduling{
+		NodeSelector:    scheduling.NodeSelector,
+		PodAffinities:   []types.PodAffinity{scheduling.PodAffinities[1], scheduling.PodAffinities[0]},
+		TopologySpreads: scheduling.TopologySpreads,
+		Tolerations:     scheduling.Tolerations,
+	})
+
+	podLabels["track"] = "green"
+	scheduling.NodeSelector["gpu"] = "true"
+	ut.Equal(t, k8sPodSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.LabelSelector.MatchLabels, map[string]string{"app": "web"})
+	ut.Equal(t, k8sPodSpec.TopologySpreadConstraints[0].LabelSelector.MatchLabels, map[string]string{"app": "web"})
+	ut.Equal(t, k8sPodSpec.NodeSelector, map[string]string{"disk": "ssd"})
+
+	for _, invalid := range []types.Scheduling{
+		{NodeSelector: map[string]string{"bad key": "v"}},
+		{TopologySpreads: []types.TopologySpread{{MaxSkew: 1}}},
+		{Tolerations: []types.Toleration{{Value: "v"}}},
+		{Tolerations: []types.Toleration{{Key: "k", Operator: "Exists", Value: "v"}}},
+		{Tolerations: []types.Toleration{{Key: "k", Effect: "NoSchedule", TolerationSeconds: &seconds}}},
+	} {
+		ut.Assert(t, setK8sPodSpecScheduling(&k8sPodSpec, invalid, podLabels) != nil, "%v should be invalid", invalid)
+	}
+}
+
+func TestReservedLabelKey(t *testing.T) {
+	ut.Assert(t, isReservedLabelKey("node-role.kubernetes.io/worker"), "")
+	ut.Assert(t, isReservedLabelKey("node.zcloud.cn/unexecutable"), "")
+	ut.Assert(t, isReservedLabelKey("k8s.io/foo"), "")
+	ut.Assert(t, !isReservedLabelKey("disk"), "")
+	ut.Assert(t, !isReservedLabelKey("example.com/gpu"), "")
+
+	_, err := scTaintsToK8sTaints([]types.Taint{{Key: "dedicated", Effect: "NoSchedule"}, {Key: "dedicated", Effect: "NoSchedule"}})
+	ut.Assert(t, err != nil, "duplicate taint should be invalid")
+	_, err = scTaintsToK8sTaints([]types.Taint{{Key: "node.kubernetes.io/unschedulable", Effect: "NoSchedule"}})
+	ut.Assert(t, err != nil, "reserved taint should be invalid")
+}
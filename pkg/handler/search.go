@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zdnscloud/cement/slice"
+	"github.com/zdnscloud/gok8s/cache"
+	"github.com/zdnscloud/gok8s/client"
+	resterr "github.com/zdnscloud/gorest/error"
+	restresource "github.com/zdnscloud/gorest/resource"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/pkg/zke"
+)
+
+const (
+	clusterLinkPrefix = "/apis/zcloud.cn/v1/clusters/%s"
+)
+
+var (
+	searchKindPod     = restresource.DefaultKindName(types.Pod{})
+	searchKindService = restresource.DefaultKindName(types.Service{})
+	searchKindNode    = restresource.DefaultKindName(types.Node{})
+
+	searchableKinds = []string{
+		searchKindPod,
+		types.ResourceTypeDeployment,
+		types.ResourceTypeDaemonSet,
+		types.ResourceTypeStatefulSet,
+		types.ResourceTypeJob,
+		types.ResourceTypeCronJob,
+		searchKindService,
+		searchKindNode,
+	}
+)
+
+type resourceSearcher struct {
+	user        string
+	cluster     *zke.Cluster
+	cache       cache.Cache
+	cond        *types.ResourceSearch
+	selector    labels.Selector
+	clusters    *ClusterManager
+	enableDebug bool
+}
+
+func (m *ClusterManager) search(ctx *restresource.Context) (interface{}, *resterr.APIError) {
+	cond := &types.ResourceSearch{}
+	if !takeActionInput(ctx, cond) {
+		return nil, resterr.NewAPIError(resterr.InvalidFormat, "action search param is not valid")
+	}
+	cond.TrimFieldSpace()
+
+	selector := labels.Everything()
+	if cond.LabelSelector != "" {
+		s, err := labels.Parse(cond.LabelSelector)
+		if err != nil {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, fmt.Sprintf("invalid label selector %s", err.Error()))
+		}
+		selector = s
+	}
+
+	kinds := cond.Kinds
+	if len(kinds) == 0 {
+		kinds = searchableKinds
+	}
+	for _, k := range kinds {
+		if slice.SliceIndex(searchableKinds, k) == -1 {
+			return nil, resterr.NewAPIError(resterr.InvalidFormat, fmt.Sprintf("unsupported search kind %s", k))
+		}
+	}
+
+	user := getCurrentUser(ctx)
+	var clusters []*zke.Cluster
+	for _, c := range m.zkeManager.ListReady() {
+		if len(cond.Clusters) != 0 && slice.SliceIndex(cond.Clusters, c.Name) == -1 {
+			continue
+		}
+		if m.authorizer.Authorize(user, c.Name, "") {
+			clusters = append(clusters, c)
+		}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	result := &types.ResourceSearchResult{Items: []types.SearchResultItem{}}
+	for _, c := range clusters {
+		wg.Add(1)
+		go func(c *zke.Cluster) {
+			defer wg.Done()
+			s := &resourceSearcher{
+				user:        user,
+				cluster:     c,
+				cache:       c.GetKubeCache(),
+				cond:        cond,
+				selector:    selector,
+				clusters:    m,
+				enableDebug: m.enableDebug,
+			}
+			items, err := s.search(kinds)
+			lock.Lock()
+			defer lock.Unlock()
+			result.Items = append(result.Items, items...)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("search cluster %s failed %s", c.Name, err.Error()))
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	sort.Slice(result.Items, func(i, j int) bool {
+		a, b := result.Items[i], result.Items[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return result, nil
+}
+
+func (s *resourceSearcher) search(kinds []string) ([]types.SearchResultItem, error) {
+	var result []types.SearchResultItem
+	for _, kind := range kinds {
+		items, err := s.searchKind(kind)
+		if err != nil {
+			return result, fmt.Errorf("list %s failed %s", kind, err.Error())
+		}
+		for _, item := range items {
+			if s.match(item) {
+				result = append(result, item)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *resourceSearcher) searchKind(kind string) ([]types.SearchResultItem, error) {
+	var items []types.SearchResultItem
+	opts := &client.ListOptions{LabelSelector: s.selector}
+	switch kind {
+	case searchKindPod:
+		var pods corev1.PodList
+		if err := s.cache.List(context.TODO(), opts, &pods); err != nil {
+			return nil, err
+		}
+		for _, p := range pods.Items {
+			if !s.isVisible(p.Namespace) {
+				continue
+			}
+			item := s.newItem(kind, p.Namespace, p.Name, p.Labels)
+			item.Images = podSpecImages(p.Spec)
+			item.Node = p.Spec.NodeName
+			if p.Status.PodIP != "" {
+				item.IPs = []string{p.Status.PodIP}
+			}
+			item.Link = s.podLink(&p)
+			items = append(items, item)
+		}
+	case types.ResourceTypeDeployment:
+		var deploys appsv1.DeploymentList
+		if err := s.cache.List(context.TODO(), opts, &deploys); err != nil {
+			return nil, err
+		}
+		for _, d := range deploys.Items {
+			if s.isVisible(d.Namespace) {
+				items = append(items, s.newWorkloadItem(kind, d.Namespace, d.Name, d.Labels, d.Spec.Template.Spec))
+			}
+		}
+	case types.ResourceTypeDaemonSet:
+		var dss appsv1.DaemonSetList
+		if err := s.cache.List(context.TODO(), opts, &dss); err != nil {
+			return nil, err
+		}
+		for _, ds := range dss.Items {
+			if s.isVisible(ds.Namespace) {
+				items = append(items, s.newWorkloadItem(kind, ds.Namespace, ds.Name, ds.Labels, ds.Spec.Template.Spec))
+			}
+		}
+	case types.ResourceTypeStatefulSet:
+		var stss appsv1.StatefulSetList
+		if err := s.cache.List(context.TODO(), opts, &stss); err != nil {
+			return nil, err
+		}
+		for _, sts := range stss.Items {
+			if s.isVisible(sts.Namespace) {
+				items = append(items, s.newWorkloadItem(kind, sts.Namespace, sts.Name, sts.Labels, sts.Spec.Template.Spec))
+			}
+		}
+	case types.ResourceTypeJob:
+		var jobs batchv1.JobList
+		if err := s.cache.List(context.TODO(), opts, &jobs); err != nil {
+			return nil, err
+		}
+		for _, j := range jobs.Items {
+			if s.isVisible(j.Namespace) {
+				items = append(items, s.newWorkloadItem(kind, j.Namespace, j.Name, j.Labels, j.Spec.Template.Spec))
+			}
+		}
+	case types.ResourceTypeCronJob:
+		var cronJobs batchv1beta1.CronJobList
+		if err := s.cache.List(context.TODO(), opts, &cronJobs); err != nil {
+			return nil, err
+		}
+		for _, cj := range cronJobs.Items {
+			if s.isVisible(cj.Namespace) {
+				items = append(items, s.newWorkloadItem(kind, cj.Namespace, cj.Name, cj.Labels, cj.Spec.JobTemplate.Spec.Template.Spec))
+			}
+		}
+	case searchKindService:
+		var services corev1.ServiceList
+		if err := s.cache.List(context.TODO(), opts, &services); err != nil {
+			return nil, err
+		}
+		for _, svc := range services.Items {
+			if !s.isVisible(svc.Namespace) {
+				continue
+			}
+			item := s.newItem(kind, svc.Namespace, svc.Name, svc.Labels)
+			if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+				item.IPs = append(item.IPs, svc.Spec.ClusterIP)
+			}
+			item.IPs = append(item.IPs, svc.Spec.ExternalIPs...)
+			item.Link = fmt.Sprintf(clusterLinkPrefix+"/namespaces/%s/%s/%s", s.cluster.Name, svc.Namespace, restresource.DefaultResourceName(types.Service{}), svc.Name)
+			items = append(items, item)
+		}
+	case searchKindNode:
+		var nodes corev1.NodeList
+		if err := s.cache.List(context.TODO(), opts, &nodes); err != nil {
+			return nil, err
+		}
+		for _, n := range nodes.Items {
+			item := s.newItem(kind, "", n.Name, n.Labels)
+			item.Node = n.Name
+			for _, addr := range n.Status.Addresses {
+				if addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
+					item.IPs = append(item.IPs, addr.Address)
+				}
+			}
+			item.Link = fmt.Sprintf(clusterLinkPrefix+"/%s/%s", s.cluster.Name, restresource.DefaultResourceName(types.Node{}), n.Name)
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *resourceSearcher) isVisible(namespace string) bool {
+	return IsNamespaceVisiable(namespace, s.enableDebug) &&
+		s.clusters.authorizer.Authorize(s.user, s.cluster.Name, namespace)
+}
+
+func (s *resourceSearcher) match(item types.SearchResultItem) bool {
+	if s.cond.Keyword != "" && !strings.Contains(strings.ToLower(item.Name), strings.ToLower(s.cond.Keyword)) {
+		return false
+	}
+
+	if s.cond.Image != "" {
+		found := false
+		for _, image := range item.Images {
+			if strings.Contains(image, s.cond.Image) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if s.cond.Node != "" && item.Node != s.cond.Node {
+		return false
+	}
+
+	if s.cond.IP != "" && slice.SliceIndex(item.IPs, s.cond.IP) == -1 {
+		return false
+	}
+	return true
+}
+
+func (s *resourceSearcher) newItem(kind, namespace, name string, labels map[string]string) types.SearchResultItem {
+	return types.SearchResultItem{
+		Cluster:   s.cluster.Name,
+		Namespace: namespace,
+		Kind:      kind,
+		Name:      name,
+		Labels:    labels,
+	}
+}
+
+func (s *resourceSearcher) newWorkloadItem(kind, namespace, name string, labels map[string]string, spec corev1.PodSpec) types.SearchResultItem {
+	item := s.newItem(kind, namespace, name, labels)
+	item.Images = podSpecImages(spec)
+	item.Link = workloadLink(s.cluster.Name, namespace, kind, name)
+	return item
+}
+
+// pod is a sub resource of its owner workload, pod without
+// a supported owner has no link
+func (s *resourceSearcher) podLink(pod *corev1.Pod) string {
+	if len(pod.OwnerReferences) != 1 {
+		return ""
+	}
+
+	owner := pod.OwnerReferences[0]
+	if owner.Kind == "ReplicaSet" {
+		var rs appsv1.ReplicaSet
+		if err := s.cache.Get(context.TODO(), k8stypes.NamespacedName{pod.Namespace, owner.Name}, &rs); err != nil {
+			return ""
+		}
+		if len(rs.OwnerReferences) != 1 {
+			return ""
+		}
+		owner = rs.OwnerReferences[0]
+	}
+
+	kind := strings.ToLower(owner.Kind)
+	if slice.SliceIndex(searchableKinds, kind) == -1 || kind == searchKindPod || kind == searchKindService || kind == searchKindNode {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", workloadLink(s.cluster.Name, pod.Namespace, kind, owner.Name), restresource.DefaultResourceName(types.Pod{}), pod.Name)
+}
+
+func workloadLink(cluster, namespace, kind, name string) string {
+	return fmt.Sprintf(clusterLinkPrefix+"/namespaces/%s/%ss/%s", cluster, namespace, kind, name)
+}
+
+func podSpecImages(spec corev1.PodSpec) []string {
+	var images []string
+	for _, c := range spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range spec.Containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	restresource "github.com/zdnscloud/gorest/resource"
+
+	"github.com/zdnscloud/singlecloud/pkg/authorization"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/pkg/zke"
+)
+
+func TestTakeSearchInput(t *testing.T) {
+	input := &types.ResourceSearch{Keyword: " web ", Clusters: []string{"c1"}}
+	cluster := &types.Cluster{}
+	cluster.SetAction(&restresource.Action{Name: types.CSSearchAction, Input: input})
+	ctx := &restresource.Context{Resource: cluster}
+
+	cond := &types.ResourceSearch{}
+	ut.Assert(t, takeActionInput(ctx, cond), "")
+	cond.TrimFieldSpace()
+	ut.Equal(t, cond.Keyword, "web")
+	ut.Equal(t, cond.Clusters, []string{"c1"})
+	ut.Equal(t, *input, types.ResourceSearch{})
+
+	ut.Assert(t, !takeActionInput(ctx, &types.NodeLabels{}), "input type mismatch")
+}
+
+func TestSearchMatch(t *testing.T) {
+	s := &resourceSearcher{
+		cluster: &zke.Cluster{Name: "c1"},
+		cond:    &types.ResourceSearch{Keyword: "Web", Image: "nginx", Node: "worker1", IP: "10.42.0.5"},
+	}
+	item := s.newItem(searchKindPod, "default", "web-0", nil)
+	item.Images = []string{"busybox", "nginx:1.17"}
+	item.Node = "worker1"
+	item.IPs = []string{"10.42.0.5"}
+	ut.Assert(t, s.match(item), "")
+
+	for _, cond := range []types.ResourceSearch{
+		{Keyword: "db"},
+		{Image: "redis"},
+		{Node: "worker2"},
+		{IP: "10.42.0.6"},
+	} {
+		s.cond = &cond
+		ut.Assert(t, !s.match(item), "item shouldn't match %v", cond)
+	}
+}
+
+func TestSearchVisible(t *testing.T) {
+	s := &resourceSearcher{
+		user:     types.Administrator,
+		cluster:  &zke.Cluster{Name: "c1"},
+		clusters: &ClusterManager{authorizer: &authorization.Authorizer{}},
+	}
+	ut.Assert(t, s.isVisible("default"), "")
+	ut.Assert(t, !s.isVisible(ZCloudNamespace), "hidden namespace isn't visible")
+	s.enableDebug = true
+	ut.Assert(t, s.isVisible(ZCloudNamespace), "")
+
+	s.user = "unknown"
+	ut.Assert(t, !s.isVisible("default"), "unknown user has no permission")
+}
@@ -128,7 +128,7 @@ func (m *ServiceManager) Delete(ctx *resource.Context) *resterror.APIError {
 		}
 		return resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("delete service %s failed %s", service.GetID(), err.Error()))
 	} else {
-		eventbus.PublishResourceDeleteEvent(service)
+		eventbus.PublishResourceDeleteEvent(ctx.Request.Context(), service)
 	}
 	return nil
 }
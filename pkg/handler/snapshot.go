@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	AdminPrefix              = "/apis/admin.zcloud.cn/v1"
+	SnapshotBackupPath       = AdminPrefix + "/backup"
+	SnapshotRestorePath      = AdminPrefix + "/restore"
+	RotateKeyPath            = AdminPrefix + "/rotatekey"
+	SnapshotPassphraseHeader = "X-Snapshot-Passphrase"
+	maxSnapshotSize          = 1 << 30
+)
+
+func (a *App) registerSnapshotHandler(router gin.IRoutes) {
+	router.GET(SnapshotBackupPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can backup db"})
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := db.Backup(&buf, c.GetHeader(SnapshotPassphraseHeader)); err != nil {
+			logger.Warnf("backup db failed %s", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"message": fmt.Sprintf("backup db failed %s", err.Error())})
+			return
+		}
+
+		fileName := fmt.Sprintf("singlecloud-%s.snapshot", time.Now().Format("20060102150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		c.Data(http.StatusOK, "application/octet-stream", buf.Bytes())
+	})
+
+	router.POST(SnapshotRestorePath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can restore db"})
+			return
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxSnapshotSize)
+		if err := db.Restore(body, c.GetHeader(SnapshotPassphraseHeader)); err != nil {
+			logger.Warnf("restore db failed %s", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("restore db failed %s", err.Error())})
+			return
+		}
+
+		logger.Infof("db is restored, singlecloud should be restarted")
+		c.JSON(http.StatusOK, gin.H{"message": "db is restored, restart singlecloud to load restored data"})
+	})
+
+	//key file is reloaded, so new primary key should be added to key file first
+	router.POST(RotateKeyPath, func(c *gin.Context) {
+		if !isAdminRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "only admin can rotate encryption key"})
+			return
+		}
+
+		count, err := db.RotateEncryptionKey()
+		if err != nil {
+			logger.Warnf("rotate encryption key failed %s", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"message": fmt.Sprintf("rotate encryption key failed %s", err.Error())})
+			return
+		}
+
+		logger.Infof("rotate encryption key, %d records are rewrapped", count)
+		c.JSON(http.StatusOK, gin.H{"rewrapped": count})
+	})
+}
+
+func isAdminRequest(c *gin.Context) bool {
+	user, _ := c.Request.Context().Value(types.CurrentUserKey).(string)
+	return isAdmin(user)
+}
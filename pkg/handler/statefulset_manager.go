@@ -35,6 +35,10 @@ func (m *StatefulSetManager) Create(ctx *resource.Context) (resource.Resource, *
 
 	namespace := ctx.Resource.GetParent().GetID()
 	statefulset := ctx.Resource.(*types.StatefulSet)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), statefulset.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
 	if err := createStatefulSet(cluster.GetKubeClient(), namespace, statefulset); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			return nil, resterror.NewAPIError(resterror.DuplicateResource, fmt.Sprintf("duplicate statefulset name %s", statefulset.Name))
@@ -97,13 +101,39 @@ func (m *StatefulSetManager) Update(ctx *resource.Context) (resource.Resource, *
 		return nil, apiErr
 	}
 
-	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), statefulSet.Containers, statefulSet.PersistentVolumes)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), statefulSet.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
+
+	k8sPodSpec, _, err := scPodSpecToK8sPodSpecAndPVCs(cluster.GetKubeClient(), getPodOwnerPodSpec(statefulSet))
 	if err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update statefulset failed %s", err.Error()))
 	}
 
+	if err := setK8sPodSpecScheduling(&k8sPodSpec, statefulSet.Scheduling, k8sStatefulSet.Spec.Template.Labels); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update statefulset failed %s", err.Error()))
+	}
+
+	replicas := replicasOf(k8sStatefulSet.Spec.Replicas)
+	if err := validateWorkloadResources(cluster.GetKubeClient(), namespace, &k8sStatefulSet.Spec.Template.Spec, replicas, &k8sPodSpec, replicas); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update statefulset failed %s", err.Error()))
+	}
+
 	k8sStatefulSet.Spec.Template.Spec.Containers = k8sPodSpec.Containers
+	k8sStatefulSet.Spec.Template.Spec.InitContainers = k8sPodSpec.InitContainers
 	k8sStatefulSet.Spec.Template.Spec.Volumes = k8sPodSpec.Volumes
+	k8sStatefulSet.Spec.Template.Spec.NodeSelector = k8sPodSpec.NodeSelector
+	k8sStatefulSet.Spec.Template.Spec.Affinity = k8sPodSpec.Affinity
+	k8sStatefulSet.Spec.Template.Spec.TopologySpreadConstraints = k8sPodSpec.TopologySpreadConstraints
+	k8sStatefulSet.Spec.Template.Spec.Tolerations = k8sPodSpec.Tolerations
+	if err := setK8sPodTemplateSecurity(&k8sStatefulSet.Spec.Template, statefulSet.SecurityContext); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update statefulset failed %s", err.Error()))
+	}
+
+	if err := validatePodSecurity(cluster.GetKubeClient(), namespace, &k8sStatefulSet.Spec.Template); err != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("update statefulset failed %s", err.Error()))
+	}
+
 	k8sStatefulSet.Annotations = addWorkloadUpdateMemoToAnnotations(k8sStatefulSet.Annotations, statefulSet.Memo)
 	if err := cluster.GetKubeClient().Update(context.TODO(), k8sStatefulSet); err != nil {
 		return nil, resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("update statefulset failed %s", err.Error()))
@@ -143,7 +173,7 @@ func (m *StatefulSetManager) Delete(ctx *resource.Context) *resterror.APIError {
 	if delete, ok := k8sStatefulSet.Annotations[AnnkeyForDeletePVsWhenDeleteWorkload]; ok && delete == "true" {
 		deleteWorkLoadPVCs(cluster.GetKubeClient(), namespace, volumes)
 	}
-	eb.PublishResourceDeleteEvent(statefulset)
+	eb.PublishResourceDeleteEvent(ctx.Request.Context(), statefulset)
 	return nil
 }
 
@@ -153,8 +183,12 @@ func (m *StatefulSetManager) Action(ctx *resource.Context) (interface{}, *rester
 		return m.getStatefulSetHistory(ctx)
 	case types.ActionRollback:
 		return nil, m.rollback(ctx)
+	case types.ActionDiff:
+		return m.diff(ctx)
 	case types.ActionSetPodCount:
 		return m.setPodCount(ctx)
+	case types.ActionPause, types.ActionResume, types.ActionRestart:
+		return nil, m.pauseOrRestart(ctx)
 	default:
 		return nil, resterror.NewAPIError(resterror.InvalidAction, fmt.Sprintf("action %s is unknown", ctx.Resource.GetAction().Name))
 	}
@@ -227,11 +261,9 @@ func k8sStatefulSetToSCStatefulSet(k8sStatefulSet *appsv1.StatefulSet) *types.St
 		json.Unmarshal([]byte(opts), &advancedOpts)
 	}
 
-	containers, templates := k8sPodSpecToScContainersAndVCTemplates(k8sStatefulSet.Spec.Template.Spec.Containers,
-		k8sStatefulSet.Spec.Template.Spec.Volumes)
-
+	spec := k8sPodSpecToScPodSpec(k8sStatefulSet.Spec.Template.Spec)
 	var pvs []types.PersistentVolumeTemplate
-	for _, template := range templates {
+	for _, template := range spec.PersistentVolumes {
 		if template.StorageClassName == types.StorageClassNameTemp {
 			pvs = append(pvs, template)
 		}
@@ -251,10 +283,14 @@ func k8sStatefulSetToSCStatefulSet(k8sStatefulSet *appsv1.StatefulSet) *types.St
 	statefulset := &types.StatefulSet{
 		Name:              k8sStatefulSet.Name,
 		Replicas:          int(*k8sStatefulSet.Spec.Replicas),
-		Containers:        containers,
+		Containers:        spec.Containers,
+		InitContainers:    spec.InitContainers,
+		Volumes:           spec.Volumes,
 		AdvancedOptions:   advancedOpts,
 		PersistentVolumes: pvs,
-		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sStatefulSet.Status.ReadyReplicas)},
+		Scheduling:        k8sPodSpecToScScheduling(k8sStatefulSet.Spec.Template.Spec, k8sStatefulSet.Spec.Template.Labels),
+		SecurityContext:   k8sPodTemplateToScPodSecurityContext(k8sStatefulSet.Spec.Template),
+		Status:            types.WorkloadStatus{ReadyReplicas: int(k8sStatefulSet.Status.ReadyReplicas), Paused: isStatefulSetPaused(k8sStatefulSet)},
 	}
 
 	if k8sStatefulSet.Status.CurrentRevision != k8sStatefulSet.Status.UpdateRevision {
@@ -380,8 +416,8 @@ func (m *StatefulSetManager) setPodCount(ctx *resource.Context) (interface{}, *r
 		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
 	}
 
-	param, ok := ctx.Resource.GetAction().Input.(*types.SetPodCount)
-	if ok == false {
+	param := &types.SetPodCount{}
+	if takeActionInput(ctx, param) == false {
 		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action set pod count param is not valid")
 	}
 
@@ -392,6 +428,14 @@ func (m *StatefulSetManager) setPodCount(ctx *resource.Context) (interface{}, *r
 		return nil, err
 	}
 
+	//only scaling up needs more quota
+	if replicas := replicasOf(k8sStatefulSet.Spec.Replicas); param.Replicas > replicas {
+		spec := &k8sStatefulSet.Spec.Template.Spec
+		if err := validateWorkloadResources(cluster.GetKubeClient(), namespace, spec, replicas, spec, param.Replicas); err != nil {
+			return nil, resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("set statefulset pod count failed %s", err.Error()))
+		}
+	}
+
 	if int(*k8sStatefulSet.Spec.Replicas) != param.Replicas {
 		if err := cluster.GetKubeClient().Patch(context.TODO(), k8sStatefulSet, k8stypes.MergePatchType,
 			[]byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, param.Replicas))); err != nil {
@@ -401,3 +445,67 @@ func (m *StatefulSetManager) setPodCount(ctx *resource.Context) (interface{}, *r
 
 	return param, nil
 }
+
+func (m *StatefulSetManager) pauseOrRestart(ctx *resource.Context) *resterror.APIError {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	statefulset := ctx.Resource.(*types.StatefulSet)
+	k8sStatefulSet, apiErr := getStatefulSet(cluster.GetKubeClient(), namespace, statefulset.GetID())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var patch []byte
+	var err error
+	action := ctx.Resource.GetAction().Name
+	switch action {
+	case types.ActionPause:
+		patch, err = pauseStatefulSetPatch(k8sStatefulSet, true)
+	case types.ActionResume:
+		patch, err = pauseStatefulSetPatch(k8sStatefulSet, false)
+	case types.ActionRestart:
+		if isStatefulSetPaused(k8sStatefulSet) {
+			return resterror.NewAPIError(resterror.InvalidOption, "cannot restart a paused statefulset")
+		}
+		patch = restartWorkloadPatch()
+	}
+	if err != nil {
+		return resterror.NewAPIError(resterror.InvalidOption, fmt.Sprintf("%s statefulset failed: %s", action, err.Error()))
+	}
+
+	if err := cluster.GetKubeClient().Patch(context.TODO(), k8sStatefulSet, k8stypes.MergePatchType, patch); err != nil {
+		return resterror.NewAPIError(types.ConnectClusterFailed, fmt.Sprintf("%s statefulset failed: %v", action, err.Error()))
+	}
+
+	return nil
+}
+
+func (m *StatefulSetManager) diff(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	param := &types.DiffVersions{}
+	if takeActionInput(ctx, param) == false {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, "action diff versions param is not valid")
+	}
+
+	namespace := ctx.Resource.GetParent().GetID()
+	statefulset := ctx.Resource.(*types.StatefulSet)
+	_, controllerRevisions, err := getStatefulSetAndControllerRevisions(cluster.GetKubeClient(), namespace, statefulset.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	templates, parseErr := getControllerRevisionsTemplates(controllerRevisions)
+	if parseErr != nil {
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, parseErr.Error())
+	}
+
+	return diffVersions(templates, param)
+}
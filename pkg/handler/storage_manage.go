@@ -9,7 +9,6 @@ import (
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterr "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -245,7 +244,7 @@ func strToBool(str string) bool {
 func genStoragePVFromClusterAgent(cluster *zke.Cluster, name string) ([]types.PV, error) {
 	var info types.PVInfo
 	if err := clusteragent.GetAgent().GetResource(cluster.Name, "/storages/"+name, &info); err != nil {
-		log.Warnf("get storages from clusteragent failed:%s", err.Error())
+		logger.Warnf("get storages from clusteragent failed:%s", err.Error())
 		return nil, err
 	}
 	return info.PVs, nil
@@ -11,14 +11,13 @@ import (
 	"strings"
 
 	"github.com/golang/protobuf/ptypes/duration"
-	"github.com/gorilla/websocket"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/slice"
 	sm "github.com/zdnscloud/servicemesh"
 	pb "github.com/zdnscloud/servicemesh/public"
 
 	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/server"
 )
 
 const (
@@ -37,32 +36,32 @@ var (
 func (m *ClusterManager) Tap(clusterID, ns, kind, name, toKind, toName, method, path string, r *http.Request, w http.ResponseWriter) {
 	cluster := m.GetClusterByName(clusterID)
 	if cluster == nil {
-		log.Warnf("cluster %s isn't found to open tap", clusterID)
+		logger.Warnf("cluster %s isn't found to open tap", clusterID)
 		return
 	}
 
 	req, err := buildTapRequest(ns, kind, name, toKind, toName, method, path)
 	if err != nil {
-		log.Warnf("build tap request failed: %s", err.Error())
+		logger.Warnf("build tap request failed: %s", err.Error())
 		return
 	}
 
 	url, err := url.Parse(cluster.GetKubeRestConfig().Host)
 	if err != nil {
-		log.Warnf("build tap request url failed: %s", err.Error())
+		logger.Warnf("build tap request url failed: %s", err.Error())
 		return
 	}
 
 	url.Path = fmt.Sprintf(TapApiURLPath, ns, kind, name)
 	resp, err := sm.HandleRequest(cluster.GetKubeHttpClient(), url, req)
 	if err != nil {
-		log.Warnf("handle tap request failed: %s", err.Error())
+		logger.Warnf("handle tap request failed: %s", err.Error())
 		return
 	}
 
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
-		log.Warnf("open websocket for tap failed: %s", err.Error())
+		logger.Warnf("open websocket for tap failed: %s", err.Error())
 		return
 	}
 
@@ -80,13 +79,13 @@ func (m *ClusterManager) Tap(clusterID, ns, kind, name, toKind, toName, method,
 		}
 
 		if err != nil {
-			log.Warnf("get tap response failed:%s", err.Error())
+			logger.Warnf("get tap response failed:%s", err.Error())
 			break
 		}
 
 		if err := conn.WriteJSON(pbTapEventToScTap(clusterID, ns, &event)); err != nil {
 			if isBrokenPipeErr(err) == false {
-				log.Warnf("send tap response to websocket failed:%s", err.Error())
+				logger.Warnf("send tap response to websocket failed:%s", err.Error())
 			}
 			break
 		}
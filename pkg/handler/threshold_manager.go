@@ -1,13 +1,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
-	"github.com/zdnscloud/cement/log"
 	resterr "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
 	restresource "github.com/zdnscloud/gorest/resource"
@@ -15,6 +15,7 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/alarm"
 	"github.com/zdnscloud/singlecloud/pkg/db"
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
@@ -39,7 +40,7 @@ type ThresholdManager struct {
 func newThresholdManager(clusters *ClusterManager) (*ThresholdManager, error) {
 	m := &ThresholdManager{
 		clusters:       clusters,
-		clusterEventCh: eb.SubscribeResourceEvent(types.Cluster{}),
+		clusterEventCh: eb.SubscribeDurableResourceEvent("threshold", types.Cluster{}),
 	}
 	if err := m.initThreshold(); err != nil {
 		return nil, err
@@ -128,12 +129,15 @@ func addOrUpdateThresholdToDB(table kvzoo.Table, threshold *types.Threshold, act
 
 func (m *ThresholdManager) eventLoop() {
 	for {
-		event := <-m.clusterEventCh
+		event, ok := <-m.clusterEventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceCreateEvent:
 			cluster := e.Resource.(*types.Cluster)
 			if err := createConfigMap(cluster.KubeProvider.GetKubeClient(), ZCloudNamespace, thresholdToConfigmap(m.threshold)); err != nil {
-				log.Warnf("create configmap in cluster %s failed for threshold: %s", cluster.Name, err.Error())
+				logger.Warnf("create configmap in cluster %s failed for threshold: %s", cluster.Name, err.Error())
 				alarm.New().
 					Cluster(cluster.Name).
 					Namespace(ZCloudNamespace).
@@ -141,7 +145,7 @@ func (m *ThresholdManager) eventLoop() {
 					Name(m.threshold.GetID()).
 					Reason(err.Error()).
 					Message(fmt.Sprintf("failed to apply threshold to cluster %s", cluster.Name)).
-					Publish()
+					Publish(logging.WithRequestID(context.Background(), e.RequestID))
 			}
 		}
 	}
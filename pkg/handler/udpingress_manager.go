@@ -7,7 +7,6 @@ import (
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -35,11 +34,14 @@ func newUDPIngressManager(clusters *ClusterManager) *UDPIngressManager {
 }
 
 func (m *UDPIngressManager) eventLoop() {
-	eventCh := eventbus.SubscribeResourceEvent(
+	eventCh := eventbus.SubscribeDurableResourceEvent("udpingress",
 		types.Namespace{},
 		types.Service{})
 	for {
-		event := <-eventCh
+		event, ok := <-eventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eventbus.ResourceDeleteEvent:
 			switch r := e.Resource.(type) {
@@ -47,7 +49,7 @@ func (m *UDPIngressManager) eventLoop() {
 				cluster := m.clusters.GetClusterForSubResource(r)
 				if cluster != nil {
 					if err := clearTransportLayerIngress(cluster.GetKubeClient(), r.GetID(), ""); err != nil {
-						log.Warnf("clean udp ingress for namespace %s failed:%s", r.GetID(), err.Error())
+						logger.Warnf("clean udp ingress for namespace %s failed:%s", r.GetID(), err.Error())
 					}
 				}
 			case *types.Service:
@@ -55,7 +57,7 @@ func (m *UDPIngressManager) eventLoop() {
 				if cluster != nil {
 					namespace := r.GetParent().GetID()
 					if err := clearTransportLayerIngress(cluster.GetKubeClient(), namespace, r.GetID()); err != nil {
-						log.Warnf("delete udp ingress for svc %s failed:%s", r.GetID(), err.Error())
+						logger.Warnf("delete udp ingress for svc %s failed:%s", r.GetID(), err.Error())
 					}
 				}
 			}
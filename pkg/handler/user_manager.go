@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/zdnscloud/cement/log"
 	resterr "github.com/zdnscloud/gorest/error"
 	restresource "github.com/zdnscloud/gorest/resource"
 	"github.com/zdnscloud/singlecloud/pkg/authentication/jwt"
@@ -104,7 +103,7 @@ func (m *UserManager) List(ctx *restresource.Context) (interface{}, *resterr.API
 		if user != nil {
 			users = []*types.User{user}
 		} else {
-			log.Errorf("user %s is deleted during request", currentUser)
+			logger.Errorf("user %s is deleted during request", currentUser)
 		}
 	}
 	return users, nil
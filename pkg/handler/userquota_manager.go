@@ -309,7 +309,7 @@ func (m *UserQuotaManager) approval(ctx *resource.Context) *resterror.APIError {
 
 	exists := hasNamespace(cluster.GetKubeClient(), quota.Namespace)
 	if exists == false {
-		if err := createNamespace(cluster.GetKubeClient(), quota.Namespace); err != nil {
+		if err := createNamespace(cluster.GetKubeClient(), quota.Namespace, ""); err != nil {
 			return resterror.NewAPIError(types.ConnectClusterFailed,
 				fmt.Sprintf("create user %s namespace %s failed %s",
 					quota.UserName, quota.Namespace, err.Error()))
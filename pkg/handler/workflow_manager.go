@@ -10,7 +10,6 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/types"
 
 	tektonv1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	resterror "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
@@ -47,6 +46,9 @@ func (m *WorkFlowManager) Create(ctx *resource.Context) (resource.Resource, *res
 
 	ns := ctx.Resource.GetParent().GetID()
 	wf := ctx.Resource.(*types.WorkFlow)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), wf.Deploy.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
 	wf.SetCreationTimestamp(time.Now())
 	wf.SetID(wf.Name)
 
@@ -123,11 +125,11 @@ func createWorkFlow(cli client.Client, namespace string, wf *types.WorkFlow) err
 func workFlowCreateFailBack(cli client.Client, namespace, name string, objs []runtime.Object) {
 	for _, obj := range objs {
 		if err := cli.Delete(context.TODO(), obj); err != nil {
-			log.Warnf("delete k8s object failed in workflow create failback %s", err.Error())
+			logger.Warnf("delete k8s object failed in workflow create failback %s", err.Error())
 		}
 	}
 	if err := deleteWorkFlowSaFromCRB(cli, name, namespace); err != nil {
-		log.Warnf("delete workflow %s_%s serviceaccount failed in workflow create failback %s", namespace, name, err.Error())
+		logger.Warnf("delete workflow %s_%s serviceaccount failed in workflow create failback %s", namespace, name, err.Error())
 	}
 }
 
@@ -237,6 +239,9 @@ func (m *WorkFlowManager) Update(ctx *resource.Context) (resource.Resource, *res
 
 	ns := ctx.Resource.GetParent().GetID()
 	newer := ctx.Resource.(*types.WorkFlow)
+	if apiErr := validatePodVolumesPermission(getCurrentUser(ctx), newer.Deploy.Volumes); apiErr != nil {
+		return nil, apiErr
+	}
 
 	older, err := getWorkFlow(cluster.GetKubeClient(), ns, newer.GetID())
 	if err != nil {
@@ -8,11 +8,12 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/client"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/zdnscloud/singlecloud/server"
 )
 
 const (
@@ -28,23 +29,23 @@ type workFlowTaskContainer struct {
 func (m *ClusterManager) OpenWorkFlowTaskLog(clusterID, namespace, workFlow, workFlowTask string, r *http.Request, w http.ResponseWriter) {
 	cluster := m.GetClusterByName(clusterID)
 	if cluster == nil {
-		log.Infof("cluster %s isn't found to open workflowtask %s log", clusterID, getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
+		logger.Infof("cluster %s isn't found to open workflowtask %s log", clusterID, getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
 		return
 	}
 
 	_, err := getWorkFlowTask(cluster.GetKubeClient(), namespace, workFlowTask)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			log.Infof("workflowtask %s doesn't exist to open log", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
+			logger.Infof("workflowtask %s doesn't exist to open log", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
 		} else {
-			log.Warnf("get workflowtask %s failed to open log", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
+			logger.Warnf("get workflowtask %s failed to open log", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask))
 		}
 		return
 	}
 
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
-		log.Warnf("workflowtask %s log websocket upgrade failed %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), err.Error())
+		logger.Warnf("workflowtask %s log websocket upgrade failed %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), err.Error())
 		return
 	}
 	defer conn.Close()
@@ -54,7 +55,7 @@ func (m *ClusterManager) OpenWorkFlowTaskLog(clusterID, namespace, workFlow, wor
 	for {
 		allContainers, err := getNewWorkFlowContainers(cluster.GetKubeClient(), namespace, workFlowTask)
 		if err != nil {
-			log.Warnf("get workflowtask %s containers failed to open log %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), err.Error())
+			logger.Warnf("get workflowtask %s containers failed to open log %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), err.Error())
 			return
 		}
 
@@ -76,7 +77,7 @@ func (m *ClusterManager) OpenWorkFlowTaskLog(clusterID, namespace, workFlow, wor
 					readedContainers = append(readedContainers, container)
 					continue
 				}
-				log.Warnf("read workflowtask %s container %s_%s log failed %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), container.pod, container.container, err.Error())
+				logger.Warnf("read workflowtask %s container %s_%s log failed %s", getFormatWorkFlowTaskID(clusterID, namespace, workFlow, workFlowTask), container.pod, container.container, err.Error())
 				return
 			}
 		}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -548,7 +547,17 @@ func k8sDeployToYaml(cli client.Client, namespace string, deploy *types.Deployme
 }
 
 func scDeployToK8sDeployAndPvcs(cli client.Client, namespace string, deploy *types.Deployment) (*appsv1.Deployment, []corev1.PersistentVolumeClaim, error) {
-	podTemplate, k8sPVCs, err := getWorkLoadPodTempateSpecAndPvcs(namespace, deploy, cli)
+	//deployment created by previous task is replaced by the new one
+	var oldSpec *corev1.PodSpec
+	oldReplicas := 0
+	if k8sDeploy, apiErr := getDeployment(cli, namespace, deploy.Name); apiErr == nil {
+		oldSpec = &k8sDeploy.Spec.Template.Spec
+		oldReplicas = replicasOf(k8sDeploy.Spec.Replicas)
+	} else if apiErr.ErrorCode != resterror.NotFound {
+		return nil, nil, fmt.Errorf("%s", apiErr.Message)
+	}
+
+	podTemplate, k8sPVCs, err := buildPodTempateSpec(namespace, deploy, cli, oldSpec, oldReplicas)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -576,26 +585,3 @@ func scDeployToK8sDeployAndPvcs(cli client.Client, namespace string, deploy *typ
 	}
 	return k8sDeploy, k8sPVCs, nil
 }
-
-func getWorkLoadPodTempateSpecAndPvcs(namespace string, podOwner interface{}, cli client.Client) (*corev1.PodTemplateSpec, []corev1.PersistentVolumeClaim, error) {
-	structVal := reflect.ValueOf(podOwner).Elem()
-	advancedOpts := structVal.FieldByName("AdvancedOptions").Interface().(types.AdvancedOptions)
-	containers := structVal.FieldByName("Containers").Interface().([]types.Container)
-	pvs := structVal.FieldByName("PersistentVolumes").Interface().([]types.PersistentVolumeTemplate)
-
-	k8sPodSpec, k8sPVCs, err := scPodSpecToK8sPodSpecAndPVCs(cli, containers, pvs)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	name := structVal.FieldByName("Name").String()
-	meta, err := createPodTempateObjectMeta(name, namespace, cli, advancedOpts, containers)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return &corev1.PodTemplateSpec{
-		ObjectMeta: meta,
-		Spec:       k8sPodSpec,
-	}, k8sPVCs, nil
-}
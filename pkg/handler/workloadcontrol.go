@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const (
+	AnnKeyForRestartedAt = "kubectl.kubernetes.io/restartedAt"
+	// update strategy before pause, workload is paused if it exists
+	AnnKeyForPausedUpdateStrategy = "zcloud.cn/paused-update-strategy"
+
+	// statefulset has no paused field, pods are kept in current revision
+	// by partition which is larger than any ordinal
+	statefulSetPausedPartition = math.MaxInt32
+)
+
+// restart changes annotation of pod template to trigger rolling update,
+// which is the same as kubectl rollout restart
+func restartWorkloadPatch() []byte {
+	return []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"%s":"%s"}}}}}`,
+		AnnKeyForRestartedAt, time.Now().Format(time.RFC3339)))
+}
+
+func pauseDeploymentPatch(paused bool) []byte {
+	return []byte(fmt.Sprintf(`{"spec":{"paused":%t}}`, paused))
+}
+
+// pauseStatefulSetPatch is a merge patch, user's strategy is saved in
+// annotation when paused and restored when resumed
+func pauseStatefulSetPatch(k8sStatefulSet *appsv1.StatefulSet, paused bool) ([]byte, error) {
+	if paused {
+		if isStatefulSetPaused(k8sStatefulSet) {
+			return nil, fmt.Errorf("statefulset %s is already paused", k8sStatefulSet.Name)
+		}
+		partition := int32(statefulSetPausedPartition)
+		return pauseUpdateStrategyPatch(k8sStatefulSet.Spec.UpdateStrategy, appsv1.StatefulSetUpdateStrategy{
+			Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+		})
+	}
+
+	var strategy appsv1.StatefulSetUpdateStrategy
+	if err := getPausedUpdateStrategy(k8sStatefulSet.Annotations, &strategy); err != nil {
+		return nil, fmt.Errorf("statefulset %s %s", k8sStatefulSet.Name, err.Error())
+	}
+	return resumeUpdateStrategyPatch(strategy.Type, strategy.RollingUpdate)
+}
+
+func isStatefulSetPaused(k8sStatefulSet *appsv1.StatefulSet) bool {
+	_, ok := k8sStatefulSet.Annotations[AnnKeyForPausedUpdateStrategy]
+	return ok
+}
+
+// daemonset is paused by OnDelete strategy, so pods are only updated
+// when they are deleted
+func pauseDaemonSetPatch(k8sDaemonSet *appsv1.DaemonSet, paused bool) ([]byte, error) {
+	if paused {
+		if isDaemonSetPaused(k8sDaemonSet) {
+			return nil, fmt.Errorf("daemonset %s is already paused", k8sDaemonSet.Name)
+		}
+		return pauseUpdateStrategyPatch(k8sDaemonSet.Spec.UpdateStrategy, appsv1.DaemonSetUpdateStrategy{
+			Type: appsv1.OnDeleteDaemonSetStrategyType,
+		})
+	}
+
+	var strategy appsv1.DaemonSetUpdateStrategy
+	if err := getPausedUpdateStrategy(k8sDaemonSet.Annotations, &strategy); err != nil {
+		return nil, fmt.Errorf("daemonset %s %s", k8sDaemonSet.Name, err.Error())
+	}
+	return resumeUpdateStrategyPatch(strategy.Type, strategy.RollingUpdate)
+}
+
+// user configured OnDelete strategy isn't paused
+func isDaemonSetPaused(k8sDaemonSet *appsv1.DaemonSet) bool {
+	_, ok := k8sDaemonSet.Annotations[AnnKeyForPausedUpdateStrategy]
+	return ok
+}
+
+func pauseUpdateStrategyPatch(current, paused interface{}) ([]byte, error) {
+	saved, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	//rollingUpdate is set to null explicitly, otherwise it's merged
+	pausedStrategy := map[string]interface{}{"rollingUpdate": nil}
+	data, _ := json.Marshal(paused)
+	if err := json.Unmarshal(data, &pausedStrategy); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AnnKeyForPausedUpdateStrategy: string(saved)},
+		},
+		"spec": map[string]interface{}{"updateStrategy": pausedStrategy},
+	})
+}
+
+func getPausedUpdateStrategy(annotations map[string]string, strategy interface{}) error {
+	saved, ok := annotations[AnnKeyForPausedUpdateStrategy]
+	if ok == false {
+		return fmt.Errorf("isn't paused")
+	}
+	if err := json.Unmarshal([]byte(saved), strategy); err != nil {
+		return fmt.Errorf("has invalid paused update strategy: %s", err.Error())
+	}
+	return nil
+}
+
+func resumeUpdateStrategyPatch(strategyType, rollingUpdate interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AnnKeyForPausedUpdateStrategy: nil},
+		},
+		"spec": map[string]interface{}{
+			"updateStrategy": map[string]interface{}{
+				"type":          strategyType,
+				"rollingUpdate": rollingUpdate,
+			},
+		},
+	})
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// applyMergePatch applies json merge patch to obj like apiserver
+func applyMergePatch(t *testing.T, obj interface{}, patch []byte) {
+	data, err := json.Marshal(obj)
+	ut.Assert(t, err == nil, "")
+	var doc, p map[string]interface{}
+	ut.Assert(t, json.Unmarshal(data, &doc) == nil, "")
+	ut.Assert(t, json.Unmarshal(patch, &p) == nil, "")
+	data, _ = json.Marshal(mergeJSON(doc, p))
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	ut.Assert(t, json.Unmarshal(data, obj) == nil, "")
+}
+
+func mergeJSON(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+		} else if pv, ok := v.(map[string]interface{}); ok {
+			dv, _ := doc[k].(map[string]interface{})
+			doc[k] = mergeJSON(dv, pv)
+		} else {
+			doc[k] = v
+		}
+	}
+	return doc
+}
+
+func TestPauseWorkloadPatch(t *testing.T) {
+	var k8sDeploy appsv1.Deployment
+	ut.Assert(t, json.Unmarshal(pauseDeploymentPatch(true), &k8sDeploy) == nil, "")
+	ut.Assert(t, k8sDeploy.Spec.Paused, "")
+
+	partition := int32(2)
+	k8sStatefulSet := &appsv1.StatefulSet{}
+	k8sStatefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+	}
+	ut.Assert(t, isStatefulSetPaused(k8sStatefulSet) == false, "")
+	_, err := pauseStatefulSetPatch(k8sStatefulSet, false)
+	ut.Assert(t, err != nil, "resume statefulset which isn't paused should fail")
+	patch, err := pauseStatefulSetPatch(k8sStatefulSet, true)
+	ut.Assert(t, err == nil, "")
+	applyMergePatch(t, k8sStatefulSet, patch)
+	ut.Assert(t, isStatefulSetPaused(k8sStatefulSet), "")
+	ut.Equal(t, *k8sStatefulSet.Spec.UpdateStrategy.RollingUpdate.Partition, int32(statefulSetPausedPartition))
+	_, err = pauseStatefulSetPatch(k8sStatefulSet, true)
+	ut.Assert(t, err != nil, "pause statefulset twice should fail")
+	patch, err = pauseStatefulSetPatch(k8sStatefulSet, false)
+	ut.Assert(t, err == nil, "")
+	applyMergePatch(t, k8sStatefulSet, patch)
+	ut.Assert(t, isStatefulSetPaused(k8sStatefulSet) == false, "")
+	ut.Equal(t, *k8sStatefulSet.Spec.UpdateStrategy.RollingUpdate.Partition, partition)
+
+	k8sStatefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	patch, _ = pauseStatefulSetPatch(k8sStatefulSet, true)
+	applyMergePatch(t, k8sStatefulSet, patch)
+	patch, _ = pauseStatefulSetPatch(k8sStatefulSet, false)
+	applyMergePatch(t, k8sStatefulSet, patch)
+	ut.Equal(t, k8sStatefulSet.Spec.UpdateStrategy, appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType})
+
+	maxUnavailable := intstr.FromInt(3)
+	k8sDaemonSet := &appsv1.DaemonSet{}
+	k8sDaemonSet.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnavailable},
+	}
+	patch, err = pauseDaemonSetPatch(k8sDaemonSet, true)
+	ut.Assert(t, err == nil, "")
+	applyMergePatch(t, k8sDaemonSet, patch)
+	ut.Assert(t, isDaemonSetPaused(k8sDaemonSet), "")
+	ut.Equal(t, k8sDaemonSet.Spec.UpdateStrategy, appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType})
+	patch, err = pauseDaemonSetPatch(k8sDaemonSet, false)
+	ut.Assert(t, err == nil, "")
+	applyMergePatch(t, k8sDaemonSet, patch)
+	ut.Assert(t, isDaemonSetPaused(k8sDaemonSet) == false, "")
+	ut.Equal(t, k8sDaemonSet.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable.IntValue(), 3)
+
+	onDelete := &appsv1.DaemonSet{}
+	onDelete.Spec.UpdateStrategy.Type = appsv1.OnDeleteDaemonSetStrategyType
+	ut.Assert(t, isDaemonSetPaused(onDelete) == false, "")
+
+	var k8sTemplate appsv1.Deployment
+	ut.Assert(t, json.Unmarshal(restartWorkloadPatch(), &k8sTemplate) == nil, "")
+	ut.Assert(t, k8sTemplate.Spec.Template.Annotations[AnnKeyForRestartedAt] != "", "")
+}
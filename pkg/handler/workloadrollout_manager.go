@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/gok8s/client"
+	resterror "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/gorest/resource"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/server"
+)
+
+const (
+	progressDeadlineExceededReason = "ProgressDeadlineExceeded"
+	workloadRolloutWatchInterval   = 2 * time.Second
+	defaultWorkloadRolloutTimeout  = 10 * time.Minute
+)
+
+// container waiting in these reasons won't be ready without user
+// intervention
+var stuckContainerReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+type WorkloadRolloutManager struct {
+	clusters *ClusterManager
+}
+
+func newWorkloadRolloutManager(clusters *ClusterManager) *WorkloadRolloutManager {
+	return &WorkloadRolloutManager{clusters: clusters}
+}
+
+func (m *WorkloadRolloutManager) List(ctx *resource.Context) (interface{}, *resterror.APIError) {
+	rollout, err := m.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []*types.WorkloadRollout{rollout}, nil
+}
+
+func (m *WorkloadRolloutManager) Get(ctx *resource.Context) (resource.Resource, *resterror.APIError) {
+	if ctx.Resource.GetID() != ctx.Resource.GetParent().GetID() {
+		return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found rollout %s", ctx.Resource.GetID()))
+	}
+	return m.get(ctx)
+}
+
+func (m *WorkloadRolloutManager) get(ctx *resource.Context) (*types.WorkloadRollout, *resterror.APIError) {
+	cluster := m.clusters.GetClusterForSubResource(ctx.Resource)
+	if cluster == nil {
+		return nil, resterror.NewAPIError(resterror.NotFound, "cluster doesn't exist")
+	}
+
+	namespace := ctx.Resource.GetParent().GetParent().GetID()
+	ownerType := ctx.Resource.GetParent().GetType()
+	ownerName := ctx.Resource.GetParent().GetID()
+	return getWorkloadRollout(cluster.GetKubeClient(), namespace, ownerType, ownerName)
+}
+
+func getWorkloadRollout(cli client.Client, namespace, ownerType, ownerName string) (*types.WorkloadRollout, *resterror.APIError) {
+	var rollout *types.WorkloadRollout
+	switch ownerType {
+	case types.ResourceTypeDeployment:
+		k8sDeploy, apiErr := getDeployment(cli, namespace, ownerName)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		rollout = k8sDeployToWorkloadRollout(k8sDeploy)
+	case types.ResourceTypeStatefulSet:
+		k8sStatefulSet, apiErr := getStatefulSet(cli, namespace, ownerName)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		rollout = k8sStatefulSetToWorkloadRollout(k8sStatefulSet)
+	case types.ResourceTypeDaemonSet:
+		k8sDaemonSet, apiErr := getDaemonSet(cli, namespace, ownerName)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		rollout = k8sDaemonSetToWorkloadRollout(k8sDaemonSet)
+	default:
+		return nil, resterror.NewAPIError(resterror.InvalidFormat, fmt.Sprintf("%s has no rollout", ownerType))
+	}
+
+	if rollout.Phase == types.WorkloadRolloutPhaseProgressing {
+		stuckPods, err := getStuckPods(cli, namespace, ownerType, ownerName)
+		if err != nil {
+			return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("get pods of %s %s failed %s", ownerType, ownerName, err.Error()))
+		}
+		if len(stuckPods) > 0 {
+			rollout.Phase = types.WorkloadRolloutPhaseStalled
+			rollout.StuckPods = stuckPods
+		}
+	}
+
+	rollout.SetID(ownerName)
+	return rollout, nil
+}
+
+func k8sDeployToWorkloadRollout(k8sDeploy *appsv1.Deployment) *types.WorkloadRollout {
+	desired := replicasOf(k8sDeploy.Spec.Replicas)
+	status := k8sDeploy.Status
+	rollout := &types.WorkloadRollout{
+		Phase:             types.WorkloadRolloutPhaseProgressing,
+		Revision:          k8sDeploy.Annotations[RevisionAnnotation],
+		DesiredReplicas:   desired,
+		UpdatedReplicas:   int(status.UpdatedReplicas),
+		ReadyReplicas:     int(status.ReadyReplicas),
+		AvailableReplicas: int(status.AvailableReplicas),
+		Conditions:        k8sWorkloadConditionsToScWorkloadConditions(status.Conditions, true),
+	}
+
+	for _, c := range status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == progressDeadlineExceededReason {
+			rollout.Phase = types.WorkloadRolloutPhaseFailed
+			rollout.Message = c.Message
+			return rollout
+		}
+	}
+
+	if k8sDeploy.Spec.Paused {
+		rollout.Phase = types.WorkloadRolloutPhasePaused
+	} else if status.ObservedGeneration >= k8sDeploy.Generation && int(status.UpdatedReplicas) == desired &&
+		int(status.Replicas) == desired && int(status.AvailableReplicas) == desired {
+		rollout.Phase = types.WorkloadRolloutPhaseComplete
+	}
+	return rollout
+}
+
+func k8sStatefulSetToWorkloadRollout(k8sStatefulSet *appsv1.StatefulSet) *types.WorkloadRollout {
+	desired := replicasOf(k8sStatefulSet.Spec.Replicas)
+	status := k8sStatefulSet.Status
+	rollout := &types.WorkloadRollout{
+		Phase:             types.WorkloadRolloutPhaseProgressing,
+		Revision:          status.UpdateRevision,
+		DesiredReplicas:   desired,
+		UpdatedReplicas:   int(status.UpdatedReplicas),
+		ReadyReplicas:     int(status.ReadyReplicas),
+		AvailableReplicas: int(status.ReadyReplicas),
+		Conditions:        k8sWorkloadConditionsToScWorkloadConditions(status.Conditions, false),
+	}
+
+	if isStatefulSetPaused(k8sStatefulSet) {
+		rollout.Phase = types.WorkloadRolloutPhasePaused
+	} else if status.ObservedGeneration >= k8sStatefulSet.Generation && status.CurrentRevision == status.UpdateRevision &&
+		int(status.UpdatedReplicas) == desired && int(status.ReadyReplicas) == desired {
+		rollout.Phase = types.WorkloadRolloutPhaseComplete
+	}
+	return rollout
+}
+
+func k8sDaemonSetToWorkloadRollout(k8sDaemonSet *appsv1.DaemonSet) *types.WorkloadRollout {
+	status := k8sDaemonSet.Status
+	desired := int(status.DesiredNumberScheduled)
+	rollout := &types.WorkloadRollout{
+		Phase:             types.WorkloadRolloutPhaseProgressing,
+		Revision:          strconv.FormatInt(k8sDaemonSet.Generation, 10),
+		DesiredReplicas:   desired,
+		UpdatedReplicas:   int(status.UpdatedNumberScheduled),
+		ReadyReplicas:     int(status.NumberReady),
+		AvailableReplicas: int(status.NumberAvailable),
+		Conditions:        k8sWorkloadConditionsToScWorkloadConditions(status.Conditions, false),
+	}
+
+	if isDaemonSetPaused(k8sDaemonSet) {
+		rollout.Phase = types.WorkloadRolloutPhasePaused
+	} else if status.ObservedGeneration >= k8sDaemonSet.Generation && int(status.UpdatedNumberScheduled) == desired &&
+		int(status.NumberAvailable) == desired {
+		rollout.Phase = types.WorkloadRolloutPhaseComplete
+	}
+	return rollout
+}
+
+// getStuckPods returns pods which aren't ready with reason from container
+// status, pod condition or the latest warning event of the pod
+func getStuckPods(cli client.Client, namespace, ownerType, ownerName string) ([]types.StuckPod, error) {
+	k8sPods, err := getOwnerPods(cli, namespace, ownerType, ownerName)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sEvents := corev1.EventList{}
+	opts := &client.ListOptions{Namespace: namespace}
+	opts.MatchingField("type", corev1.EventTypeWarning)
+	if err := cli.List(context.TODO(), opts, &k8sEvents); err != nil {
+		return nil, err
+	}
+
+	return k8sPodsToStuckPods(k8sPods.Items, latestPodWarningEvents(k8sEvents.Items)), nil
+}
+
+func latestPodWarningEvents(k8sEvents []corev1.Event) map[k8stypes.UID]corev1.Event {
+	events := make(map[k8stypes.UID]corev1.Event)
+	for _, e := range k8sEvents {
+		if e.InvolvedObject.Kind != "Pod" || e.Type != corev1.EventTypeWarning {
+			continue
+		}
+		if latest, ok := events[e.InvolvedObject.UID]; ok == false || eventLastTimestamp(latest).Before(eventLastTimestamp(e)) {
+			events[e.InvolvedObject.UID] = e
+		}
+	}
+	return events
+}
+
+func eventLastTimestamp(e corev1.Event) time.Time {
+	if e.LastTimestamp.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.LastTimestamp.Time
+}
+
+func k8sPodsToStuckPods(k8sPods []corev1.Pod, events map[k8stypes.UID]corev1.Event) []types.StuckPod {
+	var stuckPods []types.StuckPod
+	for _, k8sPod := range k8sPods {
+		if k8sPod.DeletionTimestamp != nil || isPodReady(&k8sPod) {
+			continue
+		}
+
+		stuck := false
+		for _, status := range append(append([]corev1.ContainerStatus{}, k8sPod.Status.InitContainerStatuses...), k8sPod.Status.ContainerStatuses...) {
+			if waiting := status.State.Waiting; waiting != nil && stuckContainerReasons[waiting.Reason] {
+				stuckPods = append(stuckPods, types.StuckPod{
+					Name:      k8sPod.Name,
+					Container: status.Name,
+					Reason:    waiting.Reason,
+					Message:   waiting.Message,
+				})
+				stuck = true
+			}
+		}
+		if stuck {
+			continue
+		}
+
+		for _, c := range k8sPod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				stuckPods = append(stuckPods, types.StuckPod{
+					Name:          k8sPod.Name,
+					Reason:        c.Reason,
+					Message:       c.Message,
+					LastTimestamp: resource.ISOTime(c.LastTransitionTime.Time),
+				})
+				stuck = true
+			}
+		}
+		if stuck {
+			continue
+		}
+
+		if e, ok := events[k8sPod.UID]; ok {
+			stuckPods = append(stuckPods, types.StuckPod{
+				Name:          k8sPod.Name,
+				Container:     fieldPathToContainerName(e.InvolvedObject.FieldPath),
+				Reason:        e.Reason,
+				Message:       e.Message,
+				Count:         e.Count,
+				LastTimestamp: resource.ISOTime(eventLastTimestamp(e)),
+			})
+		}
+	}
+	return stuckPods
+}
+
+func isPodReady(k8sPod *corev1.Pod) bool {
+	for _, c := range k8sPod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// OpenWorkloadRollout sends rollout of workload when it changes, until
+// rollout is complete, failed or timeout which is in seconds
+func (m *ClusterManager) OpenWorkloadRollout(clusterID, namespace, ownerType, ownerName, timeout string, r *http.Request, w http.ResponseWriter) {
+	cluster := m.GetClusterByName(clusterID)
+	if cluster == nil {
+		logger.Warnf("cluster %s isn't found to open %s %s rollout", clusterID, ownerType, ownerName)
+		return
+	}
+
+	duration := defaultWorkloadRolloutTimeout
+	if timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds <= 0 {
+			http.Error(w, fmt.Sprintf("invalid timeout %s", timeout), http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
+	if err != nil {
+		logger.Warnf("%s %s-%s-%s rollout websocket upgrade failed %s", ownerType, clusterID, namespace, ownerName, err.Error())
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(workloadRolloutWatchInterval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	var last []byte
+	for {
+		rollout, apiErr := getWorkloadRollout(cluster.GetKubeClient(), namespace, ownerType, ownerName)
+		if apiErr != nil {
+			logger.Warnf("get %s %s-%s-%s rollout failed %s", ownerType, clusterID, namespace, ownerName, apiErr.Message)
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, apiErr.Message))
+			return
+		}
+
+		data, _ := json.Marshal(rollout)
+		if string(data) != string(last) {
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				logger.Warnf("send %s %s-%s-%s rollout failed %s", ownerType, clusterID, namespace, ownerName, err.Error())
+				return
+			}
+			last = data
+		}
+
+		if rollout.Phase == types.WorkloadRolloutPhaseComplete || rollout.Phase == types.WorkloadRolloutPhaseFailed {
+			return
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-deadline:
+			rollout.Phase = types.WorkloadRolloutPhaseTimeout
+			rollout.Message = fmt.Sprintf("rollout isn't complete in %s", duration.String())
+			data, _ := json.Marshal(rollout)
+			conn.WriteMessage(websocket.TextMessage, data)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestK8sDeployToWorkloadRollout(t *testing.T) {
+	replicas := int32(3)
+	k8sDeploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           4,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  3,
+		},
+	}
+	ut.Equal(t, k8sDeployToWorkloadRollout(k8sDeploy).Phase, types.WorkloadRolloutPhaseProgressing)
+
+	k8sDeploy.Status.Replicas = 3
+	k8sDeploy.Status.UpdatedReplicas = 3
+	ut.Equal(t, k8sDeployToWorkloadRollout(k8sDeploy).Phase, types.WorkloadRolloutPhaseComplete)
+
+	k8sDeploy.Spec.Paused = true
+	ut.Equal(t, k8sDeployToWorkloadRollout(k8sDeploy).Phase, types.WorkloadRolloutPhasePaused)
+
+	k8sDeploy.Status.Conditions = []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Reason: progressDeadlineExceededReason, Message: "timeout"},
+	}
+	rollout := k8sDeployToWorkloadRollout(k8sDeploy)
+	ut.Equal(t, rollout.Phase, types.WorkloadRolloutPhaseFailed)
+	ut.Equal(t, rollout.Message, "timeout")
+}
+
+func TestK8sPodsToStuckPods(t *testing.T) {
+	now := time.Now()
+	k8sPods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ready", UID: "1"},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "backoff", UID: "2"},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "web", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "mount", UID: "3"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "starting", UID: "4"},
+		},
+	}
+	events := latestPodWarningEvents([]corev1.Event{
+		{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", UID: "3"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedScheduling",
+			LastTimestamp:  metav1.NewTime(now.Add(-time.Minute)),
+		},
+		{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", UID: "3"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedMount",
+			Count:          2,
+			LastTimestamp:  metav1.NewTime(now),
+		},
+	})
+	ut.Equal(t, len(events), 1)
+	ut.Equal(t, events[k8stypes.UID("3")].Reason, "FailedMount")
+
+	stuckPods := k8sPodsToStuckPods(k8sPods, events)
+	ut.Equal(t, len(stuckPods), 2)
+	ut.Equal(t, stuckPods[0].Name, "backoff")
+	ut.Equal(t, stuckPods[0].Container, "web")
+	ut.Equal(t, stuckPods[0].Reason, "ImagePullBackOff")
+	ut.Equal(t, stuckPods[1].Name, "mount")
+	ut.Equal(t, stuckPods[1].Reason, "FailedMount")
+	ut.Equal(t, stuckPods[1].Count, int32(2))
+}
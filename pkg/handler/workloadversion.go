@@ -3,6 +3,10 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,6 +14,8 @@ import (
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
 	"github.com/zdnscloud/gok8s/client"
+	resterror "github.com/zdnscloud/gorest/error"
+	"github.com/zdnscloud/singlecloud/pkg/types"
 )
 
 func getControllerRevisions(cli client.Client, namespace string, selector *metav1.LabelSelector, uid k8stypes.UID) ([]appsv1.ControllerRevision, error) {
@@ -57,3 +63,169 @@ func isControllerBy(ownerRefs []metav1.OwnerReference, uid k8stypes.UID) bool {
 	}
 	return false
 }
+
+// data of controllerrevision of statefulset and daemonset is a patch
+// which contains the whole pod template
+func getControllerRevisionTemplate(cr *appsv1.ControllerRevision) (corev1.PodTemplateSpec, error) {
+	var data struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(cr.Data.Raw, &data); err != nil {
+		return corev1.PodTemplateSpec{}, fmt.Errorf("unmarshal controllerrevision data failed: %v", err.Error())
+	}
+	return data.Spec.Template, nil
+}
+
+func getControllerRevisionsTemplates(controllerRevisions []appsv1.ControllerRevision) (map[int]corev1.PodTemplateSpec, error) {
+	templates := make(map[int]corev1.PodTemplateSpec)
+	for i := range controllerRevisions {
+		template, err := getControllerRevisionTemplate(&controllerRevisions[i])
+		if err != nil {
+			return nil, err
+		}
+		templates[int(controllerRevisions[i].Revision)] = template
+	}
+	return templates, nil
+}
+
+func diffVersions(templates map[int]corev1.PodTemplateSpec, param *types.DiffVersions) (*types.VersionDiff, *resterror.APIError) {
+	from, ok := templates[param.From]
+	if ok == false {
+		return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found version: %v", param.From))
+	}
+	to, ok := templates[param.To]
+	if ok == false {
+		return nil, resterror.NewAPIError(resterror.NotFound, fmt.Sprintf("no found version: %v", param.To))
+	}
+
+	changes, err := diffPodTemplates(from, to)
+	if err != nil {
+		return nil, resterror.NewAPIError(resterror.ServerError, fmt.Sprintf("diff versions failed: %v", err.Error()))
+	}
+	return &types.VersionDiff{
+		From:    param.From,
+		To:      param.To,
+		Changes: changes,
+	}, nil
+}
+
+// hash labels are added by controllers, they are different in every
+// version and meaningless to user
+var versionHashLabels = []string{appsv1.DefaultDeploymentUniqueLabelKey, appsv1.ControllerRevisionHashLabelKey}
+
+func diffPodTemplates(from, to corev1.PodTemplateSpec) ([]types.FieldChange, error) {
+	var values []interface{}
+	for _, template := range []corev1.PodTemplateSpec{from, to} {
+		template := template.DeepCopy()
+		for _, label := range versionHashLabels {
+			delete(template.Labels, label)
+		}
+
+		data, err := json.Marshal(template)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	changes := []types.FieldChange{}
+	diffValues("", values[0], values[1], &changes)
+	return changes, nil
+}
+
+func diffValues(path string, from, to interface{}, changes *[]types.FieldChange) {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			for _, key := range unionKeys(f, t) {
+				diffValues(joinFieldPath(path, key), f[key], t[key], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			fromNames, fromNamed := namedElements(f)
+			toNames, toNamed := namedElements(t)
+			if fromNamed && toNamed {
+				for _, name := range unionKeys(fromNames, toNames) {
+					diffValues(path+"["+name+"]", fromNames[name], toNames[name], changes)
+				}
+				return
+			} else if len(f) == len(t) {
+				for i := range f {
+					diffValues(fmt.Sprintf("%s[%d]", path, i), f[i], t[i], changes)
+				}
+				return
+			}
+		}
+	}
+
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+
+	change := types.FieldChange{Path: path, Type: types.FieldChangeModify}
+	if from == nil {
+		change.Type = types.FieldChangeAdd
+	} else {
+		data, _ := json.Marshal(from)
+		change.Old = string(data)
+	}
+	if to == nil {
+		change.Type = types.FieldChangeRemove
+	} else {
+		data, _ := json.Marshal(to)
+		change.New = string(data)
+	}
+	*changes = append(*changes, change)
+}
+
+// elements of list like containers, ports and env are identified by
+// name, so insertion or removal doesn't shift other elements
+func namedElements(list []interface{}) (map[string]interface{}, bool) {
+	elements := make(map[string]interface{})
+	for _, e := range list {
+		m, ok := e.(map[string]interface{})
+		if ok == false {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if ok == false || name == "" {
+			return nil, false
+		}
+		if _, ok := elements[name]; ok {
+			return nil, false
+		}
+		elements[name] = e
+	}
+	return elements, true
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; ok == false {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinFieldPath(path, key string) string {
+	if strings.ContainsAny(key, "./") {
+		key = "[" + key + "]"
+	} else if path != "" {
+		key = "." + key
+	}
+	return path + key
+}
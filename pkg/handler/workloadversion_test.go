@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestDiffPodTemplates(t *testing.T) {
+	from := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "abc"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "web", Image: "nginx:1.16", Args: []string{"-a"}},
+				{Name: "sidecar", Image: "busybox"},
+			},
+		},
+	}
+	to := *from.DeepCopy()
+	to.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "def"
+	to.Annotations = map[string]string{AnnKeyForRestartedAt: "now"}
+	to.Spec.Containers = []corev1.Container{
+		{Name: "web", Image: "nginx:1.17", Args: []string{"-a"}},
+		{Name: "log", Image: "fluentd"},
+	}
+
+	changes, err := diffPodTemplates(from, to)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, changes, []types.FieldChange{
+		{Path: "metadata.annotations", Type: types.FieldChangeAdd, New: `{"kubectl.kubernetes.io/restartedAt":"now"}`},
+		{Path: "spec.containers[log]", Type: types.FieldChangeAdd, New: `{"image":"fluentd","name":"log","resources":{}}`},
+		{Path: "spec.containers[sidecar]", Type: types.FieldChangeRemove, Old: `{"image":"busybox","name":"sidecar","resources":{}}`},
+		{Path: "spec.containers[web].image", Type: types.FieldChangeModify, Old: `"nginx:1.16"`, New: `"nginx:1.17"`},
+	})
+
+	changes, err = diffPodTemplates(from, from)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, len(changes), 0)
+}
+
+func TestGetControllerRevisionTemplate(t *testing.T) {
+	cr := &appsv1.ControllerRevision{
+		Data: runtime.RawExtension{
+			Raw: []byte(`{"spec":{"template":{"$patch":"replace","metadata":{"labels":{"app":"db"}},"spec":{"containers":[{"name":"db","image":"redis"}]}}}}`),
+		},
+	}
+	template, err := getControllerRevisionTemplate(cr)
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, template.Labels["app"], "db")
+	ut.Equal(t, template.Spec.Containers[0].Image, "redis")
+}
@@ -3,14 +3,16 @@ package k8seventwatcher
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
+	"github.com/zdnscloud/singlecloud/server"
 	"net/http"
 
-	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/uuid"
 )
 
+var logger = logging.GetLogger("k8seventwatcher").Std()
+
 const (
 	WSPrefix        = "/apis/ws.zcloud.cn/v1"
 	WSEventPathTemp = WSPrefix + "/clusters/%s/event"
@@ -32,13 +34,13 @@ func (mgr *WatcherManager) OpenEvent(clusterID string, r *http.Request, w http.R
 	mgr.lock.Unlock()
 
 	if ok == false {
-		log.Warnf("cluster %s isn't found to open console", clusterID)
+		logger.Warnf("cluster %s isn't found to open console", clusterID)
 		return
 	}
 
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
-		log.Warnf("cluster %s event websocket upgrade failed %s", clusterID, err.Error())
+		logger.Warnf("cluster %s event websocket upgrade failed %s", clusterID, err.Error())
 		return
 	}
 	defer conn.Close()
@@ -67,7 +69,7 @@ func (mgr *WatcherManager) OpenEvent(clusterID string, r *http.Request, w http.R
 		}
 		err := conn.WriteJSON(event)
 		if err != nil {
-			log.Warnf("send k8s event failed:%s", err.Error())
+			logger.Warnf("send k8s event failed:%s", err.Error())
 			break
 		}
 	}
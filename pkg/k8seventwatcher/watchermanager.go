@@ -3,7 +3,6 @@ package k8seventwatcher
 import (
 	"sync"
 
-	"github.com/zdnscloud/cement/log"
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 )
@@ -19,7 +18,7 @@ type WatcherManager struct {
 func New() *WatcherManager {
 	mgr := &WatcherManager{
 		watchers:       make(map[string]*EventWatcher),
-		clusterEventCh: eb.SubscribeResourceEvent(types.Cluster{}),
+		clusterEventCh: eb.SubscribeDurableResourceEvent("k8seventwatcher", types.Cluster{}),
 	}
 	go mgr.eventLoop()
 	return mgr
@@ -27,18 +26,21 @@ func New() *WatcherManager {
 
 func (mgr *WatcherManager) eventLoop() *EventWatcher {
 	for {
-		event := <-mgr.clusterEventCh
+		event, ok := <-mgr.clusterEventCh
+		if !ok {
+			return nil
+		}
 		switch e := event.(type) {
 		case eb.ResourceCreateEvent:
 			cluster := e.Resource.(*types.Cluster)
 			mgr.lock.Lock()
 			_, ok := mgr.watchers[cluster.Name]
 			if ok {
-				log.Warnf("event watcher detect duplicate cluster %s", cluster.Name)
+				logger.Warnf("event watcher detect duplicate cluster %s", cluster.Name)
 			} else {
 				watcher, err := NewEventWatcher(cluster.KubeProvider.GetKubeCache(), MaxEventCount)
 				if err != nil {
-					log.Warnf("create event watcher for cluster %s failed: %s", cluster.Name, err.Error())
+					logger.Warnf("create event watcher for cluster %s failed: %s", cluster.Name, err.Error())
 				} else {
 					mgr.watchers[cluster.Name] = watcher
 				}
@@ -52,7 +54,7 @@ func (mgr *WatcherManager) eventLoop() *EventWatcher {
 				watcher.Stop()
 				delete(mgr.watchers, clusterName)
 			} else {
-				log.Warnf("event watcher unknown cluster %s", clusterName)
+				logger.Warnf("event watcher unknown cluster %s", clusterName)
 			}
 			mgr.lock.Unlock()
 		}
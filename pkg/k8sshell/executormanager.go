@@ -3,8 +3,6 @@ package k8sshell
 import (
 	"sync"
 
-	"github.com/zdnscloud/cement/log"
-
 	"github.com/zdnscloud/gok8s/exec"
 	eb "github.com/zdnscloud/singlecloud/pkg/eventbus"
 	"github.com/zdnscloud/singlecloud/pkg/types"
@@ -19,7 +17,7 @@ type ExecutorManager struct {
 func New() *ExecutorManager {
 	mgr := &ExecutorManager{
 		executors:      make(map[string]*exec.Executor),
-		clusterEventCh: eb.SubscribeResourceEvent(types.Cluster{}),
+		clusterEventCh: eb.SubscribeDurableResourceEvent("k8sshell", types.Cluster{}),
 	}
 	go mgr.eventLoop()
 	return mgr
@@ -27,18 +25,21 @@ func New() *ExecutorManager {
 
 func (mgr *ExecutorManager) eventLoop() {
 	for {
-		event := <-mgr.clusterEventCh
+		event, ok := <-mgr.clusterEventCh
+		if !ok {
+			return
+		}
 		switch e := event.(type) {
 		case eb.ResourceCreateEvent:
 			cluster := e.Resource.(*types.Cluster)
 			mgr.lock.Lock()
 			_, ok := mgr.executors[cluster.Name]
 			if ok {
-				log.Warnf("shell executor detect duplicate cluster %s", cluster.Name)
+				logger.Warnf("shell executor detect duplicate cluster %s", cluster.Name)
 			} else {
 				executor, err := exec.New(cluster.KubeProvider.GetKubeRestConfig(), cluster.KubeProvider.GetKubeClient(), cluster.KubeProvider.GetKubeCache())
 				if err != nil {
-					log.Warnf("create executor for cluster %s failed: %s", cluster.Name, err.Error())
+					logger.Warnf("create executor for cluster %s failed: %s", cluster.Name, err.Error())
 				} else {
 					mgr.executors[cluster.Name] = executor
 				}
@@ -52,7 +53,7 @@ func (mgr *ExecutorManager) eventLoop() {
 				executor.Stop()
 				delete(mgr.executors, clusterName)
 			} else {
-				log.Warnf("event watcher unknown cluster %s", clusterName)
+				logger.Warnf("event watcher unknown cluster %s", clusterName)
 			}
 			mgr.lock.Unlock()
 		}
@@ -8,12 +8,15 @@ import (
 	"net/http"
 
 	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/exec"
 	"github.com/zdnscloud/singlecloud/pkg/handler"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+	"github.com/zdnscloud/singlecloud/server"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
+var logger = logging.GetLogger("k8sshell").Std()
+
 const (
 	WSPrefix                 = "/apis/ws.zcloud.cn/v1"
 	WSClusterShellPathTemp   = WSPrefix + "/clusters/%s/shell"
@@ -51,7 +54,7 @@ type ShellConn struct {
 }
 
 func newShellConn(r *http.Request, w http.ResponseWriter) (*ShellConn, error) {
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +95,7 @@ func (mgr *ExecutorManager) OpenClusterConsole(clusterID string, r *http.Request
 	mgr.lock.Unlock()
 
 	if ok == false {
-		log.Warnf("cluster %s is unknow for shell executor", clusterID)
+		logger.Warnf("cluster %s is unknow for shell executor", clusterID)
 		return
 	}
 
@@ -108,12 +111,12 @@ func (mgr *ExecutorManager) OpenClusterConsole(clusterID string, r *http.Request
 
 	stream, err := newShellConn(r, w)
 	if err != nil {
-		log.Warnf("new cluster %s console conn failed %s", clusterID, err.Error())
+		logger.Warnf("new cluster %s console conn failed %s", clusterID, err.Error())
 	}
 	defer stream.conn.Close()
 
 	if err := executor.Exec(pod, cmd, stream); err != nil {
-		log.Errorf("execute cmd failed %s", err.Error())
+		logger.Errorf("execute cmd failed %s", err.Error())
 	}
 }
 
@@ -123,7 +126,7 @@ func (mgr *ExecutorManager) OpenContainerConsole(clusterID, namespace, pod, cont
 	mgr.lock.Unlock()
 
 	if ok == false {
-		log.Warnf("cluster %s is unknow for shell executor", clusterID)
+		logger.Warnf("cluster %s is unknow for shell executor", clusterID)
 		return
 	}
 
@@ -135,11 +138,11 @@ func (mgr *ExecutorManager) OpenContainerConsole(clusterID, namespace, pod, cont
 
 	stream, err := newShellConn(r, w)
 	if err != nil {
-		log.Warnf("new container %s-%s-%s-%s console failed %s", clusterID, namespace, pod, container, err.Error())
+		logger.Warnf("new container %s-%s-%s-%s console failed %s", clusterID, namespace, pod, container, err.Error())
 	}
 	defer stream.conn.Close()
 
 	if err := executor.Exec(k8sPod, exec.Cmd{Path: ShPath}, stream); err != nil {
-		log.Errorf("execute bash failed %s", err.Error())
+		logger.Errorf("execute bash failed %s", err.Error())
 	}
 }
@@ -0,0 +1,248 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return Debug, fmt.Errorf("unknown log level %s, it should be one of %s", s, strings.Join(levelNames, ","))
+}
+
+type Fields map[string]interface{}
+
+// Logger writes one json object per line, records below the level of
+// its module are dropped
+type Logger struct {
+	module string
+	fields Fields
+}
+
+type ModuleLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+	//level is inherited from default level
+	Inherited bool `json:"inherited"`
+}
+
+type registry struct {
+	lock         sync.RWMutex
+	defaultLevel Level
+	levels       map[string]Level
+	modules      map[string]struct{}
+
+	outLock sync.Mutex
+	out     io.Writer
+}
+
+var gRegistry = &registry{
+	defaultLevel: Info,
+	levels:       make(map[string]Level),
+	modules:      make(map[string]struct{}),
+	out:          os.Stdout,
+}
+
+// GetLogger returns logger of module, the module name is used to adjust
+// log level at runtime
+func GetLogger(module string) *Logger {
+	gRegistry.lock.Lock()
+	gRegistry.modules[module] = struct{}{}
+	gRegistry.lock.Unlock()
+	return &Logger{module: module}
+}
+
+func SetOutput(w io.Writer) {
+	gRegistry.outLock.Lock()
+	gRegistry.out = w
+	gRegistry.outLock.Unlock()
+}
+
+func SetDefaultLevel(level Level) {
+	gRegistry.lock.Lock()
+	gRegistry.defaultLevel = level
+	gRegistry.lock.Unlock()
+}
+
+func GetDefaultLevel() Level {
+	gRegistry.lock.RLock()
+	defer gRegistry.lock.RUnlock()
+	return gRegistry.defaultLevel
+}
+
+// SetLevel overrides default level for module
+func SetLevel(module string, level Level) {
+	gRegistry.lock.Lock()
+	gRegistry.levels[module] = level
+	gRegistry.modules[module] = struct{}{}
+	gRegistry.lock.Unlock()
+}
+
+// ResetLevel makes module use default level again
+func ResetLevel(module string) {
+	gRegistry.lock.Lock()
+	delete(gRegistry.levels, module)
+	gRegistry.lock.Unlock()
+}
+
+func GetLevel(module string) Level {
+	gRegistry.lock.RLock()
+	defer gRegistry.lock.RUnlock()
+	if level, ok := gRegistry.levels[module]; ok {
+		return level
+	}
+	return gRegistry.defaultLevel
+}
+
+func GetLevels() []ModuleLevel {
+	gRegistry.lock.RLock()
+	defer gRegistry.lock.RUnlock()
+	levels := make([]ModuleLevel, 0, len(gRegistry.modules))
+	for module := range gRegistry.modules {
+		level, ok := gRegistry.levels[module]
+		if !ok {
+			level = gRegistry.defaultLevel
+		}
+		levels = append(levels, ModuleLevel{
+			Module:    module,
+			Level:     level.String(),
+			Inherited: !ok,
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Module < levels[j].Module })
+	return levels
+}
+
+// WithFields returns a logger which adds fields to every record
+func (l *Logger) WithFields(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{module: l.module, fields: merged}
+}
+
+func (l *Logger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	l.log(ctx, Debug, format, args...)
+}
+
+func (l *Logger) Infof(ctx context.Context, format string, args ...interface{}) {
+	l.log(ctx, Info, format, args...)
+}
+
+func (l *Logger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.log(ctx, Warn, format, args...)
+}
+
+func (l *Logger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.log(ctx, Error, format, args...)
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= GetLevel(l.module)
+}
+
+func (l *Logger) log(ctx context.Context, level Level, format string, args ...interface{}) {
+	if l.Enabled(level) {
+		l.write(ctx, level, format, args...)
+	}
+}
+
+// write should be called by log methods directly, so the caller of
+// log methods is recorded as source
+func (l *Logger) write(ctx context.Context, level Level, format string, args ...interface{}) {
+	record := make(map[string]interface{}, len(l.fields)+6)
+	for k, v := range l.fields {
+		record[k] = v
+	}
+	record["time"] = time.Now().Format(time.RFC3339Nano)
+	record["level"] = level.String()
+	record["module"] = l.module
+	record["msg"] = fmt.Sprintf(format, args...)
+	if id := RequestIDFromContext(ctx); id != "" {
+		record["requestId"] = id
+	}
+	if _, file, line, ok := runtime.Caller(3); ok {
+		record["source"] = fmt.Sprintf("%s:%d", path.Base(file), line)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{
+			"time":   record["time"],
+			"level":  record["level"],
+			"module": l.module,
+			"msg":    fmt.Sprintf("marshal log record failed %s: %s", err.Error(), record["msg"]),
+		})
+	}
+	gRegistry.outLock.Lock()
+	gRegistry.out.Write(append(data, '\n'))
+	gRegistry.outLock.Unlock()
+}
+
+// StdLogger is used where there is no request context, like background
+// loops and startup
+type StdLogger struct {
+	logger *Logger
+}
+
+func (l *Logger) Std() *StdLogger {
+	return &StdLogger{logger: l}
+}
+
+func (l *StdLogger) Debugf(format string, args ...interface{}) {
+	l.logger.log(nil, Debug, format, args...)
+}
+
+func (l *StdLogger) Infof(format string, args ...interface{}) {
+	l.logger.log(nil, Info, format, args...)
+}
+
+func (l *StdLogger) Warnf(format string, args ...interface{}) {
+	l.logger.log(nil, Warn, format, args...)
+}
+
+func (l *StdLogger) Errorf(format string, args ...interface{}) {
+	l.logger.log(nil, Error, format, args...)
+}
+
+// Fatalf logs error and exits, error is the highest level, so it's
+// never dropped
+func (l *StdLogger) Fatalf(format string, args ...interface{}) {
+	l.logger.log(nil, Error, format, args...)
+	os.Exit(1)
+}
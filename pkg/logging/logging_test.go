@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stdout)
+
+	logger := GetLogger("test").WithFields(Fields{"cluster": "local"})
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.Debugf(ctx, "dropped")
+	ut.Equal(t, buf.Len(), 0)
+
+	logger.Infof(ctx, "create %s", "cluster")
+	var record map[string]interface{}
+	ut.Assert(t, json.Unmarshal(buf.Bytes(), &record) == nil, "log should be json")
+	ut.Equal(t, record["msg"], "create cluster")
+	ut.Equal(t, record["level"], "info")
+	ut.Equal(t, record["module"], "test")
+	ut.Equal(t, record["requestId"], "req-1")
+	ut.Equal(t, record["cluster"], "local")
+	ut.Assert(t, strings.HasPrefix(record["source"].(string), "logging_test.go:"), "source should be caller")
+
+	buf.Reset()
+	SetLevel("test", Debug)
+	logger.Debugf(nil, "kept")
+	ut.Assert(t, buf.Len() > 0, "debug log should be kept after level changed")
+	ut.Equal(t, GetLevel("other"), Info)
+
+	levels := GetLevels()
+	ut.Equal(t, levels[0], ModuleLevel{Module: "test", Level: "debug"})
+	ResetLevel("test")
+	ut.Equal(t, GetLevel("test"), Info)
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	ut.Assert(t, err == nil, "")
+	ut.Equal(t, level, Warn)
+	_, err = ParseLevel("verbose")
+	ut.Assert(t, err != nil, "unknown level should fail")
+}
+
+func TestValidRequestID(t *testing.T) {
+	ut.Assert(t, ValidRequestID("0f6d-4a_b.1"), "")
+	ut.Assert(t, !ValidRequestID(""), "")
+	ut.Assert(t, !ValidRequestID("a\nb"), "")
+	ut.Assert(t, !ValidRequestID(strings.Repeat("a", 65)), "")
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stdout)
+
+	logger := GetLogger("std").Std()
+	logger.Debugf("dropped")
+	ut.Equal(t, buf.Len(), 0)
+
+	logger.Warnf("disk %s is full", "sda")
+	var record map[string]interface{}
+	ut.Assert(t, json.Unmarshal(buf.Bytes(), &record) == nil, "log should be json")
+	ut.Equal(t, record["msg"], "disk sda is full")
+	ut.Equal(t, record["module"], "std")
+	_, ok := record["requestId"]
+	ut.Equal(t, ok, false)
+	ut.Assert(t, strings.HasPrefix(record["source"].(string), "logging_test.go:"), "source should be caller")
+}
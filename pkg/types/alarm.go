@@ -23,6 +23,7 @@ type Alarm struct {
 	Reason                string           `json:"reason" rest:"description=readonly"`
 	Message               string           `json:"message" rest:"description=readonly"`
 	Acknowledged          bool             `json:"acknowledged"`
+	RequestID             string           `json:"requestId,omitempty" rest:"description=readonly"`
 }
 
 type Alarms []*Alarm
@@ -15,6 +15,7 @@ type AuditLog struct {
 	ResourceKind          string `json:"resourceKind"`
 	ResourcePath          string `json:"resourcePath"`
 	Detail                string `json:"detail"`
+	RequestID             string `json:"requestId,omitempty"`
 }
 
 type AuditLogs []*AuditLog
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"strings"
 
 	"github.com/zdnscloud/gok8s/cache"
@@ -20,7 +21,12 @@ const (
 	CSDeleting     ClusterStatus = "Deleting"
 	CSDeleted      ClusterStatus = "Deleted"
 
-	CSCancelAction = "cancel"
+	CSCancelAction     = "cancel"
+	CSPreflightAction  = "preflight"
+	CSRetryNodesAction = "retryNodes"
+	CSRemoveNodeAction = "removeNode"
+	CSHealthAction     = "healthHistory"
+	CSSearchAction     = "search"
 
 	DefaultNetworkPlugin       = "flannel"
 	DefaultClusterCIDR         = "10.42.0.0/16"
@@ -54,17 +60,22 @@ type Cluster struct {
 	PodUsed         int64  `json:"podUsed" rest:"description=readonly"`
 	PodUsedRatio    string `json:"podUsedRatio" rest:"description=readonly"`
 
-	SSHUser string `json:"sshUser" rest:"required=true,minLen=1,maxLen=128"`
+	SSHUser string `json:"sshUser" rest:"maxLen=128"`
 	//sshkey is necessary for create, but we cat't get it by get or list api due to some security problem(all user can get the cluster sshkey by get or list api), so we do this required check in cluster handler and it's not necessary for update
-	SSHKey              string   `json:"sshKey"`
-	SSHPort             string   `json:"sshPort"`
-	ClusterCidr         string   `json:"clusterCidr" rest:"description=immutable"`
-	ServiceCidr         string   `json:"serviceCidr" rest:"description=immutable"`
-	ClusterDomain       string   `json:"clusterDomain" rest:"required=true,description=immutable,isDomain=true"`
-	ClusterDNSServiceIP string   `json:"clusterDNSServiceIP,omitempty" rest:"description=immutable"`
-	ClusterUpstreamDNS  []string `json:"clusterUpstreamDNS" rest:"description=immutable"`
-
-	KubeProvider KubeProvider `json:"-"`
+	SSHKey              string         `json:"sshKey"`
+	SSHPort             string         `json:"sshPort"`
+	ClusterCidr         string         `json:"clusterCidr" rest:"description=immutable"`
+	ServiceCidr         string         `json:"serviceCidr" rest:"description=immutable"`
+	ClusterDomain       string         `json:"clusterDomain" rest:"description=immutable,isDomain=true"`
+	ClusterDNSServiceIP string         `json:"clusterDNSServiceIP,omitempty" rest:"description=immutable"`
+	ClusterUpstreamDNS  []string       `json:"clusterUpstreamDNS" rest:"description=immutable"`
+	Template            string         `json:"template,omitempty" rest:"description=immutable"`
+	TemplateRevision    int            `json:"templateRevision,omitempty" rest:"description=readonly"`
+	TemplateDrift       []string       `json:"templateDrift,omitempty" rest:"description=readonly"`
+	Health              *ClusterHealth `json:"health,omitempty" rest:"description=readonly"`
+
+	KubeProvider KubeProvider    `json:"-"`
+	setFields    map[string]bool `json:"-"`
 }
 
 type ClusterNetwork struct {
@@ -80,6 +91,48 @@ type ClusterLoadBalance struct {
 	Password     string `json:"password"`
 }
 
+type PreflightReport struct {
+	Passed bool                  `json:"passed"`
+	Nodes  []NodePreflightResult `json:"nodes"`
+}
+
+type NodePreflightResult struct {
+	Name          string   `json:"name"`
+	Address       string   `json:"address"`
+	Reachable     bool     `json:"reachable"`
+	Hostname      string   `json:"hostname,omitempty"`
+	OS            string   `json:"os,omitempty"`
+	KernelVersion string   `json:"kernelVersion,omitempty"`
+	DockerVersion string   `json:"dockerVersion,omitempty"`
+	FreeDisk      int64    `json:"freeDisk,omitempty"`
+	FreeMemory    int64    `json:"freeMemory,omitempty"`
+	TimeOffset    int64    `json:"timeOffset"`
+	PortsInUse    []int    `json:"portsInUse,omitempty"`
+	Passed        bool     `json:"passed"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+type RemoveNode struct {
+	Name string `json:"name" rest:"required=true"`
+}
+
+type ClusterHealth struct {
+	Time       resource.ISOTime  `json:"time"`
+	Reachable  bool              `json:"reachable"`
+	Latency    int64             `json:"latency"`
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+type ComponentHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+type ClusterHealthHistory struct {
+	Records []ClusterHealth `json:"records"`
+}
+
 type KubeProvider interface {
 	GetKubeClient() client.Client
 	GetKubeCache() cache.Cache
@@ -104,6 +157,27 @@ var ClusterActions = []resource.Action{
 	resource.Action{
 		Name: CSCancelAction,
 	},
+	resource.Action{
+		Name:   CSPreflightAction,
+		Input:  &Cluster{},
+		Output: &PreflightReport{},
+	},
+	resource.Action{
+		Name: CSRetryNodesAction,
+	},
+	resource.Action{
+		Name:  CSRemoveNodeAction,
+		Input: &RemoveNode{},
+	},
+	resource.Action{
+		Name:   CSHealthAction,
+		Output: &ClusterHealthHistory{},
+	},
+	resource.Action{
+		Name:   CSSearchAction,
+		Input:  &ResourceSearch{},
+		Output: &ResourceSearchResult{},
+	},
 }
 
 func (c Cluster) GetActions() []resource.Action {
@@ -114,6 +188,29 @@ func (c Cluster) SupportAsyncDelete() bool {
 	return true
 }
 
+// UnmarshalJSON records fields present in request, so fields explicitly set
+// to default value won't be overwritten by cluster template
+func (c *Cluster) UnmarshalJSON(data []byte) error {
+	type cluster Cluster
+	if err := json.Unmarshal(data, (*cluster)(c)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.setFields = make(map[string]bool)
+	for name := range fields {
+		c.setFields[name] = true
+	}
+	return nil
+}
+
+func (c *Cluster) IsFieldSet(jsonName string) bool {
+	return c.setFields[jsonName]
+}
+
 func (c *Cluster) TrimFieldSpace() {
 	c.Name = strings.TrimSpace(c.Name)
 	c.SSHUser = strings.TrimSpace(c.SSHUser)
@@ -123,6 +220,7 @@ func (c *Cluster) TrimFieldSpace() {
 	c.ClusterCidr = strings.TrimSpace(c.ClusterCidr)
 	c.ServiceCidr = strings.TrimSpace(c.ServiceCidr)
 	c.ClusterDNSServiceIP = strings.TrimSpace(c.ClusterDNSServiceIP)
+	c.Template = strings.TrimSpace(c.Template)
 	c.LoadBalance.MasterServer = strings.TrimSpace(c.LoadBalance.MasterServer)
 	c.LoadBalance.BackupServer = strings.TrimSpace(c.LoadBalance.BackupServer)
 	c.LoadBalance.User = strings.TrimSpace(c.LoadBalance.User)
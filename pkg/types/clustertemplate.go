@@ -0,0 +1,74 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/zdnscloud/gorest/resource"
+)
+
+const (
+	ClusterTemplateTable = "clustertemplate"
+)
+
+type ClusterTemplate struct {
+	resource.ResourceBase `json:",inline"`
+	Name                  string             `json:"name" rest:"required=true,isDomain=true,description=immutable"`
+	Revision              int                `json:"revision" rest:"description=readonly"`
+	Network               ClusterNetwork     `json:"network"`
+	LoadBalance           ClusterLoadBalance `json:"loadBalance"`
+	SSHUser               string             `json:"sshUser" rest:"required=true,minLen=1,maxLen=128"`
+	SSHPort               string             `json:"sshPort"`
+	ClusterCidr           string             `json:"clusterCidr"`
+	ServiceCidr           string             `json:"serviceCidr"`
+	ClusterDomain         string             `json:"clusterDomain" rest:"required=true,isDomain=true"`
+	ClusterDNSServiceIP   string             `json:"clusterDNSServiceIP,omitempty"`
+	ClusterUpstreamDNS    []string           `json:"clusterUpstreamDNS"`
+	Memo                  string             `json:"memo,omitempty"`
+}
+
+func (t ClusterTemplate) CreateDefaultResource() resource.Resource {
+	return &ClusterTemplate{
+		Network: ClusterNetwork{
+			Plugin: DefaultNetworkPlugin,
+		},
+		ClusterCidr:         DefaultClusterCIDR,
+		ServiceCidr:         DefaultServiceCIDR,
+		ClusterDomain:       DefaultClusterDomain,
+		ClusterDNSServiceIP: DefaultClusterDNSServiceIP,
+		ClusterUpstreamDNS:  []string{DefaultClusterUpstreamDNS1, DefaultClusterUpstreamDNS2},
+		SSHPort:             DefaultSSHPort,
+	}
+}
+
+var ClusterTemplateActions = []resource.Action{
+	resource.Action{
+		Name:   ActionGetHistory,
+		Output: &ClusterTemplateHistory{},
+	},
+}
+
+func (t ClusterTemplate) GetActions() []resource.Action {
+	return ClusterTemplateActions
+}
+
+type ClusterTemplateHistory struct {
+	Revisions []*ClusterTemplate `json:"revisions"`
+}
+
+func (t *ClusterTemplate) TrimFieldSpace() {
+	t.Name = strings.TrimSpace(t.Name)
+	t.SSHUser = strings.TrimSpace(t.SSHUser)
+	t.SSHPort = strings.TrimSpace(t.SSHPort)
+	t.ClusterDomain = strings.TrimSpace(t.ClusterDomain)
+	t.ClusterCidr = strings.TrimSpace(t.ClusterCidr)
+	t.ServiceCidr = strings.TrimSpace(t.ServiceCidr)
+	t.ClusterDNSServiceIP = strings.TrimSpace(t.ClusterDNSServiceIP)
+	t.LoadBalance.MasterServer = strings.TrimSpace(t.LoadBalance.MasterServer)
+	t.LoadBalance.BackupServer = strings.TrimSpace(t.LoadBalance.BackupServer)
+	t.LoadBalance.User = strings.TrimSpace(t.LoadBalance.User)
+	t.LoadBalance.Password = strings.TrimSpace(t.LoadBalance.Password)
+
+	for i, ns := range t.ClusterUpstreamDNS {
+		t.ClusterUpstreamDNS[i] = strings.TrimSpace(ns)
+	}
+}
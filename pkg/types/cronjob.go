@@ -4,13 +4,23 @@ import (
 	"github.com/zdnscloud/gorest/resource"
 )
 
+const (
+	ActionSuspend = "suspend"
+	ActionTrigger = "trigger"
+)
+
 type CronJob struct {
 	resource.ResourceBase `json:",inline"`
-	Name                  string        `json:"name" rest:"required=true,isDomain=true"`
-	Schedule              string        `json:"schedule" rest:"required=true"`
-	RestartPolicy         string        `json:"restartPolicy" rest:"required=true,options=OnFailure|Never"`
-	Containers            []Container   `json:"containers" rest:"required=true"`
-	Status                CronJobStatus `json:"status,omitempty" rest:"description=readonly"`
+	Name                  string             `json:"name" rest:"required=true,isDomain=true"`
+	Schedule              string             `json:"schedule" rest:"required=true"`
+	RestartPolicy         string             `json:"restartPolicy" rest:"required=true,options=OnFailure|Never"`
+	Containers            []Container        `json:"containers" rest:"required=true"`
+	InitContainers        []Container        `json:"initContainers,omitempty"`
+	Volumes               []PodVolume        `json:"volumes,omitempty"`
+	Scheduling            Scheduling         `json:"scheduling,omitempty"`
+	SecurityContext       PodSecurityContext `json:"securityContext,omitempty"`
+	Suspend               bool               `json:"suspend,omitempty"`
+	Status                CronJobStatus      `json:"status,omitempty" rest:"description=readonly"`
 }
 
 type CronJobStatus struct {
@@ -32,6 +42,25 @@ func (c CronJob) GetParents() []resource.ResourceKind {
 	return []resource.ResourceKind{Namespace{}}
 }
 
+// trigger creates a job from job template of the cronjob immediately,
+// output is reference of the job
+var CronJobActions = []resource.Action{
+	resource.Action{
+		Name: ActionSuspend,
+	},
+	resource.Action{
+		Name: ActionResume,
+	},
+	resource.Action{
+		Name:   ActionTrigger,
+		Output: &ObjectReference{},
+	},
+}
+
+func (c CronJob) GetActions() []resource.Action {
+	return CronJobActions
+}
+
 func (c CronJob) SupportAsyncDelete() bool {
 	return true
 }
@@ -9,8 +9,12 @@ type DaemonSet struct {
 	Name                  string                     `json:"name" rest:"required=true,isDomain=true,description=immutable"`
 	Replicas              int                        `json:"replicas" rest:"description=readonly"`
 	Containers            []Container                `json:"containers" rest:"required=true"`
+	InitContainers        []Container                `json:"initContainers,omitempty"`
+	Volumes               []PodVolume                `json:"volumes,omitempty"`
 	AdvancedOptions       AdvancedOptions            `json:"advancedOptions,omitempty" rest:"description=immutable"`
 	PersistentVolumes     []PersistentVolumeTemplate `json:"persistentVolumes,omitempty"`
+	Scheduling            Scheduling                 `json:"scheduling,omitempty"`
+	SecurityContext       PodSecurityContext         `json:"securityContext,omitempty"`
 	Status                WorkloadStatus             `json:"status,omitempty" rest:"description=readonly"`
 	Memo                  string                     `json:"memo,omitempty"`
 }
@@ -28,6 +32,20 @@ var DaemonSetActions = []resource.Action{
 		Name:  ActionRollback,
 		Input: &RollBackVersion{},
 	},
+	resource.Action{
+		Name:   ActionDiff,
+		Input:  &DiffVersions{},
+		Output: &VersionDiff{},
+	},
+	resource.Action{
+		Name: ActionPause,
+	},
+	resource.Action{
+		Name: ActionResume,
+	},
+	resource.Action{
+		Name: ActionRestart,
+	},
 }
 
 func (d DaemonSet) GetActions() []resource.Action {
@@ -9,6 +9,10 @@ const (
 	ActionRollback    = "rollback"
 	ActionSetImage    = "setImage"
 	ActionSetPodCount = "setPodCount"
+	ActionPause       = "pause"
+	ActionResume      = "resume"
+	ActionRestart     = "restart"
+	ActionDiff        = "diff"
 
 	VolumeTypeConfigMap        = "configmap"
 	VolumeTypeSecret           = "secret"
@@ -22,19 +26,65 @@ type ContainerPort struct {
 }
 
 type Container struct {
-	Name         string          `json:"name" rest:"required=true,isDomain=true"`
-	Image        string          `json:"image" rest:"required=true"`
-	Command      []string        `json:"command,omitempty"`
-	Args         []string        `json:"args,omitempty"`
-	ExposedPorts []ContainerPort `json:"exposedPorts,omitempty"`
-	Env          []EnvVar        `json:"env,omitempty"`
-	Volumes      []Volume        `json:"volumes,omitempty"`
+	Name            string               `json:"name" rest:"required=true,isDomain=true"`
+	Image           string               `json:"image" rest:"required=true"`
+	Command         []string             `json:"command,omitempty"`
+	Args            []string             `json:"args,omitempty"`
+	ExposedPorts    []ContainerPort      `json:"exposedPorts,omitempty"`
+	Env             []EnvVar             `json:"env,omitempty"`
+	Volumes         []Volume             `json:"volumes,omitempty"`
+	Resources       ResourceRequirements `json:"resources,omitempty"`
+	LivenessProbe   *Probe               `json:"livenessProbe,omitempty"`
+	ReadinessProbe  *Probe               `json:"readinessProbe,omitempty"`
+	StartupProbe    *Probe               `json:"startupProbe,omitempty"`
+	SecurityContext *SecurityContext     `json:"securityContext,omitempty"`
 }
 
+const (
+	ProbeTypeHTTP = "http"
+	ProbeTypeTCP  = "tcp"
+	ProbeTypeExec = "exec"
+)
+
+// http probe uses Path, Port or PortName, Scheme, Host and HTTPHeaders,
+// tcp probe uses Port or PortName and Host, exec probe uses Command,
+// PortName refers to exposed port of container, zero value of timing
+// fields means k8s default
+type Probe struct {
+	Type                string       `json:"type" rest:"required=true,options=http|tcp|exec"`
+	Path                string       `json:"path,omitempty"`
+	Port                int          `json:"port,omitempty"`
+	PortName            string       `json:"portName,omitempty"`
+	Host                string       `json:"host,omitempty"`
+	Scheme              string       `json:"scheme,omitempty" rest:"options=http|https"`
+	HTTPHeaders         []HTTPHeader `json:"httpHeaders,omitempty"`
+	Command             []string     `json:"command,omitempty"`
+	InitialDelaySeconds int          `json:"initialDelaySeconds,omitempty"`
+	TimeoutSeconds      int          `json:"timeoutSeconds,omitempty"`
+	PeriodSeconds       int          `json:"periodSeconds,omitempty"`
+	SuccessThreshold    int          `json:"successThreshold,omitempty"`
+	FailureThreshold    int          `json:"failureThreshold,omitempty"`
+}
+
+type HTTPHeader struct {
+	Name  string `json:"name" rest:"required=true"`
+	Value string `json:"value"`
+}
+
+// key of requests and limits could be cpu, memory, ephemeral-storage,
+// hugepages-<size> or extended resource like nvidia.com/gpu
+type ResourceRequirements struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// Name is configmap or secret name for configmap and secret, persistent
+// volume name for persistentVolume, pod volume name for others
 type Volume struct {
-	Type      string `json:"type,omitempty" rest:"options=configmap|secret|persistentVolume"`
+	Type      string `json:"type,omitempty" rest:"options=configmap|secret|persistentVolume|emptyDir|hostPath|projected"`
 	Name      string `json:"name,omitempty" rest:"isDomain=true"`
 	MountPath string `json:"mountPath,omitempty"`
+	SubPath   string `json:"subPath,omitempty"`
 }
 
 type EnvVar struct {
@@ -54,9 +104,14 @@ type Deployment struct {
 	Name                  string                     `json:"name" rest:"required=true,isDomain=true,description=immutable"`
 	Replicas              int                        `json:"replicas" rest:"required=true,min=0,max=50"`
 	Containers            []Container                `json:"containers" rest:"required=true"`
+	InitContainers        []Container                `json:"initContainers,omitempty"`
+	Volumes               []PodVolume                `json:"volumes,omitempty"`
 	AdvancedOptions       AdvancedOptions            `json:"advancedOptions,omitempty" rest:"description=immutable"`
 	PersistentVolumes     []PersistentVolumeTemplate `json:"persistentVolumes,omitempty"`
+	Scheduling            Scheduling                 `json:"scheduling,omitempty"`
+	SecurityContext       PodSecurityContext         `json:"securityContext,omitempty"`
 	Status                WorkloadStatus             `json:"status,omitempty" rest:"description=readonly"`
+	Rollout               *RolloutStatus             `json:"rollout,omitempty" rest:"description=readonly"`
 	Memo                  string                     `json:"memo,omitempty"`
 }
 
@@ -78,11 +133,38 @@ var DeploymentActions = []resource.Action{
 		Name:  ActionRollback,
 		Input: &RollBackVersion{},
 	},
+	resource.Action{
+		Name:   ActionDiff,
+		Input:  &DiffVersions{},
+		Output: &VersionDiff{},
+	},
 	resource.Action{
 		Name:   ActionSetPodCount,
 		Input:  &SetPodCount{},
 		Output: &SetPodCount{},
 	},
+	resource.Action{
+		Name: ActionPause,
+	},
+	resource.Action{
+		Name: ActionResume,
+	},
+	resource.Action{
+		Name: ActionRestart,
+	},
+	resource.Action{
+		Name:  ActionStartRollout,
+		Input: &Rollout{},
+	},
+	resource.Action{
+		Name: ActionPauseRollout,
+	},
+	resource.Action{
+		Name: ActionPromoteRollout,
+	},
+	resource.Action{
+		Name: ActionAbortRollout,
+	},
 }
 
 func (d Deployment) GetActions() []resource.Action {
@@ -95,6 +177,7 @@ func (d Deployment) SupportAsyncDelete() bool {
 
 type WorkloadStatus struct {
 	ReadyReplicas    int                 `json:"readyReplicas,omitempty"`
+	Paused           bool                `json:"paused,omitempty"`
 	Updating         bool                `json:"updating,omitempty"`
 	CurrentReplicas  int                 `json:"currentReplicas,omitempty"`
 	UpdatingReplicas int                 `json:"updatingReplicas,omitempty"`
@@ -135,6 +218,33 @@ func (vs VersionInfos) Less(i, j int) bool {
 	return vs[i].Version < vs[j].Version
 }
 
+// diff compares pod templates of two versions in history
+type DiffVersions struct {
+	From int `json:"from" rest:"required=true"`
+	To   int `json:"to" rest:"required=true"`
+}
+
+const (
+	FieldChangeAdd    = "add"
+	FieldChangeRemove = "remove"
+	FieldChangeModify = "modify"
+)
+
+// path of field is like spec.containers[web].image, Old and New are
+// json values
+type FieldChange struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+type VersionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
 type RollBackVersion struct {
 	Version int    `json:"version" rest:"required=true"`
 	Memo    string `json:"memo"`
@@ -6,10 +6,14 @@ import (
 
 type Job struct {
 	resource.ResourceBase `json:",inline"`
-	Name                  string      `json:"name" rest:"required=true,isDomain=true"`
-	RestartPolicy         string      `json:"restartPolicy" rest:"required=true,options=OnFailure|Never"`
-	Containers            []Container `json:"containers" rest:"required=true"`
-	Status                JobStatus   `json:"status,omitempty" rest:"description=readonly"`
+	Name                  string             `json:"name" rest:"required=true,isDomain=true"`
+	RestartPolicy         string             `json:"restartPolicy" rest:"required=true,options=OnFailure|Never"`
+	Containers            []Container        `json:"containers" rest:"required=true"`
+	InitContainers        []Container        `json:"initContainers,omitempty"`
+	Volumes               []PodVolume        `json:"volumes,omitempty"`
+	Scheduling            Scheduling         `json:"scheduling,omitempty"`
+	SecurityContext       PodSecurityContext `json:"securityContext,omitempty"`
+	Status                JobStatus          `json:"status,omitempty" rest:"description=readonly"`
 }
 
 type JobStatus struct {
@@ -0,0 +1,30 @@
+package types
+
+const (
+	ActionImportYAML = "importYAML"
+	ActionExportYAML = "exportYAML"
+)
+
+// yaml could contain multiple documents separated by ---
+type Manifest struct {
+	Yaml string `json:"yaml" rest:"required=true"`
+}
+
+// kind is kubernetes kind like Deployment, Message is the reason why the
+// resource isn't imported
+type ManifestResource struct {
+	Kind    string `json:"kind" rest:"required=true"`
+	Name    string `json:"name" rest:"required=true"`
+	Message string `json:"message,omitempty"`
+}
+
+type ManifestImportResult struct {
+	Created     []ManifestResource `json:"created,omitempty"`
+	Unsupported []ManifestResource `json:"unsupported,omitempty"`
+	Failed      []ManifestResource `json:"failed,omitempty"`
+}
+
+// all supported resources in namespace are exported if Resources is empty
+type ManifestExport struct {
+	Resources []ManifestResource `json:"resources,omitempty"`
+}
@@ -5,12 +5,14 @@ import (
 )
 
 const (
-	ActionSearchPod = "searchPod"
+	ActionSearchPod          = "searchPod"
+	ActionSetSecurityProfile = "setSecurityProfile"
 )
 
 type Namespace struct {
 	resource.ResourceBase `json:",inline"`
 	Name                  string           `json:"name" rest:"required=true,isDomain=true,description=immutable"`
+	SecurityProfile       string           `json:"securityProfile,omitempty" rest:"options=privileged|baseline|restricted"`
 	Cpu                   int64            `json:"cpu" rest:"description=readonly"`
 	CpuUsed               int64            `json:"cpuUsed" rest:"description=readonly"`
 	CpuUsedRatio          string           `json:"cpuUsedRatio" rest:"description=readonly"`
@@ -57,6 +59,20 @@ var NamespaceActions = []resource.Action{
 		Input:  &PodToSearch{},
 		Output: &PodInfo{},
 	},
+	resource.Action{
+		Name:  ActionSetSecurityProfile,
+		Input: &NamespaceSecurityProfile{},
+	},
+	resource.Action{
+		Name:   ActionImportYAML,
+		Input:  &Manifest{},
+		Output: &ManifestImportResult{},
+	},
+	resource.Action{
+		Name:   ActionExportYAML,
+		Input:  &ManifestExport{},
+		Output: &Manifest{},
+	},
 }
 
 func (n Namespace) GetActions() []resource.Action {
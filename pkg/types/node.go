@@ -13,6 +13,15 @@ const (
 	NSDrained  NodeStatus = "Drained"
 )
 
+type NodeProvisionStatus string
+
+const (
+	NPSPending      NodeProvisionStatus = "Pending"
+	NPSProvisioning NodeProvisionStatus = "Provisioning"
+	NPSReady        NodeProvisionStatus = "Ready"
+	NPSFailed       NodeProvisionStatus = "Failed"
+)
+
 type NodeRole string
 
 const (
@@ -24,31 +33,36 @@ const (
 )
 
 const (
-	NodeCordon   string = "cordon"
-	NodeUnCordon string = "uncordon"
-	NodeDrain    string = "drain"
+	NodeCordon    string = "cordon"
+	NodeUnCordon  string = "uncordon"
+	NodeDrain     string = "drain"
+	NodeSetLabels string = "setLabels"
+	NodeSetTaints string = "setTaints"
 )
 
 type Node struct {
 	resource.ResourceBase `json:",inline"`
-	Name                  string            `json:"name" rest:"required=true,description=immutable,isDomain=true"`
-	Status                NodeStatus        `json:"status" rest:"description=readonly"`
-	Address               string            `json:"address,omitempty" rest:"required=true,description=immutable"`
-	Roles                 []NodeRole        `json:"roles,omitempty" rest:"required=true,options=controlplane|worker|edge"`
-	Labels                map[string]string `json:"labels,omitempty" rest:"description=readonly"`
-	Annotations           map[string]string `json:"annotations,omitempty" rest:"description=readonly"`
-	OperatingSystem       string            `json:"operatingSystem,omitempty" rest:"description=readonly"`
-	OperatingSystemImage  string            `json:"operatingSystemImage,omitempty" rest:"description=readonly"`
-	DockerVersion         string            `json:"dockerVersion,omitempty" rest:"description=readonly"`
-	Cpu                   int64             `json:"cpu" rest:"description=readonly"`
-	CpuUsed               int64             `json:"cpuUsed" rest:"description=readonly"`
-	CpuUsedRatio          string            `json:"cpuUsedRatio" rest:"description=readonly"`
-	Memory                int64             `json:"memory" rest:"description=readonly"`
-	MemoryUsed            int64             `json:"memoryUsed" rest:"description=readonly"`
-	MemoryUsedRatio       string            `json:"memoryUsedRatio" rest:"description=readonly"`
-	Pod                   int64             `json:"pod" rest:"description=readonly"`
-	PodUsed               int64             `json:"podUsed" rest:"description=readonly"`
-	PodUsedRatio          string            `json:"podUsedRatio" rest:"description=readonly"`
+	Name                  string              `json:"name" rest:"required=true,description=immutable,isDomain=true"`
+	Status                NodeStatus          `json:"status" rest:"description=readonly"`
+	Address               string              `json:"address,omitempty" rest:"required=true,description=immutable"`
+	Roles                 []NodeRole          `json:"roles,omitempty" rest:"required=true,options=controlplane|worker|edge"`
+	Labels                map[string]string   `json:"labels,omitempty" rest:"description=readonly"`
+	Annotations           map[string]string   `json:"annotations,omitempty" rest:"description=readonly"`
+	Taints                []Taint             `json:"taints,omitempty" rest:"description=readonly"`
+	OperatingSystem       string              `json:"operatingSystem,omitempty" rest:"description=readonly"`
+	OperatingSystemImage  string              `json:"operatingSystemImage,omitempty" rest:"description=readonly"`
+	DockerVersion         string              `json:"dockerVersion,omitempty" rest:"description=readonly"`
+	Cpu                   int64               `json:"cpu" rest:"description=readonly"`
+	CpuUsed               int64               `json:"cpuUsed" rest:"description=readonly"`
+	CpuUsedRatio          string              `json:"cpuUsedRatio" rest:"description=readonly"`
+	Memory                int64               `json:"memory" rest:"description=readonly"`
+	MemoryUsed            int64               `json:"memoryUsed" rest:"description=readonly"`
+	MemoryUsedRatio       string              `json:"memoryUsedRatio" rest:"description=readonly"`
+	Pod                   int64               `json:"pod" rest:"description=readonly"`
+	PodUsed               int64               `json:"podUsed" rest:"description=readonly"`
+	PodUsedRatio          string              `json:"podUsedRatio" rest:"description=readonly"`
+	ProvisionStatus       NodeProvisionStatus `json:"provisionStatus,omitempty" rest:"description=readonly"`
+	ProvisionError        string              `json:"provisionError,omitempty" rest:"description=readonly"`
 }
 
 func (n Node) GetParents() []resource.ResourceKind {
@@ -65,6 +79,30 @@ var NodeActions = []resource.Action{
 	resource.Action{
 		Name: NodeDrain,
 	},
+	resource.Action{
+		Name:  NodeSetLabels,
+		Input: &NodeLabels{},
+	},
+	resource.Action{
+		Name:  NodeSetTaints,
+		Input: &NodeTaints{},
+	},
+}
+
+type Taint struct {
+	Key    string `json:"key" rest:"required=true"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect" rest:"required=true,options=NoSchedule|PreferNoSchedule|NoExecute"`
+}
+
+// labels and taints set by action replace all the ones on node except
+// those reserved by kubernetes and zcloud
+type NodeLabels struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type NodeTaints struct {
+	Taints []Taint `json:"taints"`
 }
 
 func (n Node) GetActions() []resource.Action {
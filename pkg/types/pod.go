@@ -30,14 +30,24 @@ type PodCondition struct {
 }
 
 type ContainerStatus struct {
-	Name         string          `json:"name,omitempty"`
-	Ready        bool            `json:"ready,omitempty"`
-	RestartCount int32           `json:"restartCount"`
-	Image        string          `json:"image,omitempty"`
-	ImageID      string          `json:"imageID,omitempty"`
-	ContainerID  string          `json:"containerID,omitempty"`
-	LastState    *ContainerState `json:"lastState,omitempty"`
-	State        *ContainerState `json:"state,omitempty"`
+	Name          string          `json:"name,omitempty"`
+	Ready         bool            `json:"ready,omitempty"`
+	RestartCount  int32           `json:"restartCount"`
+	Image         string          `json:"image,omitempty"`
+	ImageID       string          `json:"imageID,omitempty"`
+	ContainerID   string          `json:"containerID,omitempty"`
+	LastState     *ContainerState `json:"lastState,omitempty"`
+	State         *ContainerState `json:"state,omitempty"`
+	Started       *bool           `json:"started,omitempty"`
+	ProbeFailures []ProbeFailure  `json:"probeFailures,omitempty"`
+}
+
+// ProbeFailure is collected from Unhealthy events of pod
+type ProbeFailure struct {
+	Probe         string           `json:"probe"`
+	Message       string           `json:"message"`
+	Count         int32            `json:"count"`
+	LastTimestamp resource.ISOTime `json:"lastTimestamp"`
 }
 
 type ContainerState struct {
@@ -0,0 +1,41 @@
+package types
+
+const (
+	VolumeTypeEmptyDir  = "emptyDir"
+	VolumeTypeHostPath  = "hostPath"
+	VolumeTypeProjected = "projected"
+
+	StorageMediumMemory = "Memory"
+
+	ProjectedSourceTypeConfigMap           = "configmap"
+	ProjectedSourceTypeSecret              = "secret"
+	ProjectedSourceTypeDownwardAPI         = "downwardAPI"
+	ProjectedSourceTypeServiceAccountToken = "serviceAccountToken"
+)
+
+// PodVolume is shared by containers and init containers of pod, container
+// mounts it with volume type same as the pod volume type. emptyDir uses
+// Medium and SizeLimit, hostPath uses Path and HostPathType and could only
+// be used by admin, projected uses Sources
+type PodVolume struct {
+	Name         string            `json:"name" rest:"required=true,isDomain=true"`
+	Type         string            `json:"type" rest:"required=true,options=emptyDir|hostPath|projected"`
+	Medium       string            `json:"medium,omitempty" rest:"options=Memory"`
+	SizeLimit    string            `json:"sizeLimit,omitempty"`
+	Path         string            `json:"path,omitempty"`
+	HostPathType string            `json:"hostPathType,omitempty" rest:"options=DirectoryOrCreate|Directory|FileOrCreate|File|Socket"`
+	Sources      []ProjectedSource `json:"sources,omitempty"`
+}
+
+// configmap and secret source use Name and Items which maps key to path,
+// downwardAPI source uses Items which maps path to field path like
+// metadata.labels, serviceAccountToken source uses Path, Audience and
+// ExpirationSeconds
+type ProjectedSource struct {
+	Type              string            `json:"type" rest:"required=true,options=configmap|secret|downwardAPI|serviceAccountToken"`
+	Name              string            `json:"name,omitempty"`
+	Items             map[string]string `json:"items,omitempty"`
+	Path              string            `json:"path,omitempty"`
+	Audience          string            `json:"audience,omitempty"`
+	ExpirationSeconds int               `json:"expirationSeconds,omitempty"`
+}
@@ -7,6 +7,7 @@ import (
 func Resources() []resource.ResourceKind {
 	return []resource.ResourceKind{
 		Cluster{},
+		ClusterTemplate{},
 		Node{},
 		PodNetwork{},
 		NodeNetwork{},
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"github.com/zdnscloud/gorest/resource"
+)
+
+const (
+	ActionStartRollout   = "startRollout"
+	ActionPauseRollout   = "pauseRollout"
+	ActionPromoteRollout = "promoteRollout"
+	ActionAbortRollout   = "abortRollout"
+
+	RolloutStrategyCanary    = "canary"
+	RolloutStrategyBlueGreen = "blueGreen"
+
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhasePaused      = "Paused"
+	RolloutPhaseSwitched    = "Switched"
+	RolloutPhasePromoting   = "Promoting"
+)
+
+// Rollout deploys containers as new version of deployment progressively,
+// canary shifts replicas or traffic weight of service mesh to new version
+// by steps in percentage, the next step is entered after StepInterval
+// seconds if it isn't 0, otherwise by promote action, blue green switches
+// services to new version once it's ready and waits promote action,
+// only containers, init containers and volumes of new version come from
+// rollout, other fields of pod spec such as scheduling and security
+// context are kept, update the deployment to change them
+type Rollout struct {
+	Strategy            string      `json:"strategy" rest:"required=true,options=canary|blueGreen"`
+	Containers          []Container `json:"containers" rest:"required=true"`
+	Steps               []int       `json:"steps,omitempty"`
+	StepIntervalSeconds int         `json:"stepIntervalSeconds,omitempty"`
+	Memo                string      `json:"memo,omitempty"`
+}
+
+type RolloutStatus struct {
+	Strategy           string           `json:"strategy"`
+	Phase              string           `json:"phase"`
+	Steps              []int            `json:"steps,omitempty"`
+	CurrentStep        int              `json:"currentStep"`
+	TrafficSplit       bool             `json:"trafficSplit,omitempty"`
+	NewReplicas        int              `json:"newReplicas"`
+	ReadyNewReplicas   int              `json:"readyNewReplicas"`
+	Message            string           `json:"message,omitempty"`
+	LastTransitionTime resource.ISOTime `json:"lastTransitionTime,omitempty"`
+}
@@ -0,0 +1,40 @@
+package types
+
+const (
+	PodAffinityTypeAffinity     = "affinity"
+	PodAffinityTypeAntiAffinity = "antiAffinity"
+)
+
+// Scheduling is shared by workloads to decide which nodes their pods
+// could run on
+type Scheduling struct {
+	NodeSelector    map[string]string `json:"nodeSelector,omitempty"`
+	PodAffinities   []PodAffinity     `json:"podAffinities,omitempty"`
+	TopologySpreads []TopologySpread  `json:"topologySpreads,omitempty"`
+	Tolerations     []Toleration      `json:"tolerations,omitempty"`
+}
+
+// pod affinity with empty PodLabels refers to pods of the workload
+// itself, antiAffinity with it spreads replicas, topology key is
+// kubernetes.io/hostname when omitted
+type PodAffinity struct {
+	Type        string            `json:"type" rest:"required=true,options=affinity|antiAffinity"`
+	Required    bool              `json:"required,omitempty"`
+	PodLabels   map[string]string `json:"podLabels,omitempty"`
+	TopologyKey string            `json:"topologyKey,omitempty"`
+}
+
+// topology spread always counts pods of the workload itself
+type TopologySpread struct {
+	TopologyKey       string `json:"topologyKey" rest:"required=true"`
+	MaxSkew           int    `json:"maxSkew,omitempty"`
+	WhenUnsatisfiable string `json:"whenUnsatisfiable,omitempty" rest:"options=DoNotSchedule|ScheduleAnyway"`
+}
+
+type Toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty" rest:"options=Equal|Exists"`
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty" rest:"options=NoSchedule|PreferNoSchedule|NoExecute"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"strings"
+)
+
+// search conditions are combined with AND, empty condition matches all
+type ResourceSearch struct {
+	Keyword       string   `json:"keyword,omitempty"`
+	LabelSelector string   `json:"labelSelector,omitempty"`
+	Kinds         []string `json:"kinds,omitempty"`
+	Clusters      []string `json:"clusters,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	Node          string   `json:"node,omitempty"`
+	IP            string   `json:"ip,omitempty"`
+}
+
+func (s *ResourceSearch) TrimFieldSpace() {
+	s.Keyword = strings.TrimSpace(s.Keyword)
+	s.LabelSelector = strings.TrimSpace(s.LabelSelector)
+	s.Image = strings.TrimSpace(s.Image)
+	s.Node = strings.TrimSpace(s.Node)
+	s.IP = strings.TrimSpace(s.IP)
+	for i, k := range s.Kinds {
+		s.Kinds[i] = strings.ToLower(strings.TrimSpace(k))
+	}
+	for i, c := range s.Clusters {
+		s.Clusters[i] = strings.TrimSpace(c)
+	}
+}
+
+type ResourceSearchResult struct {
+	Items  []SearchResultItem `json:"items"`
+	Errors []string           `json:"errors,omitempty"`
+}
+
+type SearchResultItem struct {
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace,omitempty"`
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Images    []string          `json:"images,omitempty"`
+	Node      string            `json:"node,omitempty"`
+	IPs       []string          `json:"ips,omitempty"`
+	Link      string            `json:"link,omitempty"`
+}
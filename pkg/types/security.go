@@ -0,0 +1,38 @@
+package types
+
+const (
+	SecurityProfilePrivileged = "privileged"
+	SecurityProfileBaseline   = "baseline"
+	SecurityProfileRestricted = "restricted"
+
+	SeccompProfileRuntimeDefault = "runtime/default"
+	SeccompProfileUnconfined     = "unconfined"
+)
+
+type SecurityContext struct {
+	Privileged               bool     `json:"privileged,omitempty"`
+	RunAsUser                *int64   `json:"runAsUser,omitempty"`
+	RunAsGroup               *int64   `json:"runAsGroup,omitempty"`
+	RunAsNonRoot             bool     `json:"runAsNonRoot,omitempty"`
+	ReadOnlyRootFilesystem   bool     `json:"readOnlyRootFilesystem,omitempty"`
+	AllowPrivilegeEscalation *bool    `json:"allowPrivilegeEscalation,omitempty"`
+	CapabilitiesAdd          []string `json:"capabilitiesAdd,omitempty"`
+	CapabilitiesDrop         []string `json:"capabilitiesDrop,omitempty"`
+}
+
+// seccomp profile could be runtime/default, unconfined or
+// localhost/<profile path on node>
+type PodSecurityContext struct {
+	RunAsUser      *int64 `json:"runAsUser,omitempty"`
+	RunAsGroup     *int64 `json:"runAsGroup,omitempty"`
+	RunAsNonRoot   bool   `json:"runAsNonRoot,omitempty"`
+	FSGroup        *int64 `json:"fsGroup,omitempty"`
+	SeccompProfile string `json:"seccompProfile,omitempty"`
+	HostNetwork    bool   `json:"hostNetwork,omitempty"`
+	HostPID        bool   `json:"hostPID,omitempty"`
+	HostIPC        bool   `json:"hostIPC,omitempty"`
+}
+
+type NamespaceSecurityProfile struct {
+	Profile string `json:"profile" rest:"required=true,options=privileged|baseline|restricted"`
+}
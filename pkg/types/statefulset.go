@@ -13,8 +13,12 @@ type StatefulSet struct {
 	Name                  string                     `json:"name" rest:"required=true,isDomain=true,description=immutable"`
 	Replicas              int                        `json:"replicas" rest:"required=true,min=0,max=50"`
 	Containers            []Container                `json:"containers" rest:"required=true"`
+	InitContainers        []Container                `json:"initContainers,omitempty"`
+	Volumes               []PodVolume                `json:"volumes,omitempty"`
 	AdvancedOptions       AdvancedOptions            `json:"advancedOptions,omitempty" rest:"description=immutable"`
 	PersistentVolumes     []PersistentVolumeTemplate `json:"persistentVolumes,omitempty"`
+	Scheduling            Scheduling                 `json:"scheduling,omitempty"`
+	SecurityContext       PodSecurityContext         `json:"securityContext,omitempty"`
 	Status                WorkloadStatus             `json:"status,omitempty" rest:"description=readonly"`
 	Memo                  string                     `json:"memo,omitempty"`
 }
@@ -29,8 +33,38 @@ func (s StatefulSet) GetParents() []resource.ResourceKind {
 	return []resource.ResourceKind{Namespace{}}
 }
 
+var StatefulSetActions = []resource.Action{
+	resource.Action{
+		Name:   ActionGetHistory,
+		Output: &VersionHistory{},
+	},
+	resource.Action{
+		Name:  ActionRollback,
+		Input: &RollBackVersion{},
+	},
+	resource.Action{
+		Name:   ActionDiff,
+		Input:  &DiffVersions{},
+		Output: &VersionDiff{},
+	},
+	resource.Action{
+		Name:   ActionSetPodCount,
+		Input:  &SetPodCount{},
+		Output: &SetPodCount{},
+	},
+	resource.Action{
+		Name: ActionPause,
+	},
+	resource.Action{
+		Name: ActionResume,
+	},
+	resource.Action{
+		Name: ActionRestart,
+	},
+}
+
 func (s StatefulSet) GetActions() []resource.Action {
-	return DeploymentActions
+	return StatefulSetActions
 }
 
 func (s StatefulSet) SupportAsyncDelete() bool {
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"github.com/zdnscloud/gorest/resource"
+)
+
+const (
+	WorkloadRolloutPhaseProgressing = "Progressing"
+	WorkloadRolloutPhaseStalled     = "Stalled"
+	WorkloadRolloutPhasePaused      = "Paused"
+	WorkloadRolloutPhaseComplete    = "Complete"
+	WorkloadRolloutPhaseFailed      = "Failed"
+	WorkloadRolloutPhaseTimeout     = "Timeout"
+)
+
+// WorkloadRollout is progress of the latest update of workload, its id is
+// the workload name, rollout is stalled if some pods can't be ready
+type WorkloadRollout struct {
+	resource.ResourceBase `json:",inline"`
+	Phase                 string              `json:"phase"`
+	Revision              string              `json:"revision,omitempty"`
+	DesiredReplicas       int                 `json:"desiredReplicas"`
+	UpdatedReplicas       int                 `json:"updatedReplicas"`
+	ReadyReplicas         int                 `json:"readyReplicas"`
+	AvailableReplicas     int                 `json:"availableReplicas"`
+	Conditions            []WorkloadCondition `json:"conditions,omitempty"`
+	StuckPods             []StuckPod          `json:"stuckPods,omitempty"`
+	Message               string              `json:"message,omitempty"`
+}
+
+type StuckPod struct {
+	Name          string           `json:"name"`
+	Container     string           `json:"container,omitempty"`
+	Reason        string           `json:"reason"`
+	Message       string           `json:"message,omitempty"`
+	Count         int32            `json:"count,omitempty"`
+	LastTimestamp resource.ISOTime `json:"lastTimestamp,omitempty"`
+}
+
+func (r WorkloadRollout) GetParents() []resource.ResourceKind {
+	return []resource.ResourceKind{Deployment{}, DaemonSet{}, StatefulSet{}}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 	"github.com/zdnscloud/singlecloud/pkg/zke/zkelog"
 
@@ -26,6 +28,11 @@ const (
 	connectionCheckInterval = time.Second * 15
 )
 
+var (
+	zkeLogger    = logging.GetLogger("zke")
+	zkeStdLogger = zkeLogger.Std()
+)
+
 type Cluster struct {
 	Name           string
 	createTime     time.Time
@@ -40,6 +47,13 @@ type Cluster struct {
 	fsm            *fsm.FSM
 	scVersion      string
 	kubeHttpClient *http.Client
+	nodeStates     map[string]nodeState
+	nodeLock       sync.RWMutex
+	template       string
+	templateRev    int
+	healthConf     healthConf
+	health         *healthHistory
+	requestID      string
 }
 
 func (c *Cluster) GetCreationTimestamp() time.Time {
@@ -74,7 +88,9 @@ type AlarmCluster struct {
 
 func newCluster(name string, initialStatus types.ClusterStatus) *Cluster {
 	cluster := &Cluster{
-		Name: name,
+		Name:       name,
+		healthConf: healthConf{interval: connectionCheckInterval, unreachableThreshold: 1, historySize: 1},
+		health:     newHealthHistory(1),
 	}
 
 	fsm := newClusterFsm(cluster, initialStatus)
@@ -91,11 +107,20 @@ func (c *Cluster) IsReady() bool {
 }
 
 func (c *Cluster) event(e string, zkeMgr *ZKEManager, state clusterState, errMessage string) {
+	state.NodeStates = c.getNodeStates()
+	state.Operation = ""
+	state.RequestID = ""
 	if err := c.fsm.Event(e, zkeMgr, state, errMessage); err != nil {
-		log.Warnf("send cluster %s fsm %s event failed %s", c.Name, e, err.Error())
+		zkeStdLogger.Warnf("send cluster %s fsm %s event failed %s", c.Name, e, err.Error())
 	}
 }
 
+// operationContext carries id of the request which starts the running
+// operation, so logs, events and alarms of the operation have it
+func (c *Cluster) operationContext() context.Context {
+	return logging.WithRequestID(context.Background(), c.requestID)
+}
+
 func (c *Cluster) Event(e string) error {
 	return c.fsm.Event(e)
 }
@@ -156,22 +181,6 @@ func (c *Cluster) Init(kubeConfig string) error {
 	return nil
 }
 
-func (c *Cluster) connectionCheckLoop() {
-	for {
-		select {
-		case <-c.stopCh:
-			log.Debugf("cluster %s connectionCheckLoop exit", c.Name)
-			return
-		case <-time.After(connectionCheckInterval):
-			if _, err := c.GetKubeClient().ServerVersion(); err != nil {
-				c.Event(GetInfoFailedEvent)
-			} else {
-				c.Event(GetInfoSucceedEvent)
-			}
-		}
-	}
-}
-
 func (c *Cluster) setCache(k8sConfig *rest.Config) error {
 	httpClient, err := c.newKubeHttpClient(k8sConfig)
 	if err != nil {
@@ -204,7 +213,8 @@ func (c *Cluster) newKubeHttpClient(k8sConfig *rest.Config) (*http.Client, error
 func (c *Cluster) Create(ctx context.Context, state clusterState, mgr *ZKEManager) {
 	defer func() {
 		if r := recover(); r != nil {
-			err := log.Errorf("zke pannic info %s", r)
+			err := fmt.Errorf("zke pannic info %s", r)
+			zkeLogger.Errorf(ctx, "%s", err.Error())
 			c.event(CreateFailedEvent, mgr, state, err.Error())
 		}
 	}()
@@ -212,33 +222,42 @@ func (c *Cluster) Create(ctx context.Context, state clusterState, mgr *ZKEManage
 	logger, logCh := log.NewISO3339Log4jBufLogger(zkelog.MaxLogSize, log.Info)
 	defer logger.Close()
 	mgr.logger.AddOrUpdate(c.Name, logCh)
+	c.transitNodeStates(types.NPSPending, types.NPSProvisioning, "")
+	zkeLogger.Infof(ctx, "start creating cluster %s", c.Name)
+	start := time.Now()
 	zkeState, k8sConfig, kubeClient, err := upZKECluster(ctx, c.config, state.FullState, logger)
+	observeZKEOperation(zkeOperationCreate, start, err, c.isCanceled)
 	state.FullState = zkeState
 	if c.isCanceled {
+		c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, "canceled")
 		c.event(CreateCanceledEvent, mgr, state, "")
 		return
 	}
 	if err != nil {
-		log.Errorf("zke err info %s", err)
+		zkeLogger.Errorf(ctx, "create cluster %s failed %s", c.Name, err.Error())
 		logger.Error(err.Error())
+		c.failProvisioningNodes(err.Error())
 		c.event(CreateFailedEvent, mgr, state, err.Error())
 		return
 	}
 
 	c.kubeClient = kubeClient
 	if err := c.setCache(k8sConfig); err != nil {
+		c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, err.Error())
 		c.event(CreateFailedEvent, mgr, state, err.Error())
 		return
 	}
 	go c.connectionCheckLoop()
 	state.Created = true
+	c.transitNodeStates(types.NPSProvisioning, types.NPSReady, "")
 	c.event(CreateSucceedEvent, mgr, state, "")
 }
 
 func (c *Cluster) Update(ctx context.Context, state clusterState, mgr *ZKEManager) {
 	defer func() {
 		if r := recover(); r != nil {
-			err := log.Errorf("zke pannic info %s", r)
+			err := fmt.Errorf("zke pannic info %s", r)
+			zkeLogger.Errorf(ctx, "%s", err.Error())
 			c.event(UpdateCompletedEvent, mgr, state, err.Error())
 		}
 	}()
@@ -247,9 +266,14 @@ func (c *Cluster) Update(ctx context.Context, state clusterState, mgr *ZKEManage
 	defer logger.Close()
 	mgr.logger.AddOrUpdate(c.Name, logCh)
 
+	c.transitNodeStates(types.NPSPending, types.NPSProvisioning, "")
+	zkeLogger.Infof(ctx, "start updating cluster %s", c.Name)
+	start := time.Now()
 	zkeState, k8sConfig, k8sClient, err := upZKECluster(ctx, c.config, state.FullState, logger)
+	observeZKEOperation(zkeOperationUpdate, start, err, c.isCanceled)
 	state.FullState = zkeState
 	if c.isCanceled {
+		c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, "canceled")
 		if state.Created {
 			c.event(UpdateCanceledEvent, mgr, state, "")
 		} else {
@@ -258,8 +282,9 @@ func (c *Cluster) Update(ctx context.Context, state clusterState, mgr *ZKEManage
 		return
 	}
 	if err != nil {
-		log.Errorf("zke err info %s", err)
+		zkeLogger.Errorf(ctx, "update cluster %s failed %s", c.Name, err.Error())
 		logger.Error(err.Error())
+		c.failProvisioningNodes(err.Error())
 		if state.Created {
 			c.event(UpdateCompletedEvent, mgr, state, err.Error())
 		} else {
@@ -269,13 +294,17 @@ func (c *Cluster) Update(ctx context.Context, state clusterState, mgr *ZKEManage
 	}
 
 	if state.Created {
+		c.transitNodeStates(types.NPSProvisioning, types.NPSReady, "")
 		c.event(UpdateCompletedEvent, mgr, state, "")
 	} else {
 		c.kubeClient = k8sClient
 		if err := c.setCache(k8sConfig); err != nil {
+			c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, err.Error())
 			c.event(CreateFailedEvent, mgr, state, err.Error())
+			return
 		}
 		state.Created = true
+		c.transitNodeStates(types.NPSProvisioning, types.NPSReady, "")
 		c.event(CreateSucceedEvent, mgr, state, "")
 	}
 }
@@ -283,7 +312,8 @@ func (c *Cluster) Update(ctx context.Context, state clusterState, mgr *ZKEManage
 func (c *Cluster) Destroy(ctx context.Context, mgr *ZKEManager) {
 	defer func() {
 		if r := recover(); r != nil {
-			err := log.Errorf("zke pannic info %s", r)
+			err := fmt.Errorf("zke pannic info %s", r)
+			zkeLogger.Errorf(ctx, "%s", err.Error())
 			c.event(DeleteCompletedEvent, mgr, clusterState{}, err.Error())
 		}
 	}()
@@ -291,9 +321,12 @@ func (c *Cluster) Destroy(ctx context.Context, mgr *ZKEManager) {
 	logger, _ := log.NewISO3339Log4jBufLogger(zkelog.MaxLogSize, log.Info)
 	defer logger.Close()
 
+	zkeLogger.Infof(ctx, "start deleting cluster %s", c.Name)
+	start := time.Now()
 	err := removeZKECluster(ctx, c.config, logger)
+	observeZKEOperation(zkeOperationDelete, start, err, false)
 	if err != nil {
-		log.Errorf("zke err info %s", err)
+		zkeLogger.Errorf(ctx, "delete cluster %s failed %s", c.Name, err.Error())
 		logger.Error(err.Error())
 		c.event(DeleteCompletedEvent, mgr, clusterState{}, err.Error())
 		return
@@ -313,17 +346,28 @@ func (c *Cluster) ToScCluster() *types.Cluster {
 		ClusterUpstreamDNS:  c.config.Option.ClusterUpstreamDNS,
 		SingleCloudAddress:  c.config.SingleCloudAddress,
 		ScVersion:           c.scVersion,
+		Template:            c.template,
+		TemplateRevision:    c.templateRev,
 
 		Network: types.ClusterNetwork{
 			Plugin: c.config.Network.Plugin,
 			Iface:  c.config.Network.Iface,
 		},
+		LoadBalance: types.ClusterLoadBalance{
+			Enable:       c.config.LoadBalance.Enable,
+			MasterServer: c.config.LoadBalance.MasterServer,
+			BackupServer: c.config.LoadBalance.BackupServer,
+			User:         c.config.LoadBalance.User,
+		},
 	}
 
 	for _, node := range c.config.Nodes {
+		state := c.getNodeState(node.NodeName)
 		n := types.Node{
-			Name:    node.NodeName,
-			Address: node.Address,
+			Name:            node.NodeName,
+			Address:         node.Address,
+			ProvisionStatus: state.Status,
+			ProvisionError:  state.Reason,
 		}
 		for _, role := range node.Role {
 			if role == string(types.RoleEtcd) {
@@ -339,6 +383,7 @@ func (c *Cluster) ToScCluster() *types.Cluster {
 	sc.SetCreationTimestamp(c.createTime)
 	sc.SetDeletionTimestamp(c.deleteTime)
 	sc.Status = c.getStatus()
+	sc.Health = c.health.latest()
 	sc.KubeProvider = c
 	return sc
 }
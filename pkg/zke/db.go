@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/zdnscloud/kvzoo"
+	"github.com/zdnscloud/singlecloud/pkg/db"
 
 	"github.com/zdnscloud/zke/core"
 	"github.com/zdnscloud/zke/core/pki"
@@ -19,10 +20,21 @@ const (
 type clusterState struct {
 	*core.FullState  `json:",inline"`
 	*types.ZKEConfig `json:",inline"`
-	CreateTime       time.Time `json:"createTime"`
-	DeleteTime       time.Time `json:"deleteTime"`
-	Created          bool      `json:"created"`
-	ScVersion        string    `json:"zcloudVersion"`
+	CreateTime       time.Time            `json:"createTime"`
+	DeleteTime       time.Time            `json:"deleteTime"`
+	Created          bool                 `json:"created"`
+	ScVersion        string               `json:"zcloudVersion"`
+	NodeStates       map[string]nodeState `json:"nodeStates,omitempty"`
+	Template         string               `json:"template,omitempty"`
+	TemplateRevision int                  `json:"templateRevision,omitempty"`
+	//operation in progress, it's left when singlecloud exits before
+	//the operation completes
+	Operation string `json:"operation,omitempty"`
+	//id of request which starts the operation
+	RequestID string `json:"requestId,omitempty"`
+	//full state has certificates and zke config, it's encrypted as a whole
+	EncryptedFullState string `json:"encryptedFullState,omitempty"`
+	needEncrypt        bool
 }
 
 func getClusterFromDB(clusterID string, table kvzoo.Table) (clusterState, error) {
@@ -52,6 +64,11 @@ func createOrUpdateClusterFromDB(clsuterID string, s clusterState, table kvzoo.T
 	}
 	defer tx.Rollback()
 
+	s, err = encryptClusterState(s)
+	if err != nil {
+		return fmt.Errorf("encrypt cluster %s state failed %s", clsuterID, err.Error())
+	}
+
 	value, err := json.Marshal(s)
 	if err != nil {
 		return fmt.Errorf("marshal cluster %s state failed %s", clsuterID, err.Error())
@@ -120,9 +137,105 @@ func readClusterJson(js []byte) (clusterState, error) {
 	if err := json.Unmarshal(js, &s); err != nil {
 		return s, err
 	}
+	if err := decryptClusterState(&s); err != nil {
+		return s, err
+	}
 	if s.FullState != nil && s.FullState.DesiredState.CertificatesBundle != nil {
 		s.DesiredState.CertificatesBundle = pki.TransformPEMToObject(s.DesiredState.CertificatesBundle)
 		s.CurrentState.CertificatesBundle = pki.TransformPEMToObject(s.CurrentState.CertificatesBundle)
 	}
 	return s, nil
 }
+
+func encryptClusterState(s clusterState) (clusterState, error) {
+	if !db.EncryptionEnabled() {
+		return s, nil
+	}
+
+	if s.ZKEConfig != nil {
+		config := s.ZKEConfig.DeepCopy()
+		if err := encryptFields(getSensitiveFields(config)); err != nil {
+			return s, err
+		}
+		s.ZKEConfig = config
+	}
+
+	if s.FullState != nil {
+		js, err := json.Marshal(s.FullState)
+		if err != nil {
+			return s, err
+		}
+		if s.EncryptedFullState, err = db.EncryptString(string(js)); err != nil {
+			return s, err
+		}
+		s.FullState = nil
+	}
+	return s, nil
+}
+
+// decryptClusterState also records whether the state is written before
+// encryption is enabled, so it could be encrypted on load
+func decryptClusterState(s *clusterState) error {
+	if s.ZKEConfig != nil {
+		for _, f := range getSensitiveFields(s.ZKEConfig) {
+			if *f != "" && !db.IsEncrypted(*f) {
+				s.needEncrypt = true
+			}
+		}
+		if err := decryptFields(getSensitiveFields(s.ZKEConfig)); err != nil {
+			return err
+		}
+	}
+
+	if s.EncryptedFullState != "" {
+		js, err := db.DecryptString(s.EncryptedFullState)
+		if err != nil {
+			return err
+		}
+		var fullState core.FullState
+		if err := json.Unmarshal([]byte(js), &fullState); err != nil {
+			return err
+		}
+		s.FullState = &fullState
+		s.EncryptedFullState = ""
+	} else if s.FullState != nil {
+		s.needEncrypt = true
+	}
+
+	s.needEncrypt = s.needEncrypt && db.EncryptionEnabled()
+	return nil
+}
+
+// ssh keys, load balance password and private registry passwords
+func getSensitiveFields(config *types.ZKEConfig) []*string {
+	fields := []*string{&config.Option.SSHKey, &config.LoadBalance.Password}
+	for i := range config.Nodes {
+		fields = append(fields, &config.Nodes[i].SSHKey)
+	}
+	for i := range config.PrivateRegistries {
+		fields = append(fields, &config.PrivateRegistries[i].Password)
+	}
+	return fields
+}
+
+func encryptFields(fields []*string) error {
+	for _, f := range fields {
+		v, err := db.EncryptString(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+func decryptFields(fields []*string) error {
+	for _, f := range fields {
+		v, err := db.DecryptString(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
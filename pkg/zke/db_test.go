@@ -8,6 +8,7 @@ import (
 	ut "github.com/zdnscloud/cement/unittest"
 	"github.com/zdnscloud/kvzoo"
 	"github.com/zdnscloud/kvzoo/backend/bolt"
+	"github.com/zdnscloud/singlecloud/pkg/types"
 	"github.com/zdnscloud/zke/core"
 	zketypes "github.com/zdnscloud/zke/types"
 )
@@ -56,3 +57,33 @@ func TestClusterDB(t *testing.T) {
 		ut.Assert(t, err == kvzoo.ErrNotFound, "get cluster from db after delete should get not found err: %s", err)
 	})
 }
+
+func TestMigrateClusterNodeStates(t *testing.T) {
+	ut.WithTempFile(t, "cluster_migration.db", func(t *testing.T, f *os.File) {
+		db, err := bolt.New(f.Name())
+		ut.Assert(t, err == nil, "create db should succeed: %s", err)
+		defer db.Close()
+
+		tn, _ := kvzoo.TableNameFromSegments(ZKEManagerDBTable)
+		table, err := db.CreateOrGetTable(tn)
+		ut.Assert(t, err == nil, "create db table should succeed: %s", err)
+
+		config := &zketypes.ZKEConfig{Nodes: []zketypes.ZKEConfigNode{{NodeName: "master"}, {NodeName: "worker"}}}
+		ut.Assert(t, createOrUpdateClusterFromDB("old", clusterState{ZKEConfig: config, Created: true}, table) == nil, "")
+		ut.Assert(t, createOrUpdateClusterFromDB("creating", clusterState{ZKEConfig: config}, table) == nil, "")
+
+		for i := 0; i < 2; i++ {
+			ut.Assert(t, migrateClusterNodeStates(db) == nil, "migrate should succeed")
+		}
+
+		state, err := getClusterFromDB("old", table)
+		ut.Assert(t, err == nil, "")
+		ut.Equal(t, state.NodeStates, map[string]nodeState{
+			"master": nodeState{Status: types.NPSReady},
+			"worker": nodeState{Status: types.NPSReady},
+		})
+		state, err = getClusterFromDB("creating", table)
+		ut.Assert(t, err == nil, "")
+		ut.Equal(t, len(state.NodeStates), 0)
+	})
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/zdnscloud/singlecloud/pkg/types"
 
 	"github.com/zdnscloud/cement/fsm"
-	"github.com/zdnscloud/cement/log"
 )
 
 const (
@@ -50,90 +49,90 @@ func newClusterFsm(cluster *Cluster, initialStatus types.ClusterStatus) *fsm.FSM
 			CreateSucceedEvent: func(e *fsm.Event) {
 				mgr, state, _, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", CreateSucceedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", CreateSucceedEvent, err.Error())
 				}
 
 				if err := mgr.logger.Delete(cluster.Name); err != nil {
-					log.Warnf("fsm %s callback failed %s", CreateSucceedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", CreateSucceedEvent, err.Error())
 				}
 
 				if err := createOrUpdateClusterFromDB(cluster.Name, state, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
-				eventbus.PublishResourceCreateEvent(cluster.ToScCluster())
+				eventbus.PublishResourceCreateEvent(cluster.operationContext(), cluster.ToScCluster())
 			},
 			CreateFailedEvent: func(e *fsm.Event) {
 				mgr, state, errMsg, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", CreateFailedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", CreateFailedEvent, err.Error())
 					return
 				}
 
 				if errMsg != "" {
-					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(CreateFailedEvent).Message(errMsg).Publish()
+					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(CreateFailedEvent).Message(errMsg).Publish(cluster.operationContext())
 				}
 
 				if err := createOrUpdateClusterFromDB(cluster.Name, state, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
 			},
 			CreateCanceledEvent: func(e *fsm.Event) {
 				mgr, state, _, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", CreateCanceledEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", CreateCanceledEvent, err.Error())
 					return
 				}
 
 				cluster.isCanceled = false
 				if err := createOrUpdateClusterFromDB(cluster.Name, state, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
 			},
 			UpdateCompletedEvent: func(e *fsm.Event) {
 				mgr, state, errMsg, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
 					return
 				}
 
 				if err := mgr.logger.Delete(cluster.Name); err != nil {
-					log.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
 				}
 
 				if errMsg != "" {
-					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(UpdateFailedEvent).Message(errMsg).Publish()
+					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(UpdateFailedEvent).Message(errMsg).Publish(cluster.operationContext())
 				}
 
 				if err := createOrUpdateClusterFromDB(cluster.Name, state, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
 			},
 			UpdateCanceledEvent: func(e *fsm.Event) {
 				mgr, state, _, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", UpdateCompletedEvent, err.Error())
 					return
 				}
 
 				cluster.isCanceled = false
 				if err := createOrUpdateClusterFromDB(cluster.Name, state, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
 			},
 			DeleteCompletedEvent: func(e *fsm.Event) {
 				mgr, _, errMsg, err := getFsmEventArgs(e)
 				if err != nil {
-					log.Warnf("fsm %s callback failed %s", DeleteCompletedEvent, err.Error())
+					zkeStdLogger.Warnf("fsm %s callback failed %s", DeleteCompletedEvent, err.Error())
 					return
 				}
 
 				if errMsg != "" {
-					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(DeleteFailedEvent).Message(errMsg).Publish()
+					alarm.New().Kind(clusterKindName).Cluster(cluster.Name).Name(cluster.Name).Reason(DeleteFailedEvent).Message(errMsg).Publish(cluster.operationContext())
 				}
 
 				mgr.Remove(cluster)
 				if err := deleteClusterFromDB(cluster.Name, mgr.GetDBTable()); err != nil {
-					log.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
+					zkeStdLogger.Warnf("update db failed after cluster %s %s event %s", cluster.Name, e.Event, err.Error())
 				}
 			},
 		},
@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/zdnscloud/singlecloud/config"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 
 	ut "github.com/zdnscloud/cement/unittest"
@@ -21,7 +22,7 @@ func TestFsmCreateSuccessEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		cluster.event(CreateSucceedEvent, mgr, state, "")
@@ -35,7 +36,7 @@ func TestFsmCreateFailedEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		err = cluster.fsm.Event(CreateFailedEvent, mgr, state)
@@ -50,7 +51,7 @@ func TestFsmCreateCanceledEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		cluster.event(CreateCanceledEvent, mgr, state, "")
@@ -64,7 +65,7 @@ func TestFsmContinueCreateEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		cluster.event(ContinuteCreateEvent, mgr, state, "")
@@ -78,7 +79,7 @@ func TestFsmUpdateCompletedEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		cluster.event(UpdateCompletedEvent, mgr, state, "")
@@ -92,7 +93,7 @@ func TestFsmUpdateCanceledEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.add(cluster)
 		cluster.event(UpdateCanceledEvent, mgr, state, "")
@@ -105,7 +106,7 @@ func TestFsmGetInfoSuccessEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.Event(GetInfoSucceedEvent)
@@ -118,7 +119,7 @@ func TestFsmGetInfoFailedEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.Event(GetInfoFailedEvent)
@@ -132,7 +133,7 @@ func TestFsmDeleteEventWhenRunning(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.event(DeleteEvent, mgr, state, "")
@@ -146,7 +147,7 @@ func TestFsmDeleteEventWhenUnreached(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.event(DeleteEvent, mgr, state, "")
@@ -160,7 +161,7 @@ func TestFsmDeleteEventWhenCreateFailed(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.event(DeleteEvent, mgr, state, "")
@@ -174,7 +175,7 @@ func TestFsmDeleteCompleteEvent(t *testing.T) {
 	ut.WithTempFile(t, fsmTestDbPath, func(t *testing.T, f *os.File) {
 		db, err := bolt.New(f.Name())
 		ut.Assert(t, err == nil, "create db should succeed: %s", err)
-		mgr, err := newZKEManager(db, nil)
+		mgr, err := newZKEManager(db, nil, config.CreateDefaultConfig().Cluster)
 		ut.Assert(t, err == nil, "create zke manager obj should succeed: %s", err)
 		mgr.clusters = append(mgr.clusters, cluster)
 		cluster.event(DeleteCompletedEvent, mgr, state, "")
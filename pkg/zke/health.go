@@ -0,0 +1,277 @@
+package zke
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/gorest/resource"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/singlecloud/config"
+	"github.com/zdnscloud/singlecloud/pkg/alarm"
+	"github.com/zdnscloud/singlecloud/pkg/clusteragent"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	ComponentAPIServer         = "apiserver"
+	ComponentEtcd              = "etcd"
+	ComponentScheduler         = "scheduler"
+	ComponentControllerManager = "controller-manager"
+	ComponentDNS               = "dns"
+	ComponentIngress           = "ingress"
+	ComponentStorageOperator   = "storage-operator"
+	ComponentClusterAgent      = "cluster-agent"
+
+	UnreachableTooLongEvent = "unreachableTooLong"
+)
+
+type healthConf struct {
+	interval             time.Duration
+	unreachableThreshold int
+	alarmDuration        time.Duration
+	historySize          int
+}
+
+func newHealthConf(conf config.ClusterConf) healthConf {
+	hc := healthConf{
+		interval:             time.Duration(conf.HealthCheckInterval) * time.Second,
+		unreachableThreshold: conf.UnreachableThreshold,
+		alarmDuration:        time.Duration(conf.UnreachableAlarmDuration) * time.Second,
+		historySize:          conf.HealthHistorySize,
+	}
+	if hc.interval <= 0 {
+		hc.interval = connectionCheckInterval
+	}
+	if hc.unreachableThreshold <= 0 {
+		hc.unreachableThreshold = 1
+	}
+	if hc.historySize <= 0 {
+		hc.historySize = 1
+	}
+	return hc
+}
+
+type healthHistory struct {
+	lock    sync.RWMutex
+	records []types.ClusterHealth
+	next    int
+	full    bool
+}
+
+func newHealthHistory(size int) *healthHistory {
+	return &healthHistory{
+		records: make([]types.ClusterHealth, size),
+	}
+}
+
+func (h *healthHistory) add(r types.ClusterHealth) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *healthHistory) list() []types.ClusterHealth {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if !h.full {
+		return append([]types.ClusterHealth{}, h.records[:h.next]...)
+	}
+	return append(append([]types.ClusterHealth{}, h.records[h.next:]...), h.records[:h.next]...)
+}
+
+func (h *healthHistory) latest() *types.ClusterHealth {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if !h.full && h.next == 0 {
+		return nil
+	}
+	i := (h.next - 1 + len(h.records)) % len(h.records)
+	r := h.records[i]
+	return &r
+}
+
+// unreachableTracker decides when a cluster should be treated as
+// unreachable and when an alarm should be raised for it
+type unreachableTracker struct {
+	threshold     int
+	alarmDuration time.Duration
+	failures      int
+	since         time.Time
+	alarmed       bool
+}
+
+// update returns whether the cluster is unreachable and whether an
+// alarm should be published for this probe
+func (t *unreachableTracker) update(reachable bool, now time.Time) (bool, bool) {
+	if reachable {
+		t.failures = 0
+		t.since = time.Time{}
+		t.alarmed = false
+		return false, false
+	}
+
+	t.failures += 1
+	if t.since.IsZero() {
+		t.since = now
+	}
+	if t.failures < t.threshold {
+		return false, false
+	}
+
+	if !t.alarmed && t.alarmDuration > 0 && now.Sub(t.since) >= t.alarmDuration {
+		t.alarmed = true
+		return true, true
+	}
+	return true, false
+}
+
+func (c *Cluster) connectionCheckLoop() {
+	tracker := &unreachableTracker{
+		threshold:     c.healthConf.unreachableThreshold,
+		alarmDuration: c.healthConf.alarmDuration,
+	}
+
+	for {
+		select {
+		case <-c.stopCh:
+			zkeStdLogger.Debugf("cluster %s connectionCheckLoop exit", c.Name)
+			return
+		case <-time.After(c.healthConf.interval):
+			health := c.probeHealth()
+			c.health.add(health)
+			unreachable, needAlarm := tracker.update(health.Reachable, time.Time(health.Time))
+			if unreachable {
+				c.Event(GetInfoFailedEvent)
+			} else if health.Reachable {
+				c.Event(GetInfoSucceedEvent)
+			}
+
+			if needAlarm {
+				alarm.New().
+					Kind(clusterKindName).
+					Cluster(c.Name).
+					Name(c.Name).
+					Reason(UnreachableTooLongEvent).
+					Message(fmt.Sprintf("cluster has been unreachable since %s", tracker.since.Format(time.RFC3339))).
+					Publish(context.TODO())
+			}
+		}
+	}
+}
+
+func (c *Cluster) probeHealth() types.ClusterHealth {
+	now := time.Now()
+	health := types.ClusterHealth{
+		Time: resource.ISOTime(now),
+	}
+
+	cli := c.GetKubeClient()
+	_, err := cli.ServerVersion()
+	health.Latency = int64(time.Since(now) / time.Millisecond)
+	observeConnectionCheck(c.Name, time.Since(now), err == nil)
+	health.Components = append(health.Components, newComponentHealth(ComponentAPIServer, err))
+	if err != nil {
+		return health
+	}
+
+	health.Reachable = true
+	health.Components = append(health.Components, getControlPlaneHealth(cli)...)
+	health.Components = append(health.Components,
+		newComponentHealth(ComponentDNS, checkDeploymentReady(cli, "kube-system", "coredns")),
+		newComponentHealth(ComponentIngress, checkDaemonSetReady(cli, "ingress-nginx", "nginx-ingress-controller")),
+		newComponentHealth(ComponentStorageOperator, checkDeploymentReady(cli, "zcloud", "storage-operator")),
+		newComponentHealth(ComponentClusterAgent, clusteragent.GetAgent().CheckConnection(c.Name)),
+	)
+	return health
+}
+
+func newComponentHealth(name string, err error) types.ComponentHealth {
+	if err != nil {
+		return types.ComponentHealth{Name: name, Message: err.Error()}
+	}
+	return types.ComponentHealth{Name: name, Healthy: true}
+}
+
+func getControlPlaneHealth(cli client.Client) []types.ComponentHealth {
+	statuses := corev1.ComponentStatusList{}
+	if err := cli.List(context.TODO(), nil, &statuses); err != nil {
+		return []types.ComponentHealth{
+			newComponentHealth(ComponentEtcd, err),
+			newComponentHealth(ComponentScheduler, err),
+			newComponentHealth(ComponentControllerManager, err),
+		}
+	}
+
+	components := []types.ComponentHealth{
+		types.ComponentHealth{Name: ComponentEtcd, Healthy: true},
+		types.ComponentHealth{Name: ComponentScheduler, Message: "no component status found"},
+		types.ComponentHealth{Name: ComponentControllerManager, Message: "no component status found"},
+	}
+	foundEtcd := false
+	for _, s := range statuses.Items {
+		var component *types.ComponentHealth
+		switch {
+		case strings.HasPrefix(s.Name, ComponentEtcd):
+			component = &components[0]
+			foundEtcd = true
+		case s.Name == ComponentScheduler:
+			component = &components[1]
+			component.Healthy = true
+			component.Message = ""
+		case s.Name == ComponentControllerManager:
+			component = &components[2]
+			component.Healthy = true
+			component.Message = ""
+		default:
+			continue
+		}
+
+		for _, cond := range s.Conditions {
+			if cond.Type == corev1.ComponentHealthy && cond.Status != corev1.ConditionTrue {
+				component.Healthy = false
+				component.Message = strings.TrimSpace(fmt.Sprintf("%s %s %s", component.Message, s.Name, cond.Error))
+			}
+		}
+	}
+
+	if !foundEtcd {
+		components[0] = types.ComponentHealth{Name: ComponentEtcd, Message: "no component status found"}
+	}
+	return components
+}
+
+func checkDeploymentReady(cli client.Client, namespace, name string) error {
+	deploy := appsv1.Deployment{}
+	if err := cli.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, &deploy); err != nil {
+		return err
+	}
+	if deploy.Status.ReadyReplicas == 0 {
+		return fmt.Errorf("deployment %s/%s has no ready replica", namespace, name)
+	}
+	return nil
+}
+
+func checkDaemonSetReady(cli client.Client, namespace, name string) error {
+	ds := appsv1.DaemonSet{}
+	if err := cli.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, &ds); err != nil {
+		return err
+	}
+	if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+		return fmt.Errorf("daemonset %s/%s has %d ready pods, desired %d", namespace, name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package zke
+
+import (
+	"testing"
+	"time"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func TestHealthHistory(t *testing.T) {
+	h := newHealthHistory(3)
+	ut.Assert(t, h.latest() == nil, "empty history should has no latest record")
+
+	for i := 1; i <= 4; i++ {
+		h.add(types.ClusterHealth{Latency: int64(i)})
+	}
+	records := h.list()
+	ut.Equal(t, len(records), 3)
+	ut.Equal(t, records[0].Latency, int64(2))
+	ut.Equal(t, records[2].Latency, int64(4))
+	ut.Equal(t, h.latest().Latency, int64(4))
+}
+
+func TestUnreachableTracker(t *testing.T) {
+	tracker := &unreachableTracker{threshold: 2, alarmDuration: time.Minute}
+	now := time.Now()
+	unreachable, needAlarm := tracker.update(false, now)
+	ut.Equal(t, unreachable, false)
+	ut.Equal(t, needAlarm, false)
+
+	unreachable, needAlarm = tracker.update(false, now.Add(15*time.Second))
+	ut.Equal(t, unreachable, true)
+	ut.Equal(t, needAlarm, false)
+
+	unreachable, needAlarm = tracker.update(false, now.Add(time.Minute))
+	ut.Equal(t, unreachable, true)
+	ut.Equal(t, needAlarm, true)
+
+	_, needAlarm = tracker.update(false, now.Add(2*time.Minute))
+	ut.Equal(t, needAlarm, false)
+
+	unreachable, _ = tracker.update(true, now.Add(3*time.Minute))
+	ut.Equal(t, unreachable, false)
+}
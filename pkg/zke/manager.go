@@ -7,15 +7,17 @@ import (
 	"sync"
 	"time"
 
-	"github.com/zdnscloud/cement/log"
 	resterr "github.com/zdnscloud/gorest/error"
 	restsource "github.com/zdnscloud/gorest/resource"
 	"github.com/zdnscloud/kvzoo"
 	"github.com/zdnscloud/zke/core"
 	"github.com/zdnscloud/zke/core/pki"
 
+	"github.com/zdnscloud/singlecloud/config"
+	"github.com/zdnscloud/singlecloud/pkg/alarm"
 	"github.com/zdnscloud/singlecloud/pkg/db"
 	"github.com/zdnscloud/singlecloud/pkg/eventbus"
+	"github.com/zdnscloud/singlecloud/pkg/logging"
 	"github.com/zdnscloud/singlecloud/pkg/types"
 	"github.com/zdnscloud/singlecloud/pkg/zke/zkelog"
 )
@@ -33,17 +35,24 @@ type ZKEManager struct {
 	scVersion    string       // add cluster singlecloud version for easy to confirm zcloud component version
 	nodeListener NodeListener // for check storage node
 	logger       *zkelog.LogManager
+	healthConf   healthConf
+	operations   sync.WaitGroup
 }
 
 type NodeListener interface {
 	IsStorageNode(cluster *Cluster, node string) (bool, error)
+	RemoveNode(cluster *Cluster, node string) error
 }
 
-func New(nl NodeListener) (*ZKEManager, error) {
-	return newZKEManager(db.GetGlobalDB(), nl)
+func New(nl NodeListener, conf config.ClusterConf) (*ZKEManager, error) {
+	mgr, err := newZKEManager(db.GetGlobalDB(), nl, conf)
+	if err != nil {
+		return mgr, err
+	}
+	return mgr, registerClusterCollector(mgr)
 }
 
-func newZKEManager(db kvzoo.DB, nl NodeListener) (*ZKEManager, error) {
+func newZKEManager(db kvzoo.DB, nl NodeListener, conf config.ClusterConf) (*ZKEManager, error) {
 	tn, _ := kvzoo.TableNameFromSegments(ZKEManagerDBTable)
 	table, err := db.CreateOrGetTable(tn)
 	if err != nil {
@@ -56,6 +65,7 @@ func newZKEManager(db kvzoo.DB, nl NodeListener) (*ZKEManager, error) {
 		scVersion:    singleCloudVersion,
 		nodeListener: nl,
 		logger:       zkelog.New(),
+		healthConf:   newHealthConf(conf),
 	}
 
 	if err := mgr.loadDB(); err != nil {
@@ -85,26 +95,38 @@ func (m *ZKEManager) Create(ctx *restsource.Context) (restsource.Resource, *rest
 	}
 
 	config := genZKEConfig(typesCluster)
+	cluster := m.newManagedCluster(typesCluster.Name, types.CSCreating)
+	cluster.config = config
+	cluster.scVersion = m.scVersion
+	cluster.template = typesCluster.Template
+	cluster.templateRev = typesCluster.TemplateRevision
+	cluster.requestID = logging.RequestIDFromContext(ctx.Request.Context())
+	cluster.resetNodeStates()
+
 	state := clusterState{
-		ZKEConfig:  config,
-		CreateTime: time.Now(),
-		FullState:  &core.FullState{},
-		Created:    false,
-		ScVersion:  m.scVersion,
+		ZKEConfig:        config,
+		CreateTime:       time.Now(),
+		FullState:        &core.FullState{},
+		Created:          false,
+		ScVersion:        m.scVersion,
+		NodeStates:       cluster.getNodeStates(),
+		Template:         typesCluster.Template,
+		TemplateRevision: typesCluster.TemplateRevision,
+		Operation:        zkeOperationCreate,
+		RequestID:        cluster.requestID,
 	}
 	if err := createOrUpdateClusterFromDB(typesCluster.Name, state, m.dbTable); err != nil {
 		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("%s", err))
 	}
 
-	cluster := newCluster(typesCluster.Name, types.CSCreating)
 	cluster.createTime = state.CreateTime
-	cluster.config = config
-	cluster.scVersion = m.scVersion
 	m.add(cluster)
 
-	cancelCtx, cancel := context.WithCancel(context.Background())
+	cancelCtx, cancel := context.WithCancel(cluster.operationContext())
 	cluster.cancel = cancel
-	go cluster.Create(cancelCtx, state, m)
+	m.runOperation(func() {
+		cluster.Create(cancelCtx, state, m)
+	})
 	typesCluster.SetID(typesCluster.Name)
 	typesCluster.SetCreationTimestamp(state.CreateTime)
 	return typesCluster, nil
@@ -125,7 +147,6 @@ func (m *ZKEManager) Update(ctx *restsource.Context) (restsource.Resource, *rest
 	if err := validateConfigForUpdate(existCluster.ToScCluster(), typesCluster, m.nodeListener, existCluster); err != nil {
 		return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("cluster config validate failed %s", err))
 	}
-	config := genZKEConfigForUpdate(existCluster.config, typesCluster)
 
 	state, err := getClusterFromDB(typesCluster.Name, m.dbTable)
 	if err != nil {
@@ -135,26 +156,126 @@ func (m *ZKEManager) Update(ctx *restsource.Context) (restsource.Resource, *rest
 	if state.Created && !existCluster.Can(UpdateEvent) {
 		return nil, resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("cluster %s can't update on %s status", existCluster.Name, existCluster.getStatus()))
 	}
-	state.ZKEConfig = config
-	existCluster.config = config
 
-	if err := createOrUpdateClusterFromDB(typesCluster.Name, state, m.dbTable); err != nil {
+	existCluster.config = genZKEConfigForUpdate(existCluster.config, typesCluster)
+	if err := m.update(ctx.Request.Context(), existCluster, state); err != nil {
+		return nil, err
+	}
+	return typesCluster, nil
+}
+
+func (m *ZKEManager) RetryFailedNodes(ctx context.Context, id string) (interface{}, *resterr.APIError) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c := m.get(id)
+	if c == nil {
+		return nil, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("cluster %s desn't exist", id))
+	}
+
+	if !c.hasNodeInStatus(types.NPSFailed) {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("cluster %s has no failed node", id))
+	}
+
+	state, err := getClusterFromDB(id, m.dbTable)
+	if err != nil {
 		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("%s", err))
 	}
 
+	if state.Created && !c.Can(UpdateEvent) {
+		return nil, resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("cluster %s can't retry failed nodes on %s status", id, c.getStatus()))
+	}
+	return nil, m.update(ctx, c, state)
+}
+
+func (m *ZKEManager) RemoveNode(ctx context.Context, id string, node string) (interface{}, *resterr.APIError) {
+	m.lock.Lock()
+	c, _, _, err := m.prepareRemoveNode(id, node)
+	m.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// drain and delete node may take long, don't block other clusters
+	if err := m.nodeListener.RemoveNode(c, node); err != nil {
+		return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("remove node %s from kubernetes failed %s", node, err.Error()))
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c, newCluster, state, err := m.prepareRemoveNode(id, node)
+	if err != nil {
+		return nil, err
+	}
+	c.config = genZKEConfigForUpdate(c.config, newCluster)
+	return nil, m.update(ctx, c, state)
+}
+
+// prepareRemoveNode should be called with lock held, cluster may be changed
+// while node is removed from kubernetes, so it's checked again before update
+func (m *ZKEManager) prepareRemoveNode(id string, node string) (*Cluster, *types.Cluster, clusterState, *resterr.APIError) {
+	c := m.get(id)
+	if c == nil {
+		return nil, nil, clusterState{}, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("cluster %s desn't exist", id))
+	}
+
+	state, err := getClusterFromDB(id, m.dbTable)
+	if err != nil {
+		return nil, nil, clusterState{}, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("%s", err))
+	}
+
+	if !state.Created || !c.Can(UpdateEvent) {
+		return nil, nil, clusterState{}, resterr.NewAPIError(resterr.PermissionDenied, fmt.Sprintf("cluster %s can't remove node on %s status", id, c.getStatus()))
+	}
+
+	oldCluster := c.ToScCluster()
+	newCluster := c.ToScCluster()
+	newCluster.Nodes = nil
+	for _, n := range oldCluster.Nodes {
+		if n.Name != node {
+			newCluster.Nodes = append(newCluster.Nodes, n)
+		}
+	}
+	if len(newCluster.Nodes) == len(oldCluster.Nodes) {
+		return nil, nil, clusterState{}, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("node %s desn't exist in cluster %s", node, id))
+	}
+
+	if err := validateConfigForUpdate(oldCluster, newCluster, m.nodeListener, c); err != nil {
+		return nil, nil, clusterState{}, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("remove node validate failed %s", err))
+	}
+	return c, newCluster, state, nil
+}
+
+// update persist the cluster current config and node states and run zke
+// to make the cluster match them, failed nodes will be retried
+func (m *ZKEManager) update(ctx context.Context, c *Cluster, state clusterState) *resterr.APIError {
+	c.resetNodeStates()
+	c.transitNodeStates(types.NPSFailed, types.NPSPending, "")
+	state.ZKEConfig = c.config
+	state.NodeStates = c.getNodeStates()
+	state.Operation = zkeOperationUpdate
+	state.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := createOrUpdateClusterFromDB(c.Name, state, m.dbTable); err != nil {
+		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("%s", err))
+	}
+
 	if state.Created {
-		if err := existCluster.Event(UpdateEvent); err != nil {
-			return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("send cluster %s fsm %s event failed %s", existCluster.Name, UpdateEvent, err.Error()))
+		if err := c.Event(UpdateEvent); err != nil {
+			return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("send cluster %s fsm %s event failed %s", c.Name, UpdateEvent, err.Error()))
 		}
 	} else {
-		if err := existCluster.Event(ContinuteCreateEvent); err != nil {
-			return nil, resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("send cluster %s fsm %s event failed %s", existCluster.Name, ContinuteCreateEvent, err.Error()))
+		if err := c.Event(ContinuteCreateEvent); err != nil {
+			return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("send cluster %s fsm %s event failed %s", c.Name, ContinuteCreateEvent, err.Error()))
 		}
 	}
-	cancelCtx, cancel := context.WithCancel(context.Background())
-	existCluster.cancel = cancel
-	go existCluster.Update(cancelCtx, state, m)
-	return typesCluster, nil
+	c.requestID = state.RequestID
+	cancelCtx, cancel := context.WithCancel(c.operationContext())
+	c.cancel = cancel
+	m.runOperation(func() {
+		c.Update(cancelCtx, state, m)
+	})
+	return nil
 }
 
 func (m *ZKEManager) Get(id string) *Cluster {
@@ -208,7 +329,7 @@ func (m *ZKEManager) ListReady() []*Cluster {
 	return clusters
 }
 
-func (m *ZKEManager) Delete(id string) *resterr.APIError {
+func (m *ZKEManager) Delete(ctx context.Context, id string) *resterr.APIError {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
@@ -232,16 +353,20 @@ func (m *ZKEManager) Delete(id string) *resterr.APIError {
 
 	if state.Created {
 		close(toDelete.stopCh)
-		eventbus.PublishResourceDeleteEvent(toDelete.ToScCluster())
+		eventbus.PublishResourceDeleteEvent(ctx, toDelete.ToScCluster())
 	}
 
 	tm := time.Now()
 	toDelete.deleteTime = tm
+	toDelete.requestID = logging.RequestIDFromContext(ctx)
 	state.DeleteTime = tm
+	state.RequestID = toDelete.requestID
 	if err := createOrUpdateClusterFromDB(id, state, m.dbTable); err != nil {
 		return resterr.NewAPIError(resterr.ServerError, fmt.Sprintf("%s", err))
 	}
-	go toDelete.Destroy(context.TODO(), m)
+	m.runOperation(func() {
+		toDelete.Destroy(toDelete.operationContext(), m)
+	})
 	return nil
 }
 
@@ -259,35 +384,175 @@ func (m *ZKEManager) CancelCluster(id string) (interface{}, *resterr.APIError) {
 	return nil, nil
 }
 
+// Preflight fills defaults and ssh key of existing cluster into its own
+// copy, the input of caller isn't modified
+func (m *ZKEManager) Preflight(id string, input *types.Cluster) (*types.PreflightReport, *resterr.APIError) {
+	cp := *input
+	cp.Nodes = append([]types.Node(nil), input.Nodes...)
+	cp.ClusterUpstreamDNS = append([]string(nil), input.ClusterUpstreamDNS...)
+	typesCluster := &cp
+	typesCluster.TrimFieldSpace()
+
+	if id == "" {
+		fillPreflightDefaults(typesCluster, types.Cluster{}.CreateDefaultResource().(*types.Cluster))
+		if err := validateConfigForCreate(typesCluster); err != nil {
+			return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("cluster config validate failed %s", err))
+		}
+		return preflightCheck(typesCluster), nil
+	}
+
+	existCluster := m.Get(id)
+	if existCluster == nil {
+		return nil, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("cluster %s desn't exist", id))
+	}
+
+	typesCluster.Name = id
+	if typesCluster.SSHKey == "" {
+		typesCluster.SSHKey = existCluster.config.Option.SSHKey
+	}
+	oldCluster := existCluster.ToScCluster()
+	fillPreflightDefaults(typesCluster, oldCluster)
+	if err := validateConfigForUpdate(oldCluster, typesCluster, m.nodeListener, existCluster); err != nil {
+		return nil, resterr.NewAPIError(resterr.InvalidOption, fmt.Sprintf("cluster config validate failed %s", err))
+	}
+	typesCluster.Nodes = getAddedNodes(oldCluster.Nodes, typesCluster.Nodes)
+	return preflightCheck(typesCluster), nil
+}
+
 func (m *ZKEManager) loadDB() error {
 	states, err := getClustersFromDB(m.dbTable)
 	if err != nil {
 		return err
 	}
 
+	var deleting []*Cluster
 	for k, v := range states {
+		if v.needEncrypt {
+			if err := createOrUpdateClusterFromDB(k, v, m.dbTable); err != nil {
+				return err
+			}
+		}
+
+		if !v.DeleteTime.IsZero() {
+			cluster := m.newManagedCluster(k, types.CSDeleting)
+			cluster.config = v.ZKEConfig
+			cluster.createTime = v.CreateTime
+			cluster.deleteTime = v.DeleteTime
+			cluster.scVersion = v.ScVersion
+			cluster.requestID = v.RequestID
+			cluster.loadNodeStates(v.NodeStates, v.Created)
+			m.add(cluster)
+			deleting = append(deleting, cluster)
+			continue
+		}
+
+		var cluster *Cluster
 		if v.Created {
-			cluster := newCluster(k, types.CSRunning)
+			cluster = m.newManagedCluster(k, types.CSRunning)
 			cluster.config = v.ZKEConfig
 			cluster.createTime = v.CreateTime
 			cluster.scVersion = v.ScVersion
+			cluster.template = v.Template
+			cluster.templateRev = v.TemplateRevision
+			cluster.loadNodeStates(v.NodeStates, true)
 			if err := cluster.Init(v.CurrentState.CertificatesBundle[pki.KubeAdminCertName].Config); err != nil {
-				log.Warnf("init cluster %s failed %s", k, err.Error())
+				zkeStdLogger.Warnf("init cluster %s failed %s", k, err.Error())
 				continue
 			}
 			m.add(cluster)
-			eventbus.PublishResourceCreateEvent(cluster.ToScCluster())
+			eventbus.PublishResourceCreateEvent(context.TODO(), cluster.ToScCluster())
 		} else {
-			cluster := newCluster(k, types.CSCreateFailed)
+			cluster = m.newManagedCluster(k, types.CSCreateFailed)
 			cluster.config = v.ZKEConfig
 			cluster.createTime = v.CreateTime
 			cluster.scVersion = v.ScVersion
+			cluster.template = v.Template
+			cluster.templateRev = v.TemplateRevision
+			cluster.loadNodeStates(v.NodeStates, false)
 			m.add(cluster)
 		}
+
+		if v.Operation != "" {
+			m.markInterrupted(cluster, v)
+		}
+	}
+
+	//delete is resumed since cluster can't be used once delete starts
+	for _, cluster := range deleting {
+		c := cluster
+		zkeLogger.Infof(c.operationContext(), "resume deleting cluster %s", c.Name)
+		m.runOperation(func() {
+			c.Destroy(c.operationContext(), m)
+		})
 	}
 	return nil
 }
 
+// markInterrupted persists the failed node states of operation which
+// is interrupted by singlecloud exit, failed nodes could be retried
+func (m *ZKEManager) markInterrupted(c *Cluster, state clusterState) {
+	ctx := logging.WithRequestID(context.Background(), state.RequestID)
+	zkeLogger.Warnf(ctx, "cluster %s %s is interrupted, unfinished nodes are marked failed", c.Name, state.Operation)
+	alarm.New().Kind(clusterKindName).Cluster(c.Name).Name(c.Name).Reason(state.Operation + "Interrupted").Message(nodeProvisionInterrupted).Publish(ctx)
+	state.Operation = ""
+	state.RequestID = ""
+	state.NodeStates = c.getNodeStates()
+	if err := createOrUpdateClusterFromDB(c.Name, state, m.dbTable); err != nil {
+		zkeStdLogger.Warnf("update db failed after cluster %s is interrupted %s", c.Name, err.Error())
+	}
+}
+
+func (m *ZKEManager) runOperation(f func()) {
+	m.operations.Add(1)
+	go func() {
+		defer m.operations.Done()
+		f()
+	}()
+}
+
+// Shutdown cancels creating and updating, so partial result is saved,
+// then waits for running operations until ctx is done, unfinished
+// ones are handled when singlecloud starts again
+func (m *ZKEManager) Shutdown(ctx context.Context) error {
+	m.lock.Lock()
+	for _, c := range m.clusters {
+		if status := c.getStatus(); status == types.CSCreating || status == types.CSUpdating {
+			if err := c.Cancel(); err != nil {
+				zkeStdLogger.Warnf("cancel cluster %s on shutdown failed %s", c.Name, err.Error())
+			}
+		}
+	}
+	m.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.operations.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait cluster operations failed %s", ctx.Err().Error())
+	}
+}
+
+func (m *ZKEManager) newManagedCluster(name string, initialStatus types.ClusterStatus) *Cluster {
+	c := newCluster(name, initialStatus)
+	c.healthConf = m.healthConf
+	c.health = newHealthHistory(m.healthConf.historySize)
+	return c
+}
+
+func (m *ZKEManager) GetHealthHistory(id string) (*types.ClusterHealthHistory, *resterr.APIError) {
+	c := m.Get(id)
+	if c == nil {
+		return nil, resterr.NewAPIError(resterr.NotFound, fmt.Sprintf("cluster %s desn't exist", id))
+	}
+	return &types.ClusterHealthHistory{Records: c.health.list()}, nil
+}
+
 func (m *ZKEManager) add(c *Cluster) {
 	m.clusters = append(m.clusters, c)
 }
@@ -303,6 +568,7 @@ func (m *ZKEManager) Remove(cluster *Cluster) {
 	for i, c := range m.clusters {
 		if c.Name == cluster.Name {
 			m.clusters = append(m.clusters[:i], m.clusters[i+1:]...)
+			deleteClusterMetrics(cluster.Name)
 			break
 		}
 	}
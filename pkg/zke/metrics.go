@@ -0,0 +1,109 @@
+package zke
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zdnscloud/singlecloud/pkg/clusteragent"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	metricsNamespace = "singlecloud"
+
+	zkeOperationCreate = "create"
+	zkeOperationUpdate = "update"
+	zkeOperationDelete = "delete"
+)
+
+var (
+	clusterCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "zke", "clusters"),
+		"Number of clusters by status.",
+		[]string{"status"}, nil)
+
+	connectionCheckLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "zke",
+		Name:      "connection_check_latency_seconds",
+		Help:      "Latency of cluster apiserver connection check.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"cluster", "reachable"})
+
+	zkeOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "zke",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of zke create, update and delete operations.",
+		Buckets:   prometheus.ExponentialBuckets(30, 2, 8),
+	}, []string{"operation", "result"})
+)
+
+var clusterStatuses = []types.ClusterStatus{
+	types.CSRunning,
+	types.CSUnreachable,
+	types.CSCreating,
+	types.CSCreateFailed,
+	types.CSUpdating,
+	types.CSDeleting,
+	types.CSDeleted,
+}
+
+func init() {
+	prometheus.MustRegister(connectionCheckLatency, zkeOperationDuration)
+}
+
+// clusterCollector counts clusters on scrape, so the count is always
+// consistent with fsm state
+type clusterCollector struct {
+	mgr *ZKEManager
+}
+
+func (c clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clusterCountDesc
+}
+
+func (c clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := make(map[types.ClusterStatus]int)
+	for _, cluster := range c.mgr.List() {
+		counts[cluster.getStatus()] += 1
+	}
+
+	for _, status := range clusterStatuses {
+		ch <- prometheus.MustNewConstMetric(clusterCountDesc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
+
+func registerClusterCollector(mgr *ZKEManager) error {
+	if err := prometheus.Register(clusterCollector{mgr: mgr}); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func observeConnectionCheck(cluster string, latency time.Duration, reachable bool) {
+	r := "true"
+	if !reachable {
+		r = "false"
+	}
+	connectionCheckLatency.WithLabelValues(cluster, r).Observe(latency.Seconds())
+}
+
+func deleteClusterMetrics(cluster string) {
+	connectionCheckLatency.DeleteLabelValues(cluster, "true")
+	connectionCheckLatency.DeleteLabelValues(cluster, "false")
+	clusteragent.GetAgent().ForgetCluster(cluster)
+}
+
+func observeZKEOperation(operation string, start time.Time, err error, canceled bool) {
+	result := "succeed"
+	if canceled {
+		result = "canceled"
+	} else if err != nil {
+		result = "failed"
+	}
+	zkeOperationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,47 @@
+package zke
+
+import (
+	"fmt"
+
+	"github.com/zdnscloud/kvzoo"
+
+	"github.com/zdnscloud/singlecloud/pkg/db"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+func init() {
+	db.RegisterMigration(db.Migration{
+		Version:     "v1.1",
+		Name:        "cluster-node-states",
+		Description: "record ready state for nodes of clusters created before node provision state is tracked",
+		Migrate:     migrateClusterNodeStates,
+	})
+}
+
+func migrateClusterNodeStates(kvdb kvzoo.DB) error {
+	tn, _ := kvzoo.TableNameFromSegments(ZKEManagerDBTable)
+	table, err := kvdb.CreateOrGetTable(tn)
+	if err != nil {
+		return fmt.Errorf("create or get table %s failed: %s", tn, err.Error())
+	}
+
+	states, err := getClustersFromDB(table)
+	if err != nil {
+		return err
+	}
+
+	for name, state := range states {
+		if !state.Created || len(state.NodeStates) != 0 || state.ZKEConfig == nil {
+			continue
+		}
+
+		state.NodeStates = make(map[string]nodeState)
+		for _, n := range state.ZKEConfig.Nodes {
+			state.NodeStates[n.NodeName] = nodeState{Status: types.NPSReady}
+		}
+		if err := createOrUpdateClusterFromDB(name, state, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
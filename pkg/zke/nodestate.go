@@ -0,0 +1,146 @@
+package zke
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+)
+
+const (
+	nodeProvisionInterrupted = "provision is interrupted by singlecloud restart"
+	nodeProvisionAborted     = "provision is aborted since other nodes failed"
+)
+
+type nodeState struct {
+	Status types.NodeProvisionStatus `json:"status"`
+	Reason string                    `json:"reason,omitempty"`
+}
+
+func (c *Cluster) loadNodeStates(states map[string]nodeState, created bool) {
+	c.nodeLock.Lock()
+	c.nodeStates = make(map[string]nodeState)
+	for name, s := range states {
+		c.nodeStates[name] = s
+	}
+	c.nodeLock.Unlock()
+
+	c.resetNodeStates()
+	if created && len(states) == 0 {
+		c.transitNodeStates(types.NPSPending, types.NPSReady, "")
+		return
+	}
+
+	c.transitNodeStates(types.NPSPending, types.NPSFailed, nodeProvisionInterrupted)
+	c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, nodeProvisionInterrupted)
+}
+
+// resetNodeStates add pending state for nodes new in config and
+// drop the states of nodes which are no longer in config
+func (c *Cluster) resetNodeStates() {
+	c.nodeLock.Lock()
+	defer c.nodeLock.Unlock()
+
+	if c.nodeStates == nil {
+		c.nodeStates = make(map[string]nodeState)
+	}
+
+	names := make(map[string]bool)
+	if c.config != nil {
+		for _, n := range c.config.Nodes {
+			names[n.NodeName] = true
+			if _, ok := c.nodeStates[n.NodeName]; !ok {
+				c.nodeStates[n.NodeName] = nodeState{Status: types.NPSPending}
+			}
+		}
+	}
+
+	for name := range c.nodeStates {
+		if !names[name] {
+			delete(c.nodeStates, name)
+		}
+	}
+}
+
+func (c *Cluster) transitNodeStates(from, to types.NodeProvisionStatus, reason string) int {
+	c.nodeLock.Lock()
+	defer c.nodeLock.Unlock()
+
+	count := 0
+	for name, s := range c.nodeStates {
+		if s.Status == from {
+			c.nodeStates[name] = nodeState{Status: to, Reason: reason}
+			count += 1
+		}
+	}
+	return count
+}
+
+// failProvisioningNodes records reason on nodes it mentions, zke reports
+// host error with node address like [192.168.1.10], other provisioning nodes
+// are aborted, if no node is mentioned, reason is recorded on all of them
+func (c *Cluster) failProvisioningNodes(reason string) {
+	addresses := make(map[string]string)
+	if c.config != nil {
+		for _, n := range c.config.Nodes {
+			addresses[n.NodeName] = n.Address
+		}
+	}
+
+	c.nodeLock.Lock()
+	defer c.nodeLock.Unlock()
+
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(reason, func(r rune) bool {
+		return r == '[' || r == ']' || r == ',' || r == ':' || unicode.IsSpace(r)
+	}) {
+		words[w] = true
+	}
+
+	failed := make(map[string]bool)
+	for name, s := range c.nodeStates {
+		if s.Status == types.NPSProvisioning && (words[name] || words[addresses[name]]) {
+			failed[name] = true
+		}
+	}
+
+	for name, s := range c.nodeStates {
+		if s.Status != types.NPSProvisioning {
+			continue
+		}
+		if len(failed) == 0 || failed[name] {
+			c.nodeStates[name] = nodeState{Status: types.NPSFailed, Reason: reason}
+		} else {
+			c.nodeStates[name] = nodeState{Status: types.NPSFailed, Reason: nodeProvisionAborted}
+		}
+	}
+}
+
+func (c *Cluster) hasNodeInStatus(status types.NodeProvisionStatus) bool {
+	c.nodeLock.RLock()
+	defer c.nodeLock.RUnlock()
+
+	for _, s := range c.nodeStates {
+		if s.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cluster) getNodeState(name string) nodeState {
+	c.nodeLock.RLock()
+	defer c.nodeLock.RUnlock()
+	return c.nodeStates[name]
+}
+
+func (c *Cluster) getNodeStates() map[string]nodeState {
+	c.nodeLock.RLock()
+	defer c.nodeLock.RUnlock()
+
+	states := make(map[string]nodeState)
+	for name, s := range c.nodeStates {
+		states[name] = s
+	}
+	return states
+}
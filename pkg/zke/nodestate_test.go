@@ -0,0 +1,80 @@
+package zke
+
+import (
+	"testing"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	zketypes "github.com/zdnscloud/zke/types"
+)
+
+func newNodeStateTestCluster(nodes ...string) *Cluster {
+	c := newCluster("nodeStateTest", types.CSRunning)
+	c.config = &zketypes.ZKEConfig{}
+	for _, n := range nodes {
+		c.config.Nodes = append(c.config.Nodes, zketypes.ZKEConfigNode{NodeName: n})
+	}
+	return c
+}
+
+func TestResetNodeStates(t *testing.T) {
+	c := newNodeStateTestCluster("master", "worker1")
+	c.resetNodeStates()
+	ut.Equal(t, c.getNodeState("master").Status, types.NPSPending)
+	ut.Equal(t, c.transitNodeStates(types.NPSPending, types.NPSReady, ""), 2)
+
+	c.config.Nodes = append(c.config.Nodes[:1], zketypes.ZKEConfigNode{NodeName: "worker2"})
+	c.resetNodeStates()
+	states := c.getNodeStates()
+	ut.Equal(t, len(states), 2)
+	ut.Equal(t, states["master"].Status, types.NPSReady)
+	ut.Equal(t, states["worker2"].Status, types.NPSPending)
+	_, ok := states["worker1"]
+	ut.Equal(t, ok, false)
+}
+
+func TestTransitNodeStates(t *testing.T) {
+	c := newNodeStateTestCluster("master", "worker")
+	c.resetNodeStates()
+	c.transitNodeStates(types.NPSPending, types.NPSProvisioning, "")
+	c.transitNodeStates(types.NPSProvisioning, types.NPSFailed, "ssh failed")
+	ut.Equal(t, c.hasNodeInStatus(types.NPSFailed), true)
+	ut.Equal(t, c.getNodeState("worker").Reason, "ssh failed")
+
+	c.transitNodeStates(types.NPSFailed, types.NPSPending, "")
+	ut.Equal(t, c.hasNodeInStatus(types.NPSFailed), false)
+	ut.Equal(t, c.getNodeState("worker").Reason, "")
+}
+
+func TestLoadNodeStates(t *testing.T) {
+	c := newNodeStateTestCluster("master", "worker")
+	c.loadNodeStates(nil, true)
+	ut.Equal(t, c.getNodeState("master").Status, types.NPSReady)
+	ut.Equal(t, c.getNodeState("worker").Status, types.NPSReady)
+
+	c.loadNodeStates(map[string]nodeState{
+		"master": nodeState{Status: types.NPSReady},
+		"worker": nodeState{Status: types.NPSProvisioning},
+	}, true)
+	ut.Equal(t, c.getNodeState("master").Status, types.NPSReady)
+	ut.Equal(t, c.getNodeState("worker").Status, types.NPSFailed)
+	ut.Equal(t, c.getNodeState("worker").Reason, nodeProvisionInterrupted)
+}
+
+func TestFailProvisioningNodes(t *testing.T) {
+	c := newNodeStateTestCluster("master", "worker1", "worker2")
+	c.config.Nodes[1].Address = "192.168.1.1"
+	c.config.Nodes[2].Address = "192.168.1.10"
+	c.resetNodeStates()
+	c.transitNodeStates(types.NPSPending, types.NPSProvisioning, "")
+	reason := "Failed to deploy file on node [192.168.1.10]: timeout"
+	c.failProvisioningNodes(reason)
+	ut.Equal(t, c.getNodeState("worker2").Reason, reason)
+	ut.Equal(t, c.getNodeState("worker1").Reason, nodeProvisionAborted)
+	ut.Equal(t, c.getNodeState("master").Status, types.NPSFailed)
+
+	c.transitNodeStates(types.NPSFailed, types.NPSProvisioning, "")
+	c.failProvisioningNodes("etcd cluster is unhealthy")
+	ut.Equal(t, c.getNodeState("worker1").Reason, "etcd cluster is unhealthy")
+	ut.Equal(t, c.getNodeState("master").Reason, "etcd cluster is unhealthy")
+}
@@ -0,0 +1,295 @@
+package zke
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zdnscloud/singlecloud/pkg/types"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	preflightSSHTimeout    = 10 * time.Second
+	preflightDataDir       = "/var/lib"
+	preflightMinFreeDisk   = 20 << 30
+	preflightMinFreeMemory = 1 << 30
+	preflightMaxTimeOffset = 2 * time.Second
+)
+
+var (
+	controlplaneRequiredPorts = []int{2379, 2380, 6443, 10250, 10251, 10252, 10256}
+	workerRequiredPorts       = []int{10250, 10256}
+	edgeRequiredPorts         = []int{80, 443}
+)
+
+type commandRunner interface {
+	Run(cmd string) (string, error)
+	Close() error
+}
+
+type sshRunner struct {
+	client *ssh.Client
+}
+
+func newSSHRunner(address, port, user, key string) (*sshRunner, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("parse ssh key failed %s", err.Error())
+	}
+
+	client, err := ssh.Dial("tcp", net.JoinHostPort(address, port), &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         preflightSSHTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sshRunner{client: client}, nil
+}
+
+func (r *sshRunner) Run(cmd string) (string, error) {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	out, err := session.Output(cmd)
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r *sshRunner) Close() error {
+	return r.client.Close()
+}
+
+func preflightCheck(c *types.Cluster) *types.PreflightReport {
+	report := &types.PreflightReport{
+		Nodes: make([]types.NodePreflightResult, len(c.Nodes)),
+	}
+
+	var wg sync.WaitGroup
+	for i, n := range c.Nodes {
+		wg.Add(1)
+		go func(i int, n types.Node) {
+			defer wg.Done()
+			report.Nodes[i] = preflightNode(n, c.SSHUser, c.SSHPort, c.SSHKey)
+		}(i, n)
+	}
+	wg.Wait()
+
+	checkHostnameUnique(report.Nodes)
+	report.Passed = true
+	for i, r := range report.Nodes {
+		report.Nodes[i].Passed = r.Reachable && len(r.Errors) == 0
+		if !report.Nodes[i].Passed {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+func preflightNode(n types.Node, user, port, key string) types.NodePreflightResult {
+	result := types.NodePreflightResult{
+		Name:    n.Name,
+		Address: n.Address,
+	}
+
+	runner, err := newSSHRunner(n.Address, port, user, key)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("ssh connect failed %s", err.Error()))
+		return result
+	}
+	defer runner.Close()
+
+	result.Reachable = true
+	runPreflightChecks(runner, n, &result)
+	return result
+}
+
+func runPreflightChecks(r commandRunner, n types.Node, result *types.NodePreflightResult) {
+	addError := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	if hostname, err := r.Run("hostname"); err != nil {
+		addError("get hostname failed %s", err.Error())
+	} else {
+		result.Hostname = hostname
+	}
+
+	if os, err := r.Run(". /etc/os-release && echo \"$PRETTY_NAME\""); err != nil {
+		addError("get os release failed %s", err.Error())
+	} else {
+		result.OS = os
+	}
+
+	if kernel, err := r.Run("uname -r"); err != nil {
+		addError("get kernel version failed %s", err.Error())
+	} else {
+		result.KernelVersion = kernel
+	}
+
+	if version, err := r.Run("docker version --format '{{.Server.Version}}'"); err != nil || version == "" {
+		addError("docker isn't installed or isn't running")
+	} else {
+		result.DockerVersion = version
+	}
+
+	if disk, err := runForInt(r, fmt.Sprintf("df -Pk %s | tail -1 | awk '{print $4}'", preflightDataDir)); err != nil {
+		addError("get free disk failed %s", err.Error())
+	} else {
+		result.FreeDisk = disk * 1024
+		if result.FreeDisk < preflightMinFreeDisk {
+			addError("free disk of %s is %d bytes, at least %d bytes is required", preflightDataDir, result.FreeDisk, preflightMinFreeDisk)
+		}
+	}
+
+	if mem, err := runForInt(r, "awk '/MemAvailable/ {print $2}' /proc/meminfo"); err != nil {
+		addError("get free memory failed %s", err.Error())
+	} else {
+		result.FreeMemory = mem * 1024
+		if result.FreeMemory < preflightMinFreeMemory {
+			addError("free memory is %d bytes, at least %d bytes is required", result.FreeMemory, preflightMinFreeMemory)
+		}
+	}
+
+	sendTime := time.Now()
+	if remote, err := runForInt(r, "date +%s%N"); err != nil {
+		addError("get node time failed %s", err.Error())
+	} else {
+		recvTime := time.Now()
+		localTime := sendTime.Add(recvTime.Sub(sendTime) / 2)
+		offset := time.Unix(0, remote).Sub(localTime)
+		result.TimeOffset = int64(offset / time.Millisecond)
+		if offset > preflightMaxTimeOffset || offset < -preflightMaxTimeOffset {
+			addError("time offset with singlecloud is %s, it should be less than %s", offset, preflightMaxTimeOffset)
+		}
+	}
+
+	if out, err := r.Run("ss -Htln"); err != nil {
+		addError("get listening ports failed %s", err.Error())
+	} else {
+		listening := parseListeningPorts(out)
+		for _, port := range getRequiredPorts(n) {
+			if listening[port] {
+				result.PortsInUse = append(result.PortsInUse, port)
+			}
+		}
+		if len(result.PortsInUse) > 0 {
+			addError("required ports %v are already in use", result.PortsInUse)
+		}
+	}
+}
+
+func runForInt(r commandRunner, cmd string) (int64, error) {
+	out, err := r.Run(cmd)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(out, 10, 64)
+}
+
+func parseListeningPorts(ssOutput string) map[int]bool {
+	ports := make(map[int]bool)
+	for _, line := range strings.Split(ssOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		local := fields[3]
+		i := strings.LastIndex(local, ":")
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.Atoi(local[i+1:]); err == nil {
+			ports[port] = true
+		}
+	}
+	return ports
+}
+
+func getRequiredPorts(n types.Node) []int {
+	var ports []int
+	seen := make(map[int]bool)
+	add := func(ps []int) {
+		for _, p := range ps {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+
+	if n.HasRole(types.RoleControlPlane) {
+		add(controlplaneRequiredPorts)
+	}
+	if n.HasRole(types.RoleWorker) {
+		add(workerRequiredPorts)
+	}
+	if n.HasRole(types.RoleEdge) {
+		add(edgeRequiredPorts)
+	}
+	return ports
+}
+
+// existing nodes already run kubelet and etcd which listen on the required
+// ports, so only nodes to be added are checked
+func getAddedNodes(existNodes, nodes []types.Node) []types.Node {
+	exists := make(map[string]bool)
+	for _, n := range existNodes {
+		exists[n.Name] = true
+	}
+
+	var added []types.Node
+	for _, n := range nodes {
+		if exists[n.Name] == false {
+			added = append(added, n)
+		}
+	}
+	return added
+}
+
+func checkHostnameUnique(results []types.NodePreflightResult) {
+	owners := make(map[string]string)
+	for i, r := range results {
+		if r.Hostname == "" {
+			continue
+		}
+		if owner, ok := owners[r.Hostname]; ok {
+			results[i].Errors = append(results[i].Errors, fmt.Sprintf("hostname %s is duplicate with node %s", r.Hostname, owner))
+		} else {
+			owners[r.Hostname] = r.Name
+		}
+	}
+}
+
+func fillPreflightDefaults(c, base *types.Cluster) {
+	if c.SSHPort == "" {
+		c.SSHPort = base.SSHPort
+	}
+	if c.Network.Plugin == "" {
+		c.Network = base.Network
+	}
+	if c.ClusterCidr == "" {
+		c.ClusterCidr = base.ClusterCidr
+	}
+	if c.ServiceCidr == "" {
+		c.ServiceCidr = base.ServiceCidr
+	}
+	if c.ClusterDomain == "" {
+		c.ClusterDomain = base.ClusterDomain
+	}
+	if c.ClusterDNSServiceIP == "" {
+		c.ClusterDNSServiceIP = base.ClusterDNSServiceIP
+	}
+	if len(c.ClusterUpstreamDNS) == 0 {
+		c.ClusterUpstreamDNS = base.ClusterUpstreamDNS
+	}
+}
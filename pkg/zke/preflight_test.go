@@ -0,0 +1,208 @@
+package zke
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/singlecloud/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+type fakeSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	outputs  map[string]string
+}
+
+func newFakeSSHServer(t *testing.T, clientKey ssh.PublicKey, outputs map[string]string) *fakeSSHServer {
+	hostKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ut.Assert(t, err == nil, "generate host key should succeed: %s", err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	ut.Assert(t, err == nil, "create host signer should succeed: %s", err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ut.Assert(t, err == nil, "listen should succeed: %s", err)
+
+	s := &fakeSSHServer{
+		listener: listener,
+		config:   config,
+		outputs:  outputs,
+	}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSSHServer) port() string {
+	return fmt.Sprintf("%d", s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *fakeSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *fakeSSHServer) handleConn(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				cmd := string(req.Payload[4:])
+				req.Reply(true, nil)
+				status := s.exec(cmd, channel)
+				exitStatus := make([]byte, 4)
+				binary.BigEndian.PutUint32(exitStatus, status)
+				channel.SendRequest("exit-status", false, exitStatus)
+				return
+			}
+		}()
+	}
+}
+
+func (s *fakeSSHServer) exec(cmd string, channel ssh.Channel) uint32 {
+	for prefix, out := range s.outputs {
+		if strings.HasPrefix(cmd, prefix) {
+			if out == "date" {
+				out = fmt.Sprintf("%d", time.Now().UnixNano())
+			}
+			channel.Write([]byte(out + "\n"))
+			return 0
+		}
+	}
+	return 127
+}
+
+func (s *fakeSSHServer) close() {
+	s.listener.Close()
+}
+
+func genClientKey(t *testing.T) (string, ssh.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	ut.Assert(t, err == nil, "generate client key should succeed: %s", err)
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	ut.Assert(t, err == nil, "create client public key should succeed: %s", err)
+	keyPem := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	return string(keyPem), pub
+}
+
+func healthyNodeOutputs(hostname string) map[string]string {
+	return map[string]string{
+		"hostname":            hostname,
+		". /etc/os-release":   "Ubuntu 18.04.3 LTS",
+		"uname -r":            "4.15.0-60-generic",
+		"docker version":      "18.09.7",
+		"df -Pk":              "104857600",
+		"awk '/MemAvailable/": "8388608",
+		"date":                "date",
+		"ss -Htln":            "LISTEN 0 128 0.0.0.0:22 0.0.0.0:*",
+	}
+}
+
+func TestPreflightCheck(t *testing.T) {
+	key, pub := genClientKey(t)
+	master := newFakeSSHServer(t, pub, healthyNodeOutputs("master"))
+	defer master.close()
+
+	workerOutputs := healthyNodeOutputs("worker")
+	worker := newFakeSSHServer(t, pub, workerOutputs)
+	defer worker.close()
+
+	c := &types.Cluster{
+		SSHUser: "root",
+		SSHPort: master.port(),
+		SSHKey:  key,
+		Nodes: []types.Node{
+			{Name: "master", Address: "127.0.0.1", Roles: []types.NodeRole{types.RoleControlPlane}},
+		},
+	}
+	report := preflightCheck(c)
+	ut.Assert(t, report.Passed, "healthy node preflight should pass: %v", report.Nodes[0].Errors)
+	ut.Equal(t, report.Nodes[0].Reachable, true)
+	ut.Equal(t, report.Nodes[0].Hostname, "master")
+	ut.Equal(t, report.Nodes[0].DockerVersion, "18.09.7")
+	ut.Equal(t, report.Nodes[0].FreeDisk, int64(104857600*1024))
+
+	delete(workerOutputs, "docker version")
+	workerOutputs["ss -Htln"] = "LISTEN 0 128 0.0.0.0:10250 0.0.0.0:*"
+	c.SSHPort = worker.port()
+	c.Nodes = []types.Node{
+		{Name: "worker", Address: "127.0.0.1", Roles: []types.NodeRole{types.RoleWorker}},
+	}
+	report = preflightCheck(c)
+	ut.Equal(t, report.Passed, false)
+	ut.Equal(t, report.Nodes[0].Reachable, true)
+	ut.Equal(t, report.Nodes[0].PortsInUse, []int{10250})
+	ut.Equal(t, len(report.Nodes[0].Errors), 2)
+
+	otherKey, _ := genClientKey(t)
+	c.SSHKey = otherKey
+	report = preflightCheck(c)
+	ut.Equal(t, report.Passed, false)
+	ut.Equal(t, report.Nodes[0].Reachable, false)
+}
+
+func TestCheckHostnameUnique(t *testing.T) {
+	results := []types.NodePreflightResult{
+		{Name: "n1", Hostname: "host"},
+		{Name: "n2", Hostname: "host"},
+		{Name: "n3", Hostname: "other"},
+	}
+	checkHostnameUnique(results)
+	ut.Equal(t, len(results[0].Errors), 0)
+	ut.Equal(t, len(results[1].Errors), 1)
+	ut.Equal(t, len(results[2].Errors), 0)
+}
+
+func TestGetAddedNodes(t *testing.T) {
+	existNodes := []types.Node{{Name: "master"}, {Name: "worker1"}}
+	nodes := []types.Node{{Name: "master"}, {Name: "worker1"}, {Name: "worker2"}}
+	ut.Equal(t, getAddedNodes(existNodes, nodes), []types.Node{{Name: "worker2"}})
+	ut.Equal(t, len(getAddedNodes(existNodes, existNodes)), 0)
+}
+
+func TestParseListeningPorts(t *testing.T) {
+	out := "LISTEN 0 128 0.0.0.0:22 0.0.0.0:*\nLISTEN 0 128 [::]:6443 [::]:*\n"
+	ports := parseListeningPorts(out)
+	ut.Equal(t, ports[22], true)
+	ut.Equal(t, ports[6443], true)
+	ut.Equal(t, ports[80], false)
+}
@@ -13,6 +13,7 @@ type createValidator func(c *types.Cluster) error
 type updateValidator func(oldCluster, newCluster *types.Cluster) error
 
 var createValidators = []createValidator{
+	validateSSHUserAndDomain,
 	validateClusterCIDRAndIPs,
 	validateDuplicateNodes,
 	validateNodeCount,
@@ -51,6 +52,20 @@ func validateConfigForUpdate(oldCluster, newCluster *types.Cluster, nl NodeListe
 	return validateToDeleteStorageNodes(oldCluster, newCluster, nl, currentCluster)
 }
 
+func ValidateClusterTemplate(t *types.ClusterTemplate) error {
+	c := &types.Cluster{
+		LoadBalance:         t.LoadBalance,
+		ClusterCidr:         t.ClusterCidr,
+		ServiceCidr:         t.ServiceCidr,
+		ClusterDNSServiceIP: t.ClusterDNSServiceIP,
+		ClusterUpstreamDNS:  t.ClusterUpstreamDNS,
+	}
+	if err := validateClusterCIDRAndIPs(c); err != nil {
+		return err
+	}
+	return validateLBConfig(c)
+}
+
 func validateToDeleteStorageNodes(oldCluster, newCluster *types.Cluster, nl NodeListener, currentCluster *Cluster) error {
 	if currentCluster.kubeClient == nil {
 		return nil
@@ -215,6 +230,18 @@ func validateClusterCIDRAndIPs(c *types.Cluster) error {
 	return nil
 }
 
+// sshUser and clusterDomain could be provided by cluster template, so
+// they're checked after template is applied
+func validateSSHUserAndDomain(c *types.Cluster) error {
+	if len(c.SSHUser) == 0 {
+		return fmt.Errorf("cluster sshUser is empty")
+	}
+	if len(c.ClusterDomain) == 0 {
+		return fmt.Errorf("cluster clusterDomain is empty")
+	}
+	return nil
+}
+
 func validateClusterSSHKeyNotEmpty(c *types.Cluster) error {
 	if len(c.SSHKey) == 0 {
 		return fmt.Errorf("cluster sshkey is empty")
@@ -5,9 +5,13 @@ import (
 	"strings"
 
 	"github.com/gorilla/websocket"
-	"github.com/zdnscloud/cement/log"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+	"github.com/zdnscloud/singlecloud/server"
 )
 
+var logger = logging.GetLogger("zkelog").Std()
+
 const (
 	WSPrefix         = "/apis/ws.zcloud.cn/v1"
 	WSZKELogPathTemp = WSPrefix + "/clusters/%s/zkelog"
@@ -16,16 +20,16 @@ const (
 func (m *LogManager) OpenLog(id string, r *http.Request, w http.ResponseWriter) {
 	watcher := m.get(id)
 	if watcher == nil {
-		log.Warnf("cluster %s log watcher isn't found to open console", id)
+		logger.Warnf("cluster %s log watcher isn't found to open console", id)
 		return
 	}
 
 	listener := watcher.addListener()
 	defer listener.Stop()
 
-	conn, err := websocket.Upgrade(w, r, nil, 4096, 4096)
+	conn, err := server.UpgradeWebsocket(w, r, nil, 4096, 4096)
 	if err != nil {
-		log.Warnf("cluster %s console log websocket upgrade failed %s", id, err.Error())
+		logger.Warnf("cluster %s console log websocket upgrade failed %s", id, err.Error())
 	}
 	defer conn.Close()
 
@@ -39,7 +43,7 @@ func (m *LogManager) OpenLog(id string, r *http.Request, w http.ResponseWriter)
 
 		if err := conn.WriteMessage(websocket.TextMessage, []byte(logString)); err != nil {
 			if !isBrokenPipeErr(err) {
-				log.Warnf("send cluster %s console log failed:%s", id, err.Error())
+				logger.Warnf("send cluster %s console log failed:%s", id, err.Error())
 			}
 			break
 		}
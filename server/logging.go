@@ -0,0 +1,51 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+var accessLogger = logging.GetLogger("http")
+
+// probes are requested periodically, they are logged in debug level
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// requestID reuses valid id from client, otherwise generates a new
+// one, it's saved in request context so handlers, resource.Context
+// and websocket sessions could get it from the request
+func requestID(c *gin.Context) {
+	id := c.GetHeader(logging.RequestIDHeader)
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	c.Header(logging.RequestIDHeader, id)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+func accessLog(c *gin.Context) {
+	start := time.Now()
+	path := c.Request.URL.Path
+	c.Next()
+
+	logger := accessLogger.WithFields(logging.Fields{
+		"client":    c.ClientIP(),
+		"method":    c.Request.Method,
+		"path":      path,
+		"proto":     c.Request.Proto,
+		"status":    c.Writer.Status(),
+		"latency":   time.Since(start).String(),
+		"userAgent": c.Request.UserAgent(),
+	})
+	if probePaths[path] && c.Writer.Status() < 300 {
+		logger.Debugf(c.Request.Context(), "%s %s %d", c.Request.Method, path, c.Writer.Status())
+	} else {
+		logger.Infof(c.Request.Context(), "%s %s %d", c.Request.Method, path, c.Writer.Status())
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ut "github.com/zdnscloud/cement/unittest"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(requestID)
+	router.GET("/id", func(c *gin.Context) {
+		c.String(http.StatusOK, logging.RequestIDFromContext(c.Request.Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(logging.RequestIDHeader, "client-id-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	ut.Equal(t, w.Header().Get(logging.RequestIDHeader), "client-id-1")
+	ut.Equal(t, w.Body.String(), "client-id-1")
+
+	req = httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(logging.RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	id := w.Header().Get(logging.RequestIDHeader)
+	ut.Assert(t, id != "" && id != "bad id\n", "invalid request id should be replaced")
+	ut.Equal(t, w.Body.String(), id)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var websocketConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "singlecloud",
+	Subsystem: "websocket",
+	Name:      "connections",
+	Help:      "Number of open websocket connections by route.",
+}, []string{"route"})
+
+func init() {
+	prometheus.MustRegister(websocketConnections)
+}
+
+// websocket handlers block until connection is closed, so the gauge
+// and tracked connections could be maintained around the handler
+func trackWebsocket(c *gin.Context) {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		c.Next()
+		return
+	}
+
+	websockets.requests.Add(1)
+	defer websockets.requests.Done()
+	var rc *requestConns
+	c.Request, rc = withRequestConns(c.Request)
+	start := time.Now()
+	defer func() {
+		websockets.remove(rc.conns)
+		if len(rc.conns) > 0 {
+			wsLogger.Infof(c.Request.Context(), "websocket session %s is closed after %s", c.Request.URL.Path, time.Since(start))
+		}
+	}()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unknown"
+	}
+	gauge := websocketConnections.WithLabelValues(route)
+	gauge.Inc()
+	defer gauge.Dec()
+	c.Next()
+}
@@ -1,9 +1,10 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
-	"time"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
@@ -11,7 +12,8 @@ import (
 )
 
 type Server struct {
-	router *gin.Engine
+	router     *gin.Engine
+	httpServer *http.Server
 }
 
 type WebHandler interface {
@@ -22,20 +24,10 @@ func NewServer(middlewares ...gin.HandlerFunc) (*Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 	gin.DefaultWriter = os.Stdout
 	router := gin.New()
-	router.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("[%s] client:%s \"%s %s\" %s %d %s %s\n",
-			param.TimeStamp.Format(time.RFC3339),
-			param.ClientIP,
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-		)
-	}))
+	router.Use(requestID, accessLog)
 	router.Use(static.Serve("/assets/helm/icons", static.LocalFile("/helm-icons", false)))
 	router.Use(static.Serve("/assets", static.LocalFile("/www", false)))
+	router.Use(trackWebsocket)
 	router.Use(middlewares...)
 	router.NoRoute(func(c *gin.Context) {
 		c.File("/www/index.html")
@@ -46,6 +38,9 @@ func NewServer(middlewares ...gin.HandlerFunc) (*Server, error) {
 
 	return &Server{
 		router: router,
+		httpServer: &http.Server{
+			Handler: router,
+		},
 	}, nil
 }
 
@@ -53,6 +48,20 @@ func (s *Server) RegisterHandler(h WebHandler) error {
 	return h.RegisterHandler(s.router)
 }
 
+// Run returns nil after server is shutdown
 func (s *Server) Run(addr, certFile, keyFile string) error {
-	return s.router.RunTLS(addr, certFile, keyFile)
+	s.httpServer.Addr = addr
+	if err := s.httpServer.ListenAndServeTLS(certFile, keyFile); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting new requests, closes websocket connections
+// and waits for in-flight requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := websockets.closeAll(ctx); err != nil {
+		return fmt.Errorf("close websocket connections failed %s", err.Error())
+	}
+	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/zdnscloud/singlecloud/pkg/logging"
+)
+
+type websocketKey struct{}
+
+// ErrShuttingDown is returned by UpgradeWebsocket after shutdown starts,
+// the connection is closed with close frame already
+var ErrShuttingDown = errors.New("server is shutting down")
+
+const closeFrameTimeout = time.Second
+
+// websocketConns holds connections of websocket requests being served,
+// they are closed with close frame on shutdown
+type websocketConns struct {
+	lock     sync.Mutex
+	conns    map[*websocket.Conn]struct{}
+	closed   bool
+	requests sync.WaitGroup
+}
+
+var wsLogger = logging.GetLogger("websocket")
+
+var websockets = &websocketConns{
+	conns: make(map[*websocket.Conn]struct{}),
+}
+
+type requestConns struct {
+	conns []*websocket.Conn
+}
+
+// UpgradeWebsocket works like websocket.Upgrade, the connection is
+// tracked until the handler returns, request id is returned in upgrade
+// response since the connection is hijacked
+func UpgradeWebsocket(w http.ResponseWriter, r *http.Request, responseHeader http.Header, readBufSize, writeBufSize int) (*websocket.Conn, error) {
+	if id := logging.RequestIDFromContext(r.Context()); id != "" {
+		if responseHeader == nil {
+			responseHeader = make(http.Header)
+		}
+		responseHeader.Set(logging.RequestIDHeader, id)
+	}
+	conn, err := websocket.Upgrade(w, r, responseHeader, readBufSize, writeBufSize)
+	if err != nil {
+		wsLogger.Warnf(r.Context(), "upgrade %s to websocket failed: %s", r.URL.Path, err.Error())
+		return nil, err
+	}
+	wsLogger.Infof(r.Context(), "websocket session %s from %s is opened", r.URL.Path, r.RemoteAddr)
+
+	if rc, ok := r.Context().Value(websocketKey{}).(*requestConns); ok {
+		if !websockets.add(conn) {
+			closeWebsocket(conn)
+			return nil, ErrShuttingDown
+		}
+		rc.conns = append(rc.conns, conn)
+	}
+	return conn, nil
+}
+
+func withRequestConns(r *http.Request) (*http.Request, *requestConns) {
+	rc := &requestConns{}
+	return r.WithContext(context.WithValue(r.Context(), websocketKey{}, rc)), rc
+}
+
+func (ws *websocketConns) add(conn *websocket.Conn) bool {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	if ws.closed {
+		return false
+	}
+	ws.conns[conn] = struct{}{}
+	return true
+}
+
+func (ws *websocketConns) remove(conns []*websocket.Conn) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	for _, conn := range conns {
+		delete(ws.conns, conn)
+	}
+}
+
+// closeAll notifies clients with going away close frame, then waits
+// for websocket handlers to return
+func (ws *websocketConns) closeAll(ctx context.Context) error {
+	ws.lock.Lock()
+	ws.closed = true
+	conns := make([]*websocket.Conn, 0, len(ws.conns))
+	for conn := range ws.conns {
+		conns = append(conns, conn)
+	}
+	ws.lock.Unlock()
+
+	for _, conn := range conns {
+		closeWebsocket(conn)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ws.requests.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func closeWebsocket(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeFrameTimeout))
+	conn.Close()
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestCloseWebsocketOnShutdown(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(trackWebsocket)
+	upgradeErrs := make(chan error, 1)
+	router.GET("/ws", func(c *gin.Context) {
+		conn, err := UpgradeWebsocket(c.Writer, c.Request, nil, 1024, 1024)
+		if err != nil {
+			upgradeErrs <- err
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	ut.Assert(t, err == nil, "dial websocket should succeed: %v", err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ut.Assert(t, websockets.closeAll(ctx) == nil, "close websockets should succeed")
+
+	_, _, err = conn.ReadMessage()
+	ut.Assert(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "client should get going away close frame: %v", err)
+
+	late, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	ut.Assert(t, err == nil, "dial websocket should succeed: %v", err)
+	defer late.Close()
+	ut.Equal(t, <-upgradeErrs, ErrShuttingDown)
+}